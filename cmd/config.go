@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	checkDNS  bool
+	configCmd = &cobra.Command{
+		Use:   "config",
+		Short: "Manages the configuration file",
+	}
+	configValidateCmd = &cobra.Command{
+		Use:   "validate",
+		Short: "Validates the configuration file",
+		Long:  "Validates the config.toml in the configuration directory without starting SignCTRL and lists all problems at once, each with the TOML key path of the offending setting. With --check-dns, the hostnames of the validators' addresses are resolved as well",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			cfg, warnings, err := config.LoadWithWarnings()
+			if err == nil && checkDNS {
+				err = cfg.CheckDNS(net.DefaultResolver.LookupHost)
+			}
+			if err != nil {
+				var validationErr *config.ValidationError
+				if errors.As(err, &validationErr) {
+					fmt.Printf("%v is invalid (%v problems):\n%v", config.File, len(validationErr.Problems), err)
+				} else {
+					fmt.Printf("couldn't load %v:\n%v\n", config.File, err)
+				}
+				os.Exit(1)
+			}
+
+			for _, warning := range warnings {
+				fmt.Printf("Warning: %v\n", warning)
+			}
+			fmt.Printf("%v is valid ✓\n", config.File)
+		},
+	}
+	configMigrateCmd = &cobra.Command{
+		Use:   "migrate",
+		Short: "Migrates the configuration file to the current version",
+		Long:  fmt.Sprintf("Migrates the configuration file in the configuration directory to version %v and rewrites it in place, in its format, after backing it up with the %v suffix. Comments are lost in the rewritten file, but kept in the backup. Files of the current version are left untouched", config.CurrentVersion, config.BackupSuffix),
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := viper.ReadInConfig(); err != nil {
+				fmt.Printf("couldn't find %v:\n%v\n", config.File, err)
+				os.Exit(1)
+			}
+			path := viper.ConfigFileUsed()
+			from, warnings, err := config.MigrateFile(path)
+			if err != nil {
+				fmt.Printf("couldn't migrate %v:\n%v\n", path, err)
+				os.Exit(1)
+			}
+			for _, warning := range warnings {
+				fmt.Printf("Warning: %v\n", warning)
+			}
+			if from == config.CurrentVersion {
+				fmt.Printf("%v is already version %v ✓\n", path, from)
+				return
+			}
+
+			fmt.Printf("Migrated %v from version %v to version %v, backed up to %v ✓\n", path, from, config.CurrentVersion, path+config.BackupSuffix)
+		},
+	}
+)
+
+func init() {
+	configValidateCmd.Flags().BoolVar(&checkDNS, "check-dns", false, "Resolves the hostnames in validator_laddr and validator_laddrs to catch typos in them")
+	configCmd.AddCommand(configValidateCmd)
+	configCmd.AddCommand(configMigrateCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -13,13 +13,19 @@ import (
 
 var (
 	newPrivval bool
+	keyFile    string
+	initForce  bool
+	cfgFormat  string
 	initCmd    = &cobra.Command{
 		Use:   "init",
 		Short: "Initializes the SignCTRL node",
-		Long:  "Creates the .signctrl/ directory, including a config.toml and a conn.key file",
+		Long:  "Scaffolds the .signctrl/ directory with a commented config.toml (or a config file in the format given by --format), a conn.key and an empty priv_validator_state.json. The validator's priv_validator_key.json is either generated with --new-pv or imported with --key-file. Existing files are only overwritten with --force, except for the priv_validator_state.json, which is always kept",
+		Args:  cobra.NoArgs,
 		Run: func(cmd *cobra.Command, args []string) {
-			// Get the config directory.
-			cfgDir := config.Dir()
+			if newPrivval && keyFile != "" {
+				fmt.Println("--new-pv and --key-file can't be used together")
+				os.Exit(1)
+			}
 
 			// Create the config directory if it doesn't already exist.
 			if _, err := os.Stat(cfgDir); os.IsNotExist(err) {
@@ -33,33 +39,48 @@ var (
 			}
 
 			// Create the config file.
-			if err := init_util.CreateConfigFile(cfgDir); err != nil {
+			if err := init_util.CreateConfigFile(cfgDir, cfgFormat, initForce); err != nil {
 				fmt.Println(err)
 				os.Exit(1)
 			}
 
 			// Create the connection key.
-			if err := init_util.CreateConnKeyFile(cfgDir); err != nil {
+			if err := init_util.CreateConnKeyFile(cfgDir, initForce); err != nil {
 				fmt.Println(err)
 				os.Exit(1)
 			}
 
-			// Create new priv_validator_key.json and priv_validator_state.json files if --new-pv flag is set.
+			// Create a new priv_validator_key.json if --new-pv is set, or import an
+			// existing one if --key-file is set.
 			if newPrivval {
-				if err := init_util.CreateKeyAndStateFiles(cfgDir); err != nil {
+				if err := init_util.CreateKeyFile(cfgDir, initForce); err != nil {
+					fmt.Println(err)
+					os.Exit(1)
+				}
+			} else if keyFile != "" {
+				if err := init_util.ImportKeyFile(cfgDir, keyFile, initForce); err != nil {
 					fmt.Println(err)
 					os.Exit(1)
 				}
 			}
+
+			// Create an empty priv_validator_state.json.
+			if err := init_util.CreateStateFile(cfgDir); err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
 		},
 	}
 )
 
 func init() {
 	rootCmd.AddCommand(initCmd)
-	initCmd.Flags().BoolVar(&newPrivval, "new-pv", false, "Creates a new priv_validator_key.json and a priv_validator_state.json file in the configuration directory")
+	initCmd.Flags().BoolVar(&newPrivval, "new-pv", false, "Creates a new priv_validator_key.json in the configuration directory")
 	if err := viper.BindPFlag("new-pv", initCmd.Flags().Lookup("new-pv")); err != nil {
 		fmt.Println(err)
 		os.Exit(1)
 	}
+	initCmd.Flags().StringVar(&keyFile, "key-file", "", "Imports the validator's existing priv_validator_key.json from the given path into the configuration directory")
+	initCmd.Flags().BoolVar(&initForce, "force", false, "Overwrites existing files in the configuration directory, except for the priv_validator_state.json")
+	initCmd.Flags().StringVar(&cfgFormat, "format", config.FormatTOML, fmt.Sprintf("Format of the configuration file, one of %v. Only the TOML file contains comments describing the settings", config.Formats))
 }
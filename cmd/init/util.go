@@ -1,102 +1,139 @@
 package init
 
 import (
-	"bufio"
+	"bytes"
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"strings"
 
 	"github.com/BlockscapeNetwork/signctrl/config"
 	"github.com/BlockscapeNetwork/signctrl/connection"
 	"github.com/BlockscapeNetwork/signctrl/privval"
+	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	tm_json "github.com/tendermint/tendermint/libs/json"
 	tm_privval "github.com/tendermint/tendermint/privval"
 )
 
-// confirm asks the user for confirmation on file creation, like when a file is about
-// to be overwritten. It handles "y" and "yes" for approval, and "", "n" and "no" for
-// denial.
-func confirm() bool {
-	for {
-		input, err := bufio.NewReader(os.Stdin).ReadString('\n')
-		if err != nil {
-			fmt.Printf("parsing error: %v", err)
-			continue
-		}
+// PermKeyFile determines the file permissions of the priv_validator_key.json and
+// priv_validator_state.json files, which are only readable by the owner.
+const PermKeyFile = os.FileMode(0600)
 
-		switch strings.TrimSuffix(strings.ToLower(input), "\n") {
-		case "y", "yes":
-			return true
-		case "", "n", "no":
-			return false
-		default:
-			fmt.Printf("Unknown answer. Please try again. [y(es)/N(o)]: ")
-			continue
+// CreateConfigFile creates the commented configuration file in the given format in the
+// specified configuration directory. Existing configuration files in any format are
+// only replaced if forced, as only one of them can be used.
+func CreateConfigFile(cfgDir, format string, force bool) error {
+	if existing := config.ExistingFiles(cfgDir); len(existing) > 0 {
+		if !force {
+			return fmt.Errorf("found existing %v (use --force to replace it)", strings.Join(existing, ", "))
 		}
-	}
-}
-
-// CreateConfigFile creates the configuration file in the specified configuration
-// directory. In case it already exists, the user is asked to decide whether it
-// should be overwritten or not.
-func CreateConfigFile(cfgDir string) error {
-	if _, err := os.Stat(config.FilePath(cfgDir)); !os.IsNotExist(err) {
-		fmt.Printf("Found existing %v at %v. Do you want to overwrite it? [y(es)/N(o)]: ", config.File, cfgDir)
-		if confirm() {
-			os.Remove(config.FilePath(cfgDir))
-			if err := config.Create(cfgDir); err != nil {
+		for _, file := range existing {
+			if err := os.Remove(file); err != nil {
 				return err
 			}
-			fmt.Printf("Created %v at %v ✓\n", config.File, cfgDir)
-		}
-	} else {
-		if err := config.Create(cfgDir); err != nil {
-			return err
 		}
-		fmt.Printf("Created %v at %v ✓\n", config.File, cfgDir)
 	}
+	if err := config.CreateTemplateFormat(cfgDir, format); err != nil {
+		return err
+	}
+	fmt.Printf("Created %v ✓\n", config.FormatFilePath(cfgDir, format))
 
 	return nil
 }
 
 // CreateConnKeyFile creates the connection key file in the specified configuration
-// directory. In case it already exists, the user is asked to decide whether it should
-// be overwritten or not.
-func CreateConnKeyFile(cfgDir string) error {
-	if _, err := os.Stat(connection.KeyFilePath(cfgDir)); !os.IsNotExist(err) {
-		fmt.Printf("Found existing %v at %v. Do you want to overwrite it? [y(es)/N(o)]: ", connection.KeyFile, cfgDir)
-		if confirm() {
-			os.Remove(connection.KeyFilePath(cfgDir))
-			if err := connection.CreateBase64ConnKey(cfgDir); err != nil {
-				return err
-			}
-			fmt.Printf("Created new %v at %v ✓\n", connection.KeyFile, cfgDir)
-		}
-	} else {
-		if err := connection.CreateBase64ConnKey(cfgDir); err != nil {
-			return err
-		}
-		fmt.Printf("Created %v at %v ✓\n", connection.KeyFile, cfgDir)
+// directory and prints its public key. An existing one is only overwritten if forced.
+func CreateConnKeyFile(cfgDir string, force bool) error {
+	connKey, err := connection.GenConnKey(cfgDir, force)
+	if errors.Is(err, connection.ErrConnKeyExists) {
+		return fmt.Errorf("%v (use --force to overwrite it)", err)
+	} else if err != nil {
+		return err
 	}
+	fmt.Printf("Created %v at %v ✓\n", connection.KeyFile, cfgDir)
+	fmt.Printf("Fingerprint: %v\n", connection.ConnKeyFingerprint(connKey))
+	fmt.Printf("Public key:  %v\n", connection.ConnPubKey(connKey))
 
 	return nil
 }
 
-// CreateKeyAndStateFiles creates the priv_validator_key.json and priv_validator_state.json
-// in the specified configuration directory. In case it already exists, the user is asked
-// to decide whether it should be overwritten or not.
-func CreateKeyAndStateFiles(cfgDir string) error {
-	if _, err := os.Stat(privval.KeyFilePath(cfgDir)); !os.IsNotExist(err) {
-		fmt.Printf("Found existing priv_validator_key.json at %v. Do you want to overwrite it? [y(es)/N(o)]: ", cfgDir)
-		if confirm() {
-			os.Remove(privval.KeyFilePath(cfgDir))
-			os.Remove(privval.StateFilePath(cfgDir))
-			tm_privval.LoadOrGenFilePV(privval.KeyFilePath(cfgDir), privval.StateFilePath(cfgDir))
-			fmt.Printf("Created new priv_validator_key.json and priv_validator_state.json at %v ✓\n", cfgDir)
-		}
-	} else {
-		tm_privval.LoadOrGenFilePV(privval.KeyFilePath(cfgDir), privval.StateFilePath(cfgDir))
-		fmt.Printf("Created priv_validator_key.json and priv_validator_state.json at %v ✓\n", cfgDir)
+// CreateKeyFile generates a new validator key and saves it to the
+// priv_validator_key.json in the specified configuration directory. An existing one
+// is only overwritten if forced.
+func CreateKeyFile(cfgDir string, force bool) error {
+	privKey := tm_ed25519.GenPrivKey()
+	key := tm_privval.FilePVKey{
+		Address: privKey.PubKey().Address(),
+		PubKey:  privKey.PubKey(),
+		PrivKey: privKey,
+	}
+	if err := writeKeyFile(cfgDir, key, force); err != nil {
+		return err
+	}
+	fmt.Printf("Created %v with validator address %v ✓\n", privval.KeyFilePath(cfgDir), key.Address)
+
+	return nil
+}
+
+// ImportKeyFile copies the validator key from the priv_validator_key.json at the given
+// path to the specified configuration directory, after checking that it is
+// consistent. An existing one is only overwritten if forced.
+func ImportKeyFile(cfgDir, path string, force bool) error {
+	bz, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var key tm_privval.FilePVKey
+	if err := tm_json.Unmarshal(bz, &key); err != nil {
+		return fmt.Errorf("couldn't parse %v: %v", path, err)
+	}
+	if key.PrivKey == nil {
+		return fmt.Errorf("%v doesn't contain a private key", path)
+	}
+	if !key.PubKey.Equals(key.PrivKey.PubKey()) || !bytes.Equal(key.Address, key.PrivKey.PubKey().Address()) {
+		return fmt.Errorf("%v is inconsistent, its public key or address doesn't match its private key", path)
+	}
+	if err := writeKeyFile(cfgDir, key, force); err != nil {
+		return err
+	}
+	fmt.Printf("Imported %v with validator address %v ✓\n", privval.KeyFilePath(cfgDir), key.Address)
+
+	return nil
+}
+
+// writeKeyFile saves the given validator key to the priv_validator_key.json in the
+// specified configuration directory. An existing one is only overwritten if forced.
+func writeKeyFile(cfgDir string, key tm_privval.FilePVKey, force bool) error {
+	path := privval.KeyFilePath(cfgDir)
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("%v already exists (use --force to overwrite it)", path)
+	}
+	bz, err := tm_json.MarshalIndent(key, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return config.WriteFileAtomic(path, bz, PermKeyFile)
+}
+
+// CreateStateFile creates an empty priv_validator_state.json in the specified
+// configuration directory, which lets the validator sign from the first height on. An
+// existing one is always kept, even if forced, as resetting it risks double-signing.
+func CreateStateFile(cfgDir string) error {
+	path := privval.StateFilePath(cfgDir)
+	if _, err := os.Stat(path); err == nil {
+		fmt.Printf("Kept existing %v, as resetting it risks double-signing (delete it yourself if you really need to)\n", path)
+		return nil
+	}
+	bz, err := tm_json.MarshalIndent(tm_privval.FilePVLastSignState{}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := config.WriteFileAtomic(path, bz, PermKeyFile); err != nil {
+		return err
 	}
+	fmt.Printf("Created %v ✓\n", path)
 
 	return nil
 }
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/spf13/cobra"
+)
+
+var (
+	forceConnKey  bool
+	keygenConnCmd = &cobra.Command{
+		Use:   "keygen-conn",
+		Short: "Creates a new conn.key",
+		Long:  "Creates a new conn.key in the configuration directory, which SignCTRL uses to establish secret connections to the validator, and prints its public key, so that it can be whitelisted on the validator",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			connKey, err := connection.GenConnKey(cfgDir, forceConnKey)
+			if errors.Is(err, connection.ErrConnKeyExists) {
+				fmt.Printf("couldn't create %v: %v (use --force to overwrite it)\n", connection.KeyFile, err)
+				os.Exit(1)
+			} else if err != nil {
+				fmt.Printf("couldn't create %v: %v\n", connection.KeyFile, err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("Created %v at %v ✓\n", connection.KeyFile, cfgDir)
+			fmt.Printf("Fingerprint: %v\n", connection.ConnKeyFingerprint(connKey))
+			fmt.Printf("Public key:  %v\n", connection.ConnPubKey(connKey))
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(keygenConnCmd)
+	keygenConnCmd.Flags().BoolVar(&forceConnKey, "force", false, "Overwrites an existing conn.key")
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/spf13/cobra"
+)
+
+var (
+	pauseCmd = &cobra.Command{
+		Use:   "pause <reason>",
+		Short: "Pauses the monitoring of missed blocks",
+		Long:  "Pauses the monitoring of missed blocks of the running node, e.g. during a coordinated chain upgrade, so that no rank updates are triggered until it is resumed. The pause is kept across restarts and must be made on every node in the set",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			if _, err := privval.Pause(args[0]); err != nil {
+				fmt.Printf("couldn't pause: %v\n", err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("Paused monitoring of missed blocks: %v\n", args[0])
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(pauseCmd)
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/spf13/cobra"
+)
+
+var (
+	promoteCmd = &cobra.Command{
+		Use:   "promote",
+		Short: "Promotes the node by one rank",
+		Long:  "Promotes the running node by one rank, even while automatic promotions are suppressed due to max_auto_promotions. Make sure the validator on the rank above has stopped signing first, as two validators on rank 1 double-sign",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			sr, err := privval.Promote()
+			if err != nil {
+				fmt.Printf("couldn't promote: %v\n", err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("Promoted node to rank %v/%v\n", sr.Rank, sr.SetSize)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(promoteCmd)
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reloadCmd = &cobra.Command{
+		Use:   "reload",
+		Short: "Reloads the configuration of the running node",
+		Long:  "Makes the running node read and validate its config.toml again, just like sending it SIGHUP, and prints what changed. The log level, the threshold, the retry policy and the hooks are applied right away, while other changes are ignored until restart. An invalid config.toml leaves the previous configuration in effect",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := privval.ReloadConfig()
+			if err != nil {
+				fmt.Printf("couldn't reload %v:\n%v\n", config.File, err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("Reloaded %v ✓\n", config.File)
+			for _, change := range resp.Applied {
+				fmt.Printf("Applied: %v\n", change)
+			}
+			for _, change := range resp.Ignored {
+				fmt.Printf("Ignored until restart: %v\n", change)
+			}
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(reloadCmd)
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/spf13/cobra"
+)
+
+var (
+	resumeCmd = &cobra.Command{
+		Use:   "resume",
+		Short: "Resumes the monitoring of missed blocks",
+		Long:  "Resumes the monitoring of missed blocks of the running node after it has been paused. Missed blocks are counted again once the validator's first commitsig has been seen",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			if _, err := privval.Resume(); err != nil {
+				fmt.Printf("couldn't resume: %v\n", err)
+				os.Exit(1)
+			}
+
+			fmt.Println("Resumed monitoring of missed blocks")
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(resumeCmd)
+}
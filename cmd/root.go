@@ -4,16 +4,28 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/BlockscapeNetwork/signctrl/config"
 	"github.com/spf13/cobra"
 )
 
 var (
+	// home is the configuration directory given with --home.
+	home string
+
+	// cfgDir is the configuration directory in use, resolved from --home,
+	// $SIGNCTRL_HOME and the default (see config.ResolveDir) once the flags are parsed.
+	cfgDir string
+
 	rootCmd = &cobra.Command{
 		Use:   "signctrl",
 		Short: "SignCTRL is a high availability solution for validators in Tendermint-based blockchain networks",
 	}
 )
 
+func init() {
+	rootCmd.PersistentFlags().StringVar(&home, "home", "", fmt.Sprintf("Configuration directory (overrides $%v, defaults to $HOME/.signctrl)", config.HomeEnv))
+}
+
 // Execute executes the root command.
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
@@ -0,0 +1,36 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rotateConnKeyCmd = &cobra.Command{
+		Use:   "rotate-conn-key",
+		Short: "Replaces the conn.key of the running node",
+		Long:  "Replaces the conn.key of the running node with a new one and prints its public key. The established connections to the validator keep using the old key, and the new one is used from the next (re)connect on, so whitelist the new public key on the validator before it drops the connection",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			resp, err := privval.RotateConnKey()
+			if err != nil {
+				fmt.Printf("couldn't rotate %v: %v\n", connection.KeyFile, err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("Rotated %v ✓\n", connection.KeyFile)
+			if resp.OldPubKey != "" {
+				fmt.Printf("Old public key: %v\n", resp.OldPubKey)
+			}
+			fmt.Printf("New public key: %v\n", resp.NewPubKey)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(rotateConnKeyCmd)
+}
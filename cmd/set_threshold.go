@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/BlockscapeNetwork/signctrl/privval"
+	"github.com/spf13/cobra"
+)
+
+var (
+	setThresholdCmd = &cobra.Command{
+		Use:   "set-threshold <threshold>",
+		Short: "Sets the node's threshold at runtime",
+		Long:  "Sets the threshold of blocks missed in a row of the running node without restarting it, e.g. to loosen it during planned maintenance. The change is lost on restart and must be made on every node in the set",
+		Args:  cobra.ExactArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			threshold, err := strconv.Atoi(args[0])
+			if err != nil {
+				fmt.Printf("couldn't parse threshold: %v\n", err)
+				os.Exit(1)
+			}
+
+			sr, err := privval.SetThreshold(threshold)
+			if err != nil {
+				fmt.Printf("couldn't set threshold: %v\n", err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("Set threshold to %v (effective on rank %v: %v)\n", threshold, sr.Rank, sr.Threshold)
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(setThresholdCmd)
+}
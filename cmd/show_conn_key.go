@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/spf13/cobra"
+)
+
+var (
+	showConnKeyCmd = &cobra.Command{
+		Use:   "show-conn-key",
+		Short: "Shows the public key of the conn.key",
+		Long:  "Prints the fingerprint and the public key of the conn.key in the configuration directory without starting SignCTRL, so that it can be whitelisted on the validator and checked against SignCTRL's logs",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			connKey, err := connection.LoadConnKey(cfgDir)
+			if err != nil {
+				fmt.Printf("couldn't load %v: %v\n", connection.KeyFile, err)
+				os.Exit(1)
+			}
+
+			fmt.Printf("Fingerprint: %v\n", connection.ConnKeyFingerprint(connKey))
+			fmt.Printf("Public key:  %v\n", connection.ConnPubKey(connKey))
+		},
+	}
+)
+
+func init() {
+	rootCmd.AddCommand(showConnKeyCmd)
+}
@@ -2,44 +2,41 @@ package cmd
 
 import (
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
 	"time"
 
 	"github.com/BlockscapeNetwork/signctrl/config"
 	"github.com/BlockscapeNetwork/signctrl/privval"
 	"github.com/BlockscapeNetwork/signctrl/types"
-	"github.com/hashicorp/logutils"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 	tm_privval "github.com/tendermint/tendermint/privval"
+	tm_types "github.com/tendermint/tendermint/types"
 )
 
 var (
+	force    bool
 	startCmd = &cobra.Command{
 		Use:   "start",
 		Short: "Starts the SignCTRL node",
 		Run: func(cmd *cobra.Command, args []string) {
 			// Load the config into memory.
-			cfg, err := config.Load()
+			cfg, warnings, err := config.LoadWithWarnings()
 			if err != nil {
 				fmt.Printf("couldn't load %v:\n%v", config.File, err)
 				os.Exit(1)
 			}
-			cfgDir := config.Dir()
-
-			// Set the logger and its mininum log level.
-			logger := types.NewSyncLogger(os.Stderr, "", 0)
-			filter := &logutils.LevelFilter{
-				Levels:   types.LogLevels,
-				MinLevel: logutils.LogLevel(cfg.Base.LogLevel),
-				Writer:   os.Stderr,
+			if checkDNS {
+				if err := cfg.CheckDNS(net.DefaultResolver.LookupHost); err != nil {
+					fmt.Printf("couldn't resolve the validators' addresses:\n%v", err)
+					os.Exit(1)
+				}
 			}
-			logger.SetOutput(filter)
 
 			// Load the state.
 			state, err := config.LoadOrGenState(cfgDir)
@@ -48,22 +45,65 @@ var (
 				os.Exit(1)
 			}
 
+			// Create the logger configured in the logging section.
+			logger, err := privval.NewLogger(cfg.Logging)
+			if err != nil {
+				fmt.Printf("couldn't initialize SignCTRL:\n%v\n", err)
+				os.Exit(1)
+			}
+
+			// Refuse to start with corrupted or tampered key and state files. They are
+			// checked before they are loaded, as Tendermint exits on a corrupted file
+			// and generates a missing key.
+			if err := privval.CheckFiles(logger, cfgDir, cfg.Privval, force); err != nil {
+				logger.Error(err.Error())
+				os.Exit(1)
+			}
+
+			// Load the signer. In watch-only mode, only the public key is loaded.
+			var tmpv tm_types.PrivValidator
+			if cfg.Privval.WatchOnly {
+				if tmpv, err = privval.LoadWatchOnlyPV(privval.PubKeyFilePath(cfgDir)); err != nil {
+					fmt.Printf("couldn't load %v:\n%v\n", privval.PubKeyFile, err)
+					os.Exit(1)
+				}
+			} else {
+				tmpv = tm_privval.LoadOrGenFilePV(
+					privval.ResolveKeyFilePath(cfgDir, cfg.Privval),
+					privval.ResolveStateFilePath(cfgDir, cfg.Privval),
+				)
+			}
+
 			// Initialize a new SCFilePV.
-			pv := privval.NewSCFilePV(
+			pv, err := privval.NewSCFilePV(
 				logger,
+				cfgDir,
 				cfg,
 				state,
-				tm_privval.LoadOrGenFilePV(
-					privval.KeyFilePath(cfgDir),
-					privval.StateFilePath(cfgDir),
-				),
+				tmpv,
 				&http.Server{Addr: fmt.Sprintf(":%v", privval.DefaultHTTPPort)},
 			)
+			if err != nil {
+				fmt.Printf("couldn't initialize SignCTRL:\n%v\n", err)
+				os.Exit(1)
+			}
+			for _, warning := range warnings {
+				pv.Logger.Warn("%v", warning)
+			}
 			pv.Gauges = types.RegisterGauges()
+			types.RegisterConnMetrics(pv.GetConnStats)
+			pv.Gauges.ThresholdGauge.Set(float64(pv.GetThreshold()))
+			pv.Version = SemVer
+
+			// Load the watermark protecting against double-signing.
+			if pv.Watermark, err = privval.LoadOrGenWatermark(cfgDir); err != nil {
+				fmt.Printf("couldn't load %v:\n%v\n", privval.WatermarkFile, err)
+				os.Exit(1)
+			}
 
 			// Start the SignCTRL service.
 			if err := pv.Start(); err != nil {
-				logger.Error(err.Error())
+				pv.Logger.Error(err.Error())
 				if err := pv.Stop(); err != nil {
 					fmt.Println(err)
 					os.Exit(1)
@@ -81,7 +121,7 @@ var (
 			case <-sigs: // The sigs channel is only used for OS interrupt signals
 				pv.Logger.Info("Shutting SignCTRL down... \u23FB (user/os interrupt)")
 				if err := pv.Stop(); err != nil {
-					logger.Error(err.Error())
+					pv.Logger.Error(err.Error())
 					os.Exit(1)
 				}
 			}
@@ -98,15 +138,26 @@ var (
 func init() {
 	cobra.OnInitialize(initConfig)
 	rootCmd.AddCommand(startCmd)
+	startCmd.Flags().BoolVar(&force, "force", false, "Starts even if the integrity check of the priv_validator_key.json and priv_validator_state.json files fails, e.g. after deliberately resetting the state")
+	startCmd.Flags().BoolVar(&checkDNS, "check-dns", false, "Resolves the hostnames in validator_laddr and validator_laddrs before starting and refuses to start if any of them can't be resolved")
+	startCmd.Flags().Bool("dry-run", false, "Handles all requests and keeps track of the rank without ever signing (overrides dry_run in the config.toml)")
+	if err := viper.BindPFlag("privval.dry_run", startCmd.Flags().Lookup("dry-run")); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	startCmd.Flags().Bool("rejoin", false, "Rejoins the set on the last rank instead of shutting down once the threshold is exceeded on rank 1 (overrides rejoin in the config.toml)")
+	if err := viper.BindPFlag("base.rejoin", startCmd.Flags().Lookup("rejoin")); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
 }
 
 func initConfig() {
-	cfgParts := strings.Split(config.File, ".")
-
-	viper.SetConfigName(cfgParts[0])
-	viper.SetConfigType(cfgParts[1])
+	cfgDir = config.ResolveDir(home)
 
-	viper.AddConfigPath("$SIGNCTRL_CONFIG_DIR")
-	viper.AddConfigPath("$HOME/.signctrl")
-	viper.AddConfigPath(".")
+	// The format of the configuration file is determined by its extension. It is only
+	// looked up in the configuration directory in use, so that the files of another
+	// instance on the same host are never picked up.
+	viper.SetConfigName(config.FileName)
+	viper.AddConfigPath(cfgDir)
 }
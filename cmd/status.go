@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/BlockscapeNetwork/signctrl/privval"
 	"github.com/spf13/cobra"
@@ -12,7 +13,7 @@ var (
 	statusCmd = &cobra.Command{
 		Use:   "status",
 		Short: "Shows the node's status",
-		Long:  "Prints out the current height, rank and missed block counter",
+		Long:  "Prints out the current height, rank, missed block counter, signing mode, downtime, connection stats since the start and the fingerprint of the conn.key",
 		Run: func(cmd *cobra.Command, args []string) {
 			sr, err := privval.GetStatus()
 			if err != nil {
@@ -20,11 +21,35 @@ var (
 				os.Exit(1)
 			}
 
+			mode := "signing"
+			if sr.DryRun {
+				mode = "dry run (never signs)"
+			}
+
 			fmt.Printf(`Status of SignCTRL validator:
   Height:  %v
   Rank:    %v/%v
   Counter: %v/%v
-`, sr.Height, sr.Rank, sr.SetSize, sr.Counter, sr.Threshold)
+  Mode:    %v
+`, sr.Height, sr.Rank, sr.SetSize, sr.Counter, sr.Threshold, mode)
+			if sr.Paused {
+				fmt.Printf("  Paused:  %v\n", sr.PauseReason)
+			}
+			if sr.PromoteReason != "" {
+				fmt.Printf("  Promoted: %v\n", sr.PromoteReason)
+			}
+			if sr.PromotionSuppressed {
+				fmt.Println("  Automatic promotions suppressed, check the set and run `signctrl promote` if needed")
+			}
+			if sr.TotalMissed > 0 {
+				fmt.Printf("  Downtime: ~%v (%v blocks missed, longest streak %v, avg block time %v)\n", sr.EstimatedDowntime.Round(time.Second), sr.TotalMissed, sr.LongestMissedStreak, sr.AvgBlockTime.Round(time.Millisecond))
+			}
+			if sr.Conn.Dials > 0 || sr.Conn.BytesRead > 0 {
+				fmt.Printf("  Connection: %v dials (%v failed), %v reconnects, last handshake %v, %v bytes read, %v bytes written\n", sr.Conn.Dials, sr.Conn.FailedDials, sr.Conn.Reconnects, sr.Conn.LastHandshake.Round(time.Millisecond), sr.Conn.BytesRead, sr.Conn.BytesWritten)
+			}
+			if sr.ConnKeyFingerprint != "" {
+				fmt.Printf("  Conn key: %v (public key %v)\n", sr.ConnKeyFingerprint, sr.ConnPubKey)
+			}
 		},
 	}
 )
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ProtocolTCP is the protocol of TCP socket addresses.
+	ProtocolTCP = "tcp"
+
+	// ProtocolUnix is the protocol of unix domain socket addresses.
+	ProtocolUnix = "unix"
+
+	// ProxySchemeSOCKS5 is the scheme of SOCKS5 proxy URLs.
+	ProxySchemeSOCKS5 = "socks5"
+
+	// ProxySchemeSOCKS5H is the scheme of SOCKS5 proxy URLs resolving hostnames on
+	// the proxy. SignCTRL always resolves them on the proxy, so it is equivalent to
+	// ProxySchemeSOCKS5.
+	ProxySchemeSOCKS5H = "socks5h"
+
+	// addressForms lists the supported forms of addresses for error messages.
+	addressForms = `"tcp://<ip>:<port>", "<ip>:<port>" or "unix:///path/to/file.sock"`
+)
+
+// ErrInvalidAddress is returned if an address can't be parsed.
+var ErrInvalidAddress = errors.New("invalid address")
+
+// Address is a parsed TCP or unix domain socket address.
+type Address struct {
+	// Protocol is either ProtocolTCP or ProtocolUnix.
+	Protocol string
+
+	// Addr is the host:port of TCP socket addresses and the path of unix domain
+	// socket addresses.
+	Addr string
+}
+
+// String returns the address including its protocol, e.g. tcp://127.0.0.1:3000.
+func (a Address) String() string {
+	return fmt.Sprintf("%v://%v", a.Protocol, a.Addr)
+}
+
+// ParseAddress parses the given TCP or unix domain socket address. TCP socket addresses
+// may be given with or without the tcp:// scheme, as in Tendermint's configuration.
+// Their host must be an IP address, with IPv6 addresses in brackets, e.g.
+// [::1]:3000 or [fe80::1%eth0]:3000 with a zone. Unix domain socket addresses need the
+// unix:// scheme and must end in .sock. An error wrapping ErrInvalidAddress is
+// returned otherwise.
+func ParseAddress(addr string) (Address, error) {
+	return parseAddress(addr, false)
+}
+
+// ParseHostAddress parses the given address like ParseAddress, but the host of TCP
+// socket addresses may also be a hostname, which is resolved again on every dial.
+func ParseHostAddress(addr string) (Address, error) {
+	return parseAddress(addr, true)
+}
+
+// parseAddress parses the given TCP or unix domain socket address. If allowHostnames
+// is set, the host of TCP socket addresses may be a hostname instead of an IP address.
+func parseAddress(addr string, allowHostnames bool) (Address, error) {
+	protocol, rest := ProtocolTCP, addr
+	if i := strings.Index(addr, "://"); i >= 0 {
+		protocol, rest = addr[:i], addr[i+len("://"):]
+	}
+
+	switch protocol {
+	case ProtocolTCP:
+		host, port, err := net.SplitHostPort(rest)
+		var addrErr *net.AddrError
+		if errors.As(err, &addrErr) && addrErr.Err == "missing port in address" || err == nil && port == "" {
+			return Address{}, fmt.Errorf("%w %q: missing port, must be %v", ErrInvalidAddress, addr, addressForms)
+		} else if err != nil {
+			return Address{}, fmt.Errorf("%w %q: not in the host:port format, must be %v", ErrInvalidAddress, addr, addressForms)
+		}
+		if host == "" {
+			return Address{}, fmt.Errorf("%w %q: missing host, must be %v", ErrInvalidAddress, addr, addressForms)
+		}
+		if !isIPLiteral(host) {
+			if looksLikeIPv4(host) {
+				return Address{}, fmt.Errorf("%w %q: host %q is not a valid IPv4 address", ErrInvalidAddress, addr, host)
+			}
+			if !allowHostnames {
+				return Address{}, fmt.Errorf("%w %q: host %q is not an IP address", ErrInvalidAddress, addr, host)
+			}
+			if !isHostname(host) {
+				return Address{}, fmt.Errorf("%w %q: host %q is neither an IP address nor a hostname", ErrInvalidAddress, addr, host)
+			}
+		}
+		if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+			return Address{}, fmt.Errorf("%w %q: port %q must be a number between 0 and 65535", ErrInvalidAddress, addr, port)
+		}
+
+	case ProtocolUnix:
+		if !strings.HasSuffix(rest, ".sock") || rest == ".sock" {
+			return Address{}, fmt.Errorf("%w %q: unix domain socket paths must end in .sock", ErrInvalidAddress, addr)
+		}
+
+	default:
+		return Address{}, fmt.Errorf("%w %q: unsupported scheme %q, must be %v", ErrInvalidAddress, addr, protocol+"://", addressForms)
+	}
+
+	return Address{Protocol: protocol, Addr: rest}, nil
+}
+
+// isIPLiteral checks whether the given host is an IP address. IPv6 addresses may have
+// a zone, e.g. fe80::1%eth0, which is needed for link-local addresses.
+func isIPLiteral(host string) bool {
+	if i := strings.LastIndex(host, "%"); i >= 0 {
+		ip := net.ParseIP(host[:i])
+		return ip != nil && ip.To4() == nil && i < len(host)-1
+	}
+
+	return net.ParseIP(host) != nil
+}
+
+// looksLikeIPv4 checks whether the given host only consists of digits and dots, i.e.
+// whether it is meant to be an IPv4 address, even if it is a malformed one like
+// 10.0.05.
+func looksLikeIPv4(host string) bool {
+	if !strings.Contains(host, ".") {
+		return false
+	}
+	for _, c := range host {
+		if !(c >= '0' && c <= '9' || c == '.') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isHostname checks whether the given host is a valid hostname. Hosts that look like
+// IPv4 addresses, i.e. whose last label is numeric, aren't hostnames.
+func isHostname(host string) bool {
+	host = strings.TrimSuffix(host, ".")
+	if host == "" || len(host) > 253 {
+		return false
+	}
+	labels := strings.Split(host, ".")
+	for _, label := range labels {
+		if label == "" || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	_, err := strconv.Atoi(labels[len(labels)-1])
+
+	return err != nil
+}
+
+// isTCPAddress checks whether the given address is a valid TCP socket address.
+func isTCPAddress(addr string) bool {
+	a, err := ParseAddress(addr)
+	return err == nil && a.Protocol == ProtocolTCP
+}
+
+// isUnixAddress checks whether the given address is a unix domain socket address,
+// regardless of whether it is valid.
+func isUnixAddress(addr string) bool {
+	return strings.HasPrefix(addr, ProtocolUnix+"://")
+}
+
+// ParseProxyURL parses the given SOCKS5 proxy URL in the
+// socks5://[user:password@]host:port format. The socks5h:// scheme is accepted as well.
+func ParseProxyURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != ProxySchemeSOCKS5 && u.Scheme != ProxySchemeSOCKS5H {
+		return nil, fmt.Errorf("unsupported scheme %q, must be %v:// or %v://", u.Scheme, ProxySchemeSOCKS5, ProxySchemeSOCKS5H)
+	}
+	if _, port, err := net.SplitHostPort(u.Host); err != nil || port == "" {
+		return nil, errors.New("not in the host:port format")
+	}
+	if u.Path != "" && u.Path != "/" || u.RawQuery != "" {
+		return nil, errors.New("must not contain a path or query")
+	}
+
+	return u, nil
+}
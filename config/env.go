@@ -0,0 +1,241 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix is the prefix of the environment variables overriding settings of the
+// configuration file.
+const EnvPrefix = "SIGNCTRL"
+
+// EnvVar returns the name of the environment variable overriding the setting with the
+// given TOML key path, e.g. SIGNCTRL_BASE_THRESHOLD for base.threshold.
+func EnvVar(key string) string {
+	return EnvPrefix + "_" + strings.ToUpper(strings.ReplaceAll(key, ".", "_"))
+}
+
+// eachSetting calls fn for every setting of the configuration with its TOML key path
+// and its settable value.
+func (c *Config) eachSetting(fn func(key string, value reflect.Value)) {
+	sections := reflect.ValueOf(c).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		eachField(sections.Type().Field(i).Tag.Get("mapstructure"), sections.Field(i), fn)
+	}
+}
+
+// eachField calls fn for every field of the given section with its TOML key path.
+// Nested structs, like the channels of the alerts section, are tables of their own,
+// while the fields of embedded structs belong to the section itself.
+func eachField(section string, settings reflect.Value, fn func(key string, value reflect.Value)) {
+	for i := 0; i < settings.NumField(); i++ {
+		field := settings.Type().Field(i)
+		switch {
+		case field.Anonymous:
+			eachField(section, settings.Field(i), fn)
+		case field.Type.Kind() == reflect.Struct:
+			eachField(section+"."+field.Tag.Get("mapstructure"), settings.Field(i), fn)
+		default:
+			fn(section+"."+field.Tag.Get("mapstructure"), settings.Field(i))
+		}
+	}
+}
+
+// applyEnv overrides the settings for which the given lookup, e.g. os.LookupEnv, finds
+// an environment variable. Values that can't be converted to the setting's type are
+// reported with the name of their variable, all at once.
+func (c *Config) applyEnv(lookup func(string) (string, bool)) error {
+	var errs string
+	c.eachSetting(func(key string, value reflect.Value) {
+		name := EnvVar(key)
+		env, ok := lookup(name)
+		if !ok {
+			return
+		}
+		if err := setValue(value, env); err != nil {
+			errs += fmt.Sprintf("\t%v %v, got %q\n", name, err, env)
+		}
+	})
+	if errs != "" {
+		return errors.New(errs)
+	}
+
+	return nil
+}
+
+// setValue converts the given environment variable to the type of the given value and
+// sets it. Lists are comma-separated, and maps are comma-separated key=value pairs.
+func setValue(value reflect.Value, env string) error {
+	switch value.Kind() {
+	case reflect.String:
+		value.SetString(env)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(env)
+		if err != nil {
+			return errors.New("must be true or false")
+		}
+		value.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		i, err := strconv.ParseInt(env, 10, value.Type().Bits())
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		value.SetInt(i)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(env, 64)
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		value.SetFloat(f)
+	case reflect.Slice:
+		var list []string
+		for _, elem := range strings.Split(env, ",") {
+			if elem = strings.TrimSpace(elem); elem != "" {
+				list = append(list, elem)
+			}
+		}
+		value.Set(reflect.ValueOf(list))
+	case reflect.Map:
+		m := make(map[int]int)
+		for _, pair := range strings.Split(env, ",") {
+			if pair = strings.TrimSpace(pair); pair == "" {
+				continue
+			}
+			parts := strings.SplitN(pair, "=", 2)
+			if len(parts) != 2 {
+				return errors.New("must be a comma-separated list of integer=integer pairs")
+			}
+			k, errK := strconv.Atoi(strings.TrimSpace(parts[0]))
+			v, errV := strconv.Atoi(strings.TrimSpace(parts[1]))
+			if errK != nil || errV != nil {
+				return errors.New("must be a comma-separated list of integer=integer pairs")
+			}
+			m[k] = v
+		}
+		value.Set(reflect.ValueOf(m))
+	default:
+		return fmt.Errorf("can't be set for settings of type %v", value.Type())
+	}
+
+	return nil
+}
+
+// RedactedValue replaces secrets in the configuration returned by Redacted.
+const RedactedValue = "****"
+
+// Redacted returns a copy of the configuration that is safe to log or dump, in which
+// the tokens of the alert channels are replaced by RedactedValue and the passwords in
+// URLs, e.g. in proxy_url, by xxxxx. Empty tokens are kept empty, so that missing ones
+// still show up.
+func (c Config) Redacted() Config {
+	redacted := c
+	redacted.Privval.ProxyURL = redactURL(c.Privval.ProxyURL)
+	redacted.RPC.Address = redactURL(c.RPC.Address)
+	for _, channel := range []*AlertChannel{&redacted.Alerts.Webhook, &redacted.Alerts.Slack.AlertChannel, &redacted.Alerts.PagerDuty} {
+		channel.URL = redactURL(channel.URL)
+		if channel.Token != "" {
+			channel.Token = RedactedValue
+		}
+	}
+
+	return redacted
+}
+
+// redactURL replaces the password in the given URL, if it has any.
+func redactURL(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		return u.Redacted()
+	}
+
+	return rawURL
+}
+
+// Settings returns every setting of the configuration as a "key = value" line, sorted
+// by their TOML key paths, e.g. to log the effective configuration. Secrets are
+// redacted (see Redacted).
+func (c Config) Settings() []string {
+	values := c.Redacted().values()
+	keys := sortedKeys(values)
+	settings := make([]string, 0, len(keys))
+	for _, key := range keys {
+		settings = append(settings, fmt.Sprintf("%v = %v", key, values[key]))
+	}
+
+	return settings
+}
+
+// Change is a setting that differs between two configurations. The values are
+// formatted like in Settings.
+type Change struct {
+	Key string
+	Old string
+	New string
+}
+
+// Diff returns the settings that differ between the configurations from and to,
+// sorted by their TOML key paths. Changed secrets are reported as well, but redacted
+// (see Redacted).
+func Diff(from, to Config) []Change {
+	oldValues, newValues := from.values(), to.values()
+	oldRedacted, newRedacted := from.Redacted().values(), to.Redacted().values()
+	var changes []Change
+	for _, key := range sortedKeys(newValues) {
+		if oldValues[key] != newValues[key] {
+			changes = append(changes, Change{Key: key, Old: oldRedacted[key], New: newRedacted[key]})
+		}
+	}
+
+	return changes
+}
+
+// values returns the formatted values of all settings by their TOML key paths.
+// Strings are quoted. Secrets aren't redacted, see Redacted.
+func (c Config) values() map[string]string {
+	values := make(map[string]string)
+	c.eachSetting(func(key string, value reflect.Value) {
+		v := value.Interface()
+		if value.Kind() == reflect.Map {
+			v = formatMap(value)
+		}
+		if value.Kind() == reflect.String {
+			values[key] = fmt.Sprintf("%q", v)
+			return
+		}
+		values[key] = fmt.Sprintf("%v", v)
+	})
+
+	return values
+}
+
+// sortedKeys returns the keys of the given map in sorted order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// formatMap formats the given map[int]int as sorted, comma-separated key=value pairs,
+// the same way it is set via an environment variable.
+func formatMap(value reflect.Value) string {
+	m := value.Interface().(map[int]int)
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, fmt.Sprintf("%v=%v", k, m[k]))
+	}
+
+	return strings.Join(pairs, ",")
+}
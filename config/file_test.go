@@ -0,0 +1,47 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.json")
+
+	// Create.
+	err := WriteFileAtomic(path, []byte("first"), 0600)
+	assert.NoError(t, err)
+
+	// Replace.
+	err = WriteFileAtomic(path, []byte("second"), 0600)
+	assert.NoError(t, err)
+
+	bz, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("second"), bz)
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// No temporary files are left behind.
+	files, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	// Writing fails if the directory doesn't exist.
+	err = WriteFileAtomic(filepath.Join(dir, "nonexistent", "file.json"), []byte("third"), 0600)
+	assert.Error(t, err)
+}
+
+func TestResolvePath(t *testing.T) {
+	assert.Equal(t, "", ResolvePath("/tmp", ""))
+	assert.Equal(t, "/tmp/key.json", ResolvePath("/tmp", "key.json"))
+	assert.Equal(t, "/tmp/keys/key.json", ResolvePath("/tmp", "./keys/key.json"))
+	assert.Equal(t, "/mnt/key.json", ResolvePath("/tmp", "/mnt/key.json"))
+}
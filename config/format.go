@@ -0,0 +1,95 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// FileName is the name of the configuration file without its extension, which
+	// determines its format.
+	FileName = "config"
+
+	// FormatTOML is the default format of the configuration file.
+	FormatTOML = "toml"
+)
+
+// Formats are the formats the configuration file can be written in, by the extension
+// of the file. All of them are decoded into the same Config with the same defaults.
+var Formats = []string{FormatTOML, "yaml", "yml", "json"}
+
+// isFormat checks whether the given file extension is one of the supported formats.
+func isFormat(format string) bool {
+	for _, f := range Formats {
+		if f == format {
+			return true
+		}
+	}
+
+	return false
+}
+
+// FormatFilePath returns the absolute path to the configuration file in the given
+// format.
+func FormatFilePath(cfgDir, format string) string {
+	return filepath.Join(cfgDir, FileName+"."+format)
+}
+
+// ExistingFiles returns the paths of the configuration files in any of the supported
+// formats that exist in the given configuration directory.
+func ExistingFiles(cfgDir string) []string {
+	var files []string
+	for _, format := range Formats {
+		path := FormatFilePath(cfgDir, format)
+		if _, err := os.Stat(path); err == nil {
+			files = append(files, path)
+		}
+	}
+
+	return files
+}
+
+// checkFormat checks that the configuration file found by viper is in one of the
+// supported formats and that it is the only configuration file in its directory, as
+// it would be ambiguous which one is used otherwise.
+func checkFormat(path string) error {
+	format := strings.TrimPrefix(filepath.Ext(path), ".")
+	if !isFormat(format) {
+		return fmt.Errorf("%v has an unsupported format, it must be one of the following: %v", path, Formats)
+	}
+	if files := ExistingFiles(filepath.Dir(path)); len(files) > 1 {
+		return fmt.Errorf("found several configuration files (%v), remove all but one of them", strings.Join(files, ", "))
+	}
+
+	return nil
+}
+
+// CreateFormat writes the sample configuration to the configuration file in the given
+// format at the specified configuration directory. The YAML and JSON files are
+// converted from the TOML sample (see WriteSample), so they contain the same settings,
+// but none of the comments describing them.
+func CreateFormat(cfgDir, format string) error {
+	if format == FormatTOML {
+		return Create(cfgDir)
+	}
+	if !isFormat(format) {
+		return fmt.Errorf("unsupported format %v, it must be one of the following: %v", format, Formats)
+	}
+	tmpl, err := template()
+	if err != nil {
+		return err
+	}
+	v := viper.New()
+	v.SetConfigType(FormatTOML)
+	if err := v.ReadConfig(bytes.NewReader(tmpl)); err != nil {
+		return err
+	}
+	v.SetConfigPermissions(PermConfigToml)
+
+	return v.WriteConfigAs(FormatFilePath(cfgDir, format))
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// loadFile loads the configuration file at the given path.
+func loadFile(t *testing.T, path string) (Config, error) {
+	t.Helper()
+	viper.SetConfigFile(path)
+	defer viper.Reset()
+
+	return Load()
+}
+
+func TestLoad_Formats(t *testing.T) {
+	// Equivalent content in all formats is loaded into the same Config, including the
+	// defaults for the settings that aren't set.
+	want, err := loadFile(t, "testdata/formats.toml")
+	assert.NoError(t, err)
+	assert.Equal(t, map[int]int{2: 12, 3: 15}, want.Base.Thresholds)
+	assert.Equal(t, DefaultDialTimeout, want.Base.DialTimeout)
+	for _, format := range []string{"yaml", "json"} {
+		cfg, err := loadFile(t, "testdata/formats."+format)
+		assert.NoError(t, err, format)
+		assert.Equal(t, want, cfg, format)
+		assert.Equal(t, want.Settings(), cfg.Settings(), format)
+	}
+}
+
+func TestLoad_UnsupportedFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.ini")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("[base]\nthreshold = 10\n"), 0600))
+	_, err := loadFile(t, path)
+	assert.EqualError(t, err, fmt.Sprintf("%v has an unsupported format, it must be one of the following: [toml yaml yml json]", path))
+}
+
+func TestLoad_SeveralFormats(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, Create(dir))
+	assert.NoError(t, CreateFormat(dir, "json"))
+	_, err := loadFile(t, FormatFilePath(dir, "json"))
+	assert.EqualError(t, err, fmt.Sprintf("found several configuration files (%v, %v), remove all but one of them", FilePath(dir), FormatFilePath(dir, "json")))
+}
+
+func TestCreateFormat(t *testing.T) {
+	// The generated files contain the same settings in every format.
+	var settings [][]string
+	for _, format := range Formats {
+		dir := t.TempDir()
+		assert.NoError(t, CreateFormat(dir, format))
+		info, err := os.Stat(FormatFilePath(dir, format))
+		assert.NoError(t, err)
+		assert.Equal(t, PermConfigToml, info.Mode().Perm())
+
+		v := viper.New()
+		v.SetConfigFile(FormatFilePath(dir, format))
+		assert.NoError(t, v.ReadInConfig())
+		var cfg Config
+		assert.NoError(t, v.Unmarshal(&cfg))
+		settings = append(settings, cfg.Settings())
+	}
+	for _, s := range settings[1:] {
+		assert.Equal(t, settings[0], s)
+	}
+
+	err := CreateFormat(t.TempDir(), "ini")
+	assert.EqualError(t, err, "unsupported format ini, it must be one of the following: [toml yaml yml json]")
+}
@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// knownKeys returns the TOML key paths of all settings of the Config, along with the
+// VersionKey.
+func knownKeys() map[string]bool {
+	known := map[string]bool{VersionKey: true}
+	(&Config{}).eachSetting(func(key string, _ reflect.Value) {
+		known[key] = true
+	})
+
+	return known
+}
+
+// unknownKeys returns a warning for every key of the given settings of a configuration
+// file that is neither a setting of the Config, nor its version, nor a deprecated
+// setting. These keys are ignored, so each warning suggests the setting with the
+// nearest key, as they are most likely misspelled.
+func unknownKeys(settings map[string]interface{}) []string {
+	known := knownKeys()
+	var warnings []string
+	for _, key := range flattenKeys("", settings, known) {
+		if known[key] || deprecatedKeys[key] != "" {
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("config key %v is unknown and ignored, did you mean %v?", key, nearestKey(key, known)))
+	}
+
+	return warnings
+}
+
+// flattenKeys returns the sorted TOML key paths of the given settings below the given
+// prefix. Tables are descended into, unless they are known settings themselves, like
+// base.thresholds.
+func flattenKeys(prefix string, settings map[string]interface{}, known map[string]bool) []string {
+	var keys []string
+	for name, value := range settings {
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		if table, ok := value.(map[string]interface{}); ok && !known[key] {
+			keys = append(keys, flattenKeys(key, table, known)...)
+		} else {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// nearestKey returns the known key with the smallest edit distance to the given key.
+// Ties are broken alphabetically.
+func nearestKey(key string, known map[string]bool) string {
+	candidates := make([]string, 0, len(known))
+	for candidate := range known {
+		candidates = append(candidates, candidate)
+	}
+	sort.Strings(candidates)
+
+	var nearest string
+	min := -1
+	for _, candidate := range candidates {
+		if d := editDistance(key, candidate); min < 0 || d < min {
+			nearest, min = candidate, d
+		}
+	}
+
+	return nearest
+}
+
+// editDistance returns the Levenshtein distance between a and b, i.e. the number of
+// single-byte insertions, deletions and substitutions turning a into b.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+
+	return prev[len(b)]
+}
+
+// minInt returns the smallest of the given integers.
+func minInt(first int, rest ...int) int {
+	min := first
+	for _, v := range rest {
+		if v < min {
+			min = v
+		}
+	}
+
+	return min
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnknownKeys(t *testing.T) {
+	// Settings given as tables, like base.thresholds, aren't descended into.
+	assert.Equal(t, []string{
+		"config key alerts.slak.channel is unknown and ignored, did you mean alerts.slack.channel?",
+		"config key base.treshold is unknown and ignored, did you mean base.threshold?",
+	}, unknownKeys(readSettings(t, "testdata/unknown.toml")))
+
+	// Deprecated keys are known, even if they are no longer settings.
+	assert.Empty(t, unknownKeys(readSettings(t, "testdata/v2.toml")))
+	assert.Empty(t, unknownKeys(readSettings(t, "testdata/deprecated.toml")))
+
+	assert.Equal(t, []string{
+		"config key verison is unknown and ignored, did you mean version?",
+	}, unknownKeys(map[string]interface{}{"verison": 3}))
+}
+
+func TestEditDistance(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"", "abc", 3},
+		{"threshold", "threshold", 0},
+		{"treshold", "threshold", 1},
+		{"thresold", "threshold", 1},
+		{"thershold", "threshold", 2},
+		{"kitten", "sitting", 3},
+	} {
+		assert.Equal(t, tc.want, editDistance(tc.a, tc.b), "%v -> %v", tc.a, tc.b)
+		assert.Equal(t, tc.want, editDistance(tc.b, tc.a), "%v -> %v", tc.b, tc.a)
+	}
+}
+
+func TestLoad_UnknownKeys(t *testing.T) {
+	cfg, warnings, err := loadFileWithWarnings(t, "testdata/unknown.toml")
+	assert.NoError(t, err)
+	assert.Equal(t, 10, cfg.Base.Threshold)
+	assert.Equal(t, map[int]int{2: 10}, cfg.Base.Thresholds)
+	assert.Len(t, warnings, 2)
+	assert.Contains(t, warnings[1], "base.treshold is unknown")
+}
@@ -0,0 +1,314 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// VersionKey is the top-level key of the configuration file that holds the version
+	// of its schema.
+	VersionKey = "version"
+
+	// CurrentVersion is the version of the configuration file's schema that the
+	// Config is decoded from. Files of older versions are migrated to it.
+	CurrentVersion = 4
+
+	// BackupSuffix is appended to the path of the configuration file to get the path
+	// its previous content is backed up to before it is migrated.
+	BackupSuffix = ".bak"
+)
+
+// ErrNewerVersion is returned if the configuration file's version is newer than the
+// versions this build of SignCTRL knows about.
+var ErrNewerVersion = errors.New("configuration file version is newer than supported")
+
+// migration upgrades the settings of a configuration file from one version to the
+// next one in place and returns a warning for every deprecated key it replaced.
+type migration func(settings map[string]interface{}) []string
+
+// migrations are the migrations from each version to the next one, i.e.
+// migrations[0] upgrades version 1 to version 2. Each of them only needs to handle the
+// schema of the version it starts from, as they are always applied in order.
+var migrations = []migration{
+	migrateV1ToV2,
+	migrateV2ToV3,
+	migrateV3ToV4,
+}
+
+// deprecatedKeys maps the TOML key paths of deprecated settings to the settings
+// replacing them.
+var deprecatedKeys = map[string]string{
+	"base.validator_laddr": "base.validator_laddrs",
+	"base.log_level":       "logging.level",
+
+	"base.retry_dial_interval":     "connection.retry.initial_interval",
+	"base.retry_dial_max_interval": "connection.retry.max_interval",
+	"base.retry_dial_multiplier":   "connection.retry.multiplier",
+	"base.retry_dial_jitter":       "connection.retry.jitter",
+	"base.retry_dial_max_attempts": "connection.retry.max_attempts",
+	"base.retry_dial_max_elapsed":  "connection.retry.max_elapsed_time",
+}
+
+// deprecatedMigrations are the migrations moving the values of deprecated keys that
+// are no longer settings of the Config to their replacements. They are also applied to
+// files of the current version that still contain these keys, e.g. because they were
+// copied from an older file, so that their values aren't silently ignored.
+var deprecatedMigrations = map[string]migration{
+	"base.log_level": migrateV2ToV3,
+
+	"base.retry_dial_interval":     migrateV3ToV4,
+	"base.retry_dial_max_interval": migrateV3ToV4,
+	"base.retry_dial_multiplier":   migrateV3ToV4,
+	"base.retry_dial_jitter":       migrateV3ToV4,
+	"base.retry_dial_max_attempts": migrateV3ToV4,
+	"base.retry_dial_max_elapsed":  migrateV3ToV4,
+}
+
+// deprecated returns the warning for the given deprecated key, naming its replacement.
+func deprecated(key string) string {
+	return fmt.Sprintf("config key %v is deprecated, use %v instead", key, deprecatedKeys[key])
+}
+
+// versionOf returns the version of the given settings. Files without a version are
+// version 1, which is the schema all files had before versioning was introduced.
+func versionOf(settings map[string]interface{}) (int, error) {
+	raw, ok := settings[VersionKey]
+	if !ok {
+		return 1, nil
+	}
+
+	// TOML decodes integers to int64, YAML to int and JSON to float64.
+	var version int
+	switch v := raw.(type) {
+	case int:
+		version = v
+	case int64:
+		version = int(v)
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("%v must be an integer, got %v", VersionKey, v)
+		}
+		version = int(v)
+	default:
+		return 0, fmt.Errorf("%v must be an integer, got %v", VersionKey, v)
+	}
+	if version < 1 {
+		return 0, fmt.Errorf("%v must be 1 or higher, got %v", VersionKey, version)
+	}
+	if version > CurrentVersion {
+		return 0, fmt.Errorf("%w: %v is %v, but SignCTRL only supports up to %v, upgrade SignCTRL or migrate it back by hand", ErrNewerVersion, VersionKey, version, CurrentVersion)
+	}
+
+	return version, nil
+}
+
+// Migrate upgrades the given settings of a configuration file, e.g. as returned by
+// viper's AllSettings, step by step from their version to the CurrentVersion in place.
+// It returns the version they had before, along with warnings for deprecated keys,
+// each naming its replacement.
+func Migrate(settings map[string]interface{}) (from int, warnings []string, err error) {
+	from, err = versionOf(settings)
+	if err != nil {
+		return 0, nil, err
+	}
+	for version := from; version < CurrentVersion; version++ {
+		warnings = append(warnings, migrations[version-1](settings)...)
+	}
+	settings[VersionKey] = CurrentVersion
+
+	// Deprecated keys may also show up in files of the current version. Those that
+	// are no longer settings are migrated to their replacements, the others still
+	// work as they are.
+	for _, key := range sortedKeys(deprecatedKeys) {
+		path := strings.SplitN(key, ".", 2)
+		section, ok := settings[path[0]].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if _, ok := section[path[1]]; !ok {
+			continue
+		}
+		if migrate, ok := deprecatedMigrations[key]; ok {
+			warnings = append(warnings, migrate(settings)...)
+		} else {
+			warnings = append(warnings, deprecated(key))
+		}
+	}
+
+	return from, warnings, nil
+}
+
+// migrateV1ToV2 moves the single validator_laddr into the validator_laddrs list, in
+// front of the other addresses in it, as validator_laddr is deprecated in version 2.
+func migrateV1ToV2(settings map[string]interface{}) []string {
+	base, ok := settings["base"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	raw, ok := base["validator_laddr"]
+	if !ok {
+		return nil
+	}
+
+	var laddrs []interface{}
+	switch l := base["validator_laddrs"].(type) {
+	case nil:
+	case []interface{}:
+		laddrs = l
+	case []string:
+		for _, laddr := range l {
+			laddrs = append(laddrs, laddr)
+		}
+	default:
+		// Leave it to the validation to report the malformed validator_laddrs.
+		return nil
+	}
+	// validator_laddr was dialed first, so it stays in front even if it was also in
+	// validator_laddrs.
+	migrated := []interface{}{}
+	if laddr, ok := raw.(string); ok && laddr != "" {
+		migrated = append(migrated, laddr)
+	}
+	for _, laddr := range laddrs {
+		if len(migrated) == 0 || laddr != migrated[0] {
+			migrated = append(migrated, laddr)
+		}
+	}
+	base["validator_laddrs"] = migrated
+	delete(base, "validator_laddr")
+
+	return []string{deprecated("base.validator_laddr")}
+}
+
+// migrateV2ToV3 moves log_level from the base section to the level of the logging
+// section introduced in version 3, which takes the lowercase names of the levels.
+func migrateV2ToV3(settings map[string]interface{}) []string {
+	base, ok := settings["base"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	raw, ok := base["log_level"]
+	if !ok {
+		return nil
+	}
+
+	level, ok := raw.(string)
+	if !ok {
+		// Leave it to the validation to report the malformed level.
+		return nil
+	}
+	if level = strings.ToLower(level); level == "err" {
+		level = "error"
+	}
+	logging, ok := settings["logging"].(map[string]interface{})
+	if !ok {
+		logging = map[string]interface{}{}
+		settings["logging"] = logging
+	}
+	// Version 2 files have no logging section, so a level in it can only be the
+	// default, which the level set in the file takes precedence over. The same goes
+	// for files of the current version that still contain log_level, as it would be
+	// ignored otherwise.
+	logging["level"] = level
+	delete(base, "log_level")
+
+	return []string{deprecated("base.log_level")}
+}
+
+// retryKeys are the keys of the retry policy in the base section of version 3 files,
+// in the order they are migrated to the connection.retry section in version 4.
+var retryKeys = []string{
+	"base.retry_dial_interval",
+	"base.retry_dial_max_interval",
+	"base.retry_dial_multiplier",
+	"base.retry_dial_jitter",
+	"base.retry_dial_max_attempts",
+	"base.retry_dial_max_elapsed",
+}
+
+// migrateV3ToV4 moves the retry_dial_* settings of the retry policy from the base
+// section to the connection.retry section introduced in version 4.
+func migrateV3ToV4(settings map[string]interface{}) []string {
+	base, ok := settings["base"].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	var warnings []string
+	for _, key := range retryKeys {
+		name := strings.TrimPrefix(key, "base.")
+		value, ok := base[name]
+		if !ok {
+			continue
+		}
+		connection, ok := settings["connection"].(map[string]interface{})
+		if !ok {
+			connection = map[string]interface{}{}
+			settings["connection"] = connection
+		}
+		retry, ok := connection["retry"].(map[string]interface{})
+		if !ok {
+			retry = map[string]interface{}{}
+			connection["retry"] = retry
+		}
+		// Version 3 files have no connection section, so a setting in it can only be
+		// the default, which the one set in the file takes precedence over. The same
+		// goes for files of the current version that still contain the old key, as it
+		// would be ignored otherwise.
+		retry[strings.TrimPrefix(deprecatedKeys[key], "connection.retry.")] = value
+		delete(base, name)
+		warnings = append(warnings, deprecated(key))
+	}
+
+	return warnings
+}
+
+// MigrateFile migrates the configuration file at the given path to the CurrentVersion
+// and rewrites it in place in its format, after backing up its previous content to the
+// same path with the BackupSuffix. Files of the current version are left untouched.
+// Comments are lost in the rewritten file, but kept in the backup. It returns the
+// version the file had before, along with warnings for deprecated and unknown keys,
+// which are kept in the rewritten file.
+func MigrateFile(path string) (from int, warnings []string, err error) {
+	if err := checkFormat(path); err != nil {
+		return 0, nil, err
+	}
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return 0, nil, err
+	}
+	settings := v.AllSettings()
+	unknown := unknownKeys(settings)
+	if from, warnings, err = Migrate(settings); err != nil {
+		return 0, nil, err
+	}
+	warnings = append(warnings, unknown...)
+	if from == CurrentVersion {
+		return from, warnings, nil
+	}
+
+	bz, err := ioutil.ReadFile(path)
+	if err != nil {
+		return 0, nil, err
+	}
+	if err := WriteFileAtomic(path+BackupSuffix, bz, PermConfigToml); err != nil {
+		return 0, nil, fmt.Errorf("couldn't back up %v: %v", path, err)
+	}
+	w := viper.New()
+	if err := w.MergeConfigMap(settings); err != nil {
+		return 0, nil, err
+	}
+	w.SetConfigPermissions(PermConfigToml)
+	if err := w.WriteConfigAs(path); err != nil {
+		return 0, nil, fmt.Errorf("couldn't rewrite %v, its previous content is backed up to %v: %v", path, path+BackupSuffix, err)
+	}
+
+	return from, warnings, nil
+}
@@ -0,0 +1,247 @@
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// readSettings reads the settings of the configuration file at the given path without
+// any defaults.
+func readSettings(t *testing.T, path string) map[string]interface{} {
+	t.Helper()
+	v := viper.New()
+	v.SetConfigFile(path)
+	assert.NoError(t, v.ReadInConfig())
+
+	return v.AllSettings()
+}
+
+func TestMigrate_V1(t *testing.T) {
+	settings := readSettings(t, "testdata/v1.toml")
+	from, warnings, err := Migrate(settings)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, from)
+	assert.Equal(t, []string{
+		"config key base.validator_laddr is deprecated, use base.validator_laddrs instead",
+		"config key base.log_level is deprecated, use logging.level instead",
+	}, warnings)
+	want := readSettings(t, "testdata/v4.toml")
+	assert.Equal(t, want["base"], settings["base"])
+	assert.Equal(t, want["logging"], settings["logging"])
+	assert.Equal(t, CurrentVersion, settings[VersionKey])
+}
+
+func TestMigrate_V1Laddrs(t *testing.T) {
+	// validator_laddr is moved in front of validator_laddrs and only kept once.
+	settings := readSettings(t, "testdata/v1_laddrs.yaml")
+	from, warnings, err := Migrate(settings)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, from)
+	assert.Len(t, warnings, 2)
+	base := settings["base"].(map[string]interface{})
+	assert.NotContains(t, base, "validator_laddr")
+	assert.Equal(t, []interface{}{"tcp://127.0.0.1:3000", "tcp://10.0.0.2:3000"}, base["validator_laddrs"])
+}
+
+func TestMigrate_V2(t *testing.T) {
+	settings := readSettings(t, "testdata/v2.toml")
+	want := readSettings(t, "testdata/v4.toml")
+	want[VersionKey] = CurrentVersion
+	from, warnings, err := Migrate(settings)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, from)
+	assert.Equal(t, []string{"config key base.log_level is deprecated, use logging.level instead"}, warnings)
+	assert.Equal(t, want, settings)
+
+	// The level in the file takes precedence over the default one, and ERR is renamed
+	// to error.
+	settings = map[string]interface{}{
+		VersionKey: 2,
+		"base":     map[string]interface{}{"log_level": "ERR"},
+		"logging":  map[string]interface{}{"level": DefaultLogLevel},
+	}
+	_, _, err = Migrate(settings)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"level": "error"}, settings["logging"])
+	assert.Empty(t, settings["base"])
+}
+
+func TestMigrate_V3(t *testing.T) {
+	settings := readSettings(t, "testdata/v3.toml")
+	want := readSettings(t, "testdata/v4.toml")
+	want[VersionKey] = CurrentVersion
+	from, warnings, err := Migrate(settings)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, from)
+	assert.Empty(t, warnings)
+	assert.Equal(t, want, settings)
+
+	// The retry policy is moved from the base section to the connection.retry
+	// section, where the settings in the file take precedence over the default ones.
+	settings = map[string]interface{}{
+		VersionKey: 3,
+		"base": map[string]interface{}{
+			"retry_dial_after":        "15s",
+			"retry_dial_interval":     "2s",
+			"retry_dial_multiplier":   1.5,
+			"retry_dial_max_elapsed":  "10m",
+			"retry_dial_max_attempts": 5,
+		},
+		"connection": map[string]interface{}{
+			"retry": map[string]interface{}{"initial_interval": DefaultRetryInitialInterval},
+		},
+	}
+	_, warnings, err = Migrate(settings)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"config key base.retry_dial_interval is deprecated, use connection.retry.initial_interval instead",
+		"config key base.retry_dial_multiplier is deprecated, use connection.retry.multiplier instead",
+		"config key base.retry_dial_max_attempts is deprecated, use connection.retry.max_attempts instead",
+		"config key base.retry_dial_max_elapsed is deprecated, use connection.retry.max_elapsed_time instead",
+	}, warnings)
+	assert.Equal(t, map[string]interface{}{
+		"initial_interval": "2s",
+		"multiplier":       1.5,
+		"max_attempts":     5,
+		"max_elapsed_time": "10m",
+	}, settings["connection"].(map[string]interface{})["retry"])
+	assert.Equal(t, map[string]interface{}{"retry_dial_after": "15s"}, settings["base"])
+}
+
+func TestMigrate_V4(t *testing.T) {
+	settings := readSettings(t, "testdata/v4.toml")
+	want := readSettings(t, "testdata/v4.toml")
+	want[VersionKey] = CurrentVersion
+	from, warnings, err := Migrate(settings)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, from)
+	assert.Empty(t, warnings)
+	assert.Equal(t, want, settings)
+
+	// Deprecated keys are still reported in files of the current version.
+	settings["base"].(map[string]interface{})["validator_laddr"] = "tcp://127.0.0.1:3000"
+	_, warnings, err = Migrate(settings)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"config key base.validator_laddr is deprecated, use base.validator_laddrs instead"}, warnings)
+
+	// Deprecated keys that are no longer settings are migrated in files of the current
+	// version as well.
+	delete(settings["base"].(map[string]interface{}), "validator_laddr")
+	settings["base"].(map[string]interface{})["log_level"] = "WARN"
+	_, warnings, err = Migrate(settings)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"config key base.log_level is deprecated, use logging.level instead"}, warnings)
+	assert.Equal(t, map[string]interface{}{"level": "warn"}, settings["logging"])
+	assert.NotContains(t, settings["base"], "log_level")
+	settings["base"].(map[string]interface{})["retry_dial_jitter"] = 0.5
+	_, warnings, err = Migrate(settings)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"config key base.retry_dial_jitter is deprecated, use connection.retry.jitter instead"}, warnings)
+	assert.Equal(t, map[string]interface{}{"retry": map[string]interface{}{"jitter": 0.5}}, settings["connection"])
+	assert.NotContains(t, settings["base"], "retry_dial_jitter")
+}
+
+func TestMigrate_Version(t *testing.T) {
+	_, _, err := Migrate(readSettings(t, "testdata/v5.json"))
+	assert.ErrorIs(t, err, ErrNewerVersion)
+
+	for _, version := range []interface{}{0, "4", 1.5} {
+		_, _, err := Migrate(map[string]interface{}{VersionKey: version})
+		assert.Error(t, err, version)
+	}
+	for _, version := range []interface{}{4, int64(4), 4.0} {
+		from, _, err := Migrate(map[string]interface{}{VersionKey: version})
+		assert.NoError(t, err, version)
+		assert.Equal(t, 4, from)
+	}
+}
+
+func TestLoad_Migrated(t *testing.T) {
+	want, warnings, err := loadFileWithWarnings(t, "testdata/v4.toml")
+	assert.NoError(t, err)
+	assert.Empty(t, warnings)
+	cfg, warnings, err := loadFileWithWarnings(t, "testdata/v3.toml")
+	assert.NoError(t, err)
+	assert.Equal(t, want, cfg)
+	assert.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "v3.toml is version 3")
+	cfg, warnings, err = loadFileWithWarnings(t, "testdata/v2.toml")
+	assert.NoError(t, err)
+	assert.Equal(t, want, cfg)
+	assert.Len(t, warnings, 2)
+	assert.Contains(t, warnings[1], "v2.toml is version 2")
+	cfg, warnings, err = loadFileWithWarnings(t, "testdata/v1.toml")
+	assert.NoError(t, err)
+	assert.Equal(t, want, cfg)
+	assert.Len(t, warnings, 3)
+	assert.Contains(t, warnings[2], "v1.toml is version 1")
+
+	_, err = loadFile(t, "testdata/v5.json")
+	assert.ErrorIs(t, err, ErrNewerVersion)
+}
+
+func TestLoad_Deprecated(t *testing.T) {
+	cfg, warnings, err := loadFileWithWarnings(t, "testdata/deprecated.toml")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"config key base.log_level is deprecated, use logging.level instead",
+		"config key base.retry_dial_jitter is deprecated, use connection.retry.jitter instead",
+		"config key base.validator_laddr is deprecated, use base.validator_laddrs instead",
+	}, warnings)
+	assert.Equal(t, "warn", cfg.Logging.Level)
+	assert.Equal(t, 0.5, cfg.Connection.Retry.Jitter)
+	assert.Equal(t, "tcp://127.0.0.1:3000", cfg.Base.ValidatorListenAddress)
+}
+
+func TestMigrateFile(t *testing.T) {
+	bz, err := ioutil.ReadFile("testdata/v1.toml")
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "config.toml")
+	assert.NoError(t, ioutil.WriteFile(path, bz, PermConfigToml))
+
+	from, warnings, err := MigrateFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, from)
+	assert.Len(t, warnings, 2)
+	backup, err := ioutil.ReadFile(path + BackupSuffix)
+	assert.NoError(t, err)
+	assert.Equal(t, bz, backup)
+	want := readSettings(t, "testdata/v4.toml")
+	assert.Equal(t, want, readSettings(t, path))
+
+	// Migrating again leaves the file of the current version untouched.
+	migrated, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	from, _, err = MigrateFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, CurrentVersion, from)
+	bz, err = ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, migrated, bz)
+	backup, err = ioutil.ReadFile(path + BackupSuffix)
+	assert.NoError(t, err)
+	assert.NotEqual(t, migrated, backup)
+
+	// Unknown keys are reported, but left in the file.
+	bz, err = ioutil.ReadFile("testdata/unknown.toml")
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(path, bz, PermConfigToml))
+	_, warnings, err = MigrateFile(path)
+	assert.NoError(t, err)
+	assert.Len(t, warnings, 2)
+	assert.Contains(t, readSettings(t, path)["base"], "treshold")
+}
+
+// loadFileWithWarnings loads the configuration file at the given path along with its
+// warnings.
+func loadFileWithWarnings(t *testing.T, path string) (Config, []string, error) {
+	t.Helper()
+	viper.SetConfigFile(path)
+	defer viper.Reset()
+
+	return LoadWithWarnings()
+}
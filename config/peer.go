@@ -0,0 +1,83 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+)
+
+// ErrInvalidPeer is returned if a peer is not in the <conn_pub_key>@<host:port>
+// format.
+var ErrInvalidPeer = errors.New("invalid peer")
+
+// ParsePeer parses the given peer in the <conn_pub_key>@<host:port> format. The
+// public key must be a base64 or bech32 encoded ed25519 public key, and the address a
+// TCP socket address whose host may be a hostname.
+func ParsePeer(peer string) (types.Peer, error) {
+	i := strings.LastIndex(peer, "@")
+	if i < 0 {
+		return types.Peer{}, fmt.Errorf("%w %q: missing @, must be <conn_pub_key>@<host:port>", ErrInvalidPeer, peer)
+	}
+	key, addr := peer[:i], peer[i+1:]
+	if _, err := DecodePubKey(key); err != nil {
+		return types.Peer{}, fmt.Errorf("%w %q: %v", ErrInvalidPeer, peer, err)
+	}
+	a, err := ParseHostAddress(addr)
+	if err != nil {
+		return types.Peer{}, fmt.Errorf("%w %q: %v", ErrInvalidPeer, peer, err)
+	}
+	if a.Protocol != ProtocolTCP {
+		return types.Peer{}, fmt.Errorf("%w %q: %q is not a TCP address", ErrInvalidPeer, peer, addr)
+	}
+	if _, port, _ := net.SplitHostPort(a.Addr); port == "0" {
+		return types.Peer{}, fmt.Errorf("%w %q: port 0 of %q can't be dialed", ErrInvalidPeer, peer, addr)
+	}
+
+	return types.Peer{Address: a.Addr, ConnPubKey: key}, nil
+}
+
+// PeerList returns the parsed peers of the base section. Invalid peers are left out,
+// as they are reported by the validation.
+func (b Base) PeerList() []types.Peer {
+	var peers []types.Peer
+	for _, peer := range b.Peers {
+		if p, err := ParsePeer(peer); err == nil {
+			peers = append(peers, p)
+		}
+	}
+
+	return peers
+}
+
+// validatePeers validates the peers of the base section. Each must be valid, no
+// public key or address must be listed twice, and there must not be more peers than
+// other validators in the set.
+func (b Base) validatePeers() string {
+	var errs string
+	keys := make(map[string]bool)
+	addrs := make(map[string]bool)
+	for i, peer := range b.Peers {
+		p, err := ParsePeer(peer)
+		if err != nil {
+			errs += fmt.Sprintf("\tpeers[%v] is invalid: %v\n", i, err)
+			continue
+		}
+		key, _ := DecodePubKey(p.ConnPubKey)
+		if keys[string(key)] {
+			errs += fmt.Sprintf("\tpeers[%v] has the same conn_pub_key as an earlier peer\n", i)
+		}
+		if addrs[p.Address] {
+			errs += fmt.Sprintf("\tpeers[%v] has the same address as an earlier peer\n", i)
+		}
+		keys[string(key)] = true
+		addrs[p.Address] = true
+	}
+	if b.SetSize >= 2 && len(b.Peers) > b.SetSize-1 {
+		errs += fmt.Sprintf("\tpeers must list at most set_size-1 (%v) other nodes of the set, got %v\n", b.SetSize-1, len(b.Peers))
+	}
+
+	return errs
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	testPeerKey      = "2KmYPwtTGfV5MqUWdRXC6bwS0NgxBG2+gCmgKEnjcFo="
+	testOtherPeerKey = "Ol9Y0xX1Jcm7l2nCTSnPrC5x0yUC5KKRr3hDDBrZ5dU="
+)
+
+func TestParsePeer(t *testing.T) {
+	peer, err := ParsePeer(testPeerKey + "@10.0.0.2:26660")
+	assert.NoError(t, err)
+	assert.Equal(t, types.Peer{Address: "10.0.0.2:26660", ConnPubKey: testPeerKey}, peer)
+	peer, err = ParsePeer(testPeerKey + "@tcp://signctrl-2.example.com:26660")
+	assert.NoError(t, err)
+	assert.Equal(t, types.Peer{Address: "signctrl-2.example.com:26660", ConnPubKey: testPeerKey}, peer)
+	peer, err = ParsePeer(testPeerKey + "@[::1]:26660")
+	assert.NoError(t, err)
+	assert.Equal(t, "[::1]:26660", peer.Address)
+
+	for _, invalid := range []string{
+		"",
+		"10.0.0.2:26660",
+		testPeerKey,
+		testPeerKey + "@",
+		"@10.0.0.2:26660",
+		"invalid@10.0.0.2:26660",
+		testPeerKey + "@10.0.0.2",
+		testPeerKey + "@10.0.0.2:0",
+		testPeerKey + "@10.0.0.256:26660",
+		testPeerKey + "@unix:///tmp/signctrl.sock",
+	} {
+		_, err := ParsePeer(invalid)
+		assert.ErrorIs(t, err, ErrInvalidPeer, invalid)
+	}
+}
+
+func TestValidatePeers(t *testing.T) {
+	// The peers aren't required.
+	cfg := testConfig(t)
+	assert.NoError(t, cfg.Base.validate())
+	assert.Empty(t, cfg.Base.PeerList())
+
+	cfg.Base.SetSize = 3
+	cfg.Base.Peers = []string{testPeerKey + "@10.0.0.2:26660", testOtherPeerKey + "@10.0.0.3:26660"}
+	assert.NoError(t, cfg.Base.validate())
+	assert.Equal(t, []types.Peer{
+		{Address: "10.0.0.2:26660", ConnPubKey: testPeerKey},
+		{Address: "10.0.0.3:26660", ConnPubKey: testOtherPeerKey},
+	}, cfg.Base.PeerList())
+
+	// More peers than other validators in the set, and duplicates.
+	cfg.Base.SetSize = 2
+	cfg.Base.Peers = []string{testPeerKey + "@10.0.0.2:26660", testPeerKey + "@tcp://10.0.0.2:26660", "invalid"}
+	err := cfg.Base.validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "\tpeers[1] has the same conn_pub_key as an earlier peer\n")
+	assert.Contains(t, err.Error(), "\tpeers[1] has the same address as an earlier peer\n")
+	assert.Contains(t, err.Error(), "\tpeers[2] is invalid: invalid peer \"invalid\": missing @")
+	assert.Contains(t, err.Error(), "\tpeers must list at most set_size-1 (1) other nodes of the set, got 3\n")
+
+	// Invalid peers are left out of the list.
+	assert.Len(t, cfg.Base.PeerList(), 2)
+
+	// The peers are answered on a TCP address.
+	cfg = testConfig(t)
+	cfg.Base.PeerListenAddress = "0.0.0.0:26660"
+	assert.EqualError(t, cfg.Base.validate(), "\tpeer_laddr needs peers to answer\n")
+	cfg.Base.Peers = []string{testPeerKey + "@10.0.0.2:26660"}
+	assert.NoError(t, cfg.Base.validate())
+	cfg.Base.PeerListenAddress = "unix:///tmp/signctrl-peers.sock"
+	assert.EqualError(t, cfg.Base.validate(), "\tpeer_laddr must be a TCP address\n")
+	cfg.Base.PeerListenAddress = "0.0.0.0"
+	assert.Error(t, cfg.Base.validate())
+}
@@ -0,0 +1,114 @@
+package config
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+var (
+	// aminoPubKeyPrefix is the amino prefix of ed25519 public keys, which bech32
+	// encoded keys of Cosmos SDK chains (e.g. cosmosvalconspub1...) carry.
+	aminoPubKeyPrefix = []byte{0x16, 0x24, 0xde, 0x64, 0x20}
+
+	// ErrInvalidPubKey is returned if a public key is neither a base64 nor a bech32
+	// encoded ed25519 public key.
+	ErrInvalidPubKey = errors.New("not a base64 or bech32 encoded ed25519 public key")
+)
+
+// DecodePubKey decodes the given base64 or bech32 encoded ed25519 public key. Bech32
+// encoded keys may be amino encoded, as is common for Cosmos SDK chains.
+func DecodePubKey(encKey string) ([]byte, error) {
+	// Bech32 strings only consist of base64 characters, so they might be decoded as
+	// base64 as well, but never to a key of the right size.
+	if key, err := base64.StdEncoding.DecodeString(encKey); err == nil && len(key) == ed25519.PublicKeySize {
+		return key, nil
+	}
+	key, err := decodeBech32(encKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPubKey, err)
+	}
+	key = bytes.TrimPrefix(key, aminoPubKeyPrefix)
+	if len(key) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%w: expected %v bytes, got %v bytes", ErrInvalidPubKey, ed25519.PublicKeySize, len(key))
+	}
+
+	return key, nil
+}
+
+// bech32Polymod computes the BCH checksum of the given 5-bit values as specified in
+// BIP-173.
+func bech32Polymod(values []byte) uint32 {
+	gen := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>uint(i))&1 == 1 {
+				chk ^= gen[i]
+			}
+		}
+	}
+
+	return chk
+}
+
+// decodeBech32 decodes the data part of the given bech32 string and verifies its
+// checksum. The human-readable part isn't checked, as it differs from chain to chain.
+func decodeBech32(s string) ([]byte, error) {
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return nil, errors.New("bech32 string has mixed case")
+	}
+	s = strings.ToLower(s)
+	sep := strings.LastIndex(s, "1")
+	if sep < 1 || sep+7 > len(s) {
+		return nil, errors.New("bech32 string has an invalid separator position")
+	}
+
+	hrp := s[:sep]
+	values := make([]byte, 0, 2*len(hrp)+1+len(s)-sep-1)
+	for _, c := range hrp {
+		values = append(values, byte(c>>5))
+	}
+	values = append(values, 0)
+	for _, c := range hrp {
+		values = append(values, byte(c&31))
+	}
+	for _, c := range s[sep+1:] {
+		v := strings.IndexRune(bech32Charset, c)
+		if v < 0 {
+			return nil, fmt.Errorf("bech32 string contains invalid character %q", c)
+		}
+		values = append(values, byte(v))
+	}
+	if bech32Polymod(values) != 1 {
+		return nil, errors.New("bech32 string has an invalid checksum")
+	}
+
+	// Regroup the 5-bit values without the checksum into bytes.
+	data := values[2*len(hrp)+1 : len(values)-6]
+	var (
+		acc  uint32
+		bits uint
+		out  []byte
+	)
+	for _, v := range data {
+		acc = acc<<5 | uint32(v)
+		bits += 5
+		if bits >= 8 {
+			bits -= 8
+			out = append(out, byte(acc>>bits))
+		}
+	}
+	if bits >= 5 || acc&(1<<bits-1) != 0 {
+		return nil, errors.New("bech32 string has invalid padding")
+	}
+
+	return out, nil
+}
@@ -0,0 +1,36 @@
+package config
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodePubKey(t *testing.T) {
+	want, err := base64.StdEncoding.DecodeString("2KmYPwtTGfV5MqUWdRXC6bwS0NgxBG2+gCmgKEnjcFo=")
+	assert.NoError(t, err)
+
+	key, err := DecodePubKey("2KmYPwtTGfV5MqUWdRXC6bwS0NgxBG2+gCmgKEnjcFo=")
+	assert.NoError(t, err)
+	assert.Equal(t, want, key)
+
+	// Amino encoded, as used by Cosmos SDK chains.
+	key, err = DecodePubKey("cosmosvalconspub1zcjduepqmz5es0ct2vvl27fj55t829wzax7p95xcxyzxm05q9xszsj0rwpdqgu87wh")
+	assert.NoError(t, err)
+	assert.Equal(t, want, key)
+
+	key, err = DecodePubKey("signctrl1mz5es0ct2vvl27fj55t829wzax7p95xcxyzxm05q9xszsj0rwpdq0cvqcp")
+	assert.NoError(t, err)
+	assert.Equal(t, want, key)
+
+	// Invalid checksum.
+	_, err = DecodePubKey("signctrl1mz5es0ct2vvl27fj55t829wzax7p95xcxyzxm05q9xszsj0rwpdq0cvqcq")
+	assert.ErrorIs(t, err, ErrInvalidPubKey)
+
+	// Wrong size.
+	_, err = DecodePubKey("dGVzdA==")
+	assert.ErrorIs(t, err, ErrInvalidPubKey)
+	_, err = DecodePubKey("invalid")
+	assert.ErrorIs(t, err, ErrInvalidPubKey)
+}
@@ -0,0 +1,232 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+const (
+	// sampleWidth is the width the descriptions of the settings in the sample
+	// configuration file are wrapped at.
+	sampleWidth = 52
+
+	// bannerWidth is the width of the banners introducing the sections of the sample
+	// configuration file.
+	bannerWidth = 61
+
+	// omitDesc is the desc tag of settings that are left out of the sample
+	// configuration file, e.g. deprecated ones.
+	omitDesc = "-"
+)
+
+// versionDesc describes the version of the sample configuration file.
+const versionDesc = `Version of the configuration file's schema. Files of older versions, or without a version, are migrated to the current one when loaded and can be rewritten with "signctrl config migrate".`
+
+// WriteSample writes a configuration file in TOML to w that contains all settings of
+// the Config with their defaults and comments describing them. Both are taken from the
+// tags of the Config's fields: desc holds the one-line description of a setting (or
+// the title of a section) and default holds its value in the sample, with lists being
+// comma-separated. On fields of nested sections, default holds comma-separated
+// key=value pairs overriding the defaults of their settings instead. Settings with a
+// desc of "-" are left out. A setting without these tags is an error, so that no
+// setting goes undocumented.
+func WriteSample(w io.Writer) error {
+	var buf bytes.Buffer
+	writeComment(&buf, versionDesc)
+	fmt.Fprintf(&buf, "%v = %v\n", VersionKey, CurrentVersion)
+
+	sections := reflect.TypeOf(Config{})
+	for i := 0; i < sections.NumField(); i++ {
+		field := sections.Field(i)
+		name := field.Tag.Get("mapstructure")
+		desc, ok := field.Tag.Lookup("desc")
+		if !ok {
+			return fmt.Errorf("%v has no desc tag", name)
+		}
+		writeBanner(&buf, desc)
+		if err := writeSection(&buf, name, "", field.Type, nil); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeSection writes the settings of the given section type as a TOML table, described
+// by the given description if it isn't empty, followed by its nested sections and maps
+// as subtables. The given overrides replace the default tags of the section's settings.
+func writeSection(buf *bytes.Buffer, section, desc string, t reflect.Type, overrides map[string]string) error {
+	var settings, tables []reflect.StructField
+	for _, field := range sampleFields(t) {
+		if field.Type.Kind() == reflect.Struct || field.Type.Kind() == reflect.Map {
+			tables = append(tables, field)
+		} else {
+			settings = append(settings, field)
+		}
+	}
+
+	if len(settings) > 0 || desc != "" {
+		fmt.Fprintf(buf, "\n[%v]\n", section)
+	}
+	if desc != "" {
+		buf.WriteString("\n")
+		writeComment(buf, desc)
+	}
+	for _, field := range settings {
+		key := section + "." + field.Tag.Get("mapstructure")
+		desc, ok := field.Tag.Lookup("desc")
+		if !ok {
+			return fmt.Errorf("%v has no desc tag", key)
+		}
+		def, ok := field.Tag.Lookup("default")
+		if !ok {
+			return fmt.Errorf("%v has no default tag", key)
+		}
+		if desc == omitDesc {
+			continue
+		}
+		if override, ok := overrides[field.Tag.Get("mapstructure")]; ok {
+			def = override
+		}
+		value, err := sampleValue(field.Type, def)
+		if err != nil {
+			return fmt.Errorf("%v has an invalid default tag: %v", key, err)
+		}
+		buf.WriteString("\n")
+		writeComment(buf, desc)
+		fmt.Fprintf(buf, "%v = %v\n", field.Tag.Get("mapstructure"), value)
+	}
+
+	for _, field := range tables {
+		key := section + "." + field.Tag.Get("mapstructure")
+		desc, ok := field.Tag.Lookup("desc")
+		if !ok {
+			return fmt.Errorf("%v has no desc tag", key)
+		}
+		if field.Type.Kind() == reflect.Map {
+			buf.WriteString("\n")
+			writeComment(buf, desc)
+			fmt.Fprintf(buf, "[%v]\n", key)
+			continue
+		}
+		overrides, err := parseOverrides(field.Tag.Get("default"))
+		if err != nil {
+			return fmt.Errorf("%v has an invalid default tag: %v", key, err)
+		}
+		if err := writeSection(buf, key, desc, field.Type, overrides); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// sampleFields returns the fields of the given section type, with the fields of
+// embedded structs in place of the embedded structs themselves.
+func sampleFields(t reflect.Type) []reflect.StructField {
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		if field := t.Field(i); field.Anonymous {
+			fields = append(fields, sampleFields(field.Type)...)
+		} else {
+			fields = append(fields, field)
+		}
+	}
+
+	return fields
+}
+
+// sampleValue returns the TOML value of the given default tag for a setting of the
+// given type.
+func sampleValue(t reflect.Type, def string) (string, error) {
+	switch t.Kind() {
+	case reflect.String:
+		return strconv.Quote(def), nil
+
+	case reflect.Bool:
+		if _, err := strconv.ParseBool(def); err != nil {
+			return "", err
+		}
+		return def, nil
+
+	case reflect.Int, reflect.Int64:
+		if _, err := strconv.ParseInt(def, 10, 64); err != nil {
+			return "", err
+		}
+		return def, nil
+
+	case reflect.Float64:
+		if _, err := strconv.ParseFloat(def, 64); err != nil {
+			return "", err
+		}
+		return def, nil
+
+	case reflect.Slice:
+		if t.Elem().Kind() != reflect.String {
+			return "", fmt.Errorf("unsupported type %v", t)
+		}
+		var values []string
+		for _, value := range strings.Split(def, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, strconv.Quote(value))
+			}
+		}
+		return "[" + strings.Join(values, ", ") + "]", nil
+
+	default:
+		return "", fmt.Errorf("unsupported type %v", t)
+	}
+}
+
+// parseOverrides parses the comma-separated key=value pairs of the default tag of a
+// nested section.
+func parseOverrides(def string) (map[string]string, error) {
+	overrides := make(map[string]string)
+	if def == "" {
+		return overrides, nil
+	}
+	for _, pair := range strings.Split(def, ",") {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("%q must be a key=value pair", pair)
+		}
+		overrides[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	return overrides, nil
+}
+
+// writeComment writes the given description as TOML comments wrapped at the
+// sampleWidth. Words longer than that are put on their own line.
+func writeComment(buf *bytes.Buffer, desc string) {
+	line := "#"
+	for _, word := range strings.Fields(desc) {
+		if len(line)+1+len(word) > sampleWidth && line != "#" {
+			buf.WriteString(line + "\n")
+			line = "#"
+		}
+		line += " " + word
+	}
+	buf.WriteString(line + "\n")
+}
+
+// writeBanner writes the banner introducing a section with the given title, which is
+// centered between hashes.
+func writeBanner(buf *bytes.Buffer, title string) {
+	inner := bannerWidth - 2*len("###")
+	left := (inner - len(title)) / 2
+	if left < 1 {
+		left = 1
+	}
+	right := inner - len(title) - left
+	if right < 1 {
+		right = 1
+	}
+	hashes := strings.Repeat("#", bannerWidth)
+	fmt.Fprintf(buf, "\n%v\n###%v%v%v###\n%v\n", hashes, strings.Repeat(" ", left), title, strings.Repeat(" ", right), hashes)
+}
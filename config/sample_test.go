@@ -0,0 +1,79 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// update rewrites the golden files with the current output instead of comparing them.
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+func TestWriteSample(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteSample(&buf))
+	if *update {
+		assert.NoError(t, ioutil.WriteFile("testdata/sample.toml", buf.Bytes(), 0644))
+	}
+	want, err := ioutil.ReadFile("testdata/sample.toml")
+	assert.NoError(t, err)
+	assert.Equal(t, string(want), buf.String(), "run \"go test ./config -run TestWriteSample -update\" after changing the tags of the Config")
+}
+
+func TestWriteSample_Tags(t *testing.T) {
+	// Every setting must have both a desc and a default tag.
+	var buf bytes.Buffer
+	err := writeSection(&buf, "test", "", reflect.TypeOf(struct {
+		Setting string `mapstructure:"setting" default:""`
+	}{}), nil)
+	assert.EqualError(t, err, "test.setting has no desc tag")
+	err = writeSection(&buf, "test", "", reflect.TypeOf(struct {
+		Setting string `mapstructure:"setting" desc:"Setting."`
+	}{}), nil)
+	assert.EqualError(t, err, "test.setting has no default tag")
+	err = writeSection(&buf, "test", "", reflect.TypeOf(struct {
+		Nested struct {
+			Setting string `mapstructure:"setting" desc:"Setting."`
+		} `mapstructure:"nested" desc:"Nested."`
+	}{}), nil)
+	assert.EqualError(t, err, "test.nested.setting has no default tag")
+	err = writeSection(&buf, "test", "", reflect.TypeOf(struct {
+		Setting int `mapstructure:"setting" default:"ten" desc:"Setting."`
+	}{}), nil)
+	assert.Error(t, err)
+
+	// Settings with a desc of "-" are left out, and nested sections may override the
+	// defaults of their settings.
+	buf.Reset()
+	err = writeSection(&buf, "test", "", reflect.TypeOf(struct {
+		Setting    []string `mapstructure:"setting" default:"a,b" desc:"Setting."`
+		Deprecated string   `mapstructure:"deprecated" default:"" desc:"-"`
+		Nested     struct {
+			Setting bool `mapstructure:"setting" default:"false" desc:"Nested setting."`
+		} `mapstructure:"nested" default:"setting=true" desc:"Nested."`
+	}{}), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "\n[test]\n\n# Setting.\nsetting = [\"a\", \"b\"]\n\n[test.nested]\n\n# Nested.\n\n# Nested setting.\nsetting = true\n", buf.String())
+}
+
+func TestWriteSample_Defaults(t *testing.T) {
+	// The sample must agree with the defaults of settings left out of the file.
+	var buf bytes.Buffer
+	assert.NoError(t, WriteSample(&buf))
+	sample := viper.New()
+	sample.SetConfigType(FormatTOML)
+	assert.NoError(t, sample.ReadConfig(&buf))
+
+	setDefaults()
+	defer viper.Reset()
+	for _, key := range viper.AllKeys() {
+		assert.True(t, sample.IsSet(key), key)
+		assert.Equal(t, fmt.Sprint(viper.Get(key)), fmt.Sprint(sample.Get(key)), key)
+	}
+}
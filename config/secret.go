@@ -0,0 +1,76 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"reflect"
+	"strings"
+)
+
+const (
+	// EnvRefPrefix marks a string setting whose value is read from the environment
+	// variable named after it, e.g. "env:PAGERDUTY_KEY".
+	EnvRefPrefix = "env:"
+
+	// FileRefPrefix marks a string setting whose value is read from the file at the
+	// path after it, e.g. "file:/run/secrets/pd_key". Leading and trailing whitespace,
+	// like the final newline, is trimmed.
+	FileRefPrefix = "file:"
+)
+
+// resolveRefs replaces every string setting that references an environment variable
+// (see EnvRefPrefix) or a file (see FileRefPrefix) with the value it references, so
+// that secrets don't need to be kept in the configuration file. Relative paths are
+// relative to the given configuration directory. All settings that can't be resolved
+// are reported at once, with their TOML key paths. Any other value, even if it
+// contains a colon, is taken literally.
+func (c *Config) resolveRefs(cfgDir string, lookup func(string) (string, bool)) error {
+	var errs string
+	c.eachSetting(func(key string, value reflect.Value) {
+		if value.Kind() != reflect.String {
+			return
+		}
+		resolved, err := resolveRef(value.String(), cfgDir, lookup)
+		if err != nil {
+			errs += fmt.Sprintf("\t%v %v\n", key, err)
+			return
+		}
+		value.SetString(resolved)
+	})
+	if errs != "" {
+		return errors.New(errs)
+	}
+
+	return nil
+}
+
+// resolveRef returns the value the given reference refers to, or the value itself if
+// it isn't a reference.
+func resolveRef(value, cfgDir string, lookup func(string) (string, bool)) (string, error) {
+	switch {
+	case strings.HasPrefix(value, EnvRefPrefix):
+		name := strings.TrimPrefix(value, EnvRefPrefix)
+		if name == "" {
+			return "", errors.New("references an environment variable without a name")
+		}
+		env, ok := lookup(name)
+		if !ok {
+			return "", fmt.Errorf("references the environment variable %v, which isn't set", name)
+		}
+		return env, nil
+
+	case strings.HasPrefix(value, FileRefPrefix):
+		path := strings.TrimPrefix(value, FileRefPrefix)
+		if path == "" {
+			return "", errors.New("references a file without a path")
+		}
+		bz, err := ioutil.ReadFile(ResolvePath(cfgDir, path))
+		if err != nil {
+			return "", fmt.Errorf("references a file that couldn't be read: %v", err)
+		}
+		return strings.TrimSpace(string(bz)), nil
+	}
+
+	return value, nil
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// lookupIn returns a lookup function, like os.LookupEnv, for the given environment.
+func lookupIn(env map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+}
+
+func TestResolveRefs_Env(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Alerts.PagerDuty.Token = "env:PD_KEY"
+	cfg.Alerts.Slack.Token = "env:SLACK_TOKEN"
+	err := cfg.resolveRefs(".", lookupIn(map[string]string{"PD_KEY": "pd-secret", "SLACK_TOKEN": ""}))
+	assert.NoError(t, err)
+	assert.Equal(t, "pd-secret", cfg.Alerts.PagerDuty.Token)
+	assert.Empty(t, cfg.Alerts.Slack.Token)
+
+	// Unset variables are reported with the settings referencing them.
+	cfg.Alerts.PagerDuty.Token = "env:PD_KEY"
+	cfg.Alerts.Webhook.Token = "env:"
+	err = cfg.resolveRefs(".", lookupIn(nil))
+	assert.EqualError(t, err, "\talerts.webhook.token references an environment variable without a name\n"+
+		"\talerts.pagerduty.token references the environment variable PD_KEY, which isn't set\n")
+}
+
+func TestResolveRefs_File(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "pd_key"), []byte("  pd-secret\n"), 0600))
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "secrets"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "secrets", "webhook"), []byte("webhook-secret"), 0600))
+
+	// Absolute paths are kept, relative ones are relative to the configuration
+	// directory, and the content is trimmed.
+	cfg := testConfig(t)
+	cfg.Alerts.PagerDuty.Token = "file:" + filepath.Join(dir, "pd_key")
+	cfg.Alerts.Webhook.Token = "file:secrets/webhook"
+	assert.NoError(t, cfg.resolveRefs(dir, lookupIn(nil)))
+	assert.Equal(t, "pd-secret", cfg.Alerts.PagerDuty.Token)
+	assert.Equal(t, "webhook-secret", cfg.Alerts.Webhook.Token)
+
+	// Files that can't be read are reported with the settings referencing them.
+	cfg.Privval.ProxyURL = "file:missing"
+	cfg.Alerts.Slack.Token = "file:"
+	err := cfg.resolveRefs(dir, lookupIn(nil))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "\tprivval.proxy_url references a file that couldn't be read: open "+filepath.Join(dir, "missing")+": no such file or directory\n")
+	assert.Contains(t, err.Error(), "\talerts.slack.token references a file without a path\n")
+}
+
+func TestResolveRefs_Literal(t *testing.T) {
+	// Values that merely contain a colon are taken literally, as are the prefixes in
+	// any other case or position.
+	cfg := testConfig(t)
+	literals := map[string]*string{
+		"tcp://127.0.0.1:3000":                     &cfg.Base.ValidatorListenAddress,
+		"socks5://env:file:@127.0.0.1:9050":        &cfg.Privval.ProxyURL,
+		"https://file:8443/env:PD_KEY":             &cfg.Alerts.PagerDuty.URL,
+		"ENV:SLACK_TOKEN":                          &cfg.Alerts.Slack.Token,
+		"xoxb:env:SLACK_TOKEN":                     &cfg.Alerts.Webhook.Token,
+		"echo file:/run/secrets/pd_key >> log.txt": &cfg.Hooks.OnPromoteCmd,
+	}
+	for literal, setting := range literals {
+		*setting = literal
+	}
+	assert.NoError(t, cfg.resolveRefs(".", lookupIn(map[string]string{"SLACK_TOKEN": "xoxb-secret"})))
+	for literal, setting := range literals {
+		assert.Equal(t, literal, *setting)
+	}
+}
+
+func TestLoad_Refs(t *testing.T) {
+	bz, err := ioutil.ReadFile("testdata/v4.toml")
+	assert.NoError(t, err)
+	cfgDir := t.TempDir()
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(cfgDir, "slack_token"), []byte("xoxb-secret\n"), 0600))
+	bz = append(bz, []byte("\n[alerts.slack]\nenabled = true\ntoken = \"file:slack_token\"\nchannel = \"env:SIGNCTRL_TEST_SLACK_CHANNEL\"\n")...)
+	assert.NoError(t, ioutil.WriteFile(FilePath(cfgDir), bz, PermConfigToml))
+
+	// The references are resolved before the configuration is validated.
+	_, err = loadFile(t, FilePath(cfgDir))
+	assert.EqualError(t, err, "\talerts.slack.channel references the environment variable SIGNCTRL_TEST_SLACK_CHANNEL, which isn't set\n")
+	os.Setenv("SIGNCTRL_TEST_SLACK_CHANNEL", "#validators")
+	defer os.Unsetenv("SIGNCTRL_TEST_SLACK_CHANNEL")
+	cfg, err := loadFile(t, FilePath(cfgDir))
+	assert.NoError(t, err)
+	assert.Equal(t, "xoxb-secret", cfg.Alerts.Slack.Token)
+	assert.Equal(t, "#validators", cfg.Alerts.Slack.Channel)
+
+	// Environment variables overriding settings may be references as well.
+	os.Setenv("SIGNCTRL_ALERTS_SLACK_CHANNEL", "env:SIGNCTRL_TEST_SLACK_CHANNEL")
+	defer os.Unsetenv("SIGNCTRL_ALERTS_SLACK_CHANNEL")
+	cfg, err = loadFile(t, FilePath(cfgDir))
+	assert.NoError(t, err)
+	assert.Equal(t, "#validators", cfg.Alerts.Slack.Channel)
+}
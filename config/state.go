@@ -23,6 +23,25 @@ const (
 type State struct {
 	LastHeight int64 `json:"last_height"`
 	LastRank   int   `json:"last_rank"`
+
+	// The counter for missed blocks in a row is persisted, so that a restart doesn't
+	// start counting over. A CurrentHeight of 0 means that it hasn't been persisted.
+	MissedInARow  int   `json:"missed_in_a_row"`
+	CurrentHeight int64 `json:"current_height"`
+	CounterLocked bool  `json:"counter_locked"`
+
+	// A pause of the monitoring of missed blocks is persisted, so that a restart
+	// during a maintenance window doesn't resume it.
+	Paused      bool   `json:"paused"`
+	PauseReason string `json:"pause_reason"`
+
+	// The reason of the last promotion is persisted, so that it can still be told why
+	// the validator is on its rank after a restart.
+	LastPromoteReason string `json:"last_promote_reason"`
+
+	// The validator address that last worked is persisted, so that a restart with
+	// failover enabled tries it first.
+	LastValidatorAddress string `json:"last_validator_addr"`
 }
 
 // validate validates the contents of the signctrl_state.json file.
@@ -34,6 +53,12 @@ func (s State) validate() error {
 	if s.LastRank < 1 {
 		errs += "\tlast_rank in signctrl_state.json must be 1 or higher\n"
 	}
+	if s.MissedInARow < 0 {
+		errs += "\tmissed_in_a_row in signctrl_state.json must be 0 or higher\n"
+	}
+	if s.CurrentHeight < 0 {
+		errs += "\tcurrent_height in signctrl_state.json must be 0 or higher\n"
+	}
 	if errs != "" {
 		return fmt.Errorf(errs)
 	}
@@ -77,15 +102,13 @@ func LoadOrGenState(cfgDir string) (State, error) {
 	return s, nil
 }
 
-// Save saves the current state to the signctrl_state.json file.
+// Save saves the current state to the signctrl_state.json file. The file is replaced
+// atomically, as it is saved on every change of the state.
 func (s *State) Save(cfgDir string) error {
-	lrFile, err := tm_json.MarshalIndent(&State{
-		LastRank:   s.LastRank,
-		LastHeight: s.LastHeight,
-	}, "", "\t")
+	lrFile, err := tm_json.MarshalIndent(s, "", "\t")
 	if err != nil {
 		return err
 	}
 
-	return ioutil.WriteFile(StateFilePath(cfgDir), lrFile, PermStateFile)
+	return WriteFileAtomic(StateFilePath(cfgDir), lrFile, PermStateFile)
 }
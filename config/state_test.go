@@ -10,8 +10,11 @@ import (
 func testState(t *testing.T) *State {
 	t.Helper()
 	return &State{
-		LastHeight: 10,
-		LastRank:   1,
+		LastHeight:    10,
+		LastRank:      1,
+		MissedInARow:  3,
+		CurrentHeight: 10,
+		CounterLocked: true,
 	}
 }
 
@@ -32,6 +35,18 @@ func TestValidateState(t *testing.T) {
 	err = state.validate()
 	assert.Error(t, err)
 	state.LastRank = testState(t).LastRank
+
+	// Invalid State.MissedInARow.
+	state.MissedInARow = -1
+	err = state.validate()
+	assert.Error(t, err)
+	state.MissedInARow = testState(t).MissedInARow
+
+	// Invalid State.CurrentHeight.
+	state.CurrentHeight = -1
+	err = state.validate()
+	assert.Error(t, err)
+	state.CurrentHeight = testState(t).CurrentHeight
 }
 
 func TestStateFilePath(t *testing.T) {
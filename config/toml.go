@@ -2,30 +2,26 @@ package config
 
 import (
 	"bytes"
-	"embed"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 )
 
 const (
 	// PermConfigDir determines the default file permissions for the configuration
-	// directory.
-	PermConfigDir = os.FileMode(0744)
+	// directory, which holds the validator's private key.
+	PermConfigDir = os.FileMode(0700)
 
 	// PermConfigToml determines the default file permissions for the configuration
-	// file.
-	PermConfigToml = os.FileMode(0644)
+	// file, which may contain credentials, e.g. in proxy_url.
+	PermConfigToml = os.FileMode(0600)
 )
 
-var (
-	// Embed the base.toml into the SignCTRL binary.
-	//go:embed templates/base.toml
-	baseTemplate embed.FS
-
-	// Embed the privval.toml into the SignCTRL binary.
-	//go:embed templates/privval.toml
-	privvalTemplate embed.FS
-)
+// ErrConfigExists is returned if a configuration template is about to overwrite an
+// existing configuration file.
+var ErrConfigExists = errors.New("configuration file already exists")
 
 // Section is a custom type for specific sections in the configuration file.
 type Section uint8
@@ -36,29 +32,60 @@ const (
 
 	// PrivvalSection defines the [privval] section of the configuration file.
 	PrivvalSection
+
+	// MonitoringSection defines the [monitoring] section of the configuration file.
+	MonitoringSection
+
+	// HooksSection defines the [hooks] section of the configuration file.
+	HooksSection
+
+	// LoggingSection defines the [logging] section of the configuration file.
+	LoggingSection
+
+	// AlertsSection defines the [alerts] section of the configuration file.
+	AlertsSection
 )
 
-// Create writes configuration templates to the configuration file at the specified
-// configuration directory. The base and privval sections are created by default.
+// Create writes the sample configuration (see WriteSample) to the configuration file at
+// the specified configuration directory. The base, privval, monitoring, hooks, logging
+// and alerts sections are created by default.
 func Create(cfgDir string, sections ...Section) error {
-	var cfg bytes.Buffer
-	baseBytes, err := baseTemplate.ReadFile("templates/base.toml")
+	cfg, err := template()
 	if err != nil {
 		return err
 	}
-	if _, err := cfg.Write(baseBytes); err != nil {
-		return err
-	}
-	privvalBytes, err := privvalTemplate.ReadFile("templates/privval.toml")
-	if err != nil {
-		return err
+
+	return ioutil.WriteFile(FilePath(cfgDir), cfg, PermConfigToml)
+}
+
+// template returns the sample configuration of all sections in TOML, see WriteSample.
+func template() ([]byte, error) {
+	var cfg bytes.Buffer
+	if err := WriteSample(&cfg); err != nil {
+		return nil, err
 	}
-	if _, err := cfg.Write(privvalBytes); err != nil {
+
+	return cfg.Bytes(), nil
+}
+
+// CreateTemplate scaffolds the given configuration directory with a config.toml that
+// contains all settings with sane defaults and comments describing them. See
+// CreateTemplateFormat.
+func CreateTemplate(cfgDir string) error {
+	return CreateTemplateFormat(cfgDir, FormatTOML)
+}
+
+// CreateTemplateFormat scaffolds the given configuration directory with a
+// configuration file in the given format. The directory is created if it doesn't
+// exist yet. An existing configuration file in any format is never overwritten, in
+// which case ErrConfigExists is returned.
+func CreateTemplateFormat(cfgDir, format string) error {
+	if err := os.MkdirAll(cfgDir, PermConfigDir); err != nil {
 		return err
 	}
-	if err := ioutil.WriteFile(FilePath(cfgDir), cfg.Bytes(), PermConfigToml); err != nil {
-		return err
+	if existing := ExistingFiles(cfgDir); len(existing) > 0 {
+		return fmt.Errorf("%w: %v", ErrConfigExists, strings.Join(existing, ", "))
 	}
 
-	return err
+	return CreateFormat(cfgDir, format)
 }
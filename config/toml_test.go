@@ -1,7 +1,9 @@
 package config
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -12,3 +14,32 @@ func TestCreate(t *testing.T) {
 	defer os.Remove("./config.toml")
 	assert.NoError(t, err)
 }
+
+func TestCreateTemplate(t *testing.T) {
+	cfgDir := filepath.Join(t.TempDir(), ".signctrl")
+	err := CreateTemplate(cfgDir)
+	assert.NoError(t, err)
+	info, err := os.Stat(cfgDir)
+	assert.NoError(t, err)
+	assert.Equal(t, PermConfigDir, info.Mode().Perm())
+	info, err = os.Stat(FilePath(cfgDir))
+	assert.NoError(t, err)
+	assert.Equal(t, PermConfigToml, info.Mode().Perm())
+
+	// The template is valid once the start rank and the chain ID are set.
+	os.Setenv("SIGNCTRL_BASE_START_RANK", "1")
+	defer os.Unsetenv("SIGNCTRL_BASE_START_RANK")
+	os.Setenv("SIGNCTRL_PRIVVAL_CHAIN_ID", "testchain")
+	defer os.Unsetenv("SIGNCTRL_PRIVVAL_CHAIN_ID")
+	_, err = loadFile(t, FilePath(cfgDir))
+	assert.NoError(t, err)
+
+	// Existing configuration files are never overwritten, in any format.
+	assert.NoError(t, ioutil.WriteFile(FilePath(cfgDir), []byte("# custom\n"), PermConfigToml))
+	err = CreateTemplateFormat(cfgDir, "yaml")
+	assert.ErrorIs(t, err, ErrConfigExists)
+	bz, err := ioutil.ReadFile(FilePath(cfgDir))
+	assert.NoError(t, err)
+	assert.Equal(t, "# custom\n", string(bz))
+	assert.NoFileExists(t, FormatFilePath(cfgDir, "yaml"))
+}
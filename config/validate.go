@@ -0,0 +1,178 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// dnsCheckTimeout is the time each hostname is given to be resolved by CheckDNS.
+const dnsCheckTimeout = 5 * time.Second
+
+// ValidationError lists all problems found in a configuration, so that they can be
+// fixed at once instead of one failed start at a time. Each problem starts with the
+// TOML key path of the offending setting, e.g. base.threshold.
+type ValidationError struct {
+	Problems []string
+}
+
+// newValidationError returns a *ValidationError for the given problems, or nil if
+// there are none.
+func newValidationError(problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+
+	return &ValidationError{Problems: problems}
+}
+
+// Error implements the error interface and lists the problems one per line.
+func (e *ValidationError) Error() string {
+	var errs string
+	for _, problem := range e.Problems {
+		errs += fmt.Sprintf("\t%v\n", problem)
+	}
+
+	return errs
+}
+
+// qualify splits the error returned by the validation of the given section into its
+// problems and prefixes each of them with the section, so that they start with their
+// TOML key path.
+func qualify(section string, err error) []string {
+	if err == nil {
+		return nil
+	}
+	var problems []string
+	for _, line := range strings.Split(err.Error(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			problems = append(problems, section+"."+line)
+		}
+	}
+
+	return problems
+}
+
+// configFile is a file the configuration refers to by its TOML key path.
+type configFile struct {
+	key  string
+	path string
+}
+
+// checkFiles checks that the files the configuration refers to exist. The key_file of
+// watch-only mode isn't checked, as it may only be written by the key_hook, and neither
+// is priv_validator_key_file in watch-only mode. The validator's key and state files
+// must only be accessible by their owner, and the state file may not exist yet, as
+// long as its directory does. The same goes for the log file.
+func (c Config) checkFiles() []string {
+	files := []configFile{
+		{"privval.tls_cert_file", c.Privval.TLSCertFile},
+		{"privval.tls_key_file", c.Privval.TLSKeyFile},
+		{"privval.tls_ca_file", c.Privval.TLSCAFile},
+	}
+	if c.Privval.SSHHost != "" {
+		files = append(files,
+			configFile{"privval.ssh_key_file", c.Privval.SSHKeyFile},
+			configFile{"privval.ssh_known_hosts_file", c.Privval.SSHKnownHostsFile},
+		)
+	}
+	if c.RPC.Address != "" {
+		files = append(files,
+			configFile{"rpc.tls_ca_file", c.RPC.TLSCAFile},
+			configFile{"rpc.tls_cert_file", c.RPC.TLSCertFile},
+			configFile{"rpc.tls_key_file", c.RPC.TLSKeyFile},
+		)
+	}
+	if !c.Privval.WatchOnly {
+		files = append(files, configFile{"privval.priv_validator_key_file", c.Privval.KeyFilePath})
+	}
+	var problems []string
+	for _, file := range files {
+		if file.path == "" {
+			continue
+		}
+		if info, err := os.Stat(file.path); err != nil {
+			problems = append(problems, fmt.Sprintf("%v must be an existing file: %v", file.key, err))
+		} else if info.IsDir() {
+			problems = append(problems, fmt.Sprintf("%v must be a file, not a directory: %v", file.key, file.path))
+		} else if file.key == "privval.priv_validator_key_file" {
+			problems = append(problems, checkOwnerOnly(file, info)...)
+		}
+	}
+	if path := c.Privval.StateFilePath; path != "" {
+		state := configFile{"privval.priv_validator_state_file", path}
+		if info, err := os.Stat(path); os.IsNotExist(err) {
+			if dir, err := os.Stat(filepath.Dir(path)); err != nil || !dir.IsDir() {
+				problems = append(problems, fmt.Sprintf("%v must be in an existing directory: %v", state.key, filepath.Dir(path)))
+			}
+		} else if err != nil {
+			problems = append(problems, fmt.Sprintf("%v must be accessible: %v", state.key, err))
+		} else if info.IsDir() {
+			problems = append(problems, fmt.Sprintf("%v must be a file, not a directory: %v", state.key, path))
+		} else {
+			problems = append(problems, checkOwnerOnly(state, info)...)
+		}
+	}
+	if path := c.Logging.Output; path != "" && path != LogOutputStderr {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			problems = append(problems, fmt.Sprintf("logging.output must be a file, not a directory: %v", path))
+		} else if dir, err := os.Stat(filepath.Dir(path)); err != nil || !dir.IsDir() {
+			problems = append(problems, fmt.Sprintf("logging.output must be in an existing directory: %v", filepath.Dir(path)))
+		}
+	}
+
+	return problems
+}
+
+// checkOwnerOnly checks that the given file is neither accessible by its group nor by
+// others, as it holds the validator's key or its double-signing protection.
+func checkOwnerOnly(file configFile, info os.FileInfo) []string {
+	if perm := info.Mode().Perm(); perm&0077 != 0 {
+		return []string{fmt.Sprintf("%v must only be accessible by its owner (e.g. chmod 600), but %v has permissions %#o", file.key, file.path, perm)}
+	}
+
+	return nil
+}
+
+// CheckDNS resolves the hostnames in validator_laddr and validator_laddrs with the given
+// lookup, e.g. net.DefaultResolver.LookupHost, so that typos in them show up before
+// SignCTRL starts dialing. All hostnames that can't be resolved are returned at once
+// in a *ValidationError. Addresses with IP addresses are skipped, as are all addresses
+// if they are dialed through privval.proxy_url or privval.ssh_host, which resolve them
+// on their end.
+func (c Config) CheckDNS(lookup func(ctx context.Context, host string) ([]string, error)) error {
+	if c.Privval.ProxyURL != "" || c.Privval.SSHHost != "" {
+		return nil
+	}
+	type address struct {
+		key  string
+		addr string
+	}
+	addrs := []address{{"base.validator_laddr", c.Base.ValidatorListenAddress}}
+	for i, addr := range c.Base.ValidatorListenAddresses {
+		addrs = append(addrs, address{fmt.Sprintf("base.validator_laddrs[%v]", i), addr})
+	}
+	var problems []string
+	for _, addr := range addrs {
+		a, err := ParseHostAddress(addr.addr)
+		if err != nil || a.Protocol != ProtocolTCP {
+			continue
+		}
+		host, _, _ := net.SplitHostPort(a.Addr)
+		if isIPLiteral(host) {
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), dnsCheckTimeout)
+		_, err = lookup(ctx, host)
+		cancel()
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%v host %q couldn't be resolved: %v", addr.key, host, err))
+		}
+	}
+
+	return newValidationError(problems)
+}
@@ -0,0 +1,186 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidate_BrokenFile(t *testing.T) {
+	viper.SetConfigFile("testdata/broken.toml")
+	defer viper.Reset()
+
+	// All problems are reported at once, with their TOML key paths.
+	_, err := Load()
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.ElementsMatch(t, []string{
+		"base.set_size must be 2 or higher",
+		"base.threshold must be 2 or higher",
+		"base.start_rank must be 1 or higher",
+		"connection.retry.multiplier must be higher than 1",
+		`base.validator_laddrs[0] is invalid: invalid address "tcp://127.0.0.1:99999": port "99999" must be a number between 0 and 65535`,
+		"base.write_timeout must be 1 or higher and use either s, m or h as the unit of time",
+		"hooks.timeout must be 1 or higher and use either s, m or h as the unit of time",
+		"privval.ssh_key_file must be an existing file: stat testdata/id_ed25519: no such file or directory",
+		"privval.ssh_known_hosts_file must be an existing file: stat testdata/known_hosts: no such file or directory",
+		"logging.level must be one of the following: [debug info warn error]",
+		"logging.format must be one of the following: [plain json]",
+		"logging.output must be in an existing directory: testdata/logs",
+	}, validationErr.Problems)
+}
+
+func TestValidate_Files(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "id_ed25519")
+	assert.NoError(t, ioutil.WriteFile(keyFile, []byte("key"), 0600))
+
+	cfg := testConfig(t)
+	cfg.Privval.SSHHost = "10.0.0.1:22"
+	cfg.Privval.SSHUser = "signctrl"
+	cfg.Privval.SSHKeyFile = keyFile
+	cfg.Privval.SSHKnownHostsFile = dir
+	err := cfg.Validate()
+	assert.EqualError(t, err, "\tprivval.ssh_known_hosts_file must be a file, not a directory: "+dir+"\n")
+
+	// The files are only checked by Validate.
+	cfg.Privval.SSHKnownHostsFile = filepath.Join(dir, "known_hosts")
+	assert.NoError(t, cfg.validate())
+	assert.Error(t, cfg.Validate())
+	assert.NoError(t, ioutil.WriteFile(cfg.Privval.SSHKnownHostsFile, []byte("hosts"), 0600))
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestValidate_KeyAndStateFiles(t *testing.T) {
+	dir := t.TempDir()
+	cfg := testConfig(t)
+	cfg.Privval.KeyFilePath = filepath.Join(dir, "key.json")
+	cfg.Privval.StateFilePath = filepath.Join(dir, "state", "state.json")
+	err := cfg.Validate()
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Len(t, validationErr.Problems, 2)
+	assert.Contains(t, validationErr.Problems[0], "privval.priv_validator_key_file must be an existing file")
+	assert.Equal(t, "privval.priv_validator_state_file must be in an existing directory: "+filepath.Join(dir, "state"), validationErr.Problems[1])
+
+	// The state file may not exist yet, as long as its directory does.
+	assert.NoError(t, ioutil.WriteFile(cfg.Privval.KeyFilePath, []byte("{}"), 0600))
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "state"), 0700))
+	assert.NoError(t, cfg.Validate())
+
+	// Both must only be accessible by their owner.
+	assert.NoError(t, os.Chmod(cfg.Privval.KeyFilePath, 0644))
+	assert.NoError(t, ioutil.WriteFile(cfg.Privval.StateFilePath, []byte("{}"), 0640))
+	err = cfg.Validate()
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{
+		"privval.priv_validator_key_file must only be accessible by its owner (e.g. chmod 600), but " + cfg.Privval.KeyFilePath + " has permissions 0644",
+		"privval.priv_validator_state_file must only be accessible by its owner (e.g. chmod 600), but " + cfg.Privval.StateFilePath + " has permissions 0640",
+	}, validationErr.Problems)
+
+	// The key file isn't checked in watch-only mode, as it is only loaded on promotion.
+	assert.NoError(t, os.Chmod(cfg.Privval.StateFilePath, 0600))
+	assert.NoError(t, os.Remove(cfg.Privval.KeyFilePath))
+	assert.Error(t, cfg.Validate())
+	cfg.Privval.WatchOnly = true
+	assert.NoError(t, cfg.Validate())
+}
+
+func TestLoad_KeyAndStateFilePaths(t *testing.T) {
+	bz, err := ioutil.ReadFile("testdata/v2.toml")
+	assert.NoError(t, err)
+	cfgDir := t.TempDir()
+	stateDir := t.TempDir()
+	assert.NoError(t, os.Mkdir(filepath.Join(cfgDir, "keys"), 0700))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(cfgDir, "keys", "key.json"), []byte("{}"), 0600))
+	bz = append(bz, []byte("priv_validator_key_file = \"keys/key.json\"\npriv_validator_state_file = \""+filepath.Join(stateDir, "state.json")+"\"\n")...)
+	assert.NoError(t, ioutil.WriteFile(FilePath(cfgDir), bz, PermConfigToml))
+
+	// Relative paths are relative to the configuration directory, absolute ones are
+	// kept as they are.
+	cfg, err := loadFile(t, FilePath(cfgDir))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(cfgDir, "keys", "key.json"), cfg.Privval.KeyFilePath)
+	assert.Equal(t, filepath.Join(stateDir, "state.json"), cfg.Privval.StateFilePath)
+
+	// Missing files are reported.
+	assert.NoError(t, os.Remove(filepath.Join(cfgDir, "keys", "key.json")))
+	_, err = loadFile(t, FilePath(cfgDir))
+	assert.Error(t, err)
+}
+
+func TestLoad_LogOutput(t *testing.T) {
+	bz, err := ioutil.ReadFile("testdata/v4.toml")
+	assert.NoError(t, err)
+	cfgDir := t.TempDir()
+	bz = append(bz, []byte("output = \"signctrl.log\"\n")...)
+	assert.NoError(t, ioutil.WriteFile(FilePath(cfgDir), bz, PermConfigToml))
+
+	// Relative paths are relative to the configuration directory, and the file may not
+	// exist yet.
+	cfg, err := loadFile(t, FilePath(cfgDir))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(cfgDir, "signctrl.log"), cfg.Logging.Output)
+
+	// stderr is kept as it is.
+	cfg, err = loadFile(t, "testdata/v4.toml")
+	assert.NoError(t, err)
+	assert.Equal(t, LogOutputStderr, cfg.Logging.Output)
+}
+
+func TestValidate_ValidatorListenAddresses(t *testing.T) {
+	// Every address is validated, each with its TOML key path.
+	cfg := testConfig(t)
+	cfg.Base.ValidatorListenAddress = "10.0.0.5"
+	cfg.Base.ValidatorListenAddresses = []string{"tcp://10.0.0.6:26659", "10.0.05:26659", "validator.example.com:0"}
+	err := cfg.validate()
+	var validationErr *ValidationError
+	assert.ErrorAs(t, err, &validationErr)
+	assert.Equal(t, []string{
+		`base.validator_laddr is invalid: invalid address "10.0.0.5": missing port, must be ` + addressForms,
+		`base.validator_laddrs[1] is invalid: invalid address "10.0.05:26659": host "10.0.05" is not a valid IPv4 address`,
+		`base.validator_laddrs[2] is invalid: port 0 of "validator.example.com:0" can't be dialed, it must be the port the validator listens on`,
+	}, validationErr.Problems)
+}
+
+func TestCheckDNS(t *testing.T) {
+	var looked []string
+	lookup := func(ctx context.Context, host string) ([]string, error) {
+		looked = append(looked, host)
+		if host == "validator.example.com" {
+			return []string{"10.0.0.7"}, nil
+		}
+		return nil, fmt.Errorf("lookup %v: no such host", host)
+	}
+
+	// Only hostnames are resolved, and all failures are reported at once.
+	cfg := testConfig(t)
+	cfg.Base.ValidatorListenAddresses = []string{"tcp://validator.example.com:26659", "unix:///tmp/validator.sock", "valdiator.example.com:26659"}
+	err := cfg.CheckDNS(lookup)
+	assert.EqualError(t, err, "\tbase.validator_laddrs[2] host \"valdiator.example.com\" couldn't be resolved: lookup valdiator.example.com: no such host\n")
+	assert.Equal(t, []string{"validator.example.com", "valdiator.example.com"}, looked)
+
+	// Hostnames are resolved on the other end of proxies and SSH tunnels.
+	looked = nil
+	cfg.Privval.ProxyURL = "socks5://127.0.0.1:9050"
+	assert.NoError(t, cfg.CheckDNS(lookup))
+	cfg.Privval.ProxyURL = ""
+	cfg.Privval.SSHHost = "10.0.0.1:22"
+	assert.NoError(t, cfg.CheckDNS(lookup))
+	assert.Empty(t, looked)
+}
+
+func TestValidate_CrossSection(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Base.ValidatorListenAddress = ""
+	cfg.Base.Rejoin = true
+	cfg.Privval.UnsafeSignAnyRank = true
+	err := cfg.Validate()
+	assert.EqualError(t, err, "\tbase.validator_laddr or base.validator_laddrs must be set in dial mode\n\tbase.rejoin must not be enabled together with privval.unsafe_sign_any_rank\n")
+}
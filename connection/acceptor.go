@@ -0,0 +1,173 @@
+package connection
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+)
+
+// handshakeFunc upgrades a connection accepted from a validator, e.g. to a secret
+// connection, and returns it together with the fingerprint of the validator's key. The
+// fingerprint is returned on failure as well, if the validator has already presented
+// its key, so that rejected validators can be told apart in the logs.
+type handshakeFunc func(conn net.Conn) (net.Conn, string, error)
+
+// Acceptor keeps accepting connections from validators on a listener in the background
+// and admits only a single authenticated one at a time, as serving two validators at
+// once risks double-signing. Handshakes are run concurrently, so that a slow or
+// malicious peer doesn't hold up the validator. Once a validator has been admitted,
+// further authenticated validators are either rejected or preempt it, i.e. its
+// connection is closed and the new one is admitted instead. The admitted validator is
+// released again once its connection is closed.
+type Acceptor struct {
+	listener  net.Listener
+	handshake handshakeFunc
+	preempt   bool
+	logger    *types.SyncLogger
+
+	mtx      sync.Mutex
+	active   *admittedConn
+	admitted chan *admittedConn
+	closed   chan struct{}
+	err      error
+}
+
+// newAcceptor returns an Acceptor upgrading the connections accepted on the given
+// listener with the given handshake and starts accepting them. If preempt is set, a
+// newly authenticated validator replaces the admitted one instead of being rejected.
+func newAcceptor(listener net.Listener, handshake handshakeFunc, preempt bool, logger *types.SyncLogger) *Acceptor {
+	a := &Acceptor{
+		listener:  listener,
+		handshake: handshake,
+		preempt:   preempt,
+		logger:    logger,
+		admitted:  make(chan *admittedConn, 1),
+		closed:    make(chan struct{}),
+	}
+	go a.run()
+
+	return a
+}
+
+// Next waits until a validator is admitted and returns its connection. It returns an
+// error once the listener is closed or the given context is canceled.
+func (a *Acceptor) Next(ctx context.Context) (net.Conn, error) {
+	select {
+	case conn := <-a.admitted:
+		return conn, nil
+	case <-a.closed:
+		return nil, a.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// run accepts connections until the listener is closed and hands each of them to its
+// own goroutine for the handshake.
+func (a *Acceptor) run() {
+	for {
+		conn, err := a.listener.Accept()
+		if err != nil {
+			a.close(err)
+			return
+		}
+		go a.accept(conn)
+	}
+}
+
+// accept runs the handshake on the given connection and admits the validator if it is
+// authenticated.
+func (a *Acceptor) accept(conn net.Conn) {
+	upgraded, fingerprint, err := a.handshake(conn)
+	if fingerprint == "" {
+		fingerprint = "unknown"
+	}
+	if err != nil {
+		a.logger.Warn("Rejected connection from %v (key %v): %v", conn.RemoteAddr(), fingerprint, err)
+		conn.Close()
+		return
+	}
+
+	a.admit(&admittedConn{Conn: upgraded, acceptor: a, remote: conn.RemoteAddr(), fingerprint: fingerprint})
+}
+
+// admit admits the given validator, unless another one has already been admitted and
+// mustn't be preempted.
+func (a *Acceptor) admit(conn *admittedConn) {
+	a.mtx.Lock()
+	select {
+	case <-a.closed:
+		a.mtx.Unlock()
+		conn.Conn.Close()
+		return
+	default:
+	}
+	preempted := a.active
+	if preempted != nil && !a.preempt {
+		a.mtx.Unlock()
+		a.logger.Warn("Rejected connection from %v (key %v): the validator at %v (key %v) is already connected", conn.remote, conn.fingerprint, preempted.remote, preempted.fingerprint)
+		conn.Conn.Close()
+		return
+	}
+
+	// A preempted connection that hasn't been picked up yet is replaced.
+	select {
+	case <-a.admitted:
+	default:
+	}
+	a.active = conn
+	a.admitted <- conn
+	a.mtx.Unlock()
+
+	if preempted != nil {
+		a.logger.Warn("Accepted the validator at %v (key %v), which preempts the validator at %v (key %v)", conn.remote, conn.fingerprint, preempted.remote, preempted.fingerprint)
+		preempted.Close()
+		return
+	}
+	a.logger.Info("Successfully accepted the validator at %v (key %v) ✓", conn.remote, conn.fingerprint)
+}
+
+// release releases the given admitted validator, so that the next one can be admitted.
+func (a *Acceptor) release(conn *admittedConn) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	if a.active == conn {
+		a.active = nil
+	}
+}
+
+// close stops admitting validators due to the given error, which is returned by Next
+// from then on. A connection that hasn't been picked up yet is closed.
+func (a *Acceptor) close(err error) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+	a.err = err
+	close(a.closed)
+	select {
+	case conn := <-a.admitted:
+		conn.Conn.Close()
+	default:
+	}
+}
+
+// admittedConn is the connection of an admitted validator, which releases it once
+// closed.
+type admittedConn struct {
+	net.Conn
+	acceptor    *Acceptor
+	remote      net.Addr
+	fingerprint string
+}
+
+// Close closes the connection and releases the validator.
+func (c *admittedConn) Close() error {
+	c.acceptor.release(c)
+	return c.Conn.Close()
+}
+
+// unwrap returns the underlying connection, so that it can be probed.
+func (c *admittedConn) unwrap() net.Conn {
+	return c.Conn
+}
@@ -0,0 +1,167 @@
+package connection
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// keyHandshake is a handshake that reads the validator's key as a single byte, which
+// is used as its fingerprint. Validators sending "x" are unauthorized.
+func keyHandshake(conn net.Conn) (net.Conn, string, error) {
+	key := make([]byte, 1)
+	if _, err := io.ReadFull(conn, key); err != nil {
+		return nil, "", err
+	}
+	if key[0] == 'x' {
+		return nil, string(key), ErrUnknownConnKey
+	}
+
+	return conn, string(key), nil
+}
+
+// dialKeys dials the given listener at the same time for each of the given keys and
+// returns the connections once all of them have sent their key.
+func dialKeys(t *testing.T, listener net.Listener, keys ...string) map[string]net.Conn {
+	t.Helper()
+	var mtx sync.Mutex
+	conns := make(map[string]net.Conn)
+	var wg sync.WaitGroup
+	for _, key := range keys {
+		wg.Add(1)
+		go func(key string) {
+			defer wg.Done()
+			conn, err := net.Dial("tcp", listener.Addr().String())
+			assert.NoError(t, err)
+			_, err = conn.Write([]byte(key))
+			assert.NoError(t, err)
+			mtx.Lock()
+			conns[key] = conn
+			mtx.Unlock()
+		}(key)
+	}
+	wg.Wait()
+
+	return conns
+}
+
+// isClosed checks whether the given connection has been closed by the other end
+// within a second.
+func isClosed(conn net.Conn) bool {
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, err := conn.Read(make([]byte, 1))
+	return errors.Is(err, io.EOF)
+}
+
+// syncBuffer is a bytes.Buffer that is safe for concurrent use.
+type syncBuffer struct {
+	mtx sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.buf.String()
+}
+
+func TestAcceptor_Reject(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	var buf syncBuffer
+	a := newAcceptor(listener, keyHandshake, false, types.NewSyncLogger(&buf, "", 0))
+
+	// Two validators race for the connection, and only the first one is admitted.
+	conns := dialKeys(t, listener, "a", "b", "x")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := a.Next(ctx)
+	assert.NoError(t, err)
+	admitted, rejected := conn.(*admittedConn).fingerprint, "a"
+	if admitted == "a" {
+		rejected = "b"
+	}
+	assert.True(t, isClosed(conns[rejected]))
+	assert.True(t, isClosed(conns["x"]))
+	assert.Eventually(t, func() bool {
+		logs := buf.String()
+		return strings.Contains(logs, "(key "+rejected+"): the validator at") &&
+			strings.Contains(logs, "(key x): "+ErrUnknownConnKey.Error())
+	}, time.Second, 10*time.Millisecond)
+	shortCtx, shortCancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer shortCancel()
+	_, err = a.Next(shortCtx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Once the admitted validator is gone, the next one is admitted.
+	conn.Close()
+	conns = dialKeys(t, listener, "c")
+	conn, err = a.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "c", conn.(*admittedConn).fingerprint)
+	conn.Close()
+	conns["c"].Close()
+}
+
+func TestAcceptor_Preempt(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	var buf syncBuffer
+	a := newAcceptor(listener, keyHandshake, true, types.NewSyncLogger(&buf, "", 0))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// The validator admitted first is preempted by the second one, even if it hasn't
+	// been picked up yet.
+	conns := dialKeys(t, listener, "a", "b")
+	assert.Eventually(t, func() bool {
+		return strings.Contains(buf.String(), "which preempts the validator")
+	}, time.Second, 10*time.Millisecond)
+	conn, err := a.Next(ctx)
+	assert.NoError(t, err)
+	preempting := conn.(*admittedConn).fingerprint
+	preempted := "a"
+	if preempting == "a" {
+		preempted = "b"
+	}
+	assert.True(t, isClosed(conns[preempted]))
+
+	// A validator that is being served is preempted, too.
+	conns = dialKeys(t, listener, "c")
+	next, err := a.Next(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "c", next.(*admittedConn).fingerprint)
+	_, err = conn.Read(make([]byte, 1))
+	assert.Error(t, err)
+	assert.Contains(t, buf.String(), "Accepted the validator at "+conns["c"].LocalAddr().String()+" (key c), which preempts the validator")
+	next.Close()
+	conns["c"].Close()
+}
+
+func TestAcceptor_Closed(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	a := newAcceptor(listener, keyHandshake, false, types.NewSyncLogger(ioutil.Discard, "", 0))
+	listener.Close()
+	conn, err := a.Next(context.Background())
+	assert.Nil(t, conn)
+	assert.Error(t, err)
+}
@@ -0,0 +1,26 @@
+package connection
+
+import "net"
+
+// rawConn returns the raw connection underneath the given secret or TLS connection.
+func rawConn(conn net.Conn) net.Conn {
+	for {
+		wrapper, ok := conn.(interface{ unwrap() net.Conn })
+		if !ok {
+			return conn
+		}
+		conn = wrapper.unwrap()
+	}
+}
+
+// CloseGracefully closes the given connection. If the raw connection underneath it
+// supports it, like TCP and unix domain socket connections do, its write side is shut
+// down first, so that the validator reads everything written so far followed by an
+// EOF, instead of a reset that truncates the last response.
+func CloseGracefully(conn net.Conn) error {
+	if closer, ok := rawConn(conn).(interface{ CloseWrite() error }); ok {
+		_ = closer.CloseWrite()
+	}
+
+	return conn.Close()
+}
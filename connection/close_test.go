@@ -0,0 +1,43 @@
+package connection
+
+import (
+	"io/ioutil"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCloseGracefully(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	received := make(chan []byte, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		bz, _ := ioutil.ReadAll(conn)
+		received <- bz
+	}()
+
+	// The raw connection is half-closed through the wrappers, so the peer reads all
+	// data followed by an EOF.
+	raw, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	var stats Stats
+	conn := stats.Wrap(withProbe(raw, raw))
+	_, err = conn.Write([]byte("last response"))
+	assert.NoError(t, err)
+	assert.NoError(t, CloseGracefully(conn))
+	assert.Equal(t, "last response", string(<-received))
+
+	// Connections without a write side to shut down are just closed.
+	local, remote := net.Pipe()
+	defer remote.Close()
+	assert.NoError(t, CloseGracefully(local))
+	_, err = local.Write([]byte("test"))
+	assert.Error(t, err)
+}
@@ -1,99 +1,229 @@
 package connection
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"net"
 	"os"
-	"os/signal"
-	"regexp"
 	"strings"
-	"syscall"
 	"time"
 
+	"github.com/BlockscapeNetwork/signctrl/config"
 	"github.com/BlockscapeNetwork/signctrl/types"
+	tm_crypto "github.com/tendermint/tendermint/crypto"
 	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
 	tm_p2pconn "github.com/tendermint/tendermint/p2p/conn"
 )
 
-var (
-	// ErrAbortDial is returned if either SIGINT or SIGTERM are fired into the quit
-	// channel.
-	ErrAbortDial = errors.New("dialing aborted")
-
-	// RetryDialInterval is the interval in which SignCTRL tries to repeatedly dial
-	// the validator. Initially set to 0, then set by retryDialX.
-	// Make SignCTRL dial immediately the first time.
-	RetryDialInterval = time.Duration(0)
-)
-
-// retryDialTCP keeps dialing the given TCP socket address until success, using the
-// given connkey for encryption and returns the secret connection.
-func retryDialTCP(address string, connkey tm_ed25519.PrivKey, sigs chan os.Signal, logger *types.SyncLogger) (net.Conn, error) {
-	for {
+// retry keeps calling the given dial function for the given address until success
+// in the intervals of the given policy and returns the connection. Failed attempts,
+// including timed out ones, are retried, unless the validator uses an unauthorized
+// connection key. Every attempt is logged at debug level, while failed ones are
+// coalesced into summaries at the policy's LogInterval. If the policy is exhausted, a
+// RetryExhaustedError is returned. Once the given context is canceled, both waiting
+// for the next attempt and a pending attempt are aborted and the context's error is
+// returned.
+func retry(ctx context.Context, address string, policy RetryPolicy, logger *types.SyncLogger, dial func(ctx context.Context) (net.Conn, error)) (net.Conn, error) {
+	start := time.Now()
+	failures := types.NewFailureLog(logger, types.RealClock{}, policy.LogInterval, address)
+	for attempt := 1; ; attempt++ {
+		timer := time.NewTimer(policy.interval(attempt))
 		select {
-		case <-sigs:
-			return nil, ErrAbortDial
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
 
-		case <-time.After(RetryDialInterval):
-			if conn, err := net.Dial("tcp", strings.TrimPrefix(address, "tcp://")); err == nil {
-				logger.Info("Successfully dialed the validator ✓")
-				return tm_p2pconn.MakeSecretConnection(conn, connkey)
+		case <-timer.C:
+			dialStart := time.Now()
+			conn, err := dial(ctx)
+			if ctx.Err() != nil {
+				if conn != nil {
+					conn.Close()
+				}
+				return nil, ctx.Err()
+			}
+			if policy.Stats != nil {
+				policy.Stats.recordDial(time.Since(dialStart), err)
+			}
+			if err == nil {
+				failures.Success()
+				return conn, nil
+			}
+			// Someone other than the validator might be listening on the address, so it
+			// must not receive any further requests.
+			if errors.Is(err, ErrUnknownConnKey) {
+				return nil, err
 			}
 
-			// After the first dial, dial in intervals of 1 second.
-			RetryDialInterval = time.Second
-			logger.Debug("Retry dialing...")
+			elapsed := time.Since(start)
+			if policy.exhausted(attempt, elapsed) {
+				return nil, &RetryExhaustedError{Address: address, Attempts: attempt, Elapsed: elapsed, Err: err}
+			}
+			logger.Debug("Retry dialing %v... (attempt %v, %v)", address, attempt, err)
+			failures.Failure(err)
 		}
 	}
 }
 
-// retryDialUnix keeps dialing the given unix domain socket address until success and
-// returns the connection.
-func retryDialUnix(address string, sigs chan os.Signal, logger *types.SyncLogger) (net.Conn, error) {
-	addrWithoutProtocol := strings.TrimPrefix(address, "unix://")
+// handshake establishes a secret connection on top of the given connection using the
+// given connkey and verifies that the validator uses one of the given authorized keys,
+// if any. It is aborted after the given timeout, unless it is 0, or once the given
+// context is canceled. On failure, the connection is closed.
+func handshake(ctx context.Context, conn net.Conn, connkey tm_ed25519.PrivKey, authorizedKeys []tm_crypto.PubKey, timeout time.Duration) (net.Conn, error) {
+	defer closeOnCancel(ctx, conn)()
+	if timeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	secretConn, err := tm_p2pconn.MakeSecretConnection(conn, connkey)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("couldn't establish secret connection: %w", err)
+	}
+	if err := authorize(secretConn, authorizedKeys); err != nil {
+		secretConn.Close()
+		return nil, err
+	}
+	if timeout > 0 {
+		if err := conn.SetDeadline(time.Time{}); err != nil {
+			secretConn.Close()
+			return nil, err
+		}
+	}
+
+	return withProbe(secretConn, conn), nil
+}
 
-	for {
+// closeOnCancel closes the given connection once the given context is canceled, which
+// aborts pending reads and writes, e.g. of a handshake. The returned function stops
+// watching the context.
+func closeOnCancel(ctx context.Context, conn net.Conn) (stop func()) {
+	done := make(chan struct{})
+	go func() {
 		select {
-		case <-sigs:
-			return nil, ErrAbortDial
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
 
-		case <-time.After(RetryDialInterval):
-			unixAddr := &net.UnixAddr{Name: addrWithoutProtocol, Net: "unix"}
-			if conn, err := net.DialUnix("unix", nil, unixAddr); err == nil {
-				logger.Info("Successfully dialed the validator ✓")
-				return conn, nil
-			}
+	return func() { close(done) }
+}
+
+// setKeepAlive enables TCP keepalive probes with the given period on the given
+// connection, or disables them if the period is 0. It returns whether keepalive has
+// been enabled, which is never the case for connections other than TCP ones.
+func setKeepAlive(conn net.Conn, period time.Duration) (bool, error) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return false, nil
+	}
+	if period <= 0 {
+		return false, tcpConn.SetKeepAlive(false)
+	}
+	if err := tcpConn.SetKeepAlive(true); err != nil {
+		return false, err
+	}
+	if err := tcpConn.SetKeepAlivePeriod(period); err != nil {
+		return false, err
+	}
 
-			// After the first dial, dial in intervals of 1 second.
+	return true, nil
+}
+
+// retryDialTCP keeps dialing the given TCP socket address until success, using the
+// given connkey for encryption and returns the secret connection. Hostnames are
+// resolved again on every attempt. TCP keepalive is set up before the handshake.
+func retryDialTCP(ctx context.Context, address string, connkey tm_ed25519.PrivKey, authorizedKeys []tm_crypto.PubKey, policy RetryPolicy, logger *types.SyncLogger) (net.Conn, error) {
+	// The dialer's own keepalive is disabled, as it is set up explicitly.
+	dialer := net.Dialer{Timeout: policy.DialTimeout, KeepAlive: -1}
+	keepAlive := false
+	conn, err := retry(ctx, address, policy, logger, func(ctx context.Context) (net.Conn, error) {
+		conn, err := dialTCP(ctx, &dialer, policy.Resolver, strings.TrimPrefix(address, "tcp://"), logger)
+		if err != nil {
+			return nil, err
+		}
+		if keepAlive, err = setKeepAlive(conn, policy.KeepAlivePeriod); err != nil {
+			logger.Warn("couldn't set TCP keepalive on connection to %v: %v", address, err)
+		}
+		return handshake(ctx, conn, connkey, authorizedKeys, policy.DialTimeout)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if keepAlive {
+		logger.Info("Successfully dialed the validator ✓ (TCP keepalive every %v)", policy.KeepAlivePeriod)
+	} else {
+		logger.Info("Successfully dialed the validator ✓ (TCP keepalive disabled)")
+	}
+	return conn, nil
+}
+
+// retryDialUnix keeps dialing the given unix domain socket address until success and
+// returns the connection. If a connkey is given, it is used to establish a secret
+// connection on top of the unix domain socket.
+func retryDialUnix(ctx context.Context, address string, connkey tm_ed25519.PrivKey, authorizedKeys []tm_crypto.PubKey, policy RetryPolicy, logger *types.SyncLogger) (net.Conn, error) {
+	addrWithoutProtocol := strings.TrimPrefix(address, "unix://")
+	dialer := net.Dialer{Timeout: policy.DialTimeout}
+	conn, err := retry(ctx, address, policy, logger, func(ctx context.Context) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, "unix", addrWithoutProtocol)
+		if err != nil {
 			os.RemoveAll(addrWithoutProtocol)
-			RetryDialInterval = time.Second
-			logger.Debug("Retry dialing...")
+			return nil, err
+		}
+		if connkey != nil {
+			return handshake(ctx, conn, connkey, authorizedKeys, policy.DialTimeout)
 		}
+		return conn, nil
+	})
+	if err != nil {
+		return nil, err
 	}
+
+	logger.Info("Successfully dialed the validator ✓")
+	return conn, nil
 }
 
-// RetryDial keeps dialing the given address until success and returns the connection.
-func RetryDial(cfgDir, address string, logger *types.SyncLogger) (net.Conn, error) {
+// RetryDial keeps dialing the given address in the intervals of the given policy until
+// success and returns the connection. The address is parsed with
+// config.ParseHostAddress, so TCP socket addresses may omit the tcp:// scheme and use
+// a hostname. If the policy is exhausted, a RetryExhaustedError is returned.
+// Connections via TCP are always secret connections, while connections via unix
+// domain sockets are only secret connections if secretUnixConn is set. Secret
+// connections are dropped without retrying if the validator doesn't use one of the
+// given authorized keys. If none are given, any key is accepted. Dialing is aborted
+// once the given context is canceled, which returns the context's error.
+func RetryDial(ctx context.Context, cfgDir, address string, secretUnixConn bool, authorizedKeys []tm_crypto.PubKey, policy RetryPolicy, logger *types.SyncLogger) (net.Conn, error) {
+	addr, err := config.ParseHostAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	address = addr.String()
 	logger.Info("Dialing %v... (Use Ctrl+C to abort)", address)
-	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
-
-	protocol := regexp.MustCompile(`tcp|unix`).FindString(address)
-	switch protocol {
-	case "tcp":
+	secret := addr.Protocol == config.ProtocolTCP || secretUnixConn
+	if secret && len(authorizedKeys) == 0 {
+		logger.Warn("The validator at %v isn't authenticated, as authorized_keys is empty! Anyone listening on this address receives the sign requests, so add the validator's connection key to authorized_keys!", address)
+	}
+	if addr.Protocol == config.ProtocolTCP {
 		// Load the connection key from the config directory which is needed to establish
 		// a secret/encrypted connection to the validator.
-		connKey, err := LoadConnKey(cfgDir)
+		connKey, err := loadConnKey(cfgDir, logger)
 		if err != nil {
-			return nil, fmt.Errorf("couldn't load conn.key: %v", err)
+			return nil, err
 		}
-		return retryDialTCP(address, connKey, sigs, logger)
-
-	case "unix":
-		return retryDialUnix(address, sigs, logger)
+		return retryDialTCP(ctx, address, connKey, authorizedKeys, policy, logger)
+	}
 
-	default:
-		return nil, fmt.Errorf("unknown protocol in address: %v", protocol)
+	if !secretUnixConn {
+		return retryDialUnix(ctx, address, nil, nil, policy, logger)
+	}
+	connKey, err := loadConnKey(cfgDir, logger)
+	if err != nil {
+		return nil, err
 	}
+	return retryDialUnix(ctx, address, connKey, authorizedKeys, policy, logger)
 }
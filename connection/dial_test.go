@@ -1,8 +1,11 @@
 package connection
 
 import (
+	"bytes"
+	"context"
 	"crypto/ed25519"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net"
@@ -11,8 +14,10 @@ import (
 	"testing"
 	"time"
 
+	"github.com/BlockscapeNetwork/signctrl/config"
 	"github.com/BlockscapeNetwork/signctrl/types"
 	"github.com/stretchr/testify/assert"
+	tm_crypto "github.com/tendermint/tendermint/crypto"
 	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
 	tm_p2pconn "github.com/tendermint/tendermint/p2p/conn"
 )
@@ -70,7 +75,7 @@ func TestRetryDialTCP_NoConnKey(t *testing.T) {
 		assert.NoError(t, err)
 	}()
 
-	conn, err := RetryDial(cfgDir, "tcp://"+laddr, types.NewSyncLogger(ioutil.Discard, "", 0))
+	conn, err := RetryDial(context.Background(), cfgDir, "tcp://"+laddr, false, nil, DefaultRetryPolicy(), types.NewSyncLogger(ioutil.Discard, "", 0))
 	assert.Nil(t, conn)
 	assert.Error(t, err)
 }
@@ -92,7 +97,29 @@ func TestRetryDialTCP_WithConnKey(t *testing.T) {
 		assert.NoError(t, err)
 	}()
 
-	conn, err := RetryDial(cfgDir, "tcp://"+laddr, types.NewSyncLogger(ioutil.Discard, "", 0))
+	conn, err := RetryDial(context.Background(), cfgDir, "tcp://"+laddr, false, nil, DefaultRetryPolicy(), types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.NotNil(t, conn)
+	assert.NoError(t, err)
+}
+
+func TestRetryDialTCP_WithoutScheme(t *testing.T) {
+	cfgDir := "./test_dial_tcp_withoutscheme"
+	err := os.MkdirAll(cfgDir, 0700)
+	assert.NoError(t, err)
+	defer os.RemoveAll(cfgDir)
+
+	err = CreateBase64ConnKey(cfgDir)
+	assert.NoError(t, err)
+
+	port, _ := getFreePort(t)
+	laddr := fmt.Sprintf("127.0.0.1:%v", port)
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	go func() {
+		err := startMockTCPServer(t, laddr, priv, 0)
+		assert.NoError(t, err)
+	}()
+
+	conn, err := RetryDial(context.Background(), cfgDir, laddr, false, nil, DefaultRetryPolicy(), types.NewSyncLogger(ioutil.Discard, "", 0))
 	assert.NotNil(t, conn)
 	assert.NoError(t, err)
 }
@@ -131,7 +158,7 @@ func TestRetryDialUnix(t *testing.T) {
 		assert.NoError(t, err)
 	}()
 
-	conn, err := RetryDial(cfgDir, "unix://"+sockAddr, types.NewSyncLogger(ioutil.Discard, "", 0))
+	conn, err := RetryDial(context.Background(), cfgDir, "unix://"+sockAddr, false, nil, DefaultRetryPolicy(), types.NewSyncLogger(ioutil.Discard, "", 0))
 	assert.NotNil(t, conn)
 	assert.NoError(t, err)
 
@@ -139,7 +166,139 @@ func TestRetryDialUnix(t *testing.T) {
 }
 
 func TestRetryDialUnknown(t *testing.T) {
-	conn, err := RetryDial(".", "invalid://127.0.0.1:3000", types.NewSyncLogger(ioutil.Discard, "", 0))
+	conn, err := RetryDial(context.Background(), ".", "invalid://127.0.0.1:3000", false, nil, DefaultRetryPolicy(), types.NewSyncLogger(ioutil.Discard, "", 0))
 	assert.Nil(t, conn)
-	assert.Error(t, err)
+	assert.ErrorIs(t, err, config.ErrInvalidAddress)
+}
+
+func TestRetryDialUnix_Secret(t *testing.T) {
+	cfgDir := t.TempDir()
+	err := CreateBase64ConnKey(cfgDir)
+	assert.NoError(t, err)
+
+	sockAddr := fmt.Sprintf("%v/test.sock", cfgDir)
+	listener, err := net.Listen("unix", sockAddr)
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		if secretConn, err := tm_p2pconn.MakeSecretConnection(conn, tm_ed25519.PrivKey(priv)); err == nil {
+			defer secretConn.Close()
+		}
+	}()
+
+	conn, err := RetryDial(context.Background(), cfgDir, "unix://"+sockAddr, true, nil, DefaultRetryPolicy(), types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.NoError(t, err)
+	assert.IsType(t, &tm_p2pconn.SecretConnection{}, conn.(*probeConn).Conn)
+}
+
+func TestSetKeepAlive(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	applied, err := setKeepAlive(conn, 30*time.Second)
+	assert.NoError(t, err)
+	assert.True(t, applied)
+	applied, err = setKeepAlive(conn, 0)
+	assert.NoError(t, err)
+	assert.False(t, applied)
+
+	// Keepalive only applies to TCP connections.
+	pipe, _ := net.Pipe()
+	applied, err = setKeepAlive(pipe, 30*time.Second)
+	assert.NoError(t, err)
+	assert.False(t, applied)
+}
+
+func TestRetryDialTCP_KeepAlive(t *testing.T) {
+	cfgDir := t.TempDir()
+	assert.NoError(t, CreateBase64ConnKey(cfgDir))
+
+	for _, tc := range []struct {
+		period time.Duration
+		log    string
+	}{
+		{30 * time.Second, "TCP keepalive every 30s"},
+		{0, "TCP keepalive disabled"},
+	} {
+		port, _ := getFreePort(t)
+		laddr := fmt.Sprintf("127.0.0.1:%v", port)
+		_, priv, _ := ed25519.GenerateKey(rand.Reader)
+		go func() {
+			err := startMockTCPServer(t, laddr, priv, 0)
+			assert.NoError(t, err)
+		}()
+
+		var buf bytes.Buffer
+		policy := DefaultRetryPolicy()
+		policy.KeepAlivePeriod = tc.period
+		conn, err := RetryDial(context.Background(), cfgDir, "tcp://"+laddr, false, nil, policy, types.NewSyncLogger(&buf, "", 0))
+		assert.NoError(t, err)
+		assert.NotNil(t, conn)
+		assert.Contains(t, buf.String(), tc.log)
+	}
+}
+
+func TestHandshake_AuthorizedKeys(t *testing.T) {
+	connKey := tm_ed25519.GenPrivKey()
+	validatorKey := tm_ed25519.GenPrivKey()
+	handshakeWith := func(authorizedKeys []tm_crypto.PubKey) (net.Conn, error) {
+		local, remote := net.Pipe()
+		go func() {
+			if secretConn, err := tm_p2pconn.MakeSecretConnection(remote, validatorKey); err == nil {
+				// Keep the connection open until the other side is done.
+				_, _ = secretConn.Read(make([]byte, 1))
+			}
+			remote.Close()
+		}()
+		return handshake(context.Background(), local, connKey, authorizedKeys, time.Second)
+	}
+
+	// The validator uses one of the authorized keys.
+	conn, err := handshakeWith([]tm_crypto.PubKey{tm_ed25519.GenPrivKey().PubKey(), validatorKey.PubKey()})
+	assert.NoError(t, err)
+	assert.True(t, conn.(*probeConn).Conn.(*tm_p2pconn.SecretConnection).RemotePubKey().Equals(validatorKey.PubKey()))
+	conn.Close()
+
+	// Without authorized keys, any key is accepted.
+	conn, err = handshakeWith(nil)
+	assert.NoError(t, err)
+	conn.Close()
+
+	// The validator uses an unauthorized key.
+	conn, err = handshakeWith([]tm_crypto.PubKey{tm_ed25519.GenPrivKey().PubKey()})
+	assert.Nil(t, conn)
+	assert.ErrorIs(t, err, ErrUnknownConnKey)
+	assert.Contains(t, err.Error(), ConnPubKey(validatorKey))
+}
+
+func TestRetryDial_UnauthorizedKey(t *testing.T) {
+	cfgDir := t.TempDir()
+	assert.NoError(t, CreateBase64ConnKey(cfgDir))
+
+	port, err := getFreePort(t)
+	assert.NoError(t, err)
+	laddr := fmt.Sprintf("127.0.0.1:%v", port)
+	_, validatorKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	go startMockTCPServer(t, laddr, validatorKey, 0)
+	time.Sleep(100 * time.Millisecond)
+
+	// The mismatch isn't retried, so no RetryExhaustedError is returned even though
+	// the policy allows several attempts.
+	policy := DefaultRetryPolicy()
+	policy.MaxAttempts = 3
+	conn, err := RetryDial(context.Background(), cfgDir, "tcp://"+laddr, false, []tm_crypto.PubKey{tm_ed25519.GenPrivKey().PubKey()}, policy, types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.Nil(t, conn)
+	assert.ErrorIs(t, err, ErrUnknownConnKey)
+	assert.False(t, errors.Is(err, ErrRetryExhausted))
 }
@@ -0,0 +1,114 @@
+package connection
+
+import "sync"
+
+// EventKind is the kind of change of a connection to the validator an Event reports.
+type EventKind string
+
+const (
+	// EventConnected is sent when the first connection to the validator at an
+	// address has been established.
+	EventConnected EventKind = "connected"
+
+	// EventDisconnected is sent when the connection to the validator at an address
+	// has been lost.
+	EventDisconnected EventKind = "disconnected"
+
+	// EventReconnected is sent when the connection to the validator at an address
+	// has been established again after it has been lost.
+	EventReconnected EventKind = "reconnected"
+
+	// EventFailover is sent when the connection to the validator at Previous has
+	// been given up in favor of the validator at Address.
+	EventFailover EventKind = "failover"
+)
+
+// Event reports a change of the connection to the validator at Address. Reason is
+// only set for disconnects and failovers, Previous only for failovers. The consumer
+// must call Done once it has handled it.
+type Event struct {
+	Kind     EventKind
+	Address  string
+	Previous string
+	Reason   error
+
+	handled chan struct{}
+}
+
+// Done marks the event as handled, which unblocks its sender.
+func (e Event) Done() {
+	close(e.handled)
+}
+
+// Events delivers the events of the connections to the validators to a single
+// consumer. Sending an event blocks until the consumer has handled it, which makes
+// sure it is handled before the connection is used again. Once the Events are closed,
+// events are discarded instead.
+// All methods are no-ops on nil Events.
+type Events struct {
+	ch   chan Event
+	quit chan struct{}
+	once sync.Once
+}
+
+// NewEvents creates new Events.
+func NewEvents() *Events {
+	return &Events{
+		ch:   make(chan Event),
+		quit: make(chan struct{}),
+	}
+}
+
+// C returns the channel the events are received on.
+func (e *Events) C() <-chan Event {
+	return e.ch
+}
+
+// Close stops delivering events, which unblocks all pending sends.
+func (e *Events) Close() {
+	if e == nil {
+		return
+	}
+	e.once.Do(func() { close(e.quit) })
+}
+
+// Connected reports that the first connection to the validator at the given address
+// has been established.
+func (e *Events) Connected(address string) {
+	e.send(Event{Kind: EventConnected, Address: address})
+}
+
+// Disconnected reports that the connection to the validator at the given address has
+// been lost for the given reason.
+func (e *Events) Disconnected(address string, reason error) {
+	e.send(Event{Kind: EventDisconnected, Address: address, Reason: reason})
+}
+
+// Reconnected reports that the connection to the validator at the given address has
+// been established again.
+func (e *Events) Reconnected(address string) {
+	e.send(Event{Kind: EventReconnected, Address: address})
+}
+
+// Failover reports that the connection to the validator at the previous address has
+// been given up for the given reason in favor of the validator at the given address.
+func (e *Events) Failover(previous, address string, reason error) {
+	e.send(Event{Kind: EventFailover, Address: address, Previous: previous, Reason: reason})
+}
+
+// send blocks until the given event has been handled or the Events are closed.
+func (e *Events) send(event Event) {
+	if e == nil {
+		return
+	}
+	event.handled = make(chan struct{})
+	select {
+	case e.ch <- event:
+	case <-e.quit:
+		return
+	}
+	select {
+	case <-event.handled:
+	case <-e.quit:
+	}
+}
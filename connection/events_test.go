@@ -0,0 +1,69 @@
+package connection
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// receive receives the next event and marks it as handled.
+func receive(t *testing.T, events *Events) Event {
+	t.Helper()
+	event := <-events.C()
+	event.Done()
+	event.handled = nil
+	return event
+}
+
+func TestEvents(t *testing.T) {
+	events := NewEvents()
+	reason := errors.New("EOF")
+	done := make(chan struct{})
+	go func() {
+		events.Connected("tcp://127.0.0.1:3000")
+		events.Disconnected("tcp://127.0.0.1:3000", reason)
+		events.Failover("tcp://127.0.0.1:3000", "tcp://127.0.0.1:3001", reason)
+		events.Reconnected("tcp://127.0.0.1:3001")
+		close(done)
+	}()
+
+	assert.Equal(t, Event{Kind: EventConnected, Address: "tcp://127.0.0.1:3000"}, receive(t, events))
+	assert.Equal(t, Event{Kind: EventDisconnected, Address: "tcp://127.0.0.1:3000", Reason: reason}, receive(t, events))
+	assert.Equal(t, Event{Kind: EventFailover, Address: "tcp://127.0.0.1:3001", Previous: "tcp://127.0.0.1:3000", Reason: reason}, receive(t, events))
+
+	// The sender is blocked until the event has been handled.
+	event := <-events.C()
+	select {
+	case <-done:
+		t.Fatal("expected sender to wait for the event to be handled")
+	case <-time.After(50 * time.Millisecond):
+	}
+	event.Done()
+	<-done
+}
+
+func TestEvents_Close(t *testing.T) {
+	// Closing unblocks pending sends, whether they have been received or not.
+	events := NewEvents()
+	done := make(chan struct{})
+	go func() {
+		events.Connected("tcp://127.0.0.1:3000")
+		events.Disconnected("tcp://127.0.0.1:3000", errors.New("EOF"))
+		close(done)
+	}()
+	<-events.C()
+	events.Close()
+	events.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected send to be unblocked within 1s")
+	}
+
+	// Nil events discard all events.
+	var nilEvents *Events
+	nilEvents.Connected("tcp://127.0.0.1:3000")
+	nilEvents.Close()
+}
@@ -0,0 +1,67 @@
+package connection
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/gogo/protobuf/proto"
+	tm_protoio "github.com/tendermint/tendermint/libs/protoio"
+)
+
+// maxHelloSize is the maximum size in bytes of a hello received from the validator.
+const maxHelloSize = 1024
+
+// ErrHelloMismatch is returned if the validator's hello doesn't match SignCTRL's, e.g.
+// as it validates for another chain. It isn't retried, as it is a misconfiguration.
+var ErrHelloMismatch = errors.New("hello mismatch")
+
+// Hello is exchanged with the validator right after connecting, before any privval
+// messages, so that SignCTRL isn't pointed at the wrong chain unnoticed. Its schema is
+// defined in hello.proto.
+type Hello struct {
+	Version string `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	Rank    int64  `protobuf:"varint,2,opt,name=rank,proto3" json:"rank,omitempty"`
+	ChainID string `protobuf:"bytes,3,opt,name=chain_id,json=chainId,proto3" json:"chain_id,omitempty"`
+}
+
+// Reset implements the proto.Message interface.
+func (h *Hello) Reset() { *h = Hello{} }
+
+// String implements the proto.Message interface.
+func (h *Hello) String() string { return proto.CompactTextString(h) }
+
+// ProtoMessage implements the proto.Message interface.
+func (*Hello) ProtoMessage() {}
+
+// ExchangeHello sends the given hello to the validator on the given connection and
+// returns the validator's. Both are sent at the same time, so it doesn't matter which
+// side reads first. An error wrapping ErrHelloMismatch is returned if the validator
+// uses another chain ID. The exchange is given up after the given timeout, which also
+// covers validators that don't speak the hello at all. The connection must be closed
+// on any error.
+func ExchangeHello(conn net.Conn, hello *Hello, timeout time.Duration) (*Hello, error) {
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+	defer func() { _ = conn.SetDeadline(time.Time{}) }()
+
+	sent := make(chan error, 1)
+	go func() {
+		_, err := tm_protoio.NewDelimitedWriter(conn).WriteMsg(hello)
+		sent <- err
+	}()
+	var remote Hello
+	if _, err := tm_protoio.NewDelimitedReader(conn, maxHelloSize).ReadMsg(&remote); err != nil {
+		return nil, fmt.Errorf("couldn't read hello: %w", err)
+	}
+	if err := <-sent; err != nil {
+		return nil, fmt.Errorf("couldn't send hello: %w", err)
+	}
+	if remote.ChainID != hello.ChainID {
+		return &remote, fmt.Errorf("%w: the validator uses chain ID '%v' (expected: '%v')", ErrHelloMismatch, remote.ChainID, hello.ChainID)
+	}
+
+	return &remote, nil
+}
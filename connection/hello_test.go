@@ -0,0 +1,76 @@
+package connection
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	tm_protoio "github.com/tendermint/tendermint/libs/protoio"
+)
+
+func TestHello_Fixture(t *testing.T) {
+	fixture, err := ioutil.ReadFile("testdata/hello.bin")
+	assert.NoError(t, err)
+	hello := &Hello{Version: "v1.0.0", Rank: 2, ChainID: "cosmoshub-4"}
+
+	bz, err := tm_protoio.MarshalDelimited(hello)
+	assert.NoError(t, err)
+	assert.Equal(t, fixture, bz)
+
+	var decoded Hello
+	err = tm_protoio.UnmarshalDelimited(fixture, &decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, *hello, decoded)
+}
+
+func TestExchangeHello(t *testing.T) {
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	// Both sides send their hello first, which must not deadlock.
+	validatorHello := &Hello{Version: "v0.34.8", ChainID: "cosmoshub-4"}
+	received := make(chan *Hello, 1)
+	go func() {
+		hello, err := ExchangeHello(remote, validatorHello, time.Second)
+		assert.NoError(t, err)
+		received <- hello
+	}()
+	hello, err := ExchangeHello(local, &Hello{Version: "v1.0.0", Rank: 2, ChainID: "cosmoshub-4"}, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, validatorHello, hello)
+	assert.Equal(t, &Hello{Version: "v1.0.0", Rank: 2, ChainID: "cosmoshub-4"}, <-received)
+}
+
+func TestExchangeHello_Mismatch(t *testing.T) {
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+	go func() {
+		_, _ = ExchangeHello(remote, &Hello{ChainID: "theta-testnet-001"}, time.Second)
+	}()
+
+	hello, err := ExchangeHello(local, &Hello{ChainID: "cosmoshub-4"}, time.Second)
+	assert.ErrorIs(t, err, ErrHelloMismatch)
+	assert.Contains(t, err.Error(), "'theta-testnet-001' (expected: 'cosmoshub-4')")
+	assert.Equal(t, "theta-testnet-001", hello.ChainID)
+}
+
+func TestExchangeHello_Timeout(t *testing.T) {
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	// Plain Tendermint never sends a hello.
+	go func() {
+		_, _ = ioutil.ReadAll(remote)
+	}()
+	_, err := ExchangeHello(local, &Hello{ChainID: "cosmoshub-4"}, 100*time.Millisecond)
+	assert.Error(t, err)
+	var netErr net.Error
+	assert.True(t, errors.As(err, &netErr) && netErr.Timeout())
+	assert.NotErrorIs(t, err, ErrHelloMismatch)
+}
@@ -1,12 +1,22 @@
 package connection
 
 import (
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	tm_crypto "github.com/tendermint/tendermint/crypto"
 	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_p2pconn "github.com/tendermint/tendermint/p2p/conn"
 )
 
 const (
@@ -15,7 +25,20 @@ const (
 
 	// PermConnKeyFile determines the default file permisssions for the connection
 	// key file.
-	PermConnKeyFile = os.FileMode(0700)
+	PermConnKeyFile = os.FileMode(0600)
+)
+
+var (
+	// ErrConnKeyNotFound is returned if there is no connection key file.
+	ErrConnKeyNotFound = errors.New("conn.key not found")
+
+	// ErrInvalidConnKey is returned if the connection key file contains neither a
+	// base64-encoded ed25519 private key nor a Tendermint node key.
+	ErrInvalidConnKey = errors.New("conn.key is neither a base64-encoded ed25519 private key nor a Tendermint node_key.json")
+
+	// ErrConnKeyExists is returned if a connection key is generated without forcing
+	// it, but the connection key file already exists.
+	ErrConnKeyExists = errors.New("conn.key already exists")
 )
 
 // KeyFilePath returns the absolute path to the connection key file.
@@ -23,26 +46,180 @@ func KeyFilePath(cfgDir string) string {
 	return filepath.Join(cfgDir, KeyFile)
 }
 
-// LoadConnKey loads the connection key from the connection key file.
+// LoadConnKey loads the connection key from the connection key file. The file either
+// contains the base64-encoded private key or is a copy of a Tendermint node_key.json,
+// which is detected by its content. An error wrapping ErrConnKeyNotFound is returned if
+// the file doesn't exist, and one wrapping ErrInvalidConnKey if it can't be parsed.
 func LoadConnKey(cfgDir string) (tm_ed25519.PrivKey, error) {
 	encSeed, err := ioutil.ReadFile(KeyFilePath(cfgDir))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w at %v, run `signctrl keygen-conn` to create one", ErrConnKeyNotFound, KeyFilePath(cfgDir))
+	} else if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(strings.TrimSpace(string(encSeed)), "{") {
+		return parseNodeKey(encSeed)
+	}
+
+	decSeed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encSeed)))
 	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConnKey, err)
+	}
+	if len(decSeed) != tm_ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("%w: expected %v bytes, got %v bytes", ErrInvalidConnKey, tm_ed25519.PrivateKeySize, len(decSeed))
+	}
+
+	return decSeed, nil
+}
+
+// nodeKey is the format of Tendermint's node_key.json, as in Tendermint's p2p package.
+type nodeKey struct {
+	PrivKey tm_crypto.PrivKey `json:"priv_key"`
+}
+
+// parseNodeKey parses the ed25519 private key from the given Tendermint node key.
+func parseNodeKey(bz []byte) (tm_ed25519.PrivKey, error) {
+	var key nodeKey
+	if err := tm_json.Unmarshal(bz, &key); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConnKey, err)
+	}
+	privKey, ok := key.PrivKey.(tm_ed25519.PrivKey)
+	if !ok {
+		return nil, fmt.Errorf("%w: expected a %v, got %T", ErrInvalidConnKey, tm_ed25519.PrivKeyName, key.PrivKey)
+	}
+	if len(privKey) != tm_ed25519.PrivateKeySize {
+		return nil, fmt.Errorf("%w: expected %v bytes, got %v bytes", ErrInvalidConnKey, tm_ed25519.PrivateKeySize, len(privKey))
+	}
+
+	return privKey, nil
+}
+
+// GenConnKey generates a new connection key and saves it base64-encoded to the
+// connection key file in the given directory, which is only readable by the owner.
+// An existing connection key file is only overwritten if forced, as the validator
+// might only accept the public key of the existing one.
+func GenConnKey(cfgDir string, force bool) (tm_ed25519.PrivKey, error) {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if force {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(KeyFilePath(cfgDir), flags, PermConnKeyFile)
+	if os.IsExist(err) {
+		return nil, fmt.Errorf("%w at %v", ErrConnKeyExists, KeyFilePath(cfgDir))
+	} else if err != nil {
 		return nil, err
 	}
+	defer f.Close()
 
-	decSeed := make([]byte, tm_ed25519.PrivateKeySize)
-	if _, err := base64.StdEncoding.Decode(decSeed, encSeed); err != nil {
+	// Overwritten files keep their permissions, so restrict them explicitly.
+	if err := f.Chmod(PermConnKeyFile); err != nil {
+		return nil, err
+	}
+	connKey := tm_ed25519.GenPrivKey()
+	if _, err := f.WriteString(base64.StdEncoding.EncodeToString(connKey)); err != nil {
 		return nil, err
 	}
 
-	return decSeed, nil
+	return connKey, f.Close()
 }
 
-// CreateBase64ConnKey creates a base64-encoded connection key.
+// CreateBase64ConnKey creates a base64-encoded connection key, overwriting an existing
+// one.
 func CreateBase64ConnKey(cfgDir string) error {
-	connKey := tm_ed25519.GenPrivKey()
-	encKey := make([]byte, base64.StdEncoding.EncodedLen(tm_ed25519.PrivateKeySize))
-	base64.StdEncoding.Encode(encKey, connKey)
+	_, err := GenConnKey(cfgDir, true)
+	return err
+}
+
+// RotateConnKey replaces the connection key in the given directory with a new one and
+// returns both. The old one is nil if it couldn't be loaded. The file is replaced
+// atomically, so it is never left unloadable, even on a crash. Established secret
+// connections aren't affected, as the connection key is loaded on every (re)dial.
+func RotateConnKey(cfgDir string) (oldKey tm_ed25519.PrivKey, newKey tm_ed25519.PrivKey, err error) {
+	oldKey, err = LoadConnKey(cfgDir)
+	if err != nil {
+		oldKey = nil
+	}
+	newKey = tm_ed25519.GenPrivKey()
+	if err := config.WriteFileAtomic(KeyFilePath(cfgDir), []byte(base64.StdEncoding.EncodeToString(newKey)), PermConnKeyFile); err != nil {
+		return nil, nil, err
+	}
+
+	return oldKey, newKey, nil
+}
+
+// loadConnKey loads the connection key like LoadConnKey and logs which one is used, so
+// that it can be checked against the key whitelisted on the validator.
+func loadConnKey(cfgDir string, logger *types.SyncLogger) (tm_ed25519.PrivKey, error) {
+	connKey, err := LoadConnKey(cfgDir)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't load conn.key: %w", err)
+	}
+	logger.Info("Using %v %v (public key %v)", KeyFile, ConnKeyFingerprint(connKey), ConnPubKey(connKey))
+
+	return connKey, nil
+}
+
+// ConnKeyFingerprint returns the fingerprint of the public key of the given connection
+// key, which identifies it in the logs without revealing it. It is "none" for a nil
+// key.
+func ConnKeyFingerprint(connKey tm_ed25519.PrivKey) string {
+	if connKey == nil {
+		return "none"
+	}
+	return PubKeyFingerprint(connKey.PubKey())
+}
+
+// PubKeyFingerprint returns the fingerprint of the given public key, i.e. the first 8
+// bytes of the SHA-256 hash of the key hex-encoded, which identifies a connection key
+// in the logs. For ed25519 keys, it is the lowercase prefix of the key's address.
+func PubKeyFingerprint(key tm_crypto.PubKey) string {
+	hash := sha256.Sum256(key.Bytes())
+	return hex.EncodeToString(hash[:8])
+}
+
+// ConnPubKey returns the base64-encoded public key of the given connection key, which
+// is what the validator needs to know to authenticate SignCTRL.
+func ConnPubKey(connKey tm_ed25519.PrivKey) string {
+	return base64.StdEncoding.EncodeToString(connKey.PubKey().Bytes())
+}
+
+// ParseConnPubKey parses the base64 or bech32 encoded public key of a connection key.
+func ParseConnPubKey(encKey string) (tm_ed25519.PubKey, error) {
+	decKey, err := config.DecodePubKey(encKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return decKey, nil
+}
+
+// ParseAuthorizedKeys parses the given base64 or bech32 encoded public keys of the
+// connection keys the validators are authorized to use.
+func ParseAuthorizedKeys(encKeys []string) ([]tm_crypto.PubKey, error) {
+	keys := make([]tm_crypto.PubKey, 0, len(encKeys))
+	for _, encKey := range encKeys {
+		key, err := ParseConnPubKey(encKey)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse authorized key %v: %w", encKey, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// authorize checks whether the remote side of the given secret connection uses one of
+// the given authorized keys. Any key is accepted if none are given.
+func authorize(secretConn *tm_p2pconn.SecretConnection, authorizedKeys []tm_crypto.PubKey) error {
+	if len(authorizedKeys) == 0 {
+		return nil
+	}
+	remoteKey := secretConn.RemotePubKey()
+	for _, key := range authorizedKeys {
+		if remoteKey.Equals(key) {
+			return nil
+		}
+	}
 
-	return ioutil.WriteFile(KeyFilePath(cfgDir), encKey, PermConnKeyFile)
+	return fmt.Errorf("%w %v", ErrUnknownConnKey, base64.StdEncoding.EncodeToString(remoteKey.Bytes()))
 }
@@ -1,10 +1,18 @@
 package connection
 
 import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
 
+	"github.com/BlockscapeNetwork/signctrl/types"
 	"github.com/stretchr/testify/assert"
+	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	tm_json "github.com/tendermint/tendermint/libs/json"
 )
 
 func TestKeyFilePath(t *testing.T) {
@@ -14,7 +22,7 @@ func TestKeyFilePath(t *testing.T) {
 
 func TestCreateAndLoadConnKey(t *testing.T) {
 	cfgDir := "./key_test_createandload"
-	err := os.MkdirAll(cfgDir, PermConnKeyFile)
+	err := os.MkdirAll(cfgDir, 0700)
 	assert.NoError(t, err)
 	defer os.RemoveAll(cfgDir)
 
@@ -31,3 +39,166 @@ func TestCreateAndLoadConnKey(t *testing.T) {
 	assert.NotNil(t, key)
 	assert.NoError(t, err)
 }
+
+func TestGenConnKey(t *testing.T) {
+	cfgDir := t.TempDir()
+
+	key, err := GenConnKey(cfgDir, false)
+	assert.NoError(t, err)
+	info, err := os.Stat(KeyFilePath(cfgDir))
+	assert.NoError(t, err)
+	assert.Equal(t, PermConnKeyFile, info.Mode().Perm())
+	loaded, err := LoadConnKey(cfgDir)
+	assert.NoError(t, err)
+	assert.Equal(t, key, loaded)
+	pub, err := ParseConnPubKey(ConnPubKey(key))
+	assert.NoError(t, err)
+	assert.Equal(t, key.PubKey(), pub)
+
+	// An existing key is only overwritten if forced.
+	_, err = GenConnKey(cfgDir, false)
+	assert.ErrorIs(t, err, ErrConnKeyExists)
+	loaded, err = LoadConnKey(cfgDir)
+	assert.NoError(t, err)
+	assert.Equal(t, key, loaded)
+
+	assert.NoError(t, os.Chmod(KeyFilePath(cfgDir), 0644))
+	newKey, err := GenConnKey(cfgDir, true)
+	assert.NoError(t, err)
+	assert.NotEqual(t, key, newKey)
+	info, err = os.Stat(KeyFilePath(cfgDir))
+	assert.NoError(t, err)
+	assert.Equal(t, PermConnKeyFile, info.Mode().Perm())
+}
+
+func TestLoadConnKey_Errors(t *testing.T) {
+	cfgDir := t.TempDir()
+
+	_, err := LoadConnKey(cfgDir)
+	assert.ErrorIs(t, err, ErrConnKeyNotFound)
+	assert.Contains(t, err.Error(), "signctrl keygen-conn")
+
+	assert.NoError(t, ioutil.WriteFile(KeyFilePath(cfgDir), []byte("not base64!"), PermConnKeyFile))
+	_, err = LoadConnKey(cfgDir)
+	assert.ErrorIs(t, err, ErrInvalidConnKey)
+
+	// The key must have the size of an ed25519 private key.
+	assert.NoError(t, ioutil.WriteFile(KeyFilePath(cfgDir), []byte("dGVzdA=="), PermConnKeyFile))
+	_, err = LoadConnKey(cfgDir)
+	assert.ErrorIs(t, err, ErrInvalidConnKey)
+	assert.Contains(t, err.Error(), "got 4 bytes")
+}
+
+func TestLoadConnKey_NodeKey(t *testing.T) {
+	cfgDir := t.TempDir()
+
+	// The fixture has been written by Tendermint's node key encoder.
+	fixture, err := ioutil.ReadFile("testdata/node_key.json")
+	assert.NoError(t, err)
+	assert.NoError(t, ioutil.WriteFile(KeyFilePath(cfgDir), fixture, PermConnKeyFile))
+	key, err := LoadConnKey(cfgDir)
+	assert.NoError(t, err)
+	assert.Equal(t, "27DD470664E227B19E66AA4D5329150FC2852212", key.PubKey().Address().String())
+
+	// Encoding the key again yields the same node key.
+	bz, err := tm_json.Marshal(nodeKey{PrivKey: key})
+	assert.NoError(t, err)
+	assert.Equal(t, string(fixture), string(bz))
+
+	// The same key in the base64 format is loaded the same.
+	assert.NoError(t, ioutil.WriteFile(KeyFilePath(cfgDir), []byte(base64.StdEncoding.EncodeToString(key)), PermConnKeyFile))
+	loaded, err := LoadConnKey(cfgDir)
+	assert.NoError(t, err)
+	assert.Equal(t, key, loaded)
+
+	// Node keys of any other type are rejected.
+	for _, invalid := range []string{
+		`{"priv_key":{"type":"tendermint/PrivKeySecp256k1","value":"dGVzdA=="}}`,
+		`{"priv_key":{"type":"tendermint/PrivKeyEd25519","value":"dGVzdA=="}}`,
+		`{"priv_key":`,
+	} {
+		assert.NoError(t, ioutil.WriteFile(KeyFilePath(cfgDir), []byte(invalid), PermConnKeyFile))
+		_, err = LoadConnKey(cfgDir)
+		assert.ErrorIs(t, err, ErrInvalidConnKey, invalid)
+	}
+}
+
+func TestRotateConnKey(t *testing.T) {
+	cfgDir := t.TempDir()
+
+	// A missing or broken key is replaced, too.
+	oldKey, newKey, err := RotateConnKey(cfgDir)
+	assert.NoError(t, err)
+	assert.Nil(t, oldKey)
+	assert.Equal(t, "none", ConnKeyFingerprint(oldKey))
+
+	oldKey, newKey2, err := RotateConnKey(cfgDir)
+	assert.NoError(t, err)
+	assert.Equal(t, newKey, oldKey)
+	assert.NotEqual(t, ConnKeyFingerprint(oldKey), ConnKeyFingerprint(newKey2))
+	loaded, err := LoadConnKey(cfgDir)
+	assert.NoError(t, err)
+	assert.Equal(t, newKey2, loaded)
+	info, err := os.Stat(KeyFilePath(cfgDir))
+	assert.NoError(t, err)
+	assert.Equal(t, PermConnKeyFile, info.Mode().Perm())
+
+	// No temporary files are left behind.
+	files, err := ioutil.ReadDir(cfgDir)
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestParseConnPubKey(t *testing.T) {
+	key, err := ParseConnPubKey("2KmYPwtTGfV5MqUWdRXC6bwS0NgxBG2+gCmgKEnjcFo=")
+	assert.NoError(t, err)
+	assert.Len(t, key, 32)
+
+	key, err = ParseConnPubKey("invalid")
+	assert.Nil(t, key)
+	assert.Error(t, err)
+
+	key, err = ParseConnPubKey("dGVzdA==")
+	assert.Nil(t, key)
+	assert.Error(t, err)
+}
+
+func TestParseAuthorizedKeys(t *testing.T) {
+	keys, err := ParseAuthorizedKeys([]string{
+		"2KmYPwtTGfV5MqUWdRXC6bwS0NgxBG2+gCmgKEnjcFo=",
+		"cosmosvalconspub1zcjduepqmz5es0ct2vvl27fj55t829wzax7p95xcxyzxm05q9xszsj0rwpdqgu87wh",
+	})
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.True(t, keys[0].Equals(keys[1]))
+
+	keys, err = ParseAuthorizedKeys([]string{"2KmYPwtTGfV5MqUWdRXC6bwS0NgxBG2+gCmgKEnjcFo=", "invalid"})
+	assert.Nil(t, keys)
+	assert.Error(t, err)
+}
+
+func TestConnKeyFingerprint(t *testing.T) {
+	// The key pair of the first test vector of RFC 8032.
+	seed, _ := hex.DecodeString("9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60")
+	pubKey, _ := hex.DecodeString("d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a")
+	connKey := tm_ed25519.PrivKey(append(seed, pubKey...))
+	assert.Equal(t, "21fe31dfa154a261", ConnKeyFingerprint(connKey))
+	assert.Equal(t, "21fe31dfa154a261", PubKeyFingerprint(tm_ed25519.PubKey(pubKey)))
+	assert.True(t, strings.HasPrefix(connKey.PubKey().Address().String(), strings.ToUpper(ConnKeyFingerprint(connKey))))
+	assert.Equal(t, "11qYAYKxCrfVS/7TyWQHOg7hcvPapiMlrwIaaPcHURo=", ConnPubKey(connKey))
+}
+
+func TestLoadConnKey_Logged(t *testing.T) {
+	cfgDir := t.TempDir()
+	var buf bytes.Buffer
+	logger := types.NewSyncLogger(&buf, "", 0)
+	_, err := loadConnKey(cfgDir, logger)
+	assert.ErrorIs(t, err, ErrConnKeyNotFound)
+	assert.Empty(t, buf.String())
+
+	connKey, err := GenConnKey(cfgDir, false)
+	assert.NoError(t, err)
+	_, err = loadConnKey(cfgDir, logger)
+	assert.NoError(t, err)
+	assert.Equal(t, "[INFO]  signctrl: Using conn.key "+ConnKeyFingerprint(connKey)+" (public key "+ConnPubKey(connKey)+")\n", buf.String())
+}
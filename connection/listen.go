@@ -0,0 +1,116 @@
+package connection
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	tm_crypto "github.com/tendermint/tendermint/crypto"
+	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	tm_p2pconn "github.com/tendermint/tendermint/p2p/conn"
+)
+
+const (
+	// HandshakeTimeout is the time after which an accepted connection is dropped if
+	// the secret connection handshake hasn't been completed.
+	HandshakeTimeout = 10 * time.Second
+
+	// PermSocketFile determines the file permissions for unix domain socket files
+	// SignCTRL listens on.
+	PermSocketFile = os.FileMode(0600)
+)
+
+// ErrUnknownConnKey is returned if the validator uses a connection key other than
+// the authorized ones.
+var ErrUnknownConnKey = errors.New("validator uses an unauthorized connection key")
+
+// Listen opens a listener on the given TCP or unix domain socket address for the
+// validator to dial. The address is parsed with config.ParseAddress. Unix domain
+// socket files are only accessible by the owner and are removed once the listener is
+// closed.
+func Listen(address string) (net.Listener, error) {
+	addr, err := config.ParseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if addr.Protocol == config.ProtocolTCP {
+		return net.Listen("tcp", addr.Addr)
+	}
+
+	// Remove the socket file left behind by an unclean shutdown.
+	path := addr.Addr
+	if fi, err := os.Stat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, PermSocketFile); err != nil {
+		listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+// upgradeConn establishes a secret connection on top of the given connection and
+// verifies that the validator uses one of the authorized connection keys. The
+// fingerprint of the validator's key is returned once the handshake is done, even if
+// the key isn't authorized.
+func upgradeConn(conn net.Conn, connKey tm_ed25519.PrivKey, authorizedKeys []tm_crypto.PubKey) (net.Conn, string, error) {
+	if err := conn.SetDeadline(time.Now().Add(HandshakeTimeout)); err != nil {
+		return nil, "", err
+	}
+	secretConn, err := tm_p2pconn.MakeSecretConnection(conn, connKey)
+	if err != nil {
+		return nil, "", err
+	}
+	fingerprint := PubKeyFingerprint(secretConn.RemotePubKey())
+	if err := authorize(secretConn, authorizedKeys); err != nil {
+		return nil, fingerprint, err
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		return nil, fingerprint, err
+	}
+
+	return withProbe(secretConn, conn), fingerprint, nil
+}
+
+// NewAcceptor starts accepting connections from validators on the given listener and
+// returns the Acceptor admitting a single one of them at a time. Only validators using
+// one of the authorized connection keys are admitted. If preempt is set, a newly
+// authenticated validator replaces the admitted one instead of being rejected.
+// Connections via unix domain sockets are only secret connections if secretUnixConn
+// is set. Otherwise, they are admitted as is, as the socket file is only accessible by
+// the owner. The connection key is loaded from the given config directory for every
+// handshake, so that a rotated key is used from the next connection on.
+func NewAcceptor(cfgDir string, listener net.Listener, secretUnixConn bool, authorizedKeys []tm_crypto.PubKey, preempt bool, logger *types.SyncLogger) (*Acceptor, error) {
+	logger.Info("Waiting for the validator to dial %v...", listener.Addr())
+	if _, ok := listener.(*net.UnixListener); ok && !secretUnixConn {
+		return newAcceptor(listener, func(conn net.Conn) (net.Conn, string, error) {
+			return conn, "none", nil
+		}, preempt, logger), nil
+	}
+
+	// Make sure the connection key needed to establish secret/encrypted connections to
+	// the validator exists before accepting any.
+	if _, err := loadConnKey(cfgDir, logger); err != nil {
+		return nil, err
+	}
+
+	return newAcceptor(listener, func(conn net.Conn) (net.Conn, string, error) {
+		connKey, err := LoadConnKey(cfgDir)
+		if err != nil {
+			return nil, "", fmt.Errorf("couldn't load conn.key: %v", err)
+		}
+		return upgradeConn(conn, connKey, authorizedKeys)
+	}, preempt, logger), nil
+}
@@ -0,0 +1,173 @@
+package connection
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	tm_crypto "github.com/tendermint/tendermint/crypto"
+	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	tm_p2pconn "github.com/tendermint/tendermint/p2p/conn"
+)
+
+// dialMockValidator dials the given address like a validator does, using the given
+// connection key for the secret connection.
+func dialMockValidator(t *testing.T, address string, connKey tm_ed25519.PrivKey) (net.Conn, error) {
+	t.Helper()
+	conn, err := net.Dial("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	return tm_p2pconn.MakeSecretConnection(conn, connKey)
+}
+
+func TestNewAcceptor(t *testing.T) {
+	cfgDir := "./test_retry_accept"
+	err := os.MkdirAll(cfgDir, 0700)
+	assert.NoError(t, err)
+	defer os.RemoveAll(cfgDir)
+
+	err = CreateBase64ConnKey(cfgDir)
+	assert.NoError(t, err)
+
+	listener, err := Listen("tcp://127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	validatorKey := tm_ed25519.GenPrivKey()
+	go func() {
+		// The first connection uses an unknown key and is rejected.
+		if conn, err := dialMockValidator(t, listener.Addr().String(), tm_ed25519.GenPrivKey()); err == nil {
+			defer conn.Close()
+		}
+		if conn, err := dialMockValidator(t, listener.Addr().String(), validatorKey); err == nil {
+			defer conn.Close()
+		}
+	}()
+
+	acceptor, err := NewAcceptor(cfgDir, listener, false, []tm_crypto.PubKey{validatorKey.PubKey()}, false, types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.NoError(t, err)
+	conn, err := acceptor.Next(context.Background())
+	assert.NoError(t, err)
+	assert.NotNil(t, conn)
+	assert.True(t, conn.(*admittedConn).Conn.(*probeConn).Conn.(*tm_p2pconn.SecretConnection).RemotePubKey().Equals(validatorKey.PubKey()))
+}
+
+func TestNewAcceptor_ClosedListener(t *testing.T) {
+	cfgDir := "./test_retry_accept_closed"
+	err := os.MkdirAll(cfgDir, 0700)
+	assert.NoError(t, err)
+	defer os.RemoveAll(cfgDir)
+
+	err = CreateBase64ConnKey(cfgDir)
+	assert.NoError(t, err)
+
+	listener, err := Listen("tcp://127.0.0.1:0")
+	assert.NoError(t, err)
+	listener.Close()
+
+	acceptor, err := NewAcceptor(cfgDir, listener, false, []tm_crypto.PubKey{tm_ed25519.GenPrivKey().PubKey()}, false, types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.NoError(t, err)
+	conn, err := acceptor.Next(context.Background())
+	assert.Nil(t, conn)
+	assert.Error(t, err)
+}
+
+func TestNewAcceptor_NoConnKey(t *testing.T) {
+	listener, err := Listen("tcp://127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	acceptor, err := NewAcceptor("./test_retry_accept_noconnkey", listener, false, []tm_crypto.PubKey{tm_ed25519.GenPrivKey().PubKey()}, false, types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.Nil(t, acceptor)
+	assert.Error(t, err)
+}
+
+func TestListenUnix(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "signctrl.sock")
+
+	// Leave a stale socket file behind, like an unclean shutdown does.
+	stale, err := net.Listen("unix", sockPath)
+	assert.NoError(t, err)
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+	_, err = os.Stat(sockPath)
+	assert.NoError(t, err)
+
+	listener, err := Listen("unix://" + sockPath)
+	assert.NoError(t, err)
+	fi, err := os.Stat(sockPath)
+	assert.NoError(t, err)
+	assert.Equal(t, PermSocketFile, fi.Mode().Perm())
+
+	// The socket file is cleaned up on shutdown.
+	err = listener.Close()
+	assert.NoError(t, err)
+	_, err = os.Stat(sockPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestListenUnix_NoSocketFile(t *testing.T) {
+	// Regular files are never removed.
+	path := filepath.Join(t.TempDir(), "signctrl.sock")
+	err := ioutil.WriteFile(path, []byte("test"), 0600)
+	assert.NoError(t, err)
+
+	listener, err := Listen("unix://" + path)
+	assert.Nil(t, listener)
+	assert.Error(t, err)
+}
+
+func TestNewAcceptorUnix(t *testing.T) {
+	sockPath := filepath.Join(t.TempDir(), "signctrl.sock")
+	listener, err := Listen("unix://" + sockPath)
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	go func() {
+		if conn, err := net.Dial("unix", sockPath); err == nil {
+			defer conn.Close()
+		}
+	}()
+
+	// No secret connection is established, so no conn.key is needed.
+	acceptor, err := NewAcceptor("./test_retry_accept_unix", listener, false, nil, false, types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.NoError(t, err)
+	conn, err := acceptor.Next(context.Background())
+	assert.NoError(t, err)
+	assert.IsType(t, &net.UnixConn{}, conn.(*admittedConn).Conn)
+}
+
+func TestNewAcceptorUnix_Secret(t *testing.T) {
+	cfgDir := t.TempDir()
+	err := CreateBase64ConnKey(cfgDir)
+	assert.NoError(t, err)
+
+	sockPath := filepath.Join(cfgDir, "signctrl.sock")
+	listener, err := Listen("unix://" + sockPath)
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	validatorKey := tm_ed25519.GenPrivKey()
+	go func() {
+		conn, err := net.Dial("unix", sockPath)
+		if err != nil {
+			return
+		}
+		if secretConn, err := tm_p2pconn.MakeSecretConnection(conn, validatorKey); err == nil {
+			defer secretConn.Close()
+		}
+	}()
+
+	acceptor, err := NewAcceptor(cfgDir, listener, true, []tm_crypto.PubKey{validatorKey.PubKey()}, false, types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.NoError(t, err)
+	conn, err := acceptor.Next(context.Background())
+	assert.NoError(t, err)
+	assert.IsType(t, &tm_p2pconn.SecretConnection{}, conn.(*admittedConn).Conn.(*probeConn).Conn)
+}
@@ -0,0 +1,67 @@
+package connection
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	tm_crypto "github.com/tendermint/tendermint/crypto"
+)
+
+// QueryPeer dials the SignCTRL node of the set at the given host:port, establishes a
+// secret connection with the connection key loaded from the given config directory and
+// exchanges the given hello with it, returning the peer's. The peer must use the given
+// public key. Both the dial and the exchange are given up after the given timeout.
+func QueryPeer(ctx context.Context, cfgDir, address string, peerKey tm_crypto.PubKey, hello *Hello, timeout time.Duration) (*Hello, error) {
+	connKey, err := LoadConnKey(cfgDir)
+	if err != nil {
+		return nil, err
+	}
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	secretConn, err := handshake(ctx, conn, connKey, []tm_crypto.PubKey{peerKey}, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer secretConn.Close()
+
+	return ExchangeHello(secretConn, hello, timeout)
+}
+
+// ServePeers answers the hellos of the other SignCTRL nodes of the set on the given
+// listener until it is closed. Every connection is upgraded to a secret connection with
+// the connection key loaded from the given config directory, and only peers using one
+// of the given public keys are answered, with the hello returned by hello. The hello
+// of every peer is passed to onHello, e.g. to compare ranks.
+func ServePeers(listener net.Listener, cfgDir string, peerKeys []tm_crypto.PubKey, hello func() *Hello, onHello func(remote net.Addr, peer *Hello), logger *types.SyncLogger) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			connKey, err := LoadConnKey(cfgDir)
+			if err != nil {
+				logger.Error("couldn't load the connection key to answer peer %v: %v", conn.RemoteAddr(), err)
+				return
+			}
+			secretConn, fingerprint, err := upgradeConn(conn, connKey, peerKeys)
+			if err != nil {
+				logger.Warn("Rejected peer connection from %v (key %v): %v", conn.RemoteAddr(), fingerprint, err)
+				return
+			}
+			defer secretConn.Close()
+			peer, err := ExchangeHello(secretConn, hello(), HandshakeTimeout)
+			if err != nil {
+				logger.Warn("Hello with peer %v failed: %v", conn.RemoteAddr(), err)
+				return
+			}
+			onHello(conn.RemoteAddr(), peer)
+		}()
+	}
+}
@@ -0,0 +1,84 @@
+package connection
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	tm_crypto "github.com/tendermint/tendermint/crypto"
+)
+
+// testPeer serves the hello with the given rank on a new listener as a peer with its
+// own connection key, answering only the given keys. It returns the peer's address,
+// its public key and the hellos it receives.
+func testPeer(t *testing.T, rank int64, peerKeys []tm_crypto.PubKey) (string, tm_crypto.PubKey, <-chan *Hello) {
+	t.Helper()
+	cfgDir := t.TempDir()
+	connKey, err := GenConnKey(cfgDir, false)
+	assert.NoError(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan *Hello, 1)
+	go ServePeers(listener, cfgDir, peerKeys, func() *Hello {
+		return &Hello{Version: "v1.0.0", Rank: rank, ChainID: "testchain"}
+	}, func(_ net.Addr, peer *Hello) {
+		received <- peer
+	}, types.NewSyncLogger(ioutil.Discard, "", 0))
+
+	return listener.Addr().String(), connKey.PubKey(), received
+}
+
+func TestQueryPeer(t *testing.T) {
+	cfgDir := t.TempDir()
+	connKey, err := GenConnKey(cfgDir, false)
+	assert.NoError(t, err)
+	address, peerKey, received := testPeer(t, 2, []tm_crypto.PubKey{connKey.PubKey()})
+
+	// Both sides learn each other's rank.
+	hello, err := QueryPeer(context.Background(), cfgDir, address, peerKey, &Hello{Version: "v1.0.0", Rank: 1, ChainID: "testchain"}, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), hello.Rank)
+	select {
+	case peer := <-received:
+		assert.Equal(t, int64(1), peer.Rank)
+	case <-time.After(time.Second):
+		t.Fatal("the peer didn't receive the hello")
+	}
+}
+
+func TestQueryPeer_UnknownKey(t *testing.T) {
+	cfgDir := t.TempDir()
+	connKey, err := GenConnKey(cfgDir, false)
+	assert.NoError(t, err)
+
+	// The peer must use the configured key.
+	address, _, _ := testPeer(t, 2, []tm_crypto.PubKey{connKey.PubKey()})
+	_, err = QueryPeer(context.Background(), cfgDir, address, connKey.PubKey(), &Hello{ChainID: "testchain"}, time.Second)
+	assert.ErrorIs(t, err, ErrUnknownConnKey)
+
+	// Nodes that aren't in the peer's list aren't answered.
+	otherKey, err := GenConnKey(t.TempDir(), false)
+	assert.NoError(t, err)
+	address, peerKey, received := testPeer(t, 2, []tm_crypto.PubKey{otherKey.PubKey()})
+	_, err = QueryPeer(context.Background(), cfgDir, address, peerKey, &Hello{ChainID: "testchain"}, time.Second)
+	assert.Error(t, err)
+	assert.Empty(t, received)
+}
+
+func TestQueryPeer_Unreachable(t *testing.T) {
+	cfgDir := t.TempDir()
+	connKey, err := GenConnKey(cfgDir, false)
+	assert.NoError(t, err)
+	port, err := getFreePort(t)
+	assert.NoError(t, err)
+
+	_, err = QueryPeer(context.Background(), cfgDir, net.JoinHostPort("127.0.0.1", fmt.Sprint(port)), connKey.PubKey(), &Hello{ChainID: "testchain"}, time.Second)
+	assert.Error(t, err)
+}
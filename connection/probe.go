@@ -0,0 +1,42 @@
+package connection
+
+import (
+	"net"
+	"time"
+)
+
+// probeConn is a secret or TLS connection that keeps the raw connection underneath it,
+// so that it can be probed.
+type probeConn struct {
+	net.Conn
+	raw net.Conn
+}
+
+// withProbe returns the given connection on top of the given raw connection, so that
+// the raw connection can be probed.
+func withProbe(conn, raw net.Conn) net.Conn {
+	return &probeConn{Conn: conn, raw: raw}
+}
+
+// unwrap returns the raw connection.
+func (c *probeConn) unwrap() net.Conn {
+	return c.raw
+}
+
+// Probe checks whether the given connection is still alive without sending any data
+// to the validator, as Tendermint's protocol doesn't let the signer send messages on
+// its own. It writes zero bytes to the raw connection underneath the secret or TLS
+// connection, which fails if the operating system has found the connection broken,
+// e.g. via TCP keepalive, or if a write has been stuck on it for longer than the given
+// timeout.
+func Probe(conn net.Conn, timeout time.Duration) error {
+	conn = rawConn(conn)
+	if timeout > 0 {
+		if err := conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+			return err
+		}
+	}
+	_, err := conn.Write(nil)
+
+	return err
+}
@@ -0,0 +1,46 @@
+package connection
+
+import (
+	"errors"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProbe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			defer conn.Close()
+			_, _ = conn.Read(make([]byte, 1))
+		}
+	}()
+	raw, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+
+	// The raw connection is probed through all wrappers, and an expired write
+	// deadline doesn't fail the probe.
+	var stats Stats
+	conn := stats.Wrap(withProbe(&net.TCPConn{}, raw))
+	assert.NoError(t, raw.SetWriteDeadline(time.Now().Add(-time.Second)))
+	assert.NoError(t, Probe(conn, time.Second))
+	assert.Zero(t, stats.Snapshot().BytesWritten)
+
+	// A closed connection fails the probe.
+	raw.Close()
+	assert.Error(t, Probe(conn, time.Second))
+}
+
+func TestProbe_Stuck(t *testing.T) {
+	// Nobody reads from the other end of the pipe, so the probe is stuck.
+	local, remote := net.Pipe()
+	defer remote.Close()
+	defer local.Close()
+	err := Probe(local, 50*time.Millisecond)
+	assert.True(t, errors.Is(err, os.ErrDeadlineExceeded))
+}
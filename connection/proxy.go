@@ -0,0 +1,132 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	tm_crypto "github.com/tendermint/tendermint/crypto"
+	"golang.org/x/net/proxy"
+)
+
+// proxyForward dials the SOCKS5 proxy itself and keeps the raw connection to it, so that
+// TCP keepalive can be set up on it. The whole SOCKS5 handshake is aborted after the
+// timeout, unless it is 0.
+type proxyForward struct {
+	timeout time.Duration
+	conn    net.Conn
+}
+
+// Dial dials the SOCKS5 proxy at the given address.
+// Implements the proxy.Dialer interface.
+func (f *proxyForward) Dial(network, address string) (net.Conn, error) {
+	return f.DialContext(context.Background(), network, address)
+}
+
+// DialContext dials the SOCKS5 proxy at the given address, unless the given context
+// is canceled first.
+// Implements the proxy.ContextDialer interface.
+func (f *proxyForward) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	// The dialer's own keepalive is disabled, as it is set up explicitly.
+	dialer := net.Dialer{Timeout: f.timeout, KeepAlive: -1}
+	conn, err := dialer.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	if f.timeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(f.timeout)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	f.conn = conn
+
+	return conn, nil
+}
+
+// dialProxy dials the given host:port through the SOCKS5 proxy at the given URL. The
+// host is passed to the proxy as is, so hostnames are resolved by the proxy. Errors
+// reaching the proxy and errors in the SOCKS5 handshake, e.g. if the proxy can't reach
+// the host, are reported as such. Both are aborted once the given context is canceled.
+func dialProxy(ctx context.Context, proxyURL, hostPort string, policy RetryPolicy) (net.Conn, error) {
+	u, err := config.ParseProxyURL(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	var auth *proxy.Auth
+	if u.User != nil {
+		password, _ := u.User.Password()
+		auth = &proxy.Auth{User: u.User.Username(), Password: password}
+	}
+	forward := &proxyForward{timeout: policy.DialTimeout}
+	dialer, err := proxy.SOCKS5("tcp", u.Host, auth, forward)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := dialer.(proxy.ContextDialer).DialContext(ctx, "tcp", hostPort)
+	if err != nil {
+		if forward.conn == nil {
+			return nil, fmt.Errorf("couldn't reach proxy %v: %w", u.Host, err)
+		}
+		return nil, fmt.Errorf("SOCKS5 handshake with proxy %v failed: %w", u.Host, err)
+	}
+
+	return conn, nil
+}
+
+// RetryDialProxy keeps dialing the given TCP address through the SOCKS5 proxy at the
+// given URL in the intervals of the given policy until success and returns the secret
+// connection established through the proxy. The address' host may be a hostname,
+// which is resolved by the proxy. Secret connections are dropped without retrying if
+// the validator doesn't use one of the given authorized keys. If none are given, any
+// key is accepted. If the policy is exhausted, a RetryExhaustedError is returned.
+// Dialing is aborted once the given context is canceled, which returns the context's
+// error.
+func RetryDialProxy(ctx context.Context, cfgDir, address, proxyURL string, authorizedKeys []tm_crypto.PubKey, policy RetryPolicy, logger *types.SyncLogger) (net.Conn, error) {
+	addr, err := config.ParseHostAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if addr.Protocol != config.ProtocolTCP {
+		return nil, errors.New("proxies are only supported for TCP addresses")
+	}
+	u, err := config.ParseProxyURL(proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %v", err)
+	}
+	address = addr.String()
+	logger.Info("Dialing %v via proxy %v... (Use Ctrl+C to abort)", address, u.Host)
+	if len(authorizedKeys) == 0 {
+		logger.Warn("The validator at %v isn't authenticated, as authorized_keys is empty! Anyone listening on this address receives the sign requests, so add the validator's connection key to authorized_keys!", address)
+	}
+	connKey, err := loadConnKey(cfgDir, logger)
+	if err != nil {
+		return nil, err
+	}
+	keepAlive := false
+	conn, err := retry(ctx, address, policy, logger, func(ctx context.Context) (net.Conn, error) {
+		conn, err := dialProxy(ctx, proxyURL, addr.Addr, policy)
+		if err != nil {
+			return nil, err
+		}
+		if keepAlive, err = setKeepAlive(conn, policy.KeepAlivePeriod); err != nil {
+			logger.Warn("couldn't set TCP keepalive on connection to proxy %v: %v", u.Host, err)
+		}
+		return handshake(ctx, conn, connKey, authorizedKeys, policy.DialTimeout)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if keepAlive {
+		logger.Info("Successfully dialed the validator via proxy %v ✓ (TCP keepalive every %v)", u.Host, policy.KeepAlivePeriod)
+	} else {
+		logger.Info("Successfully dialed the validator via proxy %v ✓ (TCP keepalive disabled)", u.Host)
+	}
+	return conn, nil
+}
@@ -0,0 +1,232 @@
+package connection
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	tm_crypto "github.com/tendermint/tendermint/crypto"
+	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+)
+
+// testSOCKS5Server is an in-process SOCKS5 proxy with username/password authentication
+// that resolves hostnames itself via its hosts map.
+type testSOCKS5Server struct {
+	listener net.Listener
+	hosts    map[string]string
+
+	mtx       sync.Mutex
+	requested []string
+}
+
+// newTestSOCKS5Server starts a new testSOCKS5Server resolving the given hosts.
+func newTestSOCKS5Server(t *testing.T, hosts map[string]string) *testSOCKS5Server {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	s := &testSOCKS5Server{listener: listener, hosts: hosts}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go s.handle(conn)
+		}
+	}()
+	t.Cleanup(func() { listener.Close() })
+
+	return s
+}
+
+// handle serves a single SOCKS5 CONNECT request on the given connection.
+func (s *testSOCKS5Server) handle(conn net.Conn) {
+	target, err := s.connect(conn)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go func() {
+		_, _ = io.Copy(target, conn)
+		target.Close()
+	}()
+	_, _ = io.Copy(conn, target)
+	conn.Close()
+}
+
+// connect performs the SOCKS5 handshake on the given connection and returns the
+// connection to the requested host.
+func (s *testSOCKS5Server) connect(conn net.Conn) (net.Conn, error) {
+	// Only username/password authentication with signctrl:secret is accepted.
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(conn, make([]byte, header[1])); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write([]byte{0x05, 0x02}); err != nil {
+		return nil, err
+	}
+	creds := make([]string, 2)
+	if _, err := io.ReadFull(conn, make([]byte, 1)); err != nil {
+		return nil, err
+	}
+	for i := range creds {
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return nil, err
+		}
+		c := make([]byte, l[0])
+		if _, err := io.ReadFull(conn, c); err != nil {
+			return nil, err
+		}
+		creds[i] = string(c)
+	}
+	if creds[0] != "signctrl" || creds[1] != "secret" {
+		_, _ = conn.Write([]byte{0x01, 0x01})
+		return nil, errors.New("authentication failed")
+	}
+	if _, err := conn.Write([]byte{0x01, 0x00}); err != nil {
+		return nil, err
+	}
+
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(conn, req); err != nil {
+		return nil, err
+	}
+	var host string
+	switch req[3] {
+	case 0x01:
+		ip := make([]byte, net.IPv4len)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return nil, err
+		}
+		host = net.IP(ip).String()
+	case 0x03:
+		l := make([]byte, 1)
+		if _, err := io.ReadFull(conn, l); err != nil {
+			return nil, err
+		}
+		name := make([]byte, l[0])
+		if _, err := io.ReadFull(conn, name); err != nil {
+			return nil, err
+		}
+		host = string(name)
+	default:
+		return nil, errors.New("unsupported address type")
+	}
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return nil, err
+	}
+	s.mtx.Lock()
+	s.requested = append(s.requested, host)
+	s.mtx.Unlock()
+
+	reply := []byte{0x05, 0x00, 0x00, 0x01, 0, 0, 0, 0, 0, 0}
+	addr, ok := s.hosts[host]
+	if !ok {
+		// Host unreachable.
+		reply[1] = 0x04
+		_, _ = conn.Write(reply)
+		return nil, errors.New("unknown host")
+	}
+	target, err := net.Dial("tcp", net.JoinHostPort(addr, strconv.Itoa(int(binary.BigEndian.Uint16(port)))))
+	if err != nil {
+		reply[1] = 0x05
+		_, _ = conn.Write(reply)
+		return nil, err
+	}
+	if _, err := conn.Write(reply); err != nil {
+		target.Close()
+		return nil, err
+	}
+
+	return target, nil
+}
+
+// requestedHosts returns the hosts requested from the proxy so far.
+func (s *testSOCKS5Server) requestedHosts() []string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return append([]string(nil), s.requested...)
+}
+
+func TestRetryDialProxy(t *testing.T) {
+	cfgDir := "./test_dial_proxy"
+	assert.NoError(t, os.MkdirAll(cfgDir, 0700))
+	defer os.RemoveAll(cfgDir)
+	assert.NoError(t, CreateBase64ConnKey(cfgDir))
+
+	validatorKey := tm_ed25519.GenPrivKey()
+	validator, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer validator.Close()
+	go startEchoSecretServer(validator, validatorKey)
+	_, port, _ := net.SplitHostPort(validator.Addr().String())
+
+	// The hostname is resolved by the proxy only.
+	proxy := newTestSOCKS5Server(t, map[string]string{"validator.internal": "127.0.0.1"})
+	proxyURL := "socks5h://signctrl:secret@" + proxy.listener.Addr().String()
+	conn, err := RetryDialProxy(context.Background(), cfgDir, "tcp://validator.internal:"+port, proxyURL, []tm_crypto.PubKey{validatorKey.PubKey()}, DefaultRetryPolicy(), types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, []string{"validator.internal"}, proxy.requestedHosts())
+
+	msg := make([]byte, 4096)
+	_, _ = rand.Read(msg)
+	go func() { _, _ = conn.Write(msg) }()
+	echo := make([]byte, len(msg))
+	assert.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = io.ReadFull(conn, echo)
+	assert.NoError(t, err)
+	assert.Equal(t, msg, echo)
+}
+
+func TestRetryDialProxy_Errors(t *testing.T) {
+	cfgDir := "./test_dial_proxy_errors"
+	assert.NoError(t, os.MkdirAll(cfgDir, 0700))
+	defer os.RemoveAll(cfgDir)
+	assert.NoError(t, CreateBase64ConnKey(cfgDir))
+	logger := types.NewSyncLogger(ioutil.Discard, "", 0)
+	policy := RetryPolicy{InitialInterval: time.Millisecond, Multiplier: 1, MaxAttempts: 2, DialTimeout: time.Second}
+
+	// The proxy itself can't be reached.
+	port, _ := getFreePort(t)
+	conn, err := RetryDialProxy(context.Background(), cfgDir, "tcp://127.0.0.1:3000", "socks5://127.0.0.1:"+strconv.Itoa(port), nil, policy, logger)
+	assert.Nil(t, conn)
+	assert.ErrorIs(t, err, ErrRetryExhausted)
+	assert.Contains(t, err.Error(), "couldn't reach proxy")
+
+	// The proxy can't reach the validator.
+	proxy := newTestSOCKS5Server(t, nil)
+	conn, err = RetryDialProxy(context.Background(), cfgDir, "tcp://validator.internal:3000", "socks5://signctrl:secret@"+proxy.listener.Addr().String(), nil, policy, logger)
+	assert.Nil(t, conn)
+	assert.ErrorIs(t, err, ErrRetryExhausted)
+	assert.Contains(t, err.Error(), "SOCKS5 handshake with proxy")
+	assert.Contains(t, err.Error(), "host unreachable")
+
+	// The proxy rejects the credentials.
+	conn, err = RetryDialProxy(context.Background(), cfgDir, "tcp://validator.internal:3000", "socks5://signctrl:wrong@"+proxy.listener.Addr().String(), nil, policy, logger)
+	assert.Nil(t, conn)
+	assert.ErrorIs(t, err, ErrRetryExhausted)
+	assert.Contains(t, err.Error(), "SOCKS5 handshake with proxy")
+
+	// Invalid proxy URL and unix domain socket addresses.
+	_, err = RetryDialProxy(context.Background(), cfgDir, "tcp://127.0.0.1:3000", "http://127.0.0.1:8080", nil, policy, logger)
+	assert.Error(t, err)
+	_, err = RetryDialProxy(context.Background(), cfgDir, "unix:///tmp/validator.sock", "socks5://127.0.0.1:1080", nil, policy, logger)
+	assert.Error(t, err)
+}
@@ -0,0 +1,67 @@
+package connection
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+)
+
+// Resolver looks up the IP addresses of hostnames. It is implemented by
+// *net.Resolver.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// dialTCP dials the given host:port with the given dialer. If the host is a hostname,
+// it is resolved with the given resolver, or net.DefaultResolver if nil, on every call,
+// so that a validator whose IP has changed is found at the new one. Each of its IP
+// addresses is tried in order until one of them accepts the connection, and the chosen
+// one is logged. The dialer's timeout applies to each IP address separately.
+func dialTCP(ctx context.Context, dialer *net.Dialer, resolver Resolver, hostPort string, logger *types.SyncLogger) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return nil, err
+	}
+	if isIPLiteral(host) {
+		return dialer.DialContext(ctx, "tcp", hostPort)
+	}
+
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	ips, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't resolve %v: %w", host, err)
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("couldn't resolve %v: no IP addresses found", host)
+	}
+	var lastErr error
+	for i, ip := range ips {
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(ip.String(), port))
+		if err == nil {
+			logger.Info("Resolved %v to %v (%v/%v)", host, ip.String(), i+1, len(ips))
+			return conn, nil
+		}
+		logger.Debug("Couldn't dial %v at %v: %v", host, ip.String(), err)
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return nil, lastErr
+}
+
+// isIPLiteral checks whether the given host is an IP address, including IPv6 addresses
+// with a zone, e.g. fe80::1%eth0.
+func isIPLiteral(host string) bool {
+	if i := strings.LastIndex(host, "%"); i >= 0 {
+		host = host[:i]
+	}
+
+	return net.ParseIP(host) != nil
+}
@@ -0,0 +1,140 @@
+package connection
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeResolver returns the next list of IP addresses on every lookup, and the last one
+// once all have been returned.
+type fakeResolver struct {
+	mtx     sync.Mutex
+	lookups int
+	ips     [][]string
+	err     error
+}
+
+// LookupIPAddr implements the Resolver interface.
+func (r *fakeResolver) LookupIPAddr(_ context.Context, _ string) ([]net.IPAddr, error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	if r.err != nil {
+		return nil, r.err
+	}
+	ips := r.ips[r.lookups]
+	if r.lookups < len(r.ips)-1 {
+		r.lookups++
+	}
+	var addrs []net.IPAddr
+	for _, ip := range ips {
+		addrs = append(addrs, net.IPAddr{IP: net.ParseIP(ip)})
+	}
+
+	return addrs, nil
+}
+
+// acceptAll accepts connections on the given listener until it is closed and sends the
+// local address of each of them to the given channel.
+func acceptAll(listener net.Listener, accepted chan<- string) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		accepted <- conn.LocalAddr().String()
+		conn.Close()
+	}
+}
+
+func TestDialTCP_Resolve(t *testing.T) {
+	// Listen on the same port of two loopback addresses, as the validator before and
+	// after its IP has changed.
+	first, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer first.Close()
+	port := first.Addr().(*net.TCPAddr).Port
+	second, err := net.Listen("tcp", fmt.Sprintf("127.0.0.2:%v", port))
+	if err != nil {
+		t.Skipf("can't listen on 127.0.0.2: %v", err)
+	}
+	defer second.Close()
+	accepted := make(chan string, 2)
+	go acceptAll(first, accepted)
+	go acceptAll(second, accepted)
+
+	// Every dial resolves the hostname again.
+	resolver := &fakeResolver{ips: [][]string{{"127.0.0.1"}, {"127.0.0.2"}}}
+	var buf bytes.Buffer
+	logger := types.NewSyncLogger(&buf, "", 0)
+	dialer := &net.Dialer{Timeout: time.Second}
+	hostPort := fmt.Sprintf("validator.example.com:%v", port)
+	for _, ip := range []string{"127.0.0.1", "127.0.0.2"} {
+		conn, err := dialTCP(context.Background(), dialer, resolver, hostPort, logger)
+		assert.NoError(t, err)
+		conn.Close()
+		assert.Equal(t, fmt.Sprintf("%v:%v", ip, port), <-accepted)
+		assert.Contains(t, buf.String(), fmt.Sprintf("Resolved validator.example.com to %v", ip))
+	}
+	assert.Equal(t, 1, resolver.lookups)
+}
+
+func TestDialTCP_MultipleRecords(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+	accepted := make(chan string, 1)
+	go acceptAll(listener, accepted)
+
+	// Nothing listens on the first two IP addresses, so the last one is tried.
+	resolver := &fakeResolver{ips: [][]string{{"::1", "127.0.0.3", "127.0.0.1"}}}
+	logger := types.NewSyncLogger(ioutil.Discard, "", 0)
+	conn, err := dialTCP(context.Background(), &net.Dialer{Timeout: time.Second}, resolver, fmt.Sprintf("validator.example.com:%v", port), logger)
+	assert.NoError(t, err)
+	conn.Close()
+	assert.Equal(t, fmt.Sprintf("127.0.0.1:%v", port), <-accepted)
+
+	// The last error is returned if none of them can be dialed.
+	resolver = &fakeResolver{ips: [][]string{{"127.0.0.3"}}}
+	_, err = dialTCP(context.Background(), &net.Dialer{Timeout: time.Second}, resolver, fmt.Sprintf("validator.example.com:%v", port), logger)
+	assert.Error(t, err)
+
+	// Failed lookups are returned as is, so that they are retried.
+	resolver = &fakeResolver{err: errors.New("no such host")}
+	_, err = dialTCP(context.Background(), &net.Dialer{Timeout: time.Second}, resolver, fmt.Sprintf("validator.example.com:%v", port), logger)
+	assert.EqualError(t, err, "couldn't resolve validator.example.com: no such host")
+}
+
+func TestDialTCP_IPLiteral(t *testing.T) {
+	listener, err := net.Listen("tcp", "[::1]:0")
+	if err != nil {
+		t.Skipf("can't listen on ::1: %v", err)
+	}
+	defer listener.Close()
+	accepted := make(chan string, 1)
+	go acceptAll(listener, accepted)
+
+	// IP addresses are never resolved.
+	resolver := &fakeResolver{err: errors.New("unexpected lookup")}
+	conn, err := dialTCP(context.Background(), &net.Dialer{Timeout: time.Second}, resolver, listener.Addr().String(), types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.NoError(t, err)
+	conn.Close()
+	assert.Equal(t, listener.Addr().String(), <-accepted)
+}
+
+func TestIsIPLiteral(t *testing.T) {
+	assert.True(t, isIPLiteral("127.0.0.1"))
+	assert.True(t, isIPLiteral("::1"))
+	assert.True(t, isIPLiteral("fe80::1%eth0"))
+	assert.False(t, isIPLiteral("validator.example.com"))
+}
@@ -0,0 +1,129 @@
+package connection
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+var (
+	// ErrRetryExhausted is returned if the validator couldn't be dialed within the
+	// attempts or the time the RetryPolicy allows.
+	ErrRetryExhausted = errors.New("gave up dialing the validator")
+)
+
+// RetryPolicy determines how often the validator is dialed until a connection is
+// established. The first dial is always done immediately. After that, the interval
+// starts out at InitialInterval and grows by Multiplier after every failed attempt,
+// up to MaxInterval. Every interval is randomized by up to Jitter (a fraction of it)
+// in both directions, so that several nodes don't dial in lockstep.
+type RetryPolicy struct {
+	InitialInterval time.Duration
+	Multiplier      float64
+	MaxInterval     time.Duration
+	Jitter          float64
+
+	// MaxAttempts is the number of dials after which dialing is given up. 0 dials
+	// forever.
+	MaxAttempts int
+
+	// MaxElapsed is the time after which dialing is given up. 0 dials forever.
+	MaxElapsed time.Duration
+
+	// DialTimeout is the time after which an attempt is aborted, including the
+	// handshake of a secret connection, so that a peer that accepts the connection
+	// but never responds doesn't block dialing. 0 never aborts an attempt.
+	DialTimeout time.Duration
+
+	// KeepAlivePeriod is the period of the TCP keepalive probes on connections via
+	// TCP, so that stateful firewalls don't drop idle connections unnoticed. 0
+	// disables them.
+	KeepAlivePeriod time.Duration
+
+	// Resolver resolves hostnames of validators on every attempt. If nil,
+	// net.DefaultResolver is used.
+	Resolver Resolver
+
+	// LogInterval is the interval at which failed attempts are summarized after the
+	// first one has been logged. 0 logs every failed attempt.
+	LogInterval time.Duration
+
+	// Stats records every dial attempt and the time the last successful one took,
+	// if set.
+	Stats *Stats
+}
+
+// DefaultRetryPolicy returns the policy used if none is configured, which backs off
+// from 1 second up to 30 seconds, aborts attempts after 5 seconds and never gives up.
+// TCP keepalive probes are sent every 30 seconds, and failed attempts are summarized
+// every minute.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		InitialInterval: time.Second,
+		Multiplier:      2,
+		MaxInterval:     30 * time.Second,
+		Jitter:          0.2,
+		DialTimeout:     5 * time.Second,
+		KeepAlivePeriod: 30 * time.Second,
+		LogInterval:     time.Minute,
+	}
+}
+
+// interval returns the time to wait before the given attempt, which is counted from
+// 1. The first attempt is never delayed.
+func (p RetryPolicy) interval(attempt int) time.Duration {
+	if attempt <= 1 {
+		return 0
+	}
+	d := float64(p.InitialInterval) * math.Pow(math.Max(p.Multiplier, 1), float64(attempt-2))
+	if p.MaxInterval > 0 && d > float64(p.MaxInterval) {
+		d = float64(p.MaxInterval)
+	}
+	if p.Jitter > 0 {
+		d += d * p.Jitter * (2*rand.Float64() - 1)
+	}
+
+	return time.Duration(d)
+}
+
+// String describes the policy, e.g. for logging the effective one on startup.
+func (p RetryPolicy) String() string {
+	maxAttempts, maxElapsed := "unlimited", "unlimited"
+	if p.MaxAttempts > 0 {
+		maxAttempts = fmt.Sprint(p.MaxAttempts)
+	}
+	if p.MaxElapsed > 0 {
+		maxElapsed = p.MaxElapsed.String()
+	}
+
+	return fmt.Sprintf("initial interval %v, multiplier %v, max interval %v, jitter %v, max attempts %v, max elapsed time %v", p.InitialInterval, p.Multiplier, p.MaxInterval, p.Jitter, maxAttempts, maxElapsed)
+}
+
+// exhausted checks whether dialing must be given up after the given number of
+// attempts and the given time since the first one.
+func (p RetryPolicy) exhausted(attempts int, elapsed time.Duration) bool {
+	return (p.MaxAttempts > 0 && attempts >= p.MaxAttempts) ||
+		(p.MaxElapsed > 0 && elapsed >= p.MaxElapsed)
+}
+
+// RetryExhaustedError is returned if the RetryPolicy is exhausted. It carries the
+// number of attempts and the last error, and wraps ErrRetryExhausted, so that it can
+// be checked for with errors.Is.
+type RetryExhaustedError struct {
+	Address  string
+	Attempts int
+	Elapsed  time.Duration
+	Err      error
+}
+
+// Error implements the error interface.
+func (e *RetryExhaustedError) Error() string {
+	return fmt.Sprintf("%v at %v after %v attempts in %v: %v", ErrRetryExhausted, e.Address, e.Attempts, e.Elapsed.Round(time.Second), e.Err)
+}
+
+// Unwrap returns ErrRetryExhausted.
+func (e *RetryExhaustedError) Unwrap() error {
+	return ErrRetryExhausted
+}
@@ -0,0 +1,184 @@
+package connection
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy_Interval(t *testing.T) {
+	p := RetryPolicy{InitialInterval: time.Second, Multiplier: 2, MaxInterval: 5 * time.Second}
+	assert.Equal(t, time.Duration(0), p.interval(1))
+	assert.Equal(t, time.Second, p.interval(2))
+	assert.Equal(t, 2*time.Second, p.interval(3))
+	assert.Equal(t, 4*time.Second, p.interval(4))
+	assert.Equal(t, 5*time.Second, p.interval(5))
+	assert.Equal(t, 5*time.Second, p.interval(50))
+
+	// A multiplier below 1 never shrinks the interval.
+	p.Multiplier = 0
+	assert.Equal(t, time.Second, p.interval(4))
+
+	// The jitter randomizes the interval in both directions.
+	p = RetryPolicy{InitialInterval: time.Second, Multiplier: 1, Jitter: 0.5}
+	for i := 0; i < 100; i++ {
+		d := p.interval(2)
+		assert.GreaterOrEqual(t, int64(d), int64(500*time.Millisecond))
+		assert.LessOrEqual(t, int64(d), int64(1500*time.Millisecond))
+	}
+}
+
+func TestRetryPolicy_Exhausted(t *testing.T) {
+	assert.False(t, DefaultRetryPolicy().exhausted(1000, time.Hour))
+	assert.False(t, RetryPolicy{MaxAttempts: 3}.exhausted(2, time.Hour))
+	assert.True(t, RetryPolicy{MaxAttempts: 3}.exhausted(3, 0))
+	assert.False(t, RetryPolicy{MaxElapsed: time.Minute}.exhausted(1000, 59*time.Second))
+	assert.True(t, RetryPolicy{MaxElapsed: time.Minute}.exhausted(1, time.Minute))
+}
+
+func TestRetryPolicy_String(t *testing.T) {
+	assert.Equal(t, "initial interval 1s, multiplier 2, max interval 30s, jitter 0.2, max attempts unlimited, max elapsed time unlimited", DefaultRetryPolicy().String())
+	p := RetryPolicy{InitialInterval: 500 * time.Millisecond, Multiplier: 1.5, MaxInterval: time.Minute, MaxAttempts: 5, MaxElapsed: 10 * time.Minute}
+	assert.Equal(t, "initial interval 500ms, multiplier 1.5, max interval 1m0s, jitter 0, max attempts 5, max elapsed time 10m0s", p.String())
+}
+
+func TestRetry_SingleAttempt(t *testing.T) {
+	// With a single attempt, a failed dial is never retried.
+	dials := 0
+	policy := RetryPolicy{InitialInterval: time.Millisecond, Multiplier: 2, MaxAttempts: 1, Stats: &Stats{}}
+	conn, err := retry(context.Background(), "tcp://127.0.0.1:3000", policy, types.NewSyncLogger(ioutil.Discard, "", 0), func(ctx context.Context) (net.Conn, error) {
+		dials++
+		return nil, errors.New("connection refused")
+	})
+	assert.Nil(t, conn)
+	assert.ErrorIs(t, err, ErrRetryExhausted)
+	assert.Equal(t, 1, dials)
+	assert.Equal(t, uint64(1), policy.Stats.Snapshot().Dials)
+}
+
+func TestRetryDial_Exhausted(t *testing.T) {
+	cfgDir := t.TempDir()
+	assert.NoError(t, CreateBase64ConnKey(cfgDir))
+
+	// Nothing listens on the port, so every dial fails.
+	port, _ := getFreePort(t)
+	policy := RetryPolicy{InitialInterval: time.Millisecond, Multiplier: 2, MaxInterval: 10 * time.Millisecond, MaxAttempts: 3, Stats: &Stats{}}
+	conn, err := RetryDial(context.Background(), cfgDir, fmt.Sprintf("tcp://127.0.0.1:%v", port), false, nil, policy, types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.Nil(t, conn)
+	assert.ErrorIs(t, err, ErrRetryExhausted)
+	var exhausted *RetryExhaustedError
+	assert.True(t, errors.As(err, &exhausted))
+	assert.Equal(t, 3, exhausted.Attempts)
+	assert.Contains(t, err.Error(), "after 3 attempts")
+
+	// Every attempt is recorded as a failed dial.
+	stats := policy.Stats.Snapshot()
+	assert.Equal(t, uint64(3), stats.Dials)
+	assert.Equal(t, uint64(3), stats.FailedDials)
+}
+
+func TestRetryDial_HandshakeTimeout(t *testing.T) {
+	cfgDir := t.TempDir()
+	assert.NoError(t, CreateBase64ConnKey(cfgDir))
+
+	// The listener accepts connections, but never completes the handshake.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	// Every attempt times out and is retried until the policy is exhausted.
+	policy := RetryPolicy{InitialInterval: time.Millisecond, Multiplier: 1, MaxAttempts: 2, DialTimeout: 100 * time.Millisecond}
+	start := time.Now()
+	conn, err := RetryDial(context.Background(), cfgDir, "tcp://"+listener.Addr().String(), false, nil, policy, types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.Nil(t, conn)
+	assert.ErrorIs(t, err, ErrRetryExhausted)
+	var exhausted *RetryExhaustedError
+	assert.True(t, errors.As(err, &exhausted))
+	assert.Equal(t, 2, exhausted.Attempts)
+	assert.Contains(t, exhausted.Err.Error(), "couldn't establish secret connection")
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+}
+
+func TestRetryDial_Canceled(t *testing.T) {
+	cfgDir := t.TempDir()
+	assert.NoError(t, CreateBase64ConnKey(cfgDir))
+
+	// Nothing answers on the blackholed address, so dialing would go on forever.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	conn, err := RetryDial(ctx, cfgDir, "tcp://10.255.255.1:3000", false, nil, DefaultRetryPolicy(), types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.Nil(t, conn)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+}
+
+func TestRetryDial_CanceledHandshake(t *testing.T) {
+	cfgDir := t.TempDir()
+	assert.NoError(t, CreateBase64ConnKey(cfgDir))
+
+	// The listener accepts connections, but never completes the handshake, which
+	// isn't aborted by a dial timeout either.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	conn, err := RetryDial(ctx, cfgDir, "tcp://"+listener.Addr().String(), false, nil, RetryPolicy{}, types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.Nil(t, conn)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+}
+
+func TestRetry_FailureLog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := types.NewSyncLogger(&buf, "", 0)
+	policy := RetryPolicy{InitialInterval: time.Millisecond, Multiplier: 1, LogInterval: time.Hour}
+
+	// Only the first failure is logged until the validator is reached again.
+	attempts := 0
+	conn, err := retry(context.Background(), "tcp://127.0.0.1:3000", policy, logger, func(ctx context.Context) (net.Conn, error) {
+		if attempts++; attempts < 5 {
+			return nil, errors.New("connection refused")
+		}
+		conn, _ := net.Pipe()
+		return conn, nil
+	})
+	assert.NoError(t, err)
+	conn.Close()
+	logs := buf.String()
+	assert.Equal(t, 1, strings.Count(logs, "[ERR]"))
+	assert.Contains(t, logs, "[ERR]   signctrl: Unable to reach tcp://127.0.0.1:3000: connection refused")
+	assert.NotContains(t, logs, "[WARN]")
+	assert.Contains(t, logs, "[INFO]  signctrl: Connection to tcp://127.0.0.1:3000 restored after")
+	assert.Contains(t, logs, "(4 failed attempts)")
+}
@@ -0,0 +1,262 @@
+package connection
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	tm_crypto "github.com/tendermint/tendermint/crypto"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const (
+	// DefaultSSHPort is the port of the SSH bastion host if its address doesn't
+	// specify one.
+	DefaultSSHPort = "22"
+)
+
+// SSHTunnel dials TCP addresses through direct-tcpip channels of an SSH session to a
+// bastion host. The session is shared by all connections through the tunnel and is
+// re-established once it breaks.
+type SSHTunnel struct {
+	host   string
+	config *ssh.ClientConfig
+
+	mtx    sync.Mutex
+	client *ssh.Client
+}
+
+// NewSSHTunnel creates a new SSHTunnel to the given bastion host, which is only
+// connected to on the first dial. The user is authenticated with the unencrypted
+// private key in keyFile, and the host key of the bastion host must be listed in the
+// knownHostsFile.
+func NewSSHTunnel(host, user, keyFile, knownHostsFile string) (*SSHTunnel, error) {
+	if _, _, err := net.SplitHostPort(host); err != nil {
+		host = net.JoinHostPort(host, DefaultSSHPort)
+	}
+	keyPEM, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't load SSH key: %v", err)
+	}
+	signer, err := ssh.ParsePrivateKey(keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse SSH key: %v", err)
+	}
+	hostKeyCallback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't load SSH known hosts: %v", err)
+	}
+
+	return &SSHTunnel{
+		host: host,
+		config: &ssh.ClientConfig{
+			User:            user,
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+			HostKeyCallback: hostKeyCallback,
+		},
+	}, nil
+}
+
+// connect establishes a new SSH session to the bastion host. Connecting is aborted
+// after the policy's dial timeout, unless it is 0, or once the given context is
+// canceled.
+func (t *SSHTunnel) connect(ctx context.Context, policy RetryPolicy) (*ssh.Client, error) {
+	// The dialer's own keepalive is disabled, as it is set up explicitly.
+	dialer := net.Dialer{Timeout: policy.DialTimeout, KeepAlive: -1}
+	conn, err := dialer.DialContext(ctx, "tcp", t.host)
+	if err != nil {
+		return nil, err
+	}
+	defer closeOnCancel(ctx, conn)()
+	if _, err := setKeepAlive(conn, policy.KeepAlivePeriod); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if policy.DialTimeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(policy.DialTimeout)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, t.host, t.config)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("couldn't establish SSH session to %v: %w", t.host, err)
+	}
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		sshConn.Close()
+		return nil, err
+	}
+
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+// openChannel opens a direct-tcpip channel to the given address on the given SSH
+// session. If it takes longer than the given timeout, unless it is 0, or the given
+// context is canceled in the meantime, the session is closed, as it is considered
+// broken.
+func openChannel(ctx context.Context, client *ssh.Client, address string, timeout time.Duration) (net.Conn, error) {
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	resultCh := make(chan result, 1)
+	go func() {
+		conn, err := client.Dial("tcp", address)
+		resultCh <- result{conn, err}
+	}()
+	var timeoutCh <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+
+	err := errors.New("timed out opening SSH channel")
+	select {
+	case r := <-resultCh:
+		return r.conn, r.err
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-timeoutCh:
+	}
+	client.Close()
+	if r := <-resultCh; r.conn != nil {
+		r.conn.Close()
+	}
+	return nil, err
+}
+
+// Dial opens a channel to the given TCP host:port through the SSH session and returns
+// it as a connection. If there is no session yet, or the existing one is broken, a new
+// one is established first. Dialing is aborted once the given context is canceled.
+func (t *SSHTunnel) Dial(ctx context.Context, address string, policy RetryPolicy) (net.Conn, error) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.client != nil {
+		channel, err := openChannel(ctx, t.client, address, policy.DialTimeout)
+		if err == nil {
+			return newTunnelConn(channel), nil
+		}
+		t.client.Close()
+		t.client = nil
+
+		// Channels are also rejected if the bastion host can't reach the address, so
+		// only fail here if a fresh session doesn't help either.
+	}
+
+	client, err := t.connect(ctx, policy)
+	if err != nil {
+		return nil, err
+	}
+	t.client = client
+	channel, err := openChannel(ctx, client, address, policy.DialTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open SSH channel to %v via %v: %w", address, t.host, err)
+	}
+
+	return newTunnelConn(channel), nil
+}
+
+// Close closes the SSH session. Connections through the tunnel are closed as well.
+func (t *SSHTunnel) Close() error {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+	if t.client == nil {
+		return nil
+	}
+	err := t.client.Close()
+	t.client = nil
+
+	return err
+}
+
+// tunnelConn is a connection through an SSH channel. As SSH channels don't support
+// deadlines, the data is passed through a net.Pipe, which does.
+type tunnelConn struct {
+	net.Conn
+	channel net.Conn
+}
+
+// newTunnelConn returns a connection that passes the data through to the given channel
+// until either of them is closed.
+func newTunnelConn(channel net.Conn) net.Conn {
+	local, remote := net.Pipe()
+	go func() {
+		_, _ = io.Copy(remote, channel)
+		remote.Close()
+	}()
+	go func() {
+		_, _ = io.Copy(channel, remote)
+		channel.Close()
+	}()
+
+	return &tunnelConn{Conn: local, channel: channel}
+}
+
+// LocalAddr returns the local address of the SSH channel.
+// Implements the net.Conn interface.
+func (c *tunnelConn) LocalAddr() net.Addr {
+	return c.channel.LocalAddr()
+}
+
+// RemoteAddr returns the remote address of the SSH channel.
+// Implements the net.Conn interface.
+func (c *tunnelConn) RemoteAddr() net.Addr {
+	return c.channel.RemoteAddr()
+}
+
+// Close closes both the pipe and the SSH channel.
+// Implements the net.Conn interface.
+func (c *tunnelConn) Close() error {
+	c.Conn.Close()
+	return c.channel.Close()
+}
+
+// RetryDialSSH keeps dialing the given TCP address through the given SSH tunnel in the
+// intervals of the given policy until success and returns the secret connection
+// established over the tunnel. Broken SSH sessions are re-established on the next
+// attempt. Secret connections are dropped without retrying if the validator doesn't
+// use one of the given authorized keys. If none are given, any key is accepted. If the
+// policy is exhausted, a RetryExhaustedError is returned. Dialing is aborted once the
+// given context is canceled, which returns the context's error. Hostnames are resolved
+// by the bastion host on every attempt.
+func RetryDialSSH(ctx context.Context, cfgDir, address string, tunnel *SSHTunnel, authorizedKeys []tm_crypto.PubKey, policy RetryPolicy, logger *types.SyncLogger) (net.Conn, error) {
+	addr, err := config.ParseHostAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if addr.Protocol != config.ProtocolTCP {
+		return nil, errors.New("SSH tunnels are only supported for TCP addresses")
+	}
+	address = addr.String()
+	logger.Info("Dialing %v via SSH bastion %v... (Use Ctrl+C to abort)", address, tunnel.host)
+	if len(authorizedKeys) == 0 {
+		logger.Warn("The validator at %v isn't authenticated, as authorized_keys is empty! Anyone listening on this address receives the sign requests, so add the validator's connection key to authorized_keys!", address)
+	}
+	connKey, err := loadConnKey(cfgDir, logger)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := retry(ctx, address, policy, logger, func(ctx context.Context) (net.Conn, error) {
+		conn, err := tunnel.Dial(ctx, addr.Addr, policy)
+		if err != nil {
+			return nil, err
+		}
+		return handshake(ctx, conn, connKey, authorizedKeys, policy.DialTimeout)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Successfully dialed the validator via SSH bastion %v ✓", tunnel.host)
+	return conn, nil
+}
@@ -0,0 +1,278 @@
+package connection
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	tm_crypto "github.com/tendermint/tendermint/crypto"
+	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	tm_p2pconn "github.com/tendermint/tendermint/p2p/conn"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// testSSHServer is an in-process SSH bastion host that forwards direct-tcpip channels.
+type testSSHServer struct {
+	t        *testing.T
+	listener net.Listener
+	hostKey  ssh.Signer
+	config   *ssh.ServerConfig
+
+	mtx      sync.Mutex
+	sessions []ssh.Conn
+}
+
+// newTestSSHServer starts a new testSSHServer that only lets in the given user key.
+func newTestSSHServer(t *testing.T, userKey ssh.PublicKey) *testSSHServer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	hostKey, err := ssh.NewSignerFromKey(priv)
+	assert.NoError(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+
+	s := &testSSHServer{t: t, listener: listener, hostKey: hostKey}
+	s.config = &ssh.ServerConfig{
+		PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if !bytes.Equal(key.Marshal(), userKey.Marshal()) {
+				return nil, errors.New("unknown key")
+			}
+			return nil, nil
+		},
+	}
+	s.config.AddHostKey(hostKey)
+	go s.serve()
+	t.Cleanup(func() {
+		listener.Close()
+		s.closeSessions()
+	})
+
+	return s
+}
+
+// serve accepts SSH sessions until the listener is closed.
+func (s *testSSHServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+// handle forwards the direct-tcpip channels of the SSH session on the given connection.
+func (s *testSSHServer) handle(conn net.Conn) {
+	sshConn, chans, reqs, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	s.mtx.Lock()
+	s.sessions = append(s.sessions, sshConn)
+	s.mtx.Unlock()
+	go ssh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "direct-tcpip" {
+			_ = newChannel.Reject(ssh.UnknownChannelType, "only direct-tcpip is supported")
+			continue
+		}
+		var payload struct {
+			Host       string
+			Port       uint32
+			OriginHost string
+			OriginPort uint32
+		}
+		if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+			_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		target, err := net.Dial("tcp", net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port))))
+		if err != nil {
+			_ = newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, channelReqs, err := newChannel.Accept()
+		if err != nil {
+			target.Close()
+			continue
+		}
+		go ssh.DiscardRequests(channelReqs)
+		go func() {
+			_, _ = io.Copy(channel, target)
+			channel.Close()
+		}()
+		go func() {
+			_, _ = io.Copy(target, channel)
+			target.Close()
+		}()
+	}
+}
+
+// numSessions returns the number of SSH sessions established so far.
+func (s *testSSHServer) numSessions() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return len(s.sessions)
+}
+
+// closeSessions closes all SSH sessions, as if the bastion host was restarted.
+func (s *testSSHServer) closeSessions() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	for _, session := range s.sessions {
+		session.Close()
+	}
+}
+
+// testSSHFiles writes a new user key to a temporary directory and returns its path and
+// public key.
+func testSSHFiles(t *testing.T) (keyFile string, userKey ssh.PublicKey) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	assert.NoError(t, err)
+	keyFile = filepath.Join(t.TempDir(), "id_ed25519")
+	assert.NoError(t, ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600))
+	userKey, err = ssh.NewPublicKey(pub)
+	assert.NoError(t, err)
+
+	return keyFile, userKey
+}
+
+// knownHostsFile writes a known_hosts file listing the server's host key and returns
+// its path.
+func (s *testSSHServer) knownHostsFile() string {
+	s.t.Helper()
+	path := filepath.Join(s.t.TempDir(), "known_hosts")
+	line := knownhosts.Line([]string{s.listener.Addr().String()}, s.hostKey.PublicKey())
+	assert.NoError(s.t, ioutil.WriteFile(path, []byte(line+"\n"), 0600))
+	return path
+}
+
+// startEchoSecretServer accepts secret connections on the given listener and echoes
+// everything it receives on them.
+func startEchoSecretServer(listener net.Listener, connKey tm_ed25519.PrivKey) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer conn.Close()
+			secretConn, err := tm_p2pconn.MakeSecretConnection(conn, connKey)
+			if err != nil {
+				return
+			}
+			_, _ = io.Copy(secretConn, secretConn)
+		}()
+	}
+}
+
+func TestRetryDialSSH(t *testing.T) {
+	cfgDir := "./test_dial_ssh"
+	assert.NoError(t, os.MkdirAll(cfgDir, 0700))
+	defer os.RemoveAll(cfgDir)
+	assert.NoError(t, CreateBase64ConnKey(cfgDir))
+
+	validatorKey := tm_ed25519.GenPrivKey()
+	validator, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer validator.Close()
+	go startEchoSecretServer(validator, validatorKey)
+
+	keyFile, userKey := testSSHFiles(t)
+	bastion := newTestSSHServer(t, userKey)
+	tunnel, err := NewSSHTunnel(bastion.listener.Addr().String(), "signctrl", keyFile, bastion.knownHostsFile())
+	assert.NoError(t, err)
+	defer tunnel.Close()
+
+	logger := types.NewSyncLogger(ioutil.Discard, "", 0)
+	authorizedKeys := []tm_crypto.PubKey{validatorKey.PubKey()}
+	conn, err := RetryDialSSH(context.Background(), cfgDir, validator.Addr().String(), tunnel, authorizedKeys, DefaultRetryPolicy(), logger)
+	assert.NoError(t, err)
+
+	// Messages spanning several secret connection frames arrive in one piece.
+	msg := make([]byte, 10*1024)
+	_, _ = rand.Read(msg)
+	go func() { _, _ = conn.Write(msg) }()
+	echo := make([]byte, len(msg))
+	assert.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	_, err = io.ReadFull(conn, echo)
+	assert.NoError(t, err)
+	assert.Equal(t, msg, echo)
+	conn.Close()
+	assert.Equal(t, 1, bastion.numSessions())
+
+	// A broken SSH session is re-established on the next dial.
+	bastion.closeSessions()
+	conn, err = RetryDialSSH(context.Background(), cfgDir, validator.Addr().String(), tunnel, authorizedKeys, DefaultRetryPolicy(), logger)
+	assert.NoError(t, err)
+	defer conn.Close()
+	assert.Equal(t, 2, bastion.numSessions())
+}
+
+func TestRetryDialSSH_UnknownHostKey(t *testing.T) {
+	cfgDir := "./test_dial_ssh_unknownhostkey"
+	assert.NoError(t, os.MkdirAll(cfgDir, 0700))
+	defer os.RemoveAll(cfgDir)
+	assert.NoError(t, CreateBase64ConnKey(cfgDir))
+
+	keyFile, userKey := testSSHFiles(t)
+	bastion := newTestSSHServer(t, userKey)
+	otherBastion := newTestSSHServer(t, userKey)
+	tunnel, err := NewSSHTunnel(bastion.listener.Addr().String(), "signctrl", keyFile, otherBastion.knownHostsFile())
+	assert.NoError(t, err)
+	defer tunnel.Close()
+
+	policy := RetryPolicy{InitialInterval: time.Millisecond, Multiplier: 1, MaxAttempts: 2, DialTimeout: time.Second}
+	conn, err := RetryDialSSH(context.Background(), cfgDir, "tcp://127.0.0.1:3000", tunnel, nil, policy, types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.Nil(t, conn)
+	assert.ErrorIs(t, err, ErrRetryExhausted)
+	assert.Contains(t, err.Error(), "knownhosts")
+	assert.Equal(t, 0, bastion.numSessions())
+}
+
+func TestRetryDialSSH_Unix(t *testing.T) {
+	tunnel := &SSHTunnel{}
+	conn, err := RetryDialSSH(context.Background(), ".", "unix:///tmp/validator.sock", tunnel, nil, DefaultRetryPolicy(), types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.Nil(t, conn)
+	assert.Error(t, err)
+}
+
+func TestNewSSHTunnel(t *testing.T) {
+	keyFile, userKey := testSSHFiles(t)
+	bastion := newTestSSHServer(t, userKey)
+	knownHostsFile := bastion.knownHostsFile()
+
+	// The default port is used if none is given.
+	tunnel, err := NewSSHTunnel("10.0.0.1", "signctrl", keyFile, knownHostsFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:22", tunnel.host)
+
+	_, err = NewSSHTunnel("10.0.0.1:22", "signctrl", "/nonexistent/id_ed25519", knownHostsFile)
+	assert.Error(t, err)
+	_, err = NewSSHTunnel("10.0.0.1:22", "signctrl", knownHostsFile, knownHostsFile)
+	assert.Error(t, err)
+	_, err = NewSSHTunnel("10.0.0.1:22", "signctrl", keyFile, "/nonexistent/known_hosts")
+	assert.Error(t, err)
+}
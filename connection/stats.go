@@ -0,0 +1,81 @@
+package connection
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+)
+
+// Stats counts the traffic, dials and reconnects of the connections to the validators.
+// All counters are updated atomically, as connections are read from and written to on
+// different goroutines. The zero value is ready to use.
+type Stats struct {
+	bytesRead     uint64
+	bytesWritten  uint64
+	dials         uint64
+	failedDials   uint64
+	reconnects    uint64
+	lastHandshake int64
+}
+
+// recordDial records a dial attempt that took the given time and failed with the
+// given error, if any.
+func (s *Stats) recordDial(d time.Duration, err error) {
+	atomic.AddUint64(&s.dials, 1)
+	if err != nil {
+		atomic.AddUint64(&s.failedDials, 1)
+		return
+	}
+	atomic.StoreInt64(&s.lastHandshake, int64(d))
+}
+
+// AddReconnect records a reconnect to a validator.
+func (s *Stats) AddReconnect() {
+	atomic.AddUint64(&s.reconnects, 1)
+}
+
+// Snapshot returns the current statistics.
+func (s *Stats) Snapshot() types.ConnStats {
+	return types.ConnStats{
+		BytesRead:     atomic.LoadUint64(&s.bytesRead),
+		BytesWritten:  atomic.LoadUint64(&s.bytesWritten),
+		Dials:         atomic.LoadUint64(&s.dials),
+		FailedDials:   atomic.LoadUint64(&s.failedDials),
+		Reconnects:    atomic.LoadUint64(&s.reconnects),
+		LastHandshake: time.Duration(atomic.LoadInt64(&s.lastHandshake)),
+	}
+}
+
+// Wrap returns the given connection with its traffic counted.
+func (s *Stats) Wrap(conn net.Conn) net.Conn {
+	return &countingConn{Conn: conn, stats: s}
+}
+
+// countingConn is a connection whose traffic is counted in its Stats.
+type countingConn struct {
+	net.Conn
+	stats *Stats
+}
+
+// unwrap returns the counted connection.
+func (c *countingConn) unwrap() net.Conn {
+	return c.Conn
+}
+
+// Read reads from the connection and counts the bytes read.
+// Implements the net.Conn interface.
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	atomic.AddUint64(&c.stats.bytesRead, uint64(n))
+	return n, err
+}
+
+// Write writes to the connection and counts the bytes written.
+// Implements the net.Conn interface.
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	atomic.AddUint64(&c.stats.bytesWritten, uint64(n))
+	return n, err
+}
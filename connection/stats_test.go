@@ -0,0 +1,56 @@
+package connection
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStats_Wrap(t *testing.T) {
+	var stats Stats
+	local, remote := net.Pipe()
+	conn := stats.Wrap(local)
+	defer conn.Close()
+
+	// Reads and writes happen concurrently.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			_, _ = remote.Write(make([]byte, 10))
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		_, _ = io.ReadFull(remote, make([]byte, 500))
+	}()
+	_, err := conn.Write(make([]byte, 500))
+	assert.NoError(t, err)
+	_, err = io.ReadFull(conn, make([]byte, 1000))
+	assert.NoError(t, err)
+	wg.Wait()
+
+	snapshot := stats.Snapshot()
+	assert.Equal(t, uint64(1000), snapshot.BytesRead)
+	assert.Equal(t, uint64(500), snapshot.BytesWritten)
+}
+
+func TestStats_RecordDial(t *testing.T) {
+	var stats Stats
+	stats.recordDial(time.Second, errors.New("connection refused"))
+	stats.recordDial(200*time.Millisecond, nil)
+	stats.recordDial(time.Second, errors.New("connection refused"))
+	stats.AddReconnect()
+
+	snapshot := stats.Snapshot()
+	assert.Equal(t, uint64(3), snapshot.Dials)
+	assert.Equal(t, uint64(2), snapshot.FailedDials)
+	assert.Equal(t, uint64(1), snapshot.Reconnects)
+	assert.Equal(t, 200*time.Millisecond, snapshot.LastHandshake)
+}
@@ -0,0 +1,222 @@
+package connection
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/types"
+)
+
+const (
+	// CertExpiryWarning is the time before a TLS certificate expires from which on
+	// its expiry is warned about.
+	CertExpiryWarning = 30 * 24 * time.Hour
+)
+
+// TLSCerts holds the certificate, private key and CA used for mutual TLS connections
+// to the validator. They can be reloaded at runtime, which only affects connections
+// established afterwards.
+type TLSCerts struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	mtx  sync.RWMutex
+	cert tls.Certificate
+	pool *x509.CertPool
+}
+
+// LoadTLSCerts loads the certificate, private key and CA from the given files.
+func LoadTLSCerts(certFile, keyFile, caFile string) (*TLSCerts, error) {
+	c := &TLSCerts{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	if err := c.Reload(); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Reload loads the certificate, private key and CA from their files again. If any of
+// them can't be loaded, the previous ones are kept.
+func (c *TLSCerts) Reload() error {
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("couldn't load TLS certificate: %v", err)
+	}
+	if cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+		return fmt.Errorf("couldn't parse TLS certificate: %v", err)
+	}
+	caPEM, err := ioutil.ReadFile(c.caFile)
+	if err != nil {
+		return fmt.Errorf("couldn't load TLS CA: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return fmt.Errorf("couldn't load TLS CA: no PEM encoded certificates in %v", c.caFile)
+	}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.cert = cert
+	c.pool = pool
+
+	return nil
+}
+
+// NotAfter returns the time at which the certificate expires.
+func (c *TLSCerts) NotAfter() time.Time {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.cert.Leaf.NotAfter
+}
+
+// CheckExpiry logs an error if the certificate has expired and a warning if it
+// expires within CertExpiryWarning.
+func (c *TLSCerts) CheckExpiry(logger *types.SyncLogger) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	warnExpiry(logger, "SignCTRL's TLS certificate", c.cert.Leaf)
+}
+
+// clientConfig returns the TLS configuration for dialing the validator with the given
+// server name.
+func (c *TLSCerts) clientConfig(serverName string) *tls.Config {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{c.cert},
+		RootCAs:      c.pool,
+		ServerName:   serverName,
+	}
+}
+
+// serverConfig returns the TLS configuration for accepting the validator, which must
+// present a client certificate signed by the CA.
+func (c *TLSCerts) serverConfig() *tls.Config {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return &tls.Config{
+		MinVersion:   tls.VersionTLS12,
+		Certificates: []tls.Certificate{c.cert},
+		ClientCAs:    c.pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+}
+
+// warnExpiry logs an error if the given certificate has expired and a warning if it
+// expires within CertExpiryWarning.
+func warnExpiry(logger *types.SyncLogger, name string, cert *x509.Certificate) {
+	left := time.Until(cert.NotAfter)
+	if left <= 0 {
+		logger.Error("%v has expired on %v!", name, cert.NotAfter.Format(time.RFC3339))
+	} else if left < CertExpiryWarning {
+		logger.Warn("%v expires on %v (in %v), renew it and send SIGHUP to reload it!", name, cert.NotAfter.Format(time.RFC3339), left.Round(time.Hour))
+	}
+}
+
+// tlsHandshake performs the TLS handshake on the given connection. It is aborted after
+// the given timeout, unless it is 0, or once the given context is canceled. On failure,
+// the connection is closed. The validator's certificate is checked for its expiry
+// afterwards.
+func tlsHandshake(ctx context.Context, conn *tls.Conn, timeout time.Duration, logger *types.SyncLogger) (net.Conn, error) {
+	defer closeOnCancel(ctx, conn)()
+	if timeout > 0 {
+		if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if err := conn.Handshake(); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("couldn't establish TLS connection: %w", err)
+	}
+	if timeout > 0 {
+		if err := conn.SetDeadline(time.Time{}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	if peerCerts := conn.ConnectionState().PeerCertificates; len(peerCerts) > 0 {
+		warnExpiry(logger, "The validator's TLS certificate", peerCerts[0])
+	}
+
+	return conn, nil
+}
+
+// RetryDialTLS keeps dialing the given TCP address in the intervals of the given policy
+// until success and returns a mutual TLS connection using the given certificates. The
+// validator's certificate must be signed by the CA and be valid for the address' host,
+// which is resolved again on every attempt if it is a hostname. If the policy is
+// exhausted, a RetryExhaustedError is returned. Dialing is aborted once the given
+// context is canceled, which returns the context's error.
+func RetryDialTLS(ctx context.Context, address string, certs *TLSCerts, policy RetryPolicy, logger *types.SyncLogger) (net.Conn, error) {
+	addr, err := config.ParseHostAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if addr.Protocol != config.ProtocolTCP {
+		return nil, errors.New("mutual TLS is only supported for TCP addresses")
+	}
+	address = addr.String()
+	logger.Info("Dialing %v via mutual TLS... (Use Ctrl+C to abort)", address)
+	hostPort := addr.Addr
+	host, _, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return nil, err
+	}
+	// The dialer's own keepalive is disabled, as it is set up explicitly.
+	dialer := net.Dialer{Timeout: policy.DialTimeout, KeepAlive: -1}
+	conn, err := retry(ctx, address, policy, logger, func(ctx context.Context) (net.Conn, error) {
+		conn, err := dialTCP(ctx, &dialer, policy.Resolver, hostPort, logger)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := setKeepAlive(conn, policy.KeepAlivePeriod); err != nil {
+			logger.Warn("couldn't set TCP keepalive on connection to %v: %v", address, err)
+		}
+		tlsConn, err := tlsHandshake(ctx, tls.Client(conn, certs.clientConfig(host)), policy.DialTimeout, logger)
+		if err != nil {
+			return nil, err
+		}
+		return withProbe(tlsConn, conn), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info("Successfully dialed the validator via mutual TLS ✓")
+	return conn, nil
+}
+
+// NewTLSAcceptor starts accepting connections from validators on the given listener and
+// returns the Acceptor admitting a single one of them at a time. Only validators with a
+// client certificate signed by the CA are admitted, which are identified by the
+// SHA-256 fingerprint of their certificate in the logs. If preempt is set, a newly
+// authenticated validator replaces the admitted one instead of being rejected.
+func NewTLSAcceptor(listener net.Listener, certs *TLSCerts, preempt bool, logger *types.SyncLogger) *Acceptor {
+	logger.Info("Waiting for the validator to dial %v via mutual TLS...", listener.Addr())
+	return newAcceptor(listener, func(conn net.Conn) (net.Conn, string, error) {
+		tlsConn, err := tlsHandshake(context.Background(), tls.Server(conn, certs.serverConfig()), HandshakeTimeout, logger)
+		if err != nil {
+			return nil, "", err
+		}
+		return withProbe(tlsConn, conn), certFingerprint(tlsConn.(*tls.Conn).ConnectionState().PeerCertificates[0]), nil
+	}, preempt, logger)
+}
+
+// certFingerprint returns the hex-encoded SHA-256 fingerprint of the given certificate.
+func certFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,225 @@
+package connection
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// testCA is a certificate authority issuing certificates for 127.0.0.1.
+type testCA struct {
+	t    *testing.T
+	dir  string
+	key  *ecdsa.PrivateKey
+	cert *x509.Certificate
+}
+
+// newTestCA creates a new testCA and writes its certificate to ca.pem.
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+
+	ca := &testCA{t: t, dir: t.TempDir(), key: key, cert: cert}
+	assert.NoError(t, ioutil.WriteFile(ca.path("ca.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+
+	return ca
+}
+
+// path returns the path to the given file in the CA's directory.
+func (ca *testCA) path(name string) string {
+	return filepath.Join(ca.dir, name)
+}
+
+// issue issues a certificate valid for the given time and writes it and its key to
+// <name>.pem and <name>-key.pem.
+func (ca *testCA) issue(name string, validFor time.Duration) {
+	ca.t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(ca.t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(validFor),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, &key.PublicKey, ca.key)
+	assert.NoError(ca.t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(ca.t, err)
+	assert.NoError(ca.t, ioutil.WriteFile(ca.path(name+".pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(ca.t, ioutil.WriteFile(ca.path(name+"-key.pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600))
+}
+
+// certs loads the certificate with the given name.
+func (ca *testCA) certs(name string) *TLSCerts {
+	ca.t.Helper()
+	certs, err := LoadTLSCerts(ca.path(name+".pem"), ca.path(name+"-key.pem"), ca.path("ca.pem"))
+	assert.NoError(ca.t, err)
+	return certs
+}
+
+func TestLoadTLSCerts(t *testing.T) {
+	ca := newTestCA(t)
+	ca.issue("signctrl", time.Hour)
+
+	_, err := LoadTLSCerts(ca.path("signctrl.pem"), ca.path("signctrl-key.pem"), ca.path("nonexistent.pem"))
+	assert.Error(t, err)
+	_, err = LoadTLSCerts(ca.path("signctrl.pem"), ca.path("signctrl-key.pem"), ca.path("signctrl-key.pem"))
+	assert.Error(t, err)
+
+	// Reloading picks up the renewed certificate, but a broken one is never loaded.
+	certs := ca.certs("signctrl")
+	notAfter := certs.NotAfter()
+	ca.issue("signctrl", 48*time.Hour)
+	assert.NoError(t, certs.Reload())
+	assert.True(t, certs.NotAfter().After(notAfter))
+	assert.NoError(t, ioutil.WriteFile(ca.path("signctrl.pem"), []byte("invalid"), 0600))
+	assert.Error(t, certs.Reload())
+	assert.True(t, certs.NotAfter().After(notAfter))
+}
+
+func TestTLSCerts_CheckExpiry(t *testing.T) {
+	ca := newTestCA(t)
+	var buf bytes.Buffer
+	logger := types.NewSyncLogger(&buf, "", 0)
+
+	ca.issue("signctrl", 60*24*time.Hour)
+	ca.certs("signctrl").CheckExpiry(logger)
+	assert.Empty(t, buf.String())
+
+	ca.issue("signctrl", 24*time.Hour)
+	ca.certs("signctrl").CheckExpiry(logger)
+	assert.Contains(t, buf.String(), "[WARN]")
+	assert.Contains(t, buf.String(), "SignCTRL's TLS certificate expires on")
+
+	ca.issue("signctrl", -time.Minute)
+	ca.certs("signctrl").CheckExpiry(logger)
+	assert.Contains(t, buf.String(), "[ERR]")
+	assert.Contains(t, buf.String(), "SignCTRL's TLS certificate has expired")
+}
+
+func TestRetryDialTLS(t *testing.T) {
+	ca := newTestCA(t)
+	ca.issue("validator", time.Hour)
+	ca.issue("signctrl", time.Hour)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", ca.certs("validator").serverConfig())
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = conn.Write([]byte("ping"))
+	}()
+
+	conn, err := RetryDialTLS(context.Background(), "tcp://"+listener.Addr().String(), ca.certs("signctrl"), DefaultRetryPolicy(), types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.NoError(t, err)
+	defer conn.Close()
+	msg := make([]byte, 4)
+	_, err = conn.Read(msg)
+	assert.NoError(t, err)
+	assert.Equal(t, "ping", string(msg))
+	assert.IsType(t, &tls.Conn{}, conn.(*probeConn).Conn)
+}
+
+func TestRetryDialTLS_UnknownCA(t *testing.T) {
+	ca := newTestCA(t)
+	ca.issue("signctrl", time.Hour)
+	otherCA := newTestCA(t)
+	otherCA.issue("validator", time.Hour)
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", otherCA.certs("validator").serverConfig())
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	policy := RetryPolicy{InitialInterval: time.Millisecond, Multiplier: 1, MaxAttempts: 2, DialTimeout: time.Second}
+	conn, err := RetryDialTLS(context.Background(), "tcp://"+listener.Addr().String(), ca.certs("signctrl"), policy, types.NewSyncLogger(ioutil.Discard, "", 0))
+	assert.Nil(t, conn)
+	assert.ErrorIs(t, err, ErrRetryExhausted)
+	assert.Contains(t, err.Error(), "couldn't establish TLS connection")
+}
+
+func TestNewTLSAcceptor(t *testing.T) {
+	ca := newTestCA(t)
+	ca.issue("validator", time.Hour)
+	ca.issue("signctrl", time.Hour)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		// The first connection doesn't present a client certificate and is rejected.
+		cfg := ca.certs("validator").clientConfig("127.0.0.1")
+		if conn, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{RootCAs: cfg.RootCAs, ServerName: "127.0.0.1"}); err == nil {
+			_, _ = conn.Read(make([]byte, 1))
+			conn.Close()
+		}
+		if conn, err := tls.Dial("tcp", listener.Addr().String(), cfg); err == nil {
+			defer conn.Close()
+			_, _ = conn.Read(make([]byte, 1))
+		}
+	}()
+
+	acceptor := NewTLSAcceptor(listener, ca.certs("signctrl"), false, types.NewSyncLogger(ioutil.Discard, "", 0))
+	conn, err := acceptor.Next(context.Background())
+	assert.NoError(t, err)
+	defer conn.Close()
+	peerCerts := conn.(*admittedConn).Conn.(*probeConn).Conn.(*tls.Conn).ConnectionState().PeerCertificates
+	assert.Len(t, peerCerts, 1)
+	assert.Len(t, certFingerprint(peerCerts[0]), 64)
+}
+
+func TestNewTLSAcceptor_ClosedListener(t *testing.T) {
+	ca := newTestCA(t)
+	ca.issue("signctrl", time.Hour)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	listener.Close()
+
+	acceptor := NewTLSAcceptor(listener, ca.certs("signctrl"), false, types.NewSyncLogger(ioutil.Discard, "", 0))
+	conn, err := acceptor.Next(context.Background())
+	assert.Nil(t, conn)
+	assert.Error(t, err)
+}
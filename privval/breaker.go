@@ -0,0 +1,130 @@
+package privval
+
+import (
+	"errors"
+	"regexp"
+	"sync"
+	"time"
+
+	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+)
+
+const (
+	// maxFailuresInARow determines the number of failures of the same class in a row
+	// while handling requests after which the signer is considered degraded.
+	maxFailuresInARow = 5
+
+	// degradedLogInterval determines how often failures are logged while the signer
+	// is degraded.
+	degradedLogInterval = time.Minute
+)
+
+// digits matches the numbers in error messages, e.g. block heights, which differ
+// between otherwise identical errors.
+var digits = regexp.MustCompile(`[0-9]+`)
+
+// breaker keeps track of failures in a row while handling requests, so that a
+// failing signing backend doesn't flood the logs with the same error on every block.
+type breaker struct {
+	mtx      sync.Mutex
+	class    string
+	failures int
+	degraded bool
+	lastLog  time.Time
+}
+
+// errorClass returns the class of the given error, which is the message of the
+// innermost wrapped error with all numbers masked.
+func errorClass(err error) string {
+	for errors.Unwrap(err) != nil {
+		err = errors.Unwrap(err)
+	}
+
+	return digits.ReplaceAllString(err.Error(), "N")
+}
+
+// isRefusal checks whether the given error is a deliberate refusal to sign rather
+// than a failure.
+func isRefusal(err error) bool {
+	return errors.Is(err, ErrNotActiveSigner) || errors.Is(err, ErrDryRun) || errors.Is(err, ErrShuttingDown)
+}
+
+// isSignMsg checks whether the given message asks for a signature.
+func isSignMsg(msg *tm_privvalproto.Message) bool {
+	switch msg.Sum.(type) {
+	case *tm_privvalproto.Message_SignVoteRequest, *tm_privvalproto.Message_SignProposalRequest:
+		return true
+	}
+
+	return false
+}
+
+// trackHandleResult logs the error returned from handling the given request. After
+// maxFailuresInARow failures of the same class in a row, the signer is marked as
+// degraded, the on_degraded_cmd is run and further failures are only logged once per
+// degradedLogInterval. The signer only recovers once a sign request succeeds.
+func (pv *SCFilePV) trackHandleResult(msg *tm_privvalproto.Message, err error) {
+	if err == nil {
+		if isSignMsg(msg) {
+			pv.recoverBreaker()
+		}
+		return
+	}
+	if isRefusal(err) {
+		pv.Logger.Error("couldn't handle request: %v\n", err)
+		return
+	}
+
+	b := &pv.breaker
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if class := errorClass(err); class != b.class {
+		b.class = class
+		b.failures = 0
+	}
+	b.failures++
+
+	switch {
+	case b.failures < maxFailuresInARow:
+		pv.Logger.Error("couldn't handle request: %v\n", err)
+	case !b.degraded:
+		b.degraded = true
+		b.lastLog = time.Now()
+		pv.Logger.Error("couldn't handle request: %v\n", err)
+		pv.Logger.Error("CRITICAL: signer is degraded after %v failures in a row, only logging them once per %v until a request is signed again\n", b.failures, degradedLogInterval)
+		pv.Gauges.DegradedGauge.Set(1)
+		pv.runHook("on_degraded_cmd", pv.hooksConfig().OnDegradedCmd, hookEvent{
+			name:    hookEventDegraded,
+			height:  pv.GetCurrentHeight(),
+			oldRank: pv.GetRank(),
+			newRank: pv.GetRank(),
+			reason:  err.Error(),
+		})
+	case time.Since(b.lastLog) >= degradedLogInterval:
+		b.lastLog = time.Now()
+		pv.Logger.Error("couldn't handle request (%v failures in a row): %v\n", b.failures, err)
+	}
+}
+
+// recoverBreaker resets the failures in a row after a request has been signed.
+func (pv *SCFilePV) recoverBreaker() {
+	b := &pv.breaker
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	if b.degraded {
+		pv.Logger.Info("Signer recovered after %v failures in a row\n", b.failures)
+		pv.Gauges.DegradedGauge.Set(0)
+	}
+	b.class = ""
+	b.failures = 0
+	b.degraded = false
+}
+
+// IsDegraded checks whether the signer is degraded due to too many failures in a row.
+func (pv *SCFilePV) IsDegraded() bool {
+	pv.breaker.mtx.Lock()
+	defer pv.breaker.mtx.Unlock()
+	return pv.breaker.degraded
+}
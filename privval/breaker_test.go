@@ -0,0 +1,138 @@
+package privval
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+	tm_prototypes "github.com/tendermint/tendermint/proto/tendermint/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+// flappingPV fails to sign as long as fail is set.
+type flappingPV struct {
+	tm_types.PrivValidator
+	fail bool
+}
+
+func (pv *flappingPV) SignVote(chainID string, vote *tm_prototypes.Vote) error {
+	if pv.fail {
+		return errors.New("signer unavailable")
+	}
+	return pv.PrivValidator.SignVote(chainID, vote)
+}
+
+func (pv *flappingPV) SignProposal(chainID string, proposal *tm_prototypes.Proposal) error {
+	if pv.fail {
+		return errors.New("signer unavailable")
+	}
+	return pv.PrivValidator.SignProposal(chainID, proposal)
+}
+
+func TestErrorClass(t *testing.T) {
+	// Errors only differing in numbers are of the same class.
+	assert.Equal(t, errorClass(errors.New("height 10 is too low")), errorClass(errors.New("height 11 is too low")))
+	assert.NotEqual(t, errorClass(errors.New("height 10 is too low")), errorClass(errors.New("key file unreadable")))
+
+	// Wrapped errors are classified by their innermost error.
+	err := errors.New("signer unavailable")
+	assert.Equal(t, errorClass(err), errorClass(fmt.Errorf("failed to sign SIGNED_MSG_TYPE_PREVOTE: %w", err)))
+	assert.Equal(t, errorClass(err), errorClass(fmt.Errorf("failed to sign SIGNED_MSG_TYPE_PROPOSAL: %w", err)))
+}
+
+func TestIsRefusal(t *testing.T) {
+	assert.True(t, isRefusal(ErrDryRun))
+	assert.True(t, isRefusal(fmt.Errorf("%w, rank 2", ErrNotActiveSigner)))
+	assert.False(t, isRefusal(errors.New("signer unavailable")))
+}
+
+// countLines counts the lines in the given buffer that contain the given string.
+func countLines(buf *bytes.Buffer, s string) int {
+	return strings.Count(buf.String(), s)
+}
+
+func TestTrackHandleResult_FlappingBackend(t *testing.T) {
+	pv := testWatermarkSCFilePV(t)
+	var buf bytes.Buffer
+	pv.Logger = types.NewSyncLogger(&buf, "", 0)
+	signer := &flappingPV{PrivValidator: pv.TMFilePV}
+	pv.TMFilePV = signer
+
+	// Every request is for a new round, so that the watermark doesn't refuse it.
+	var round int32
+	handle := func() {
+		req := testSignVoteRequest(t)
+		round++
+		req.GetSignVoteRequest().Vote.Round = round
+		_, err := HandleRequest(context.Background(), req, pv)
+		pv.trackHandleResult(req, err)
+	}
+
+	// Failures interrupted by a signature don't degrade the signer.
+	signer.fail = true
+	for i := 0; i < maxFailuresInARow-1; i++ {
+		handle()
+	}
+	signer.fail = false
+	handle()
+	signer.fail = true
+	for i := 0; i < maxFailuresInARow-1; i++ {
+		handle()
+	}
+	assert.False(t, pv.IsDegraded())
+	assert.Equal(t, 2*(maxFailuresInARow-1), countLines(&buf, "couldn't handle request"))
+
+	// Too many failures in a row degrade the signer once.
+	handle()
+	assert.True(t, pv.IsDegraded())
+	assert.Equal(t, 1, countLines(&buf, "CRITICAL"))
+
+	// Further failures are only logged once per degradedLogInterval.
+	buf.Reset()
+	handle()
+	handle()
+	assert.Equal(t, 0, countLines(&buf, "couldn't handle request"))
+	pv.breaker.lastLog = time.Now().Add(-degradedLogInterval)
+	handle()
+	handle()
+	assert.Equal(t, 1, countLines(&buf, "couldn't handle request"))
+	assert.Equal(t, 0, countLines(&buf, "CRITICAL"))
+
+	// A signature recovers the signer.
+	signer.fail = false
+	handle()
+	assert.False(t, pv.IsDegraded())
+	assert.Equal(t, 0, pv.breaker.failures)
+}
+
+func TestTrackHandleResult_Classes(t *testing.T) {
+	pv := mockSCFilePV(t)
+	req := testSignVoteRequest(t)
+
+	// Failures of different classes don't add up.
+	for i := 0; i < maxFailuresInARow; i++ {
+		pv.trackHandleResult(req, fmt.Errorf("error class %c", 'a'+i))
+	}
+	assert.False(t, pv.IsDegraded())
+
+	// Neither do refusals.
+	for i := 0; i < maxFailuresInARow; i++ {
+		pv.trackHandleResult(req, fmt.Errorf("%w, rank 2", ErrNotActiveSigner))
+	}
+	assert.False(t, pv.IsDegraded())
+
+	// Other requests succeeding don't recover the signer.
+	for i := 0; i < maxFailuresInARow; i++ {
+		pv.trackHandleResult(req, errors.New("signer unavailable"))
+	}
+	assert.True(t, pv.IsDegraded())
+	pv.trackHandleResult(wrapMsg(&tm_privvalproto.PingRequest{}), nil)
+	assert.True(t, pv.IsDegraded())
+}
@@ -0,0 +1,94 @@
+package privval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/connection"
+)
+
+// newFailoverConn returns the single connection that fails over between all validator
+// addresses. It starts out on the address persisted as the one that last worked, or
+// on the first one if none of them has been persisted.
+func (pv *SCFilePV) newFailoverConn() *validatorConn {
+	addrs := pv.Config.Base.ListenAddresses()
+	vc := &validatorConn{address: addrs[0], addrs: addrs}
+	for i, addr := range addrs {
+		if addr == pv.State.LastValidatorAddress {
+			vc.index = i
+			vc.address = addr
+			pv.Logger.Info("Dialing %v first, as it's the validator address that last worked", addr)
+			break
+		}
+	}
+
+	return vc
+}
+
+// dialConn establishes a new connection to the validator of the given connection. If
+// it fails over between several addresses, the current one is given up for the next
+// one once it has been idle for failover_idle_timeouts in a row or can't be dialed
+// within failover_dial_attempts. The configured retry policy is then applied to all
+// addresses together. Failing over stops once the given context is canceled.
+func (pv *SCFilePV) dialConn(ctx context.Context, vc *validatorConn) (net.Conn, error) {
+	if len(vc.addrs) == 0 {
+		return pv.dial(ctx, vc.address)
+	}
+
+	cfg := pv.Config.Base
+	if vc.idleTimeouts >= cfg.FailoverIdleTimeouts {
+		pv.failover(vc, fmt.Errorf("no message for %v %v times in a row", config.GetRetryDialTime(cfg.RetryDialAfter), vc.idleTimeouts))
+	}
+	retry := pv.retryConfig()
+	maxElapsed := config.GetDuration(retry.MaxElapsedTime)
+	attempts, elapsed := 0, time.Duration(0)
+	for {
+		conn, err := pv.dial(ctx, vc.address)
+		if err == nil {
+			pv.saveValidatorAddress(vc.address)
+			return conn, nil
+		}
+
+		// Unauthorized validators and aborts aren't retried on other addresses.
+		var exhausted *connection.RetryExhaustedError
+		if !errors.As(err, &exhausted) || ctx.Err() != nil {
+			return nil, err
+		}
+		attempts += exhausted.Attempts
+		elapsed += exhausted.Elapsed
+		if (retry.MaxAttempts > 0 && attempts >= retry.MaxAttempts) || (maxElapsed > 0 && elapsed >= maxElapsed) {
+			return nil, &connection.RetryExhaustedError{Address: vc.address, Attempts: attempts, Elapsed: elapsed, Err: exhausted.Err}
+		}
+		pv.failover(vc, err)
+	}
+}
+
+// failover moves the given connection on to the next validator address round-robin.
+// The failover is reported as a connection event, which locks the counter for missed
+// blocks in a row, as the new validator might not be in sync with the old one.
+func (pv *SCFilePV) failover(vc *validatorConn, reason error) {
+	previous := vc.address
+	vc.index = (vc.index + 1) % len(vc.addrs)
+	vc.setAddress(vc.addrs[vc.index])
+	vc.idleTimeouts = 0
+	pv.Logger.Warn("Failing over from the validator at %v to the validator at %v... (%v)", previous, vc.address, reason)
+	pv.connEvents.Failover(previous, vc.address, reason)
+}
+
+// saveValidatorAddress persists the given address as the validator address that last
+// worked, so that it is tried first after a restart.
+func (pv *SCFilePV) saveValidatorAddress(address string) {
+	pv.stateMtx.Lock()
+	defer pv.stateMtx.Unlock()
+	if pv.State.LastValidatorAddress == address {
+		return
+	}
+	pv.State.LastValidatorAddress = address
+	if err := pv.State.Save(pv.ConfigDir); err != nil {
+		pv.Logger.Error("couldn't persist validator address to %v: %v\n", config.StateFile, err)
+	}
+}
@@ -0,0 +1,169 @@
+package privval
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	tm_protoio "github.com/tendermint/tendermint/libs/protoio"
+	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+)
+
+// failoverConfig enables failover between the given addresses.
+func failoverConfig(pv *SCFilePV, addrs ...string) {
+	pv.Config.Base.Failover = true
+	pv.Config.Base.FailoverDialAttempts = config.DefaultFailoverDialAttempts
+	pv.Config.Base.FailoverIdleTimeouts = config.DefaultFailoverIdleTimeouts
+	pv.Config.Base.ValidatorListenAddress = addrs[0]
+	pv.Config.Base.ValidatorListenAddresses = addrs[1:]
+}
+
+func TestFailover_DeadFirstAddress(t *testing.T) {
+	cfgDir := t.TempDir()
+
+	pv := mockSCFilePVIn(t, cfgDir)
+	var buf bytes.Buffer
+	pv.Logger = types.NewSyncLogger(&buf, "", 0)
+	port, _ := getFreePort(t)
+	pv.HTTP = &http.Server{Addr: fmt.Sprintf(":%v", port)}
+	failoverConfig(pv, "tcp://127.0.0.1:3000", "tcp://127.0.0.1:3001")
+
+	// The first address is dead, the second one works.
+	signerConn, validatorConn := net.Pipe()
+	defer validatorConn.Close()
+	var dials int32
+	pv.dial = func(ctx context.Context, address string) (net.Conn, error) {
+		atomic.AddInt32(&dials, 1)
+		if address == "tcp://127.0.0.1:3000" {
+			return nil, &connection.RetryExhaustedError{Address: address, Attempts: config.DefaultFailoverDialAttempts, Err: errors.New("connection refused")}
+		}
+		return signerConn, nil
+	}
+
+	err := pv.Start()
+	assert.NoError(t, err)
+	assert.Len(t, pv.conns, 1)
+
+	// The validator at the second address is served.
+	_, err = tm_protoio.NewDelimitedWriter(validatorConn).WriteMsg(wrapMsg(&tm_privvalproto.PingRequest{}))
+	assert.NoError(t, err)
+	var resp tm_privvalproto.Message
+	assert.NoError(t, validatorConn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err = tm_protoio.NewDelimitedReader(validatorConn, pv.Config.Privval.MaxMsgSize).ReadMsg(&resp)
+	assert.NoError(t, err)
+	assert.NotNil(t, resp.GetPingResponse())
+	assert.Equal(t, int32(2), atomic.LoadInt32(&dials))
+	assert.True(t, pv.IsCounterLocked())
+	assert.NoError(t, pv.Stop())
+	assert.Contains(t, buf.String(), "Failing over from the validator at tcp://127.0.0.1:3000 to the validator at tcp://127.0.0.1:3001")
+
+	// The address that worked is persisted and tried first after a restart.
+	state, err := config.LoadOrGenState(cfgDir)
+	assert.NoError(t, err)
+	assert.Equal(t, "tcp://127.0.0.1:3001", state.LastValidatorAddress)
+	pv = mockSCFilePVIn(t, cfgDir)
+	pv.State = state
+	failoverConfig(pv, "tcp://127.0.0.1:3000", "tcp://127.0.0.1:3001")
+	vc := pv.newFailoverConn()
+	assert.Equal(t, "tcp://127.0.0.1:3001", vc.address)
+	assert.Equal(t, 1, vc.index)
+}
+
+func TestFailover_IdleTimeouts(t *testing.T) {
+	pv := mockSCFilePV(t)
+	failoverConfig(pv, "tcp://127.0.0.1:3000", "tcp://127.0.0.1:3001", "tcp://127.0.0.1:3002")
+	var dialed []string
+	pv.dial = func(ctx context.Context, address string) (net.Conn, error) {
+		dialed = append(dialed, address)
+		conn, _ := net.Pipe()
+		return conn, nil
+	}
+	vc := pv.newFailoverConn()
+
+	// A single idle timeout redials the same address.
+	vc.idleTimeouts = 1
+	_, err := pv.dialConn(context.Background(), vc)
+	assert.NoError(t, err)
+	assert.Equal(t, "tcp://127.0.0.1:3000", vc.address)
+
+	// Repeated idle timeouts fail over to the next address, round-robin.
+	vc.idleTimeouts = config.DefaultFailoverIdleTimeouts
+	_, err = pv.dialConn(context.Background(), vc)
+	assert.NoError(t, err)
+	assert.Equal(t, "tcp://127.0.0.1:3001", vc.address)
+	assert.Zero(t, vc.idleTimeouts)
+	vc.index, vc.address = 2, "tcp://127.0.0.1:3002"
+	vc.idleTimeouts = config.DefaultFailoverIdleTimeouts
+	_, err = pv.dialConn(context.Background(), vc)
+	assert.NoError(t, err)
+	assert.Equal(t, "tcp://127.0.0.1:3000", vc.address)
+	assert.Equal(t, []string{"tcp://127.0.0.1:3000", "tcp://127.0.0.1:3001", "tcp://127.0.0.1:3000"}, dialed)
+}
+
+func TestFailover_Exhausted(t *testing.T) {
+	pv := mockSCFilePV(t)
+	failoverConfig(pv, "tcp://127.0.0.1:3000", "tcp://127.0.0.1:3001")
+	pv.Config.Connection.Retry.MaxAttempts = 10
+	var dialed []string
+	pv.dial = func(ctx context.Context, address string) (net.Conn, error) {
+		dialed = append(dialed, address)
+		return nil, &connection.RetryExhaustedError{Address: address, Attempts: 3, Err: errors.New("connection refused")}
+	}
+
+	// The retry policy applies to all addresses together.
+	_, err := pv.dialConn(context.Background(), pv.newFailoverConn())
+	var exhausted *connection.RetryExhaustedError
+	assert.True(t, errors.As(err, &exhausted))
+	assert.Equal(t, 12, exhausted.Attempts)
+	assert.Equal(t, []string{"tcp://127.0.0.1:3000", "tcp://127.0.0.1:3001", "tcp://127.0.0.1:3000", "tcp://127.0.0.1:3001"}, dialed)
+
+	// Other errors aren't retried on the other addresses.
+	dialed = nil
+	pv.dial = func(ctx context.Context, address string) (net.Conn, error) {
+		dialed = append(dialed, address)
+		return nil, connection.ErrUnknownConnKey
+	}
+	_, err = pv.dialConn(context.Background(), pv.newFailoverConn())
+	assert.ErrorIs(t, err, connection.ErrUnknownConnKey)
+	assert.Len(t, dialed, 1)
+}
+
+func TestRetryPolicy_Failover(t *testing.T) {
+	cfg := testConfig(t)
+	assert.Equal(t, 0, retryPolicy(cfg.Base, cfg.Connection.Retry, nil).MaxAttempts)
+
+	// Each address is only dialed failover_dial_attempts times.
+	cfg.Base.Failover = true
+	cfg.Base.FailoverDialAttempts = 3
+	assert.Equal(t, 3, retryPolicy(cfg.Base, cfg.Connection.Retry, nil).MaxAttempts)
+	cfg.Connection.Retry.MaxAttempts = 2
+	assert.Equal(t, 2, retryPolicy(cfg.Base, cfg.Connection.Retry, nil).MaxAttempts)
+	cfg.Connection.Retry.MaxAttempts = 1
+	assert.Equal(t, 1, retryPolicy(cfg.Base, cfg.Connection.Retry, nil).MaxAttempts)
+}
+
+func TestHandleConnEvent_Failover(t *testing.T) {
+	pv := mockSCFilePV(t)
+	var buf bytes.Buffer
+	pv.Logger = types.NewSyncLogger(&buf, "", 0)
+	pv.UnlockCounter()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events := connection.NewEvents()
+	defer events.Close()
+	go pv.watchConnEvents(ctx, events)
+	events.Failover("tcp://127.0.0.1:3000", "tcp://127.0.0.1:3001", errors.New("connection refused"))
+	assert.True(t, pv.IsCounterLocked())
+	assert.Contains(t, buf.String(), "failed over from the validator at tcp://127.0.0.1:3000 to the validator at tcp://127.0.0.1:3001")
+}
@@ -0,0 +1,240 @@
+package privval
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/gogo/protobuf/proto"
+	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// privValidatorAPIServer is the server API of Tendermint's PrivValidatorAPI gRPC
+// service (Tendermint v0.35+).
+type privValidatorAPIServer interface {
+	GetPubKey(context.Context, *tm_privvalproto.PubKeyRequest) (*tm_privvalproto.PubKeyResponse, error)
+	SignVote(context.Context, *tm_privvalproto.SignVoteRequest) (*tm_privvalproto.SignedVoteResponse, error)
+	SignProposal(context.Context, *tm_privvalproto.SignProposalRequest) (*tm_privvalproto.SignedProposalResponse, error)
+}
+
+// privValidatorAPIServiceDesc describes Tendermint's PrivValidatorAPI gRPC service as
+// defined in tendermint/privval/service.proto. It reuses the message types of the
+// raw socket protocol, as both protocols share them.
+var privValidatorAPIServiceDesc = grpc.ServiceDesc{
+	ServiceName: "tendermint.privval.PrivValidatorAPI",
+	HandlerType: (*privValidatorAPIServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetPubKey",
+			Handler:    privValidatorAPIGetPubKeyHandler,
+		},
+		{
+			MethodName: "SignVote",
+			Handler:    privValidatorAPISignVoteHandler,
+		},
+		{
+			MethodName: "SignProposal",
+			Handler:    privValidatorAPISignProposalHandler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "tendermint/privval/service.proto",
+}
+
+// privValidatorAPIGetPubKeyHandler decodes GetPubKey calls.
+func privValidatorAPIGetPubKeyHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(tm_privvalproto.PubKeyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(privValidatorAPIServer).GetPubKey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tendermint.privval.PrivValidatorAPI/GetPubKey",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(privValidatorAPIServer).GetPubKey(ctx, req.(*tm_privvalproto.PubKeyRequest))
+	}
+
+	return interceptor(ctx, in, info, handler)
+}
+
+// privValidatorAPISignVoteHandler decodes SignVote calls.
+func privValidatorAPISignVoteHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(tm_privvalproto.SignVoteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(privValidatorAPIServer).SignVote(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tendermint.privval.PrivValidatorAPI/SignVote",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(privValidatorAPIServer).SignVote(ctx, req.(*tm_privvalproto.SignVoteRequest))
+	}
+
+	return interceptor(ctx, in, info, handler)
+}
+
+// privValidatorAPISignProposalHandler decodes SignProposal calls.
+func privValidatorAPISignProposalHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(tm_privvalproto.SignProposalRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(privValidatorAPIServer).SignProposal(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/tendermint.privval.PrivValidatorAPI/SignProposal",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(privValidatorAPIServer).SignProposal(ctx, req.(*tm_privvalproto.SignProposalRequest))
+	}
+
+	return interceptor(ctx, in, info, handler)
+}
+
+// gogoCodec marshals gRPC messages using gogoproto, which Tendermint's proto types are
+// generated with.
+type gogoCodec struct{}
+
+// Marshal implements the grpc.Codec and encoding.Codec interfaces.
+func (gogoCodec) Marshal(v interface{}) ([]byte, error) {
+	return proto.Marshal(v.(proto.Message))
+}
+
+// Unmarshal implements the grpc.Codec and encoding.Codec interfaces.
+func (gogoCodec) Unmarshal(data []byte, v interface{}) error {
+	return proto.Unmarshal(data, v.(proto.Message))
+}
+
+// Name implements the encoding.Codec interface.
+func (gogoCodec) Name() string {
+	return "proto"
+}
+
+// String implements the grpc.Codec interface.
+func (gogoCodec) String() string {
+	return "proto"
+}
+
+// grpcServer implements the PrivValidatorAPI gRPC service on behalf of the SCFilePV.
+type grpcServer struct {
+	pv *SCFilePV
+}
+
+// grpcServer must implement the privValidatorAPIServer interface.
+var _ privValidatorAPIServer = new(grpcServer)
+
+// handleRequest handles the given request like a request received via the raw socket
+// protocol. If the error forces SignCTRL to shut down, it is stopped.
+func (s *grpcServer) handleRequest(ctx context.Context, req proto.Message) (*tm_privvalproto.Message, error) {
+	resp, err := s.pv.safeHandleRequest(ctx, wrapMsg(req))
+	if err != nil {
+		s.pv.Logger.Error("couldn't handle request: %v\n", err)
+		if mustShutdown(err) {
+			s.pv.retire(err)
+
+			// Stopping the gRPC server waits for this call to return, so don't block.
+			go func() {
+				if err := s.pv.Stop(); err != nil {
+					s.pv.Logger.Error("%v", err)
+				}
+			}()
+		}
+	}
+
+	return resp, err
+}
+
+// GetPubKey returns the validator's public key.
+// Implements the privValidatorAPIServer interface.
+func (s *grpcServer) GetPubKey(ctx context.Context, req *tm_privvalproto.PubKeyRequest) (*tm_privvalproto.PubKeyResponse, error) {
+	resp, err := s.handleRequest(ctx, req)
+	if err != nil {
+		return nil, status.Errorf(codes.NotFound, "error getting pubkey: %v", err)
+	}
+
+	return resp.GetPubKeyResponse(), nil
+}
+
+// SignVote signs the given vote if the validator has permission to sign.
+// Implements the privValidatorAPIServer interface.
+func (s *grpcServer) SignVote(ctx context.Context, req *tm_privvalproto.SignVoteRequest) (*tm_privvalproto.SignedVoteResponse, error) {
+	resp, err := s.handleRequest(ctx, req)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "error signing vote: %v", err)
+	}
+
+	return resp.GetSignedVoteResponse(), nil
+}
+
+// SignProposal signs the given proposal if the validator has permission to sign.
+// Implements the privValidatorAPIServer interface.
+func (s *grpcServer) SignProposal(ctx context.Context, req *tm_privvalproto.SignProposalRequest) (*tm_privvalproto.SignedProposalResponse, error) {
+	resp, err := s.handleRequest(ctx, req)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "error signing proposal: %v", err)
+	}
+
+	return resp.GetSignedProposalResponse(), nil
+}
+
+// grpcTransport serves the validator's requests via Tendermint's PrivValidatorAPI gRPC
+// service (Tendermint v0.35+).
+type grpcTransport struct {
+	pv     *SCFilePV
+	server *grpc.Server
+}
+
+// start starts the gRPC server on the configured address, using TLS if certificate
+// and key files are configured.
+// Implements the transport interface.
+func (t *grpcTransport) start(ctx context.Context) (<-chan struct{}, error) {
+	cfg := t.pv.Config.Privval
+	opts := []grpc.ServerOption{grpc.CustomCodec(gogoCodec{})}
+	if cfg.TLSCertFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't load TLS credentials: %v", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	listener, err := connection.Listen(cfg.GRPCListenAddress)
+	if err != nil {
+		return nil, err
+	}
+	t.server = grpc.NewServer(opts...)
+	t.server.RegisterService(&privValidatorAPIServiceDesc, &grpcServer{pv: t.pv})
+
+	t.pv.Logger.Info("Serving the PrivValidatorAPI gRPC service on %v...", cfg.GRPCListenAddress)
+	done := make(chan struct{})
+	go func() {
+		if err := t.server.Serve(listener); err != nil {
+			t.pv.Logger.Error("gRPC server stopped: %v\n", err)
+		}
+		close(done)
+	}()
+
+	return done, nil
+}
+
+// stop stops the gRPC server and closes all open connections.
+// Implements the transport interface.
+func (t *grpcTransport) stop() {
+	if t.server != nil {
+		t.server.Stop()
+	}
+}
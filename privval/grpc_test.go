@@ -0,0 +1,203 @@
+package privval
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	tm_cryptoenc "github.com/tendermint/tendermint/crypto/encoding"
+	tm_privval "github.com/tendermint/tendermint/privval"
+	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// testPrivValidatorAPIClient is a client for the PrivValidatorAPI gRPC service like
+// the one generated for Tendermint v0.35+.
+type testPrivValidatorAPIClient struct {
+	cc *grpc.ClientConn
+}
+
+func (c *testPrivValidatorAPIClient) GetPubKey(ctx context.Context, in *tm_privvalproto.PubKeyRequest) (*tm_privvalproto.PubKeyResponse, error) {
+	out := new(tm_privvalproto.PubKeyResponse)
+	err := c.cc.Invoke(ctx, "/tendermint.privval.PrivValidatorAPI/GetPubKey", in, out)
+	return out, err
+}
+
+func (c *testPrivValidatorAPIClient) SignVote(ctx context.Context, in *tm_privvalproto.SignVoteRequest) (*tm_privvalproto.SignedVoteResponse, error) {
+	out := new(tm_privvalproto.SignedVoteResponse)
+	err := c.cc.Invoke(ctx, "/tendermint.privval.PrivValidatorAPI/SignVote", in, out)
+	return out, err
+}
+
+func (c *testPrivValidatorAPIClient) SignProposal(ctx context.Context, in *tm_privvalproto.SignProposalRequest) (*tm_privvalproto.SignedProposalResponse, error) {
+	out := new(tm_privvalproto.SignedProposalResponse)
+	err := c.cc.Invoke(ctx, "/tendermint.privval.PrivValidatorAPI/SignProposal", in, out)
+	return out, err
+}
+
+// startGRPCSCFilePV starts an SCFilePV that serves the PrivValidatorAPI gRPC service
+// and returns a client connected to it.
+func startGRPCSCFilePV(t *testing.T, cfg config.Config, dialOpt grpc.DialOption) (*SCFilePV, *testPrivValidatorAPIClient) {
+	t.Helper()
+	cfgDir := t.TempDir()
+
+	grpcPort, _ := getFreePort(t)
+	cfg.Privval.Transport = config.TransportGRPC
+	cfg.Privval.GRPCListenAddress = fmt.Sprintf("tcp://127.0.0.1:%v", grpcPort)
+
+	httpPort, _ := getFreePort(t)
+	tmpv := testFilePV(t).(*tm_privval.FilePV)
+	pv, err := NewSCFilePV(
+		types.NewSyncLogger(ioutil.Discard, "", 0),
+		cfgDir,
+		cfg,
+		testState(t),
+		tm_privval.NewFilePV(tmpv.Key.PrivKey, filepath.Join(cfgDir, KeyFile), filepath.Join(cfgDir, StateFile)),
+		&http.Server{Addr: fmt.Sprintf(":%v", httpPort)},
+	)
+	assert.NoError(t, err)
+	err = pv.Start()
+	assert.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	cc, err := grpc.DialContext(
+		ctx,
+		fmt.Sprintf("127.0.0.1:%v", grpcPort),
+		dialOpt,
+		grpc.WithBlock(),
+		grpc.WithDefaultCallOptions(grpc.ForceCodec(gogoCodec{})),
+	)
+	assert.NoError(t, err)
+	t.Cleanup(func() { cc.Close() })
+
+	return pv, &testPrivValidatorAPIClient{cc: cc}
+}
+
+func TestGRPCTransport(t *testing.T) {
+	cfg := testConfig(t)
+
+	// Start mock endpoint for the block query.
+	port, _ := getFreePort(t)
+	cfg.Base.ValidatorListenAddressRPC = fmt.Sprintf("tcp://127.0.0.1:%v", port)
+	quitCh := make(chan struct{})
+	testBlockEndpoint(t, port, testBlockResult(t), quitCh)
+	defer close(quitCh)
+
+	pv, client := startGRPCSCFilePV(t, cfg, grpc.WithInsecure())
+	ctx := context.Background()
+
+	// GetPubKey.
+	pubResp, err := client.GetPubKey(ctx, testPubKeyRequest(t).GetPubKeyRequest())
+	assert.NoError(t, err)
+	pub, _ := pv.TMFilePV.GetPubKey()
+	pubProto, _ := tm_cryptoenc.PubKeyToProto(pub)
+	assert.Equal(t, pubProto, pubResp.PubKey)
+
+	// SignProposal.
+	propResp, err := client.SignProposal(ctx, testSignProposalRequest(t).GetSignProposalRequest())
+	assert.NoError(t, err)
+	assert.NotEqual(t, []byte("Signature"), propResp.Proposal.Signature)
+	assert.NotEmpty(t, propResp.Proposal.Signature)
+
+	// SignVote.
+	voteResp, err := client.SignVote(ctx, testSignVoteRequest(t).GetSignVoteRequest())
+	assert.NoError(t, err)
+	assert.NotEmpty(t, voteResp.Vote.Signature)
+
+	// Requests for the wrong chain are rejected like via the raw socket protocol.
+	req := testSignVoteRequest(t).GetSignVoteRequest()
+	req.ChainId = "wrongchain"
+	_, err = client.SignVote(ctx, req)
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	err = pv.Stop()
+	assert.NoError(t, err)
+
+	select {
+	case <-pv.runDone:
+	case <-time.After(time.Second):
+		t.Fatal("expected the gRPC server to stop within 1s")
+	}
+}
+
+// testTLSFiles creates a self-signed certificate for 127.0.0.1, which can be used by
+// both servers and clients, and returns the paths to the certificate and key files as
+// well as the certificate pool to verify it.
+func testTLSFiles(t *testing.T) (string, string, *x509.CertPool) {
+	t.Helper()
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	assert.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(priv)
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	err = ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+	assert.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	return certFile, keyFile, pool
+}
+
+func TestGRPCTransport_TLS(t *testing.T) {
+	cfg := testConfig(t)
+	certFile, keyFile, pool := testTLSFiles(t)
+	cfg.Privval.TLSCertFile = certFile
+	cfg.Privval.TLSKeyFile = keyFile
+
+	pv, client := startGRPCSCFilePV(t, cfg, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: pool})))
+	defer pv.Stop()
+
+	_, err := client.GetPubKey(context.Background(), testPubKeyRequest(t).GetPubKeyRequest())
+	assert.NoError(t, err)
+}
+
+func TestGRPCTransport_InvalidTLS(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Privval.Transport = config.TransportGRPC
+	cfg.Privval.GRPCListenAddress = "tcp://127.0.0.1:0"
+	cfg.Privval.TLSCertFile = "/nonexistent/cert.pem"
+	cfg.Privval.TLSKeyFile = "/nonexistent/key.pem"
+
+	pv, err := NewSCFilePV(types.NewSyncLogger(ioutil.Discard, "", 0), t.TempDir(), cfg, testState(t), testFilePV(t), &http.Server{})
+	assert.NoError(t, err)
+	done, err := pv.transport.start(context.Background())
+	assert.Nil(t, done)
+	assert.Error(t, err)
+}
@@ -0,0 +1,55 @@
+package privval
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/connection"
+)
+
+// withHello wraps the given dial function, so that a hello is exchanged with every
+// validator connected to before it is served. If the exchange fails, e.g. as the
+// validator doesn't answer within hello_timeout, it is dialed again after the
+// initial_interval of the retry policy. A validator that uses another chain ID is given up right away,
+// just like one using an unauthorized key.
+func (pv *SCFilePV) withHello(dial func(ctx context.Context, address string) (net.Conn, error)) func(ctx context.Context, address string) (net.Conn, error) {
+	timeout := config.GetDuration(pv.Config.Privval.HelloTimeout)
+	interval := config.GetDuration(pv.retryConfig().InitialInterval)
+	return func(ctx context.Context, address string) (net.Conn, error) {
+		for {
+			conn, err := dial(ctx, address)
+			if err != nil {
+				return nil, err
+			}
+			hello, err := connection.ExchangeHello(conn, pv.hello(), timeout)
+			if err == nil {
+				pv.Logger.Info("Exchanged hello with the validator at %v (version: %v, rank: %v, chain ID: %v) ✓", conn.RemoteAddr(), hello.Version, hello.Rank, hello.ChainID)
+				return conn, nil
+			}
+			conn.Close()
+			if errors.Is(err, connection.ErrHelloMismatch) {
+				return nil, err
+			}
+
+			pv.Logger.Warn("Hello with the validator at %v failed, dialing it again in %v: %v", conn.RemoteAddr(), interval, err)
+			timer := pv.clock.NewTimer(interval)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			case <-timer.C():
+			}
+		}
+	}
+}
+
+// hello returns the hello SignCTRL sends to the validator.
+func (pv *SCFilePV) hello() *connection.Hello {
+	return &connection.Hello{
+		Version: pv.Version,
+		Rank:    int64(pv.GetRank()),
+		ChainID: pv.Config.Privval.ChainID,
+	}
+}
@@ -0,0 +1,79 @@
+package privval
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/stretchr/testify/assert"
+)
+
+// helloValidator returns a dial function that connects to a validator answering the
+// hello with the given chain IDs, one per dial. An empty chain ID is a validator that
+// doesn't speak the hello.
+func helloValidator(chainIDs ...string) (dial func(ctx context.Context, address string) (net.Conn, error), dials *int) {
+	dials = new(int)
+	dial = func(ctx context.Context, address string) (net.Conn, error) {
+		local, remote := net.Pipe()
+		chainID := chainIDs[*dials]
+		*dials++
+		go func() {
+			if chainID != "" {
+				_, _ = connection.ExchangeHello(remote, &connection.Hello{Version: "v0.34.8", ChainID: chainID}, time.Second)
+			}
+		}()
+		return local, nil
+	}
+
+	return dial, dials
+}
+
+func TestWithHello(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.Config.Privval.HelloTimeout = "1s"
+	pv.Config.Connection.Retry.InitialInterval = "1s"
+	dial, dials := helloValidator("testchain")
+
+	conn, err := pv.withHello(dial)(context.Background(), "tcp://127.0.0.1:3000")
+	assert.NoError(t, err)
+	assert.NotNil(t, conn)
+	assert.Equal(t, 1, *dials)
+	conn.Close()
+}
+
+func TestWithHello_Mismatch(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.Config.Privval.HelloTimeout = "1s"
+	pv.Config.Connection.Retry.InitialInterval = "1s"
+	dial, dials := helloValidator("mainnet", "testchain")
+
+	// A validator of another chain is given up right away.
+	conn, err := pv.withHello(dial)(context.Background(), "tcp://127.0.0.1:3000")
+	assert.Nil(t, conn)
+	assert.ErrorIs(t, err, connection.ErrHelloMismatch)
+	assert.Equal(t, 1, *dials)
+}
+
+func TestWithHello_Retry(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.Config.Privval.HelloTimeout = "1s"
+	pv.Config.Connection.Retry.InitialInterval = "1s"
+	dial, dials := helloValidator("", "testchain")
+
+	// A validator that doesn't answer is dialed again.
+	conn, err := pv.withHello(dial)(context.Background(), "tcp://127.0.0.1:3000")
+	assert.NoError(t, err)
+	assert.NotNil(t, conn)
+	assert.Equal(t, 2, *dials)
+	conn.Close()
+
+	// Waiting for the next dial is aborted once the context is canceled.
+	dial, _ = helloValidator("", "testchain")
+	ctx, cancel := context.WithTimeout(context.Background(), 1500*time.Millisecond)
+	defer cancel()
+	pv.Config.Connection.Retry.InitialInterval = "1m"
+	_, err = pv.withHello(dial)(ctx, "tcp://127.0.0.1:3000")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -0,0 +1,118 @@
+package privval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+)
+
+const (
+	// EnvHookEvent is the environment variable passed to hook commands that holds
+	// the event they are run for, which is either promote, shutdown or degraded.
+	EnvHookEvent = "SIGNCTRL_EVENT"
+
+	// EnvHookHeight is the environment variable passed to hook commands that holds
+	// the block height of the event.
+	EnvHookHeight = "SIGNCTRL_HEIGHT"
+
+	// EnvHookOldRank is the environment variable passed to hook commands that holds
+	// the validator's rank before the event.
+	EnvHookOldRank = "SIGNCTRL_OLD_RANK"
+
+	// EnvHookNewRank is the environment variable passed to hook commands that holds
+	// the validator's rank after the event.
+	EnvHookNewRank = "SIGNCTRL_NEW_RANK"
+
+	// EnvHookReason is the environment variable passed to hook commands that holds
+	// the reason the validator has been promoted for, SignCTRL shuts itself down or
+	// the signer is degraded.
+	EnvHookReason = "SIGNCTRL_REASON"
+
+	// hookEventPromote is the event of a promotion.
+	hookEventPromote = "promote"
+
+	// hookEventShutdown is the event of a self-induced shutdown.
+	hookEventShutdown = "shutdown"
+
+	// hookEventDegraded is the event of the signer being degraded.
+	hookEventDegraded = "degraded"
+)
+
+// hookEvent describes the event a hook command is run for.
+type hookEvent struct {
+	name    string
+	height  int64
+	oldRank int
+	newRank int
+	reason  string
+}
+
+// env returns the environment variables describing the event.
+func (e hookEvent) env() []string {
+	env := []string{
+		fmt.Sprintf("%v=%v", EnvHookEvent, e.name),
+		fmt.Sprintf("%v=%v", EnvHookHeight, e.height),
+		fmt.Sprintf("%v=%v", EnvHookOldRank, e.oldRank),
+		fmt.Sprintf("%v=%v", EnvHookNewRank, e.newRank),
+	}
+	if e.reason != "" {
+		env = append(env, fmt.Sprintf("%v=%v", EnvHookReason, e.reason))
+	}
+
+	return env
+}
+
+// runHook runs the given shell command in the background with the event passed in
+// environment variables. The command is killed once the hooks timeout is over. As
+// hooks must never delay or fail the rank update they are run for, failures are
+// only logged.
+func (pv *SCFilePV) runHook(cmdName string, cmd string, event hookEvent) {
+	if cmd == "" {
+		return
+	}
+	timeout := config.GetDuration(pv.hooksConfig().Timeout)
+
+	pv.hooks.Add(1)
+	go func() {
+		defer pv.hooks.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		c := exec.CommandContext(ctx, "sh", "-c", cmd)
+		c.Env = append(os.Environ(), event.env()...)
+		out, err := c.CombinedOutput()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("killed after %v", timeout)
+		}
+		if err != nil {
+			pv.Logger.Error("%v failed: %v (%v)", cmdName, err, strings.TrimSpace(string(out)))
+			return
+		}
+		pv.Logger.Info("Ran %v for %v at block height %v", cmdName, event.name, event.height)
+	}()
+}
+
+// waitHooks waits for the hook commands still running, but no longer than the hooks
+// timeout, so that a shutdown hook isn't cut off by SignCTRL exiting.
+func (pv *SCFilePV) waitHooks() {
+	done := make(chan struct{})
+	go func() {
+		pv.hooks.Wait()
+		close(done)
+	}()
+
+	timeout := pv.hooksConfig().Timeout
+	timer := pv.clock.NewTimer(config.GetDuration(timeout))
+	defer timer.Stop()
+
+	select {
+	case <-done:
+	case <-timer.C():
+		pv.Logger.Warn("Hook commands are still running after %v, not waiting for them any longer", timeout)
+	}
+}
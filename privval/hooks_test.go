@@ -0,0 +1,108 @@
+package privval
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// testHookCmd returns a hook command that writes the SignCTRL environment variables
+// it is run with to the returned file.
+func testHookCmd(t *testing.T) (string, string) {
+	t.Helper()
+	out := filepath.Join(t.TempDir(), "env")
+	return fmt.Sprintf("env | grep ^SIGNCTRL_ > %v", out), out
+}
+
+func TestOnPromoteCmd(t *testing.T) {
+	pv := mockSCFilePV(t)
+	cmd, out := testHookCmd(t)
+	pv.Config.Hooks.OnPromoteCmd = cmd
+	pv.BaseSignCtrled.SetRank(2)
+	pv.SetCurrentHeight(42)
+
+	assert.NoError(t, pv.Promote())
+	pv.hooks.Wait()
+	env, err := ioutil.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Contains(t, string(env), "SIGNCTRL_EVENT=promote\n")
+	assert.Contains(t, string(env), "SIGNCTRL_HEIGHT=42\n")
+	assert.Contains(t, string(env), "SIGNCTRL_OLD_RANK=2\n")
+	assert.Contains(t, string(env), "SIGNCTRL_NEW_RANK=1\n")
+	assert.Contains(t, string(env), "SIGNCTRL_REASON=threshold_exceeded\n")
+}
+
+func TestOnShutdownCmd(t *testing.T) {
+	pv := mockSCFilePV(t)
+	cmd, out := testHookCmd(t)
+	pv.Config.Hooks.OnShutdownCmd = cmd
+	pv.SetCurrentHeight(42)
+
+	pv.retire(&types.MustShutdownError{Height: 42})
+	pv.hooks.Wait()
+	env, err := ioutil.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Contains(t, string(env), "SIGNCTRL_EVENT=shutdown\n")
+	assert.Contains(t, string(env), "SIGNCTRL_HEIGHT=42\n")
+	assert.Contains(t, string(env), "SIGNCTRL_OLD_RANK=1\n")
+	assert.Contains(t, string(env), fmt.Sprintf("SIGNCTRL_NEW_RANK=%v\n", pv.Config.Base.SetSize))
+	assert.Contains(t, string(env), "SIGNCTRL_REASON=")
+}
+
+func TestOnDegradedCmd(t *testing.T) {
+	pv := mockSCFilePV(t)
+	cmd, out := testHookCmd(t)
+	pv.Config.Hooks.OnDegradedCmd = cmd
+	pv.SetCurrentHeight(42)
+
+	// The hook is only run once the signer is degraded.
+	req := testSignVoteRequest(t)
+	for i := 0; i < maxFailuresInARow-1; i++ {
+		pv.trackHandleResult(req, errors.New("signer unavailable"))
+	}
+	pv.hooks.Wait()
+	_, err := os.Stat(out)
+	assert.True(t, os.IsNotExist(err))
+
+	pv.trackHandleResult(req, errors.New("signer unavailable"))
+	pv.hooks.Wait()
+	env, err := ioutil.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Contains(t, string(env), "SIGNCTRL_EVENT=degraded\n")
+	assert.Contains(t, string(env), "SIGNCTRL_HEIGHT=42\n")
+	assert.Contains(t, string(env), "SIGNCTRL_OLD_RANK=1\n")
+	assert.Contains(t, string(env), "SIGNCTRL_NEW_RANK=1\n")
+	assert.Contains(t, string(env), "SIGNCTRL_REASON=signer unavailable\n")
+}
+
+func TestRunHook_Failure(t *testing.T) {
+	var buf bytes.Buffer
+	pv := mockSCFilePV(t)
+	pv.Logger = types.NewSyncLogger(&buf, "", 0)
+	pv.Config.Hooks.Timeout = "1s"
+	pv.BaseSignCtrled.SetRank(3)
+
+	// A failing hook is logged, but doesn't fail the promotion.
+	pv.Config.Hooks.OnPromoteCmd = "echo broken; exit 3"
+	assert.NoError(t, pv.Promote())
+	assert.Equal(t, 2, pv.GetRank())
+
+	// A hanging hook doesn't block the promotion and is killed after the timeout.
+	pv.Config.Hooks.OnPromoteCmd = "exec sleep 10"
+	start := time.Now()
+	assert.NoError(t, pv.Promote())
+	assert.Equal(t, 1, pv.GetRank())
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+
+	pv.hooks.Wait()
+	assert.Contains(t, buf.String(), "on_promote_cmd failed: exit status 3 (broken)")
+	assert.Contains(t, buf.String(), "on_promote_cmd failed: killed after 1s")
+}
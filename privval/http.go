@@ -1,11 +1,17 @@
 package privval
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/BlockscapeNetwork/signctrl/types"
 	tm_json "github.com/tendermint/tendermint/libs/json"
 )
 
@@ -16,11 +22,41 @@ const (
 
 // StatusResponse defines the response JSON for status requests.
 type StatusResponse struct {
-	Height    int64 `json:"height"`
-	Rank      int   `json:"rank"`
-	SetSize   int   `json:"set_size"`
-	Counter   int   `json:"counter"`
-	Threshold int   `json:"threshold"`
+	Height      int64  `json:"height"`
+	Rank        int    `json:"rank"`
+	SetSize     int    `json:"set_size"`
+	Counter     int    `json:"counter"`
+	Threshold   int    `json:"threshold"`
+	DryRun      bool   `json:"dry_run"`
+	Paused      bool   `json:"paused"`
+	PauseReason string `json:"pause_reason"`
+
+	// PromoteReason is the reason the validator has last been promoted for, if it
+	// has been promoted since it started.
+	PromoteReason string `json:"promote_reason"`
+
+	// The downtime stats cover the missed blocks counted since the start. The
+	// estimated downtime is the total number of them multiplied by the average
+	// block time.
+	TotalMissed         int64         `json:"total_missed"`
+	LongestMissedStreak int           `json:"longest_missed_streak"`
+	AvgBlockTime        time.Duration `json:"avg_block_time"`
+	EstimatedDowntime   time.Duration `json:"estimated_downtime"`
+
+	// PromotionSuppressed is set while automatic promotions are suppressed due to
+	// max_auto_promotions.
+	PromotionSuppressed bool `json:"promotion_suppressed"`
+
+	// Conn are the statistics of the connections to the validators.
+	Conn types.ConnStats `json:"conn"`
+
+	// The fingerprint and the base64-encoded public key of the current connection
+	// key. They are empty if the transport doesn't use one.
+	ConnKeyFingerprint string `json:"conn_key_fingerprint"`
+	ConnPubKey         string `json:"conn_pub_key"`
+
+	// Peers are the other SignCTRL nodes of the set from the peers setting.
+	Peers []types.Peer `json:"peers"`
 }
 
 // GetStatus retrieves the node's status in terms of current height, rank
@@ -45,14 +81,127 @@ func GetStatus() (*StatusResponse, error) {
 	return &sr, nil
 }
 
+// ThresholdRequest defines the request JSON for changing the threshold.
+type ThresholdRequest struct {
+	Threshold int `json:"threshold"`
+}
+
+// PauseRequest defines the request JSON for pausing the monitoring of missed blocks.
+type PauseRequest struct {
+	Reason string `json:"reason"`
+}
+
+// postAdmin sends the given request to the admin endpoint at the given path and
+// parses the node's response into the given response.
+func postAdmin(path string, req interface{}, resp interface{}) error {
+	body, err := tm_json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpResp, err := http.DefaultClient.Post(fmt.Sprintf("http://127.0.0.1:%v%v", DefaultHTTPPort, path), "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+
+	bz, err := ioutil.ReadAll(httpResp.Body)
+	if err != nil {
+		return err
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		return errors.New(strings.TrimSpace(string(bz)))
+	}
+
+	return tm_json.Unmarshal(bz, resp)
+}
+
+// postAdminStatus sends the given request to the admin endpoint at the given path and
+// returns the node's status afterwards.
+func postAdminStatus(path string, req interface{}) (*StatusResponse, error) {
+	var sr StatusResponse
+	if err := postAdmin(path, req, &sr); err != nil {
+		return nil, err
+	}
+
+	return &sr, nil
+}
+
+// SetThreshold sets the node's threshold of blocks missed in a row at runtime and
+// returns the node's status afterwards.
+func SetThreshold(threshold int) (*StatusResponse, error) {
+	return postAdminStatus("/threshold", ThresholdRequest{Threshold: threshold})
+}
+
+// Pause pauses the node's monitoring of missed blocks for the given reason and
+// returns the node's status afterwards.
+func Pause(reason string) (*StatusResponse, error) {
+	return postAdminStatus("/pause", PauseRequest{Reason: reason})
+}
+
+// Resume resumes the node's monitoring of missed blocks and returns the node's status
+// afterwards.
+func Resume() (*StatusResponse, error) {
+	return postAdminStatus("/resume", struct{}{})
+}
+
+// Promote promotes the node by one rank and returns the node's status afterwards.
+func Promote() (*StatusResponse, error) {
+	return postAdminStatus("/promote", struct{}{})
+}
+
+// RotateConnKeyResponse defines the response JSON for rotating the connection key.
+// The public keys are base64-encoded. OldPubKey is empty if the old connection key
+// couldn't be loaded.
+type RotateConnKeyResponse struct {
+	OldPubKey string `json:"old_pub_key"`
+	NewPubKey string `json:"new_pub_key"`
+}
+
+// RotateConnKey replaces the node's connection key with a new one, which is used from
+// the next (re)connect to the validator on, and returns both public keys.
+func RotateConnKey() (*RotateConnKeyResponse, error) {
+	var resp RotateConnKeyResponse
+	if err := postAdmin("/rotate-conn-key", struct{}{}, &resp); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// status returns the node's current status.
+func (pv *SCFilePV) status() StatusResponse {
+	snapshot := pv.GetStateSnapshot()
+	fingerprint, pubKey := pv.connKeyInfo()
+	return StatusResponse{
+		Height:      pv.GetCurrentHeight(),
+		Rank:        pv.GetRank(),
+		SetSize:     snapshot.SetSize,
+		Counter:     pv.GetMissedInARow(),
+		Threshold:   pv.GetThreshold(),
+		DryRun:      pv.Config.Privval.DryRun,
+		Paused:      pv.IsPaused(),
+		PauseReason: pv.GetPauseReason(),
+
+		PromoteReason: string(pv.GetPromoteReason()),
+
+		TotalMissed:         snapshot.TotalMissed,
+		LongestMissedStreak: snapshot.LongestMissedStreak,
+		AvgBlockTime:        snapshot.AvgBlockTime,
+		EstimatedDowntime:   snapshot.EstimatedDowntime,
+
+		PromotionSuppressed: snapshot.PromotionSuppressed,
+
+		Conn: pv.GetConnStats(),
+
+		ConnKeyFingerprint: fingerprint,
+		ConnPubKey:         pubKey,
+
+		Peers: snapshot.Peers,
+	}
+}
+
 func (pv *SCFilePV) statusHandler(rw http.ResponseWriter, r *http.Request) {
-	bytes, err := tm_json.Marshal(StatusResponse{
-		Height:    pv.GetCurrentHeight(),
-		Rank:      pv.GetRank(),
-		SetSize:   pv.Config.Base.SetSize,
-		Counter:   pv.GetMissedInARow(),
-		Threshold: pv.GetThreshold(),
-	})
+	bytes, err := tm_json.Marshal(pv.status())
 	if err != nil {
 		_, _ = rw.Write(nil)
 		return
@@ -61,13 +210,171 @@ func (pv *SCFilePV) statusHandler(rw http.ResponseWriter, r *http.Request) {
 	_, _ = rw.Write(bytes)
 }
 
+// isLoopback checks whether the given remote address of a request is a loopback
+// address.
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+
+	return ip != nil && ip.IsLoopback()
+}
+
+// parseAdminRequest parses the body of a request to an admin endpoint into the given
+// request, which is left untouched if the body is empty. As the HTTP server listens
+// on all interfaces, only POST requests from localhost are accepted. If the request
+// is refused, an error is written to the response and false is returned.
+func (pv *SCFilePV) parseAdminRequest(rw http.ResponseWriter, r *http.Request, req interface{}) bool {
+	if r.Method != http.MethodPost {
+		http.Error(rw, fmt.Sprintf("%v only accepts POST requests", r.URL.Path), http.StatusMethodNotAllowed)
+		return false
+	}
+	if !isLoopback(r.RemoteAddr) {
+		pv.Logger.Warn("Refused request to %v from %v, as it is only accepted from localhost", r.URL.Path, r.RemoteAddr)
+		http.Error(rw, fmt.Sprintf("%v is only accepted from localhost", r.URL.Path), http.StatusForbidden)
+		return false
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return true
+	}
+	if err := tm_json.Unmarshal(body, req); err != nil {
+		http.Error(rw, fmt.Sprintf("couldn't parse request: %v", err), http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+// writeAdminResponse writes the node's status to the response of an admin request.
+func (pv *SCFilePV) writeAdminResponse(rw http.ResponseWriter) {
+	bz, err := tm_json.Marshal(pv.status())
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	_, _ = rw.Write(bz)
+}
+
+// thresholdHandler sets the threshold of blocks missed in a row at runtime.
+func (pv *SCFilePV) thresholdHandler(rw http.ResponseWriter, r *http.Request) {
+	var req ThresholdRequest
+	if !pv.parseAdminRequest(rw, r, &req) {
+		return
+	}
+	if err := pv.BaseSignCtrled.SetThreshold(req.Threshold); err != nil {
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	pv.Gauges.ThresholdGauge.Set(float64(pv.GetThreshold()))
+
+	pv.writeAdminResponse(rw)
+}
+
+// pauseHandler pauses the monitoring of missed blocks.
+func (pv *SCFilePV) pauseHandler(rw http.ResponseWriter, r *http.Request) {
+	var req PauseRequest
+	if !pv.parseAdminRequest(rw, r, &req) {
+		return
+	}
+	if req.Reason == "" {
+		http.Error(rw, "reason must not be empty", http.StatusBadRequest)
+		return
+	}
+	pv.BaseSignCtrled.Pause(req.Reason)
+
+	pv.writeAdminResponse(rw)
+}
+
+// resumeHandler resumes the monitoring of missed blocks.
+func (pv *SCFilePV) resumeHandler(rw http.ResponseWriter, r *http.Request) {
+	var req struct{}
+	if !pv.parseAdminRequest(rw, r, &req) {
+		return
+	}
+	pv.BaseSignCtrled.Resume()
+
+	pv.writeAdminResponse(rw)
+}
+
+// promoteHandler promotes the validator by one rank, which is possible even while
+// automatic promotions are suppressed. Rank 1 can't be promoted. It is serialized with
+// the handling of requests, as both update the rank.
+func (pv *SCFilePV) promoteHandler(rw http.ResponseWriter, r *http.Request) {
+	var req struct{}
+	if !pv.parseAdminRequest(rw, r, &req) {
+		return
+	}
+
+	pv.handleMtx.Lock()
+	if pv.GetRank() == 1 {
+		pv.handleMtx.Unlock()
+		http.Error(rw, "validator is already on rank 1", http.StatusBadRequest)
+		return
+	}
+	err := pv.PromoteWithReason(types.PromoteReasonManual)
+	pv.handleMtx.Unlock()
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	pv.Gauges.MissedInARowGauge.Set(0)
+
+	pv.writeAdminResponse(rw)
+}
+
+// rotateConnKeyHandler replaces the connection key with a new one. The established
+// connections keep using the old one until they are reconnected.
+func (pv *SCFilePV) rotateConnKeyHandler(rw http.ResponseWriter, r *http.Request) {
+	var req struct{}
+	if !pv.parseAdminRequest(rw, r, &req) {
+		return
+	}
+	oldKey, newKey, err := connection.RotateConnKey(pv.ConfigDir)
+	if err != nil {
+		pv.Logger.Error("couldn't rotate %v: %v", connection.KeyFile, err)
+		http.Error(rw, fmt.Sprintf("couldn't rotate %v: %v", connection.KeyFile, err), http.StatusInternalServerError)
+		return
+	}
+	pv.Logger.Info("Rotated %v (old: %v, new: %v), the new key is used from the next (re)connect on", connection.KeyFile, connection.ConnKeyFingerprint(oldKey), connection.ConnKeyFingerprint(newKey))
+
+	resp := RotateConnKeyResponse{NewPubKey: connection.ConnPubKey(newKey)}
+	if oldKey != nil {
+		resp.OldPubKey = connection.ConnPubKey(oldKey)
+	}
+	bz, err := tm_json.Marshal(resp)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	_, _ = rw.Write(bz)
+}
+
 // StartHTTPServer starts an HTTP server.
 func (pv *SCFilePV) StartHTTPServer() error {
 	pv.Logger.Info("Starting HTTP server...")
 
-	var errCh chan error
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", pv.statusHandler)
+	mux.HandleFunc("/threshold", pv.thresholdHandler)
+	mux.HandleFunc("/pause", pv.pauseHandler)
+	mux.HandleFunc("/resume", pv.resumeHandler)
+	mux.HandleFunc("/promote", pv.promoteHandler)
+	mux.HandleFunc("/rotate-conn-key", pv.rotateConnKeyHandler)
+	mux.HandleFunc("/reload", pv.reloadHandler)
+	pv.HTTP.Handler = mux
+
+	errCh := make(chan error, 1)
 	go func() {
-		http.HandleFunc("/status", pv.statusHandler)
 		if err := pv.HTTP.ListenAndServe(); err != nil {
 			errCh <- err
 		}
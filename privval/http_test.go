@@ -1,9 +1,21 @@
 package privval
 
 import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/BlockscapeNetwork/signctrl/types"
 	"github.com/stretchr/testify/assert"
+	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_p2pconn "github.com/tendermint/tendermint/p2p/conn"
 )
 
 func TestGetStatus(t *testing.T) {
@@ -14,4 +26,166 @@ func TestGetStatus(t *testing.T) {
 	sr, err := GetStatus()
 	assert.NotNil(t, sr)
 	assert.NoError(t, err)
+	assert.False(t, sr.DryRun)
+}
+
+func TestStatus_Peers(t *testing.T) {
+	pv := mockSCFilePV(t)
+	sr := pv.status()
+	assert.Equal(t, 3, sr.SetSize)
+	assert.Empty(t, sr.Peers)
+
+	// The peers are parsed from the configuration.
+	key := base64.StdEncoding.EncodeToString(tm_ed25519.GenPrivKey().PubKey().Bytes())
+	cfg := testConfig(t)
+	cfg.Base.Peers = []string{key + "@tcp://10.0.0.2:26660"}
+	pv, err := NewSCFilePV(types.NewSyncLogger(ioutil.Discard, "", 0), t.TempDir(), cfg, testState(t), testFilePV(t), &http.Server{})
+	assert.NoError(t, err)
+	sr = pv.status()
+	assert.Equal(t, []types.Peer{{Address: "10.0.0.2:26660", ConnPubKey: key}}, sr.Peers)
+}
+
+func TestThresholdHandler(t *testing.T) {
+	pv := mockSCFilePV(t)
+	request := func(method string, remoteAddr string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/threshold", strings.NewReader(body))
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		pv.thresholdHandler(rec, req)
+		return rec
+	}
+
+	rec := request(http.MethodPost, "127.0.0.1:50000", `{"threshold":"15"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var sr StatusResponse
+	assert.NoError(t, tm_json.Unmarshal(rec.Body.Bytes(), &sr))
+	assert.Equal(t, 15, sr.Threshold)
+	assert.Equal(t, 15, pv.GetThreshold())
+
+	// Invalid thresholds are rejected.
+	rec = request(http.MethodPost, "[::1]:50000", `{"threshold":"1"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), types.ErrInvalidThreshold.Error())
+	rec = request(http.MethodPost, "127.0.0.1:50000", `invalid`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// The threshold can only be set from localhost with a POST request.
+	rec = request(http.MethodPost, "10.0.0.2:50000", `{"threshold":"5"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	rec = request(http.MethodGet, "127.0.0.1:50000", "")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, 15, pv.GetThreshold())
+}
+
+func TestPauseHandler(t *testing.T) {
+	pv := mockSCFilePV(t)
+	request := func(handler http.HandlerFunc, remoteAddr string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		return rec
+	}
+
+	// A reason is required.
+	rec := request(pv.pauseHandler, "127.0.0.1:50000", `{}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.False(t, pv.IsPaused())
+
+	rec = request(pv.pauseHandler, "10.0.0.2:50000", `{"reason":"chain upgrade"}`)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.False(t, pv.IsPaused())
+
+	rec = request(pv.pauseHandler, "127.0.0.1:50000", `{"reason":"chain upgrade"}`)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var sr StatusResponse
+	assert.NoError(t, tm_json.Unmarshal(rec.Body.Bytes(), &sr))
+	assert.True(t, sr.Paused)
+	assert.Equal(t, "chain upgrade", sr.PauseReason)
+	assert.True(t, pv.IsPaused())
+
+	// Resuming doesn't need a body.
+	rec = request(pv.resumeHandler, "127.0.0.1:50000", "")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.False(t, pv.IsPaused())
+	assert.True(t, pv.IsCounterLocked())
+}
+
+func TestPromoteHandler(t *testing.T) {
+	pv := mockSCFilePV(t)
+	assert.NoError(t, pv.Demote(2))
+	request := func(remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/promote", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		pv.promoteHandler(rec, req)
+		return rec
+	}
+
+	rec := request("10.0.0.2:50000")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Equal(t, 2, pv.GetRank())
+
+	rec = request("127.0.0.1:50000")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var sr StatusResponse
+	assert.NoError(t, tm_json.Unmarshal(rec.Body.Bytes(), &sr))
+	assert.Equal(t, 1, sr.Rank)
+	assert.Equal(t, "manual", sr.PromoteReason)
+
+	// Rank 1 can't be promoted.
+	rec = request("127.0.0.1:50000")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, 1, pv.GetRank())
+}
+
+func TestRotateConnKeyHandler(t *testing.T) {
+	pv := mockSCFilePV(t)
+	oldKey, err := connection.GenConnKey(pv.ConfigDir, true)
+	assert.NoError(t, err)
+
+	// The mock validator reports the public key SignCTRL authenticates with.
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	remotePubKeys := make(chan string, 1)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			secretConn, err := tm_p2pconn.MakeSecretConnection(conn, tm_ed25519.GenPrivKey())
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			remotePubKeys <- base64.StdEncoding.EncodeToString(secretConn.RemotePubKey().Bytes())
+			secretConn.Close()
+		}
+	}()
+	dial := func() string {
+		conn, err := pv.dialValidator(context.Background(), "tcp://"+listener.Addr().String())
+		assert.NoError(t, err)
+		defer conn.Close()
+		return <-remotePubKeys
+	}
+	assert.Equal(t, connection.ConnPubKey(oldKey), dial())
+	sr := pv.status()
+	assert.Equal(t, connection.ConnKeyFingerprint(oldKey), sr.ConnKeyFingerprint)
+	assert.Equal(t, connection.ConnPubKey(oldKey), sr.ConnPubKey)
+
+	req := httptest.NewRequest(http.MethodPost, "/rotate-conn-key", nil)
+	req.RemoteAddr = "127.0.0.1:50000"
+	rec := httptest.NewRecorder()
+	pv.rotateConnKeyHandler(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var resp RotateConnKeyResponse
+	assert.NoError(t, tm_json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, connection.ConnPubKey(oldKey), resp.OldPubKey)
+	assert.NotEqual(t, resp.OldPubKey, resp.NewPubKey)
+
+	// The next dial authenticates with the new key without a restart.
+	assert.Equal(t, resp.NewPubKey, dial())
+	assert.Equal(t, resp.NewPubKey, pv.status().ConnPubKey)
 }
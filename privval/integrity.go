@@ -0,0 +1,138 @@
+package privval
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_privval "github.com/tendermint/tendermint/privval"
+)
+
+const (
+	// StateChecksumFile is the file name of the file that holds the checksum of the
+	// priv_validator_state.json file.
+	StateChecksumFile = "priv_validator_state.json.sha256"
+
+	// PermStateChecksumFile determines the default file permissions for the
+	// priv_validator_state.json.sha256 file.
+	PermStateChecksumFile = os.FileMode(0600)
+)
+
+// StateChecksumFilePath returns the path to the priv_validator_state.json.sha256 file
+// of the priv_validator_state.json file at the given path, which is kept next to it.
+func StateChecksumFilePath(statePath string) string {
+	return statePath + ".sha256"
+}
+
+// stateChecksum returns the hex-encoded SHA-256 checksum of the given state file
+// contents.
+func stateChecksum(bz []byte) string {
+	sum := sha256.Sum256(bz)
+	return hex.EncodeToString(sum[:])
+}
+
+// SaveStateChecksum saves the checksum of the current priv_validator_state.json file
+// at the given path to its priv_validator_state.json.sha256 file.
+func SaveStateChecksum(statePath string) error {
+	bz, err := ioutil.ReadFile(statePath)
+	if err != nil {
+		return err
+	}
+
+	return config.WriteFileAtomic(StateChecksumFilePath(statePath), []byte(stateChecksum(bz)+"\n"), PermStateChecksumFile)
+}
+
+// checkKeyFile checks whether the address in the priv_validator_key.json file matches
+// its keys. A missing key file is not checked, as there is nothing to sign with.
+func checkKeyFile(keyPath string) error {
+	if _, err := os.Stat(keyPath); os.IsNotExist(err) {
+		return nil
+	}
+	_, err := loadFilePVKey(keyPath)
+
+	return err
+}
+
+// checkStateFile checks whether the height in the priv_validator_state.json file is
+// at least the given minimum height and, if enabled, whether the file still matches
+// its checksum. A missing checksum is only accepted for a fresh state, for which it
+// is created. A missing state file is not checked, as there is no state to protect.
+func checkStateFile(statePath string, minHeight int64, checksum bool) error {
+	bz, err := ioutil.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var state tm_privval.FilePVLastSignState
+	if err := tm_json.Unmarshal(bz, &state); err != nil {
+		return fmt.Errorf("couldn't parse %v: %v", statePath, err)
+	}
+	if state.Height < minHeight {
+		return fmt.Errorf("height in %v is lower than min_state_height (%v < %v)", statePath, state.Height, minHeight)
+	}
+	if !checksum {
+		return nil
+	}
+
+	sum, err := ioutil.ReadFile(StateChecksumFilePath(statePath))
+	if os.IsNotExist(err) {
+		if state.Height > 0 {
+			return fmt.Errorf("%v is missing", StateChecksumFilePath(statePath))
+		}
+		return SaveStateChecksum(statePath)
+	} else if err != nil {
+		return err
+	}
+	if strings.TrimSpace(string(sum)) != stateChecksum(bz) {
+		return fmt.Errorf("%v doesn't match its checksum in %v", statePath, StateChecksumFilePath(statePath))
+	}
+
+	return nil
+}
+
+// CheckIntegrity checks the priv_validator_key.json and priv_validator_state.json
+// files at the given paths for signs of corruption or tampering.
+func CheckIntegrity(keyPath, statePath string, minHeight int64, checksum bool) error {
+	var errs string
+	if err := checkKeyFile(keyPath); err != nil {
+		errs += fmt.Sprintf("\t%v\n", err)
+	}
+	if err := checkStateFile(statePath, minHeight, checksum); err != nil {
+		errs += fmt.Sprintf("\t%v\n", err)
+	}
+	if errs != "" {
+		return fmt.Errorf("integrity check failed:\n%v", errs)
+	}
+
+	return nil
+}
+
+// CheckFiles checks the key and state files configured in the given privval section
+// for signs of corruption or tampering. It must be called before the files are loaded,
+// as Tendermint exits on a corrupted file and generates a missing key. If forced, a
+// failed check is only logged and the state's checksum is renewed.
+func CheckFiles(logger *types.SyncLogger, cfgDir string, cfg config.PrivValidator, force bool) error {
+	statePath := ResolveStateFilePath(cfgDir, cfg)
+	err := CheckIntegrity(ResolveKeyFilePath(cfgDir, cfg), statePath, cfg.MinStateHeight, cfg.StateChecksum)
+	if err == nil {
+		return nil
+	}
+	if !force {
+		return err
+	}
+
+	logger.Warn("Starting anyway (--force): %v", err)
+	if !cfg.StateChecksum {
+		return nil
+	}
+
+	return SaveStateChecksum(statePath)
+}
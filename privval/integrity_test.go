@@ -0,0 +1,182 @@
+package privval
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	tm_privval "github.com/tendermint/tendermint/privval"
+)
+
+// testKeyAndStateFiles creates a priv_validator_key.json and a
+// priv_validator_state.json file with the given height in the given directory.
+func testKeyAndStateFiles(t *testing.T, cfgDir string, height int64) *tm_privval.FilePV {
+	t.Helper()
+	pv := tm_privval.GenFilePV(KeyFilePath(cfgDir), StateFilePath(cfgDir))
+	pv.LastSignState.Height = height
+	pv.Save()
+	return pv
+}
+
+// tamperStateFile sets the height in the priv_validator_state.json file without
+// updating its checksum.
+func tamperStateFile(t *testing.T, pv *tm_privval.FilePV, height int64) {
+	t.Helper()
+	pv.LastSignState.Height = height
+	pv.LastSignState.Save()
+}
+
+func TestStateChecksumFilePath(t *testing.T) {
+	path := StateChecksumFilePath(StateFilePath("/tmp"))
+	assert.Equal(t, "/tmp/priv_validator_state.json.sha256", path)
+	path = StateChecksumFilePath("/mnt/state/state.json")
+	assert.Equal(t, "/mnt/state/state.json.sha256", path)
+}
+
+func TestCheckIntegrity(t *testing.T) {
+	cfgDir := t.TempDir()
+
+	// No files to check.
+	err := CheckIntegrity(KeyFilePath(cfgDir), StateFilePath(cfgDir), 10, true)
+	assert.NoError(t, err)
+
+	// A fresh state gets a checksum.
+	testKeyAndStateFiles(t, cfgDir, 0)
+	err = CheckIntegrity(KeyFilePath(cfgDir), StateFilePath(cfgDir), 0, true)
+	assert.NoError(t, err)
+	assert.FileExists(t, StateChecksumFilePath(StateFilePath(cfgDir)))
+	err = CheckIntegrity(KeyFilePath(cfgDir), StateFilePath(cfgDir), 0, true)
+	assert.NoError(t, err)
+}
+
+func TestCheckIntegrity_MinStateHeight(t *testing.T) {
+	cfgDir := t.TempDir()
+	testKeyAndStateFiles(t, cfgDir, 5)
+
+	err := CheckIntegrity(KeyFilePath(cfgDir), StateFilePath(cfgDir), 5, false)
+	assert.NoError(t, err)
+	err = CheckIntegrity(KeyFilePath(cfgDir), StateFilePath(cfgDir), 6, false)
+	assert.Error(t, err)
+}
+
+func TestCheckIntegrity_KeyMismatch(t *testing.T) {
+	cfgDir := t.TempDir()
+	pv := testKeyAndStateFiles(t, cfgDir, 0)
+
+	// Address doesn't match the public key.
+	pv.Key.Address = tm_ed25519.GenPrivKey().PubKey().Address()
+	pv.Key.Save()
+	err := CheckIntegrity(KeyFilePath(cfgDir), StateFilePath(cfgDir), 0, false)
+	assert.Error(t, err)
+
+	// Private key doesn't match the public key.
+	pv.Key.Address = pv.Key.PubKey.Address()
+	pv.Key.PrivKey = tm_ed25519.GenPrivKey()
+	pv.Key.Save()
+	err = CheckIntegrity(KeyFilePath(cfgDir), StateFilePath(cfgDir), 0, false)
+	assert.Error(t, err)
+
+	// Unparsable key file.
+	err = ioutil.WriteFile(KeyFilePath(cfgDir), []byte("{"), 0600)
+	assert.NoError(t, err)
+	err = CheckIntegrity(KeyFilePath(cfgDir), StateFilePath(cfgDir), 0, false)
+	assert.Error(t, err)
+}
+
+func TestCheckIntegrity_TamperedState(t *testing.T) {
+	cfgDir := t.TempDir()
+	pv := testKeyAndStateFiles(t, cfgDir, 10)
+
+	// A state that isn't fresh must already have a checksum.
+	err := CheckIntegrity(KeyFilePath(cfgDir), StateFilePath(cfgDir), 0, true)
+	assert.Error(t, err)
+
+	err = SaveStateChecksum(StateFilePath(cfgDir))
+	assert.NoError(t, err)
+	err = CheckIntegrity(KeyFilePath(cfgDir), StateFilePath(cfgDir), 0, true)
+	assert.NoError(t, err)
+
+	// Resetting the height without updating the checksum is detected.
+	tamperStateFile(t, pv, 1)
+	err = CheckIntegrity(KeyFilePath(cfgDir), StateFilePath(cfgDir), 0, true)
+	assert.Error(t, err)
+
+	// Without checksums, the tampered state goes unnoticed.
+	err = CheckIntegrity(KeyFilePath(cfgDir), StateFilePath(cfgDir), 0, false)
+	assert.NoError(t, err)
+}
+
+func TestCheckFiles(t *testing.T) {
+	cfgDir := t.TempDir()
+
+	filePV := testKeyAndStateFiles(t, cfgDir, 10)
+	err := SaveStateChecksum(StateFilePath(cfgDir))
+	assert.NoError(t, err)
+	tamperStateFile(t, filePV, 1)
+
+	var buf bytes.Buffer
+	logger := types.NewSyncLogger(&buf, "", 0)
+	cfg := testConfig(t).Privval
+	cfg.StateChecksum = true
+
+	// The tampered state file is rejected.
+	err = CheckFiles(logger, cfgDir, cfg, false)
+	assert.Error(t, err)
+
+	// Forcing the start renews the checksum.
+	err = CheckFiles(logger, cfgDir, cfg, true)
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "Starting anyway (--force): integrity check failed")
+	err = CheckIntegrity(KeyFilePath(cfgDir), StateFilePath(cfgDir), 0, true)
+	assert.NoError(t, err)
+
+	// Missing files are left for Tendermint to generate.
+	err = CheckFiles(logger, t.TempDir(), cfg, false)
+	assert.NoError(t, err)
+}
+
+func TestCheckFiles_StateFilePath(t *testing.T) {
+	// The state file and its checksum live in another directory than the key file.
+	cfgDir := t.TempDir()
+	stateDir := t.TempDir()
+	filePV := tm_privval.GenFilePV(KeyFilePath(cfgDir), filepath.Join(stateDir, "state.json"))
+	filePV.LastSignState.Height = 10
+	filePV.Save()
+	logger := types.NewSyncLogger(ioutil.Discard, "", 0)
+	cfg := testConfig(t).Privval
+	cfg.StateChecksum = true
+	cfg.StateFilePath = filepath.Join(stateDir, "state.json")
+
+	err := CheckFiles(logger, cfgDir, cfg, false)
+	assert.Error(t, err)
+	err = CheckFiles(logger, cfgDir, cfg, true)
+	assert.NoError(t, err)
+	assert.FileExists(t, filepath.Join(stateDir, "state.json.sha256"))
+	assert.NoFileExists(t, StateChecksumFilePath(StateFilePath(cfgDir)))
+	err = CheckFiles(logger, cfgDir, cfg, false)
+	assert.NoError(t, err)
+}
+
+func TestUpdateStateChecksum(t *testing.T) {
+	cfgDir := t.TempDir()
+
+	filePV := testKeyAndStateFiles(t, cfgDir, 10)
+	pv := mockSCFilePVIn(t, cfgDir)
+
+	// Disabled.
+	err := pv.updateStateChecksum()
+	assert.NoError(t, err)
+	assert.NoFileExists(t, StateChecksumFilePath(StateFilePath(cfgDir)))
+
+	// Enabled, so the state can be advanced without failing the integrity check.
+	pv.Config.Privval.StateChecksum = true
+	tamperStateFile(t, filePV, 11)
+	err = pv.updateStateChecksum()
+	assert.NoError(t, err)
+	err = CheckIntegrity(KeyFilePath(cfgDir), StateFilePath(cfgDir), 11, true)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,67 @@
+package privval
+
+import (
+	"context"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/connection"
+)
+
+// certExpiryCheckInterval is the interval in which the expiry of the TLS certificate is
+// checked while SignCTRL is running.
+const certExpiryCheckInterval = 24 * time.Hour
+
+// loadTLSCerts loads the configured TLS certificates for the mtls transport and reloads
+// them on SIGHUP until the given context is canceled.
+func (pv *SCFilePV) loadTLSCerts(ctx context.Context) error {
+	cfg := pv.Config.Privval
+	certs, err := connection.LoadTLSCerts(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSCAFile)
+	if err != nil {
+		return err
+	}
+	certs.CheckExpiry(pv.Logger)
+	pv.tlsCerts = certs
+
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go pv.watchTLSCerts(ctx, sighup)
+
+	return nil
+}
+
+// watchTLSCerts reloads the TLS certificates whenever a signal is received on the given
+// channel and checks their expiry daily until the given context is canceled. Reloaded
+// certificates are only used for connections established afterwards.
+func (pv *SCFilePV) watchTLSCerts(ctx context.Context, sighup chan os.Signal) {
+	defer signal.Stop(sighup)
+	ticker := time.NewTicker(certExpiryCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-sighup:
+			if err := pv.tlsCerts.Reload(); err != nil {
+				pv.Logger.Error("%v, keeping the previous TLS certificates", err)
+				continue
+			}
+			pv.Logger.Info("Reloaded TLS certificates (valid until %v), using them from the next (re)connect on", pv.tlsCerts.NotAfter().Format(time.RFC3339))
+			pv.tlsCerts.CheckExpiry(pv.Logger)
+
+		case <-ticker.C:
+			pv.tlsCerts.CheckExpiry(pv.Logger)
+		}
+	}
+}
+
+// dialValidatorTLS keeps dialing the validator at the given address until success and
+// returns the mutual TLS connection. It gives up once the configured retry policy is
+// exhausted. Dialing is aborted once the given context is canceled.
+func (pv *SCFilePV) dialValidatorTLS(ctx context.Context, address string) (net.Conn, error) {
+	return connection.RetryDialTLS(ctx, address, pv.tlsCerts, retryPolicy(pv.baseConfig(), pv.retryConfig(), &pv.connStats), pv.Logger)
+}
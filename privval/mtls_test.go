@@ -0,0 +1,97 @@
+package privval
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	tm_protoio "github.com/tendermint/tendermint/libs/protoio"
+	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+)
+
+func TestMTLSTransport_Listen(t *testing.T) {
+	certFile, keyFile, pool := testTLSFiles(t)
+	laddrPort, _ := getFreePort(t)
+	cfg := testConfig(t)
+	cfg.Privval.Mode = config.ModeListen
+	cfg.Privval.ListenAddress = fmt.Sprintf("tcp://127.0.0.1:%v", laddrPort)
+	cfg.Privval.Transport = config.TransportMTLS
+	cfg.Privval.TLSCertFile = certFile
+	cfg.Privval.TLSKeyFile = keyFile
+	cfg.Privval.TLSCAFile = certFile
+
+	httpPort, _ := getFreePort(t)
+	pv, err := NewSCFilePV(types.NewSyncLogger(ioutil.Discard, "", 0), t.TempDir(), cfg, testState(t), testFilePV(t), &http.Server{Addr: fmt.Sprintf(":%v", httpPort)})
+	assert.NoError(t, err)
+	err = pv.Start()
+	assert.NoError(t, err)
+	defer pv.Stop()
+
+	// The validator connects via mutual TLS and is served via the raw socket protocol.
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	assert.NoError(t, err)
+	var conn *tls.Conn
+	for i := 0; i < 10; i++ {
+		if conn, err = tls.Dial("tcp", fmt.Sprintf("127.0.0.1:%v", laddrPort), &tls.Config{Certificates: []tls.Certificate{cert}, RootCAs: pool}); err == nil {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	_, err = tm_protoio.NewDelimitedWriter(conn).WriteMsg(wrapMsg(&tm_privvalproto.PingRequest{}))
+	assert.NoError(t, err)
+	var resp tm_privvalproto.Message
+	_, err = tm_protoio.NewDelimitedReader(conn, pv.Config.Privval.MaxMsgSize).ReadMsg(&resp)
+	assert.NoError(t, err)
+	assert.IsType(t, &tm_privvalproto.Message_PingResponse{}, resp.Sum)
+}
+
+func TestMTLSTransport_InvalidCerts(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Privval.Transport = config.TransportMTLS
+	cfg.Privval.TLSCertFile = "/nonexistent/cert.pem"
+	cfg.Privval.TLSKeyFile = "/nonexistent/key.pem"
+	cfg.Privval.TLSCAFile = "/nonexistent/ca.pem"
+
+	pv, err := NewSCFilePV(types.NewSyncLogger(ioutil.Discard, "", 0), t.TempDir(), cfg, testState(t), testFilePV(t), &http.Server{})
+	assert.NoError(t, err)
+	done, err := pv.transport.start(context.Background())
+	assert.Nil(t, done)
+	assert.Error(t, err)
+}
+
+func TestWatchTLSCerts(t *testing.T) {
+	certFile, keyFile, _ := testTLSFiles(t)
+	pv := mockSCFilePV(t)
+	pv.Config.Privval.TLSCertFile = certFile
+	pv.Config.Privval.TLSKeyFile = keyFile
+	pv.Config.Privval.TLSCAFile = certFile
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, pv.loadTLSCerts(ctx))
+	notAfter := pv.tlsCerts.NotAfter()
+
+	// Renew the certificate and reload it via SIGHUP.
+	time.Sleep(1100 * time.Millisecond)
+	renewedCertFile, renewedKeyFile, _ := testTLSFiles(t)
+	for src, dst := range map[string]string{renewedCertFile: certFile, renewedKeyFile: keyFile} {
+		bz, err := ioutil.ReadFile(src)
+		assert.NoError(t, err)
+		assert.NoError(t, ioutil.WriteFile(dst, bz, 0600))
+	}
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGHUP))
+	assert.Eventually(t, func() bool {
+		return pv.tlsCerts.NotAfter().After(notAfter)
+	}, time.Second, 10*time.Millisecond)
+}
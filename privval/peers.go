@@ -0,0 +1,118 @@
+package privval
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	tm_crypto "github.com/tendermint/tendermint/crypto"
+)
+
+// ErrDuplicateRank is returned on startup if another node of the set is on the same
+// rank, as two nodes on rank 1 would double-sign.
+var ErrDuplicateRank = errors.New("another node of the set is on the same rank")
+
+// peerKeys parses the public keys of the peers.
+func (pv *SCFilePV) peerKeys() ([]tm_crypto.PubKey, error) {
+	peers := pv.GetPeers()
+	keys := make([]tm_crypto.PubKey, 0, len(peers))
+	for _, peer := range peers {
+		key, err := connection.ParseConnPubKey(peer.ConnPubKey)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse the key of peer %v: %w", peer.Address, err)
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// servePeers starts answering the peers on peer_laddr, if it is set, so that they can
+// check their rank against this node's.
+func (pv *SCFilePV) servePeers() error {
+	if pv.Config.Base.PeerListenAddress == "" {
+		return nil
+	}
+	keys, err := pv.peerKeys()
+	if err != nil {
+		return err
+	}
+	// Without any keys, every node would be answered.
+	if len(keys) == 0 {
+		return nil
+	}
+	listener, err := connection.Listen(pv.Config.Base.PeerListenAddress)
+	if err != nil {
+		return fmt.Errorf("couldn't listen for peers on %v: %w", pv.Config.Base.PeerListenAddress, err)
+	}
+	pv.peerListener = listener
+	pv.Logger.Info("Answering peers on %v", listener.Addr())
+	go connection.ServePeers(listener, pv.ConfigDir, keys, pv.hello, pv.onPeerHello, pv.Logger)
+
+	return nil
+}
+
+// closePeerListener stops answering the peers.
+func (pv *SCFilePV) closePeerListener() {
+	if pv.peerListener != nil {
+		pv.peerListener.Close()
+	}
+}
+
+// onPeerHello compares the rank in the given hello of a peer to this node's. A peer on
+// the same rank refuses to start, but it is logged here as well.
+func (pv *SCFilePV) onPeerHello(remote net.Addr, peer *connection.Hello) {
+	if int(peer.Rank) == pv.GetRank() {
+		pv.Logger.Error("Peer %v reports rank %v, which is this node's rank as well! Check the start_rank of both nodes.", remote, peer.Rank)
+		return
+	}
+	pv.Logger.Debug("Peer %v checked its rank %v against this node's rank %v", remote, peer.Rank, pv.GetRank())
+}
+
+// checkPeerRanks asks every peer for its rank and returns an error wrapping
+// ErrDuplicateRank if one of them is on the same rank as this node. Peers that can't
+// be reached, e.g. as they are turned off, are only warned about.
+func (pv *SCFilePV) checkPeerRanks(ctx context.Context) error {
+	peers := pv.GetPeers()
+	if len(peers) == 0 {
+		return nil
+	}
+	keys, err := pv.peerKeys()
+	if err != nil {
+		return err
+	}
+
+	timeout := config.GetDuration(pv.baseConfig().DialTimeout)
+	hello := pv.hello()
+	errs := make([]error, len(peers))
+	var wg sync.WaitGroup
+	for i, peer := range peers {
+		wg.Add(1)
+		go func(i int, address string, key tm_crypto.PubKey) {
+			defer wg.Done()
+			remote, err := connection.QueryPeer(ctx, pv.ConfigDir, address, key, hello, timeout)
+			if err != nil {
+				pv.Logger.Warn("Couldn't check the rank of peer %v, skipping it: %v", address, err)
+				return
+			}
+			if remote.Rank == hello.Rank {
+				errs[i] = fmt.Errorf("%w: peer %v is on rank %v as well", ErrDuplicateRank, address, remote.Rank)
+				return
+			}
+			pv.Logger.Info("Peer %v is on rank %v ✓", address, remote.Rank)
+		}(i, peer.Address, keys[i])
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package privval
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/connection"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	tm_crypto "github.com/tendermint/tendermint/crypto"
+	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+)
+
+// testPeer answers the hellos of the node using the given key with the given rank, as
+// a peer with its own connection key. It returns the peer in the
+// <conn_pub_key>@<host:port> format.
+func testPeer(t *testing.T, nodeKey tm_crypto.PubKey, rank int64) string {
+	t.Helper()
+	cfgDir := t.TempDir()
+	connKey, err := connection.GenConnKey(cfgDir, false)
+	assert.NoError(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	t.Cleanup(func() { listener.Close() })
+	go connection.ServePeers(listener, cfgDir, []tm_crypto.PubKey{nodeKey}, func() *connection.Hello {
+		return &connection.Hello{Version: "v1.0.0", Rank: rank, ChainID: "testchain"}
+	}, func(net.Addr, *connection.Hello) {}, types.NewSyncLogger(ioutil.Discard, "", 0))
+
+	return fmt.Sprintf("%v@%v", connection.ConnPubKey(connKey), listener.Addr())
+}
+
+// mockSCFilePVWithPeers returns an SCFilePV on rank 1 with the peers returned by the
+// given function, which is passed the SCFilePV's public connection key.
+func mockSCFilePVWithPeers(t *testing.T, peers func(nodeKey tm_crypto.PubKey) []string) (*SCFilePV, *bytes.Buffer) {
+	t.Helper()
+	cfgDir := t.TempDir()
+	connKey, err := connection.GenConnKey(cfgDir, false)
+	assert.NoError(t, err)
+	cfg := testConfig(t)
+	cfg.Base.Peers = peers(connKey.PubKey())
+	var buf bytes.Buffer
+	pv, err := NewSCFilePV(types.NewSyncLogger(&buf, "", 0), cfgDir, cfg, testState(t), testFilePV(t), &http.Server{})
+	assert.NoError(t, err)
+
+	return pv, &buf
+}
+
+func TestCheckPeerRanks(t *testing.T) {
+	// Without peers, there is nothing to check.
+	pv := mockSCFilePV(t)
+	assert.NoError(t, pv.checkPeerRanks(context.Background()))
+
+	// Peers on other ranks are fine.
+	pv, buf := mockSCFilePVWithPeers(t, func(nodeKey tm_crypto.PubKey) []string {
+		return []string{testPeer(t, nodeKey, 2), testPeer(t, nodeKey, 3)}
+	})
+	assert.NoError(t, pv.checkPeerRanks(context.Background()))
+	assert.Contains(t, buf.String(), "is on rank 2 ✓")
+	assert.Contains(t, buf.String(), "is on rank 3 ✓")
+}
+
+func TestCheckPeerRanks_Duplicate(t *testing.T) {
+	// A peer on rank 1 as well must keep the node from starting.
+	pv, _ := mockSCFilePVWithPeers(t, func(nodeKey tm_crypto.PubKey) []string {
+		return []string{testPeer(t, nodeKey, 2), testPeer(t, nodeKey, 1)}
+	})
+	err := pv.checkPeerRanks(context.Background())
+	assert.ErrorIs(t, err, ErrDuplicateRank)
+	assert.Contains(t, err.Error(), "is on rank 1 as well")
+}
+
+func TestCheckPeerRanks_Unreachable(t *testing.T) {
+	// Peers that are turned off are only warned about.
+	pv, buf := mockSCFilePVWithPeers(t, func(nodeKey tm_crypto.PubKey) []string {
+		peer := testPeer(t, nodeKey, 2)
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		assert.NoError(t, err)
+		listener.Close()
+		return []string{peer, fmt.Sprintf("%v@%v", connection.ConnPubKey(tm_ed25519.GenPrivKey()), listener.Addr())}
+	})
+	assert.NoError(t, pv.checkPeerRanks(context.Background()))
+	assert.Contains(t, buf.String(), "is on rank 2 ✓")
+	assert.Contains(t, buf.String(), "Couldn't check the rank of peer")
+}
+
+func TestServePeers(t *testing.T) {
+	peerDir := t.TempDir()
+	peerKey, err := connection.GenConnKey(peerDir, false)
+	assert.NoError(t, err)
+	pv, _ := mockSCFilePVWithPeers(t, func(tm_crypto.PubKey) []string {
+		return []string{connection.ConnPubKey(peerKey) + "@127.0.0.1:26660"}
+	})
+	pv.Config.Base.PeerListenAddress = "127.0.0.1:0"
+	assert.NoError(t, pv.servePeers())
+	defer pv.closePeerListener()
+
+	// The peer learns the node's rank.
+	nodeKey, err := connection.LoadConnKey(pv.ConfigDir)
+	assert.NoError(t, err)
+	hello, err := connection.QueryPeer(context.Background(), peerDir, pv.peerListener.Addr().String(), nodeKey.PubKey(), &connection.Hello{Rank: 2, ChainID: "testchain"}, time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), hello.Rank)
+}
+
+func TestOnPeerHello(t *testing.T) {
+	pv := mockSCFilePV(t)
+	var buf bytes.Buffer
+	pv.Logger = types.NewSyncLogger(&buf, "", 0)
+	remote := &net.TCPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 50000}
+
+	pv.onPeerHello(remote, &connection.Hello{Rank: 2})
+	assert.NotContains(t, buf.String(), "[ERR")
+
+	// A peer reporting the same rank is logged.
+	pv.onPeerHello(remote, &connection.Hello{Rank: 1})
+	assert.Contains(t, buf.String(), "Peer 10.0.0.2:50000 reports rank 1, which is this node's rank as well")
+}
@@ -0,0 +1,62 @@
+package privval
+
+import (
+	"context"
+	"net"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/connection"
+)
+
+const (
+	// maxProbeFailures is the number of failed probes in a row after which the
+	// connection to a validator is considered dead.
+	maxProbeFailures = 2
+)
+
+// probe probes the connection to the validator of the given connection whenever there
+// hasn't been any traffic on it for probe_interval, until the given context is
+// canceled. Once maxProbeFailures probes in a row have failed, the connection is
+// closed, so that the run goroutine locks the counter for missed blocks in a row and
+// reconnects.
+func (pv *SCFilePV) probe(ctx context.Context, vc *validatorConn) {
+	interval := config.GetDuration(pv.Config.Base.ProbeInterval)
+	timeout := config.GetDuration(pv.Config.Base.WriteTimeout)
+	ticker := pv.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	var probed net.Conn
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C():
+			// Failures only count in a row on the same connection.
+			conn := vc.get()
+			if conn != probed {
+				probed, failures = conn, 0
+			}
+			if pv.clock.Now().Sub(vc.lastActivity()) < interval {
+				failures = 0
+				continue
+			}
+			if failures >= maxProbeFailures {
+				continue
+			}
+
+			err := connection.Probe(conn, timeout)
+			if err == nil {
+				failures = 0
+				continue
+			}
+			failures++
+			pv.Logger.Warn("Probing the connection to the validator at %v failed (%v/%v): %v", vc.getAddress(), failures, maxProbeFailures, err)
+			if failures == maxProbeFailures {
+				pv.Logger.Warn("Closing the connection to the validator at %v, as it seems to be dead...", vc.getAddress())
+				conn.Close()
+			}
+		}
+	}
+}
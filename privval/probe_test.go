@@ -0,0 +1,66 @@
+package privval
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types/clocktest"
+	"github.com/stretchr/testify/assert"
+)
+
+// deadConn is a connection whose probes always fail. It counts the probes and
+// whether it has been closed.
+type deadConn struct {
+	net.Conn
+	probes int32
+	closed int32
+}
+
+func (c *deadConn) SetWriteDeadline(time.Time) error { return nil }
+func (c *deadConn) Close() error                     { atomic.StoreInt32(&c.closed, 1); return nil }
+
+func (c *deadConn) Write(b []byte) (int, error) {
+	atomic.AddInt32(&c.probes, 1)
+	return 0, errors.New("broken pipe")
+}
+
+func TestProbe(t *testing.T) {
+	pv := mockSCFilePV(t)
+	clock := clocktest.New(time.Now())
+	pv.SetClock(clock)
+	pv.Config.Base.ProbeInterval = "10s"
+	interval := 10 * time.Second
+	conn := &deadConn{}
+	vc := &validatorConn{address: "tcp://127.0.0.1:3000", conn: conn}
+	vc.touch(clock.Now())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go pv.probe(ctx, vc)
+	assert.Eventually(t, func() bool { return clock.Waiters() == 1 }, time.Second, time.Millisecond)
+
+	// Connections with regular traffic aren't probed.
+	for i := 0; i < 3; i++ {
+		clock.Advance(interval / 2)
+		vc.touch(clock.Now())
+		clock.Advance(interval / 2)
+	}
+	time.Sleep(50 * time.Millisecond)
+	assert.Zero(t, atomic.LoadInt32(&conn.probes))
+
+	// Idle connections are closed once two probes in a row have failed.
+	assert.Eventually(t, func() bool {
+		clock.Advance(interval)
+		return atomic.LoadInt32(&conn.closed) == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(maxProbeFailures), atomic.LoadInt32(&conn.probes))
+
+	// The closed connection isn't probed anymore.
+	clock.Advance(interval)
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(maxProbeFailures), atomic.LoadInt32(&conn.probes))
+}
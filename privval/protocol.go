@@ -0,0 +1,294 @@
+package privval
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gogo/protobuf/proto"
+	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+)
+
+// ProtocolVersion is a version of the privval protocol spoken by the validator.
+type ProtocolVersion string
+
+const (
+	// ProtocolAuto detects the protocol version from the requests received.
+	ProtocolAuto ProtocolVersion = "auto"
+
+	// ProtocolV034 is the privval protocol of Tendermint v0.34 to v0.37.
+	ProtocolV034 ProtocolVersion = "v0.34"
+
+	// ProtocolV038 is the privval protocol of CometBFT v0.38, which adds vote
+	// extensions.
+	ProtocolV038 ProtocolVersion = "v0.38"
+)
+
+// Field numbers of the messages that differ between protocol versions.
+const (
+	fieldMsgSignVoteRequest    = 3
+	fieldMsgSignedVoteResponse = 4
+
+	fieldSignVoteReqVote = 1
+	fieldSignVoteReqSkip = 3
+
+	fieldSignedVoteRespVote = 1
+
+	fieldVoteExtension    = 9
+	fieldVoteExtensionSig = 10
+)
+
+// Protobuf wire types.
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var (
+	// ErrVoteExtensionsUnsupported is returned if the validator requests a signature
+	// for a vote extension, which the signing backend doesn't support.
+	ErrVoteExtensionsUnsupported = errors.New("signing vote extensions is not supported")
+
+	// errMalformedMsg is returned if a message isn't a valid protobuf message.
+	errMalformedMsg = errors.New("malformed protobuf message")
+)
+
+// SignRequest is SignCTRL's internal representation of a request from the validator,
+// independent of the protocol version it was received in.
+type SignRequest struct {
+	// Msg is the request in the schema of Tendermint v0.34.
+	Msg *tm_privvalproto.Message
+
+	// Version is the protocol version the request was received in.
+	Version ProtocolVersion
+
+	// VoteExtension is the extension of the vote to be signed (v0.38+).
+	VoteExtension []byte
+
+	// SkipExtensionSigning determines whether the vote extension needs to be signed
+	// (v0.38+).
+	SkipExtensionSigning bool
+}
+
+// needsExtensionSignature checks whether the request asks for a signature of a vote
+// extension.
+func (r *SignRequest) needsExtensionSignature() bool {
+	return r.Version == ProtocolV038 && r.Msg.GetSignVoteRequest() != nil && !r.SkipExtensionSigning
+}
+
+// SignResponse is SignCTRL's internal representation of a response to the validator,
+// independent of the protocol version it is sent in.
+type SignResponse struct {
+	// Msg is the response in the schema of Tendermint v0.34.
+	Msg *tm_privvalproto.Message
+
+	// Version is the protocol version the response is sent in.
+	Version ProtocolVersion
+
+	// VoteExtension is the extension of the signed vote (v0.38+).
+	VoteExtension []byte
+}
+
+// protoField is a single field of an encoded protobuf message.
+type protoField struct {
+	num      int
+	wireType int
+	varint   uint64
+	raw      []byte // The encoded field, including its key.
+	payload  []byte // The payload of length-delimited fields.
+}
+
+// parseFields splits an encoded protobuf message into its fields.
+func parseFields(bz []byte) ([]protoField, error) {
+	var fields []protoField
+	for i := 0; i < len(bz); {
+		start := i
+		key, n := proto.DecodeVarint(bz[i:])
+		if n == 0 {
+			return nil, errMalformedMsg
+		}
+		i += n
+
+		f := protoField{num: int(key >> 3), wireType: int(key & 7)}
+		switch f.wireType {
+		case wireVarint:
+			if f.varint, n = proto.DecodeVarint(bz[i:]); n == 0 {
+				return nil, errMalformedMsg
+			}
+			i += n
+		case wireFixed64:
+			i += 8
+		case wireFixed32:
+			i += 4
+		case wireBytes:
+			l, n := proto.DecodeVarint(bz[i:])
+			if n == 0 || uint64(len(bz)-i-n) < l {
+				return nil, errMalformedMsg
+			}
+			i += n
+			f.payload = bz[i : i+int(l)]
+			i += int(l)
+		default:
+			return nil, errMalformedMsg
+		}
+		if i > len(bz) {
+			return nil, errMalformedMsg
+		}
+
+		f.raw = bz[start:i]
+		fields = append(fields, f)
+	}
+
+	return fields, nil
+}
+
+// findField returns the last occurrence of the field with the given number, as
+// protobuf does for non-repeated fields.
+func findField(fields []protoField, num int) (protoField, bool) {
+	var found protoField
+	ok := false
+	for _, f := range fields {
+		if f.num == num {
+			found, ok = f, true
+		}
+	}
+
+	return found, ok
+}
+
+// appendBytesField appends a length-delimited field to the given encoded message.
+func appendBytesField(bz []byte, num int, payload []byte) []byte {
+	bz = append(bz, proto.EncodeVarint(uint64(num)<<3|wireBytes)...)
+	bz = append(bz, proto.EncodeVarint(uint64(len(payload)))...)
+	return append(bz, payload...)
+}
+
+// replaceBytesField replaces the length-delimited field with the given number in the
+// encoded message by the given payload.
+func replaceBytesField(bz []byte, num int, payload []byte) ([]byte, error) {
+	fields, err := parseFields(bz)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []byte
+	for _, f := range fields {
+		if f.num != num {
+			out = append(out, f.raw...)
+		}
+	}
+
+	return appendBytesField(out, num, payload), nil
+}
+
+// DecodeRequest decodes the given request received from the validator. If version is
+// ProtocolAuto, the protocol version is detected from the fields the request carries.
+func DecodeRequest(version ProtocolVersion, bz []byte) (*SignRequest, error) {
+	var msg tm_privvalproto.Message
+	if err := proto.Unmarshal(bz, &msg); err != nil {
+		return nil, err
+	}
+	req := &SignRequest{Msg: &msg, Version: version}
+	if req.Version == "" {
+		req.Version = ProtocolAuto
+	}
+	if version == ProtocolV034 || msg.GetSignVoteRequest() == nil {
+		if req.Version == ProtocolAuto {
+			req.Version = ProtocolV034
+		}
+		return req, nil
+	}
+
+	// Only SignVoteRequests differ between the protocol versions, so look for the
+	// fields added in v0.38.
+	fields, err := parseFields(bz)
+	if err != nil {
+		return nil, err
+	}
+	reqField, _ := findField(fields, fieldMsgSignVoteRequest)
+	reqFields, err := parseFields(reqField.payload)
+	if err != nil {
+		return nil, err
+	}
+	voteField, _ := findField(reqFields, fieldSignVoteReqVote)
+	voteFields, err := parseFields(voteField.payload)
+	if err != nil {
+		return nil, err
+	}
+
+	detected := ProtocolV034
+	if f, ok := findField(reqFields, fieldSignVoteReqSkip); ok {
+		req.SkipExtensionSigning = f.varint != 0
+		detected = ProtocolV038
+	}
+	if f, ok := findField(voteFields, fieldVoteExtension); ok {
+		req.VoteExtension = f.payload
+		detected = ProtocolV038
+	}
+	if _, ok := findField(voteFields, fieldVoteExtensionSig); ok {
+		detected = ProtocolV038
+	}
+	if req.Version == ProtocolAuto {
+		req.Version = detected
+	}
+
+	return req, nil
+}
+
+// EncodeResponse encodes the given response to be sent to the validator in the
+// response's protocol version.
+func EncodeResponse(resp *SignResponse) ([]byte, error) {
+	bz, err := proto.Marshal(resp.Msg)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Version != ProtocolV038 || resp.Msg.GetSignedVoteResponse() == nil || len(resp.VoteExtension) == 0 {
+		return bz, nil
+	}
+
+	// The validator replaces its vote with the one in the response, so the vote
+	// extension must be sent back.
+	fields, err := parseFields(bz)
+	if err != nil {
+		return nil, err
+	}
+	respField, _ := findField(fields, fieldMsgSignedVoteResponse)
+	respFields, err := parseFields(respField.payload)
+	if err != nil {
+		return nil, err
+	}
+	voteField, _ := findField(respFields, fieldSignedVoteRespVote)
+	vote := appendBytesField(append([]byte(nil), voteField.payload...), fieldVoteExtension, resp.VoteExtension)
+
+	respBz, err := replaceBytesField(respField.payload, fieldSignedVoteRespVote, vote)
+	if err != nil {
+		return nil, err
+	}
+	return replaceBytesField(bz, fieldMsgSignedVoteResponse, respBz)
+}
+
+// rawMsg is a protobuf message that holds an encoded message as is. It allows for
+// reading and writing messages of any protocol version via the delimited reader and
+// writer.
+type rawMsg struct {
+	bz []byte
+}
+
+// Reset implements the proto.Message interface.
+func (m *rawMsg) Reset() { m.bz = nil }
+
+// String implements the proto.Message interface.
+func (m *rawMsg) String() string { return fmt.Sprintf("%X", m.bz) }
+
+// ProtoMessage implements the proto.Message interface.
+func (*rawMsg) ProtoMessage() {}
+
+// Marshal returns the encoded message.
+func (m *rawMsg) Marshal() ([]byte, error) { return m.bz, nil }
+
+// Unmarshal stores a copy of the encoded message.
+func (m *rawMsg) Unmarshal(bz []byte) error {
+	m.bz = append([]byte(nil), bz...)
+	return nil
+}
@@ -0,0 +1,195 @@
+package privval
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"testing"
+
+	"github.com/gogo/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	tm_protoio "github.com/tendermint/tendermint/libs/protoio"
+	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+	tm_prototypes "github.com/tendermint/tendermint/proto/tendermint/types"
+)
+
+// Encoded requests as sent by validators speaking different protocol versions.
+const (
+	// SignVoteRequest for a precommit at height 2, round 1 (v0.34).
+	fixtureV034SignVoteRequest = "1a130a06080210021801120974657374636861696e"
+
+	// SignVoteRequest for a prevote at height 2, round 1 with skip_extension_signing
+	// set (v0.38).
+	fixtureV038SignVoteRequest = "1a150a06080110021801120974657374636861696e1801"
+
+	// SignVoteRequest for a precommit at height 2, round 1 with the vote extension
+	// "ext" that needs to be signed (v0.38).
+	fixtureV038SignVoteRequestExt = "1a180a0b0802100218014a03657874120974657374636861696e"
+
+	// PingRequest (all versions).
+	fixturePingRequest = "3a00"
+)
+
+func testFixture(t *testing.T, fixture string) []byte {
+	t.Helper()
+	bz, err := hex.DecodeString(fixture)
+	assert.NoError(t, err)
+	return bz
+}
+
+func TestDecodeRequest_V034(t *testing.T) {
+	req, err := DecodeRequest(ProtocolAuto, testFixture(t, fixtureV034SignVoteRequest))
+	assert.NoError(t, err)
+	assert.Equal(t, ProtocolV034, req.Version)
+	assert.Equal(t, "testchain", req.Msg.GetSignVoteRequest().ChainId)
+	assert.Equal(t, tm_prototypes.PrecommitType, req.Msg.GetSignVoteRequest().Vote.Type)
+	assert.Equal(t, int64(2), req.Msg.GetSignVoteRequest().Vote.Height)
+	assert.Equal(t, int32(1), req.Msg.GetSignVoteRequest().Vote.Round)
+	assert.False(t, req.needsExtensionSignature())
+}
+
+func TestDecodeRequest_V038(t *testing.T) {
+	req, err := DecodeRequest(ProtocolAuto, testFixture(t, fixtureV038SignVoteRequest))
+	assert.NoError(t, err)
+	assert.Equal(t, ProtocolV038, req.Version)
+	assert.Equal(t, tm_prototypes.PrevoteType, req.Msg.GetSignVoteRequest().Vote.Type)
+	assert.Equal(t, int64(2), req.Msg.GetSignVoteRequest().Vote.Height)
+	assert.True(t, req.SkipExtensionSigning)
+	assert.Empty(t, req.VoteExtension)
+	assert.False(t, req.needsExtensionSignature())
+
+	req, err = DecodeRequest(ProtocolAuto, testFixture(t, fixtureV038SignVoteRequestExt))
+	assert.NoError(t, err)
+	assert.Equal(t, ProtocolV038, req.Version)
+	assert.Equal(t, []byte("ext"), req.VoteExtension)
+	assert.False(t, req.SkipExtensionSigning)
+	assert.True(t, req.needsExtensionSignature())
+}
+
+func TestDecodeRequest_ConfiguredVersion(t *testing.T) {
+	// v0.38 requests are treated as v0.34 if configured.
+	req, err := DecodeRequest(ProtocolV034, testFixture(t, fixtureV038SignVoteRequestExt))
+	assert.NoError(t, err)
+	assert.Equal(t, ProtocolV034, req.Version)
+	assert.Empty(t, req.VoteExtension)
+	assert.False(t, req.needsExtensionSignature())
+
+	// v0.34 requests are treated as v0.38 if configured, so extensions must be signed.
+	req, err = DecodeRequest(ProtocolV038, testFixture(t, fixtureV034SignVoteRequest))
+	assert.NoError(t, err)
+	assert.Equal(t, ProtocolV038, req.Version)
+	assert.True(t, req.needsExtensionSignature())
+
+	// Requests other than SignVoteRequests are the same in all versions.
+	req, err = DecodeRequest(ProtocolV038, testFixture(t, fixturePingRequest))
+	assert.NoError(t, err)
+	assert.Equal(t, ProtocolV038, req.Version)
+	assert.IsType(t, &tm_privvalproto.Message_PingRequest{}, req.Msg.Sum)
+	assert.False(t, req.needsExtensionSignature())
+
+	req, err = DecodeRequest(ProtocolAuto, testFixture(t, fixturePingRequest))
+	assert.NoError(t, err)
+	assert.Equal(t, ProtocolV034, req.Version)
+}
+
+func TestDecodeRequest_Malformed(t *testing.T) {
+	req, err := DecodeRequest(ProtocolAuto, []byte{0x1a, 0x13, 0x0a})
+	assert.Nil(t, req)
+	assert.Error(t, err)
+}
+
+func TestEncodeResponse_V034(t *testing.T) {
+	msg := wrapMsg(&tm_privvalproto.SignedVoteResponse{Vote: *testVote(t)})
+	bz, err := EncodeResponse(&SignResponse{Msg: msg, Version: ProtocolV034, VoteExtension: []byte("ext")})
+	assert.NoError(t, err)
+
+	// v0.34 responses are encoded as is.
+	expected, err := proto.Marshal(msg)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, bz)
+}
+
+func TestEncodeResponse_V038(t *testing.T) {
+	// Empty bytes fields aren't encoded and decode as nil, so the vote is signed like
+	// the one of an actual response.
+	vote := testVote(t)
+	vote.Signature = []byte("SIG")
+	msg := wrapMsg(&tm_privvalproto.SignedVoteResponse{Vote: *vote})
+	bz, err := EncodeResponse(&SignResponse{Msg: msg, Version: ProtocolV038, VoteExtension: []byte("ext")})
+	assert.NoError(t, err)
+
+	// The response is still readable with the v0.34 schema.
+	var decoded tm_privvalproto.Message
+	err = proto.Unmarshal(bz, &decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, msg.GetSignedVoteResponse().Vote.Height, decoded.GetSignedVoteResponse().Vote.Height)
+	assert.Equal(t, msg.GetSignedVoteResponse().Vote.Signature, decoded.GetSignedVoteResponse().Vote.Signature)
+
+	// The vote extension is sent back.
+	fields, err := parseFields(bz)
+	assert.NoError(t, err)
+	respField, ok := findField(fields, fieldMsgSignedVoteResponse)
+	assert.True(t, ok)
+	respFields, err := parseFields(respField.payload)
+	assert.NoError(t, err)
+	voteField, ok := findField(respFields, fieldSignedVoteRespVote)
+	assert.True(t, ok)
+	voteFields, err := parseFields(voteField.payload)
+	assert.NoError(t, err)
+	extField, ok := findField(voteFields, fieldVoteExtension)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("ext"), extField.payload)
+}
+
+func TestParseFields(t *testing.T) {
+	fields, err := parseFields(testFixture(t, fixtureV038SignVoteRequest))
+	assert.NoError(t, err)
+	assert.Len(t, fields, 1)
+	assert.Equal(t, fieldMsgSignVoteRequest, fields[0].num)
+	assert.Equal(t, wireBytes, fields[0].wireType)
+
+	fields, err = parseFields(fields[0].payload)
+	assert.NoError(t, err)
+	assert.Len(t, fields, 3)
+	skip, ok := findField(fields, fieldSignVoteReqSkip)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), skip.varint)
+
+	// Truncated length-delimited field.
+	_, err = parseFields([]byte{0x0a, 0x05, 0x01})
+	assert.Error(t, err)
+
+	// Groups aren't supported.
+	_, err = parseFields([]byte{0x0b})
+	assert.Error(t, err)
+}
+
+func TestRawMsg(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := tm_protoio.NewDelimitedWriter(&buf).WriteMsg(&rawMsg{bz: testFixture(t, fixtureV034SignVoteRequest)})
+	assert.NoError(t, err)
+
+	var raw rawMsg
+	_, err = tm_protoio.NewDelimitedReader(&buf, 1024).ReadMsg(&raw)
+	assert.NoError(t, err)
+	assert.Equal(t, testFixture(t, fixtureV034SignVoteRequest), raw.bz)
+}
+
+func TestHandleSignRequest_VoteExtension(t *testing.T) {
+	pv := mockSCFilePV(t)
+	req, err := DecodeRequest(ProtocolAuto, testFixture(t, fixtureV038SignVoteRequestExt))
+	assert.NoError(t, err)
+
+	// The handler must not be invoked for vote extensions.
+	var handled bool
+	pv.handle = func(ctx context.Context, msg *tm_privvalproto.Message, pv *SCFilePV) (*tm_privvalproto.Message, error) {
+		handled = true
+		return HandleRequest(ctx, msg, pv)
+	}
+
+	resp, err := pv.handleSignRequest(context.Background(), req)
+	assert.Equal(t, ErrVoteExtensionsUnsupported, err)
+	assert.False(t, handled)
+	assert.Equal(t, ProtocolV038, resp.Version)
+	assert.Equal(t, ErrVoteExtensionsUnsupported.Error(), resp.Msg.GetSignedVoteResponse().GetError().GetDescription())
+}
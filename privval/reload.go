@@ -0,0 +1,176 @@
+package privval
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+)
+
+// reloadableSettings are the settings that are applied when the configuration is
+// reloaded at runtime. All others only take effect after a restart.
+var reloadableSettings = map[string]bool{
+	"base.threshold":                    true,
+	"base.dial_timeout":                 true,
+	"connection.retry.initial_interval": true,
+	"connection.retry.max_interval":     true,
+	"connection.retry.multiplier":       true,
+	"connection.retry.jitter":           true,
+	"connection.retry.max_attempts":     true,
+	"connection.retry.max_elapsed_time": true,
+	"hooks.on_promote_cmd":              true,
+	"hooks.on_shutdown_cmd":             true,
+	"hooks.on_degraded_cmd":             true,
+	"hooks.timeout":                     true,
+	"logging.level":                     true,
+}
+
+// ReloadResponse defines the response JSON for reloading the configuration. Each
+// change is formatted as "key: old -> new". Ignored changes only take effect after a
+// restart.
+type ReloadResponse struct {
+	Applied []string `json:"applied"`
+	Ignored []string `json:"ignored"`
+}
+
+// ReloadConfig makes the node reload its configuration file and returns the changes.
+func ReloadConfig() (*ReloadResponse, error) {
+	var resp ReloadResponse
+	if err := postAdmin("/reload", struct{}{}, &resp); err != nil {
+		return nil, err
+	}
+
+	return &resp, nil
+}
+
+// baseConfig returns the base section of the configuration, which is partly reloaded
+// at runtime.
+func (pv *SCFilePV) baseConfig() config.Base {
+	pv.configMtx.RLock()
+	defer pv.configMtx.RUnlock()
+	return pv.Config.Base
+}
+
+// retryConfig returns the retry policy of the connection section of the configuration,
+// which is reloaded at runtime.
+func (pv *SCFilePV) retryConfig() config.Retry {
+	pv.configMtx.RLock()
+	defer pv.configMtx.RUnlock()
+	return pv.Config.Connection.Retry
+}
+
+// hooksConfig returns the hooks section of the configuration, which is reloaded at
+// runtime.
+func (pv *SCFilePV) hooksConfig() config.Hooks {
+	pv.configMtx.RLock()
+	defer pv.configMtx.RUnlock()
+	return pv.Config.Hooks
+}
+
+// reloadConfig loads and validates the configuration again and applies the settings
+// that can be changed at runtime. Changes to the others are reported as ignored until
+// restart. If the new configuration is invalid, the previous one stays in effect as a
+// whole.
+func (pv *SCFilePV) reloadConfig() (*ReloadResponse, error) {
+	pv.reloadMtx.Lock()
+	defer pv.reloadMtx.Unlock()
+	cfg, err := pv.loadConfig()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't reload %v, keeping the previous configuration:\n%v", config.File, err)
+	}
+
+	var resp ReloadResponse
+	changes := config.Diff(pv.Config, cfg)
+	for _, change := range changes {
+		line := fmt.Sprintf("%v: %v -> %v", change.Key, change.Old, change.New)
+		if reloadableSettings[change.Key] {
+			resp.Applied = append(resp.Applied, line)
+		} else {
+			resp.Ignored = append(resp.Ignored, line)
+		}
+	}
+
+	// The threshold is the only setting that can be refused, so it is set first to
+	// leave the previous configuration in effect if it is.
+	if cfg.Base.Threshold != pv.Config.Base.Threshold {
+		if err := pv.BaseSignCtrled.SetThreshold(cfg.Base.Threshold); err != nil {
+			return nil, fmt.Errorf("couldn't reload %v, keeping the previous configuration: %w", config.File, err)
+		}
+		pv.Gauges.ThresholdGauge.Set(float64(pv.GetThreshold()))
+	}
+	if cfg.Logging.Level != pv.Config.Logging.Level {
+		pv.Logger.SetLevel(cfg.Logging.Level)
+	}
+
+	pv.configMtx.Lock()
+	base := &pv.Config.Base
+	base.Threshold = cfg.Base.Threshold
+	base.DialTimeout = cfg.Base.DialTimeout
+	pv.Config.Connection.Retry = cfg.Connection.Retry
+	pv.Config.Hooks = cfg.Hooks
+	pv.Config.Logging.Level = cfg.Logging.Level
+	pv.configMtx.Unlock()
+
+	for _, line := range resp.Applied {
+		pv.Logger.Info("Reloaded %v", line)
+	}
+	for _, line := range resp.Ignored {
+		pv.Logger.Warn("Changed %v, ignored until restart", line)
+	}
+	if len(changes) == 0 {
+		pv.Logger.Info("Reloaded %v without changes", config.File)
+	}
+
+	return &resp, nil
+}
+
+// reloadOnSIGHUP reloads the configuration on SIGHUP until the given context is
+// canceled.
+func (pv *SCFilePV) reloadOnSIGHUP(ctx context.Context) {
+	sighup := make(chan os.Signal, 1)
+	signal.Notify(sighup, syscall.SIGHUP)
+	go pv.watchConfig(ctx, sighup)
+}
+
+// watchConfig reloads the configuration whenever a signal is received on the given
+// channel until the given context is canceled.
+func (pv *SCFilePV) watchConfig(ctx context.Context, sighup chan os.Signal) {
+	defer signal.Stop(sighup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-sighup:
+			if _, err := pv.reloadConfig(); err != nil {
+				pv.Logger.Error("%v", err)
+			}
+		}
+	}
+}
+
+// reloadHandler reloads the configuration and responds with the changes.
+func (pv *SCFilePV) reloadHandler(rw http.ResponseWriter, r *http.Request) {
+	var req struct{}
+	if !pv.parseAdminRequest(rw, r, &req) {
+		return
+	}
+	resp, err := pv.reloadConfig()
+	if err != nil {
+		pv.Logger.Error("%v", err)
+		http.Error(rw, err.Error(), http.StatusBadRequest)
+		return
+	}
+	bz, err := tm_json.Marshal(resp)
+	if err != nil {
+		http.Error(rw, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	_, _ = rw.Write(bz)
+}
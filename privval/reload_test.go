@@ -0,0 +1,81 @@
+package privval
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+)
+
+func TestReloadConfig(t *testing.T) {
+	pv := mockSCFilePV(t)
+	var buf bytes.Buffer
+	pv.Logger, _ = types.NewLogger(&buf, "info", types.LogFormatPlain)
+	cfg := pv.Config
+	cfg.Logging.Level = "debug"
+	cfg.Base.Threshold = 20
+	cfg.Connection.Retry.InitialInterval = "2s"
+	cfg.Base.StartRank = 2
+	cfg.Hooks.OnPromoteCmd = "echo promoted"
+	pv.loadConfig = func() (config.Config, error) { return cfg, nil }
+
+	// The settings that can be changed at runtime are applied, the others ignored.
+	resp, err := pv.reloadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		`base.threshold: 10 -> 20`,
+		`connection.retry.initial_interval: "1s" -> "2s"`,
+		`hooks.on_promote_cmd: "" -> "echo promoted"`,
+		`logging.level: "info" -> "debug"`,
+	}, resp.Applied)
+	assert.Equal(t, []string{`base.start_rank: 1 -> 2`}, resp.Ignored)
+	assert.Equal(t, 20, pv.GetThreshold())
+	assert.Equal(t, "2s", pv.retryConfig().InitialInterval)
+	assert.Equal(t, "echo promoted", pv.hooksConfig().OnPromoteCmd)
+	assert.Equal(t, 1, pv.Config.Base.StartRank)
+	assert.Contains(t, buf.String(), "[WARN]  signctrl: Changed base.start_rank: 1 -> 2, ignored until restart")
+	assert.Equal(t, "debug", pv.Config.Logging.Level)
+	pv.Logger.Debug("now logged")
+	assert.Contains(t, buf.String(), "[DEBUG] signctrl: now logged")
+
+	// An invalid configuration leaves the previous one in effect.
+	pv.loadConfig = func() (config.Config, error) {
+		return config.Config{}, errors.New("\tbase.threshold must be 2 or higher\n")
+	}
+	_, err = pv.reloadConfig()
+	assert.EqualError(t, err, "couldn't reload config.toml, keeping the previous configuration:\n\tbase.threshold must be 2 or higher\n")
+	assert.Equal(t, 20, pv.GetThreshold())
+	assert.Equal(t, "2s", pv.retryConfig().InitialInterval)
+}
+
+func TestReloadHandler(t *testing.T) {
+	pv := mockSCFilePV(t)
+	cfg := pv.Config
+	cfg.Base.Threshold = 15
+	pv.loadConfig = func() (config.Config, error) { return cfg, nil }
+	request := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/reload", nil)
+		req.RemoteAddr = "127.0.0.1:50000"
+		rec := httptest.NewRecorder()
+		pv.reloadHandler(rec, req)
+		return rec
+	}
+
+	rec := request()
+	assert.Equal(t, http.StatusOK, rec.Code)
+	var resp ReloadResponse
+	assert.NoError(t, tm_json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, []string{"base.threshold: 10 -> 15"}, resp.Applied)
+	assert.Empty(t, resp.Ignored)
+
+	pv.loadConfig = func() (config.Config, error) { return config.Config{}, errors.New("invalid") }
+	rec = request()
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), "keeping the previous configuration")
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 
+	"github.com/BlockscapeNetwork/signctrl/config"
 	"github.com/BlockscapeNetwork/signctrl/rpc"
 	"github.com/BlockscapeNetwork/signctrl/types"
 	"github.com/gogo/protobuf/proto"
@@ -16,10 +17,26 @@ import (
 	tm_types "github.com/tendermint/tendermint/types"
 )
 
+const (
+	// CodeUnsupportedMsg is the code of the RemoteSignerError that is sent to the
+	// validator if it sent a message type SignCTRL doesn't support.
+	CodeUnsupportedMsg int32 = 1
+)
+
 var (
 	// ErrRankObsolete is returned if the requested vote height is too far ahead of the last
 	// block the validator signed. The gap must be at least {threshold} blocks.
 	ErrRankObsolete = errors.New("at least one threshold was exceeded between requested vote height and last_signed_height")
+
+	// ErrDryRun is returned for all sign requests in dry-run mode.
+	ErrDryRun = errors.New("dry-run mode, not signing")
+
+	// ErrNotActiveSigner is returned for all sign requests on any rank but 1.
+	ErrNotActiveSigner = errors.New("not the active signer")
+
+	// ErrShuttingDown is returned for sign requests that are still queued once
+	// SignCTRL is stopping.
+	ErrShuttingDown = errors.New("signer shutting down")
 )
 
 // wrapMsg wraps a protobuf message into a privval proto message.
@@ -63,6 +80,23 @@ func hasSignedCommit(valaddr tm_types.Address, commitsigs *[]tm_types.CommitSig)
 	return false
 }
 
+// commitParticipation returns the fraction of the given peer validators that have a
+// commitsig in the provided commitsigs. Without peers, the participation is 1.
+func commitParticipation(peers []tm_types.Address, commitsigs *[]tm_types.CommitSig) float64 {
+	if len(peers) == 0 {
+		return 1
+	}
+
+	signed := 0
+	for _, peer := range peers {
+		if hasSignedCommit(peer, commitsigs) {
+			signed++
+		}
+	}
+
+	return float64(signed) / float64(len(peers))
+}
+
 // isRankUpToDate checks whether the validator's rank is still up to date or obsolete.
 func isRankUpToDate(reqHeight int64, lastHeight int64, threshold int) bool {
 	return reqHeight-lastHeight < int64(threshold+1)
@@ -81,8 +115,10 @@ func handlePubKeyRequest(req *tm_privvalproto.PubKeyRequest, pv *SCFilePV) (*tm_
 	pv.Logger.Debug("Received PubKeyRequest: %v", req)
 
 	// Check if the PubKeyRequest is for the chain ID specified
-	// in the config.toml.
-	if req.GetChainId() != pv.Config.Privval.ChainID {
+	// in the config.toml. Older validators don't send a chain ID
+	// with PubKeyRequests, so only check it if it's present.
+	if req.GetChainId() != "" && req.GetChainId() != pv.Config.Privval.ChainID {
+		pv.Logger.Warn("Rejected PubKeyRequest for chain ID '%v' (expected: '%v')", req.GetChainId(), pv.Config.Privval.ChainID)
 		err := fmt.Errorf("expected PubKeyRequest for chain ID '%v', instead got '%v'", pv.Config.Privval.ChainID, req.GetChainId())
 		return wrapMsg(&tm_privvalproto.PubKeyResponse{
 			PubKey: tm_cryptoproto.PublicKey{},
@@ -112,6 +148,7 @@ type sharedSignRequestData struct {
 	chainID string
 	msgType tm_typesproto.SignedMsgType
 	height  int64
+	round   int32
 }
 
 // getSharedSignRequestData returns shared sign request data.
@@ -122,12 +159,14 @@ func getSharedSignRequestData(msg *tm_privvalproto.Message) (data sharedSignRequ
 		data.chainID = req.ChainId
 		data.msgType = req.Vote.Type
 		data.height = req.Vote.Height
+		data.round = req.Vote.Round
 
 	case *tm_privvalproto.Message_SignProposalRequest:
 		req := msg.GetSignProposalRequest()
 		data.chainID = req.ChainId
 		data.msgType = req.Proposal.Type
 		data.height = req.Proposal.Height
+		data.round = req.Proposal.Round
 	}
 
 	return data
@@ -160,7 +199,7 @@ func handleSignRequest(ctx context.Context, msg *tm_privvalproto.Message, pv *SC
 	case *tm_privvalproto.Message_SignVoteRequest:
 		pv.Logger.Debug("Received SignVoteRequest: %v", msg.GetSignVoteRequest())
 	case *tm_privvalproto.Message_SignProposalRequest:
-		pv.Logger.Debug("Received SignProposalRequest", msg.GetSignProposalRequest())
+		pv.Logger.Debug("Received SignProposalRequest: %v", msg.GetSignProposalRequest())
 	}
 
 	// Extract data shared between vote and proposal requests.
@@ -168,14 +207,16 @@ func handleSignRequest(ctx context.Context, msg *tm_privvalproto.Message, pv *SC
 
 	// Check if the request is for the chain ID specified in the config.toml.
 	if reqData.chainID != pv.Config.Privval.ChainID {
+		pv.Logger.Warn("Rejected %v for chain ID '%v' (expected: '%v')", reqData.msgType, reqData.chainID, pv.Config.Privval.ChainID)
 		err := fmt.Errorf("expected sign request for chain ID '%v', instead got '%v'", pv.Config.Privval.ChainID, reqData.chainID)
 		return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
 	}
 
 	// If the requested height is at least {threshold}+1 higher than last_signed_height,
-	// the node's rank has become obsolete due to a rank update in the set.
-	if !isRankUpToDate(reqData.height, pv.State.LastHeight, pv.GetThreshold()) {
-		pv.Logger.Debug("The requested height differs too much from the last height (%v - %v >= %v)", reqData.height, pv.State.LastHeight, pv.GetThreshold()+1)
+	// the node's rank has become obsolete due to a rank update in the set. The lowest
+	// threshold is used, as it is the first one to be exceeded in the set.
+	if !isRankUpToDate(reqData.height, pv.State.LastHeight, pv.GetMinThreshold()) {
+		pv.Logger.Debug("The requested height differs too much from the last height (%v - %v >= %v)", reqData.height, pv.State.LastHeight, pv.GetMinThreshold()+1)
 		return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: ErrRankObsolete.Error()}), ErrRankObsolete
 	}
 
@@ -189,42 +230,121 @@ func handleSignRequest(ctx context.Context, msg *tm_privvalproto.Message, pv *SC
 			return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
 		}
 
-		// Update the current height to the height of the request.
-		pv.BaseSignCtrled.SetCurrentHeight(reqData.height)
+		// The counter restored on startup might be stale, as the blocks missed while
+		// SignCTRL was down haven't been seen.
+		if pv.restored {
+			pv.checkRestoredCounter(reqData.height)
+		}
+
+		// Update the current height to the height of the request. It is higher than
+		// the current one, so it can only be rejected if the current height has been
+		// changed concurrently, in which case the request isn't handled.
+		if err := pv.BaseSignCtrled.SetCurrentHeight(reqData.height); err != nil {
+			return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
+		}
 		pv.State.LastHeight = reqData.height
+		pv.logSigningStats(reqData.height)
 
 		// Check if the commitsigs in the block are signed by the validator.
 		pub, _ := pv.TMFilePV.GetPubKey()
-		if !hasSignedCommit(pub.Address(), &rb.Block.LastCommit.Signatures) {
-			// Check if the threshold of too many missed blocks in a row is exceeded.
-			if err := pv.Missed(); err != nil {
-				if err == types.ErrMustShutdown {
-					return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
+		signed := hasSignedCommit(pub.Address(), &rb.Block.LastCommit.Signatures)
+		if !signed && pv.Config.Base.UnlockOn == config.UnlockOnAnyCommit && pv.IsCounterLocked() {
+			// With unlock_on set to any_commit, the commit unlocks the counter even
+			// without the validator's commitsig, but isn't counted as missed.
+			pv.UnlockCounter()
+		} else if !signed {
+			// If most of the peer validators missed the block as well, the chain is
+			// the problem, so a rank update wouldn't help.
+			if p := commitParticipation(pv.peerAddresses(), &rb.Block.LastCommit.Signatures); p < pv.Config.Monitoring.MinParticipation {
+				pv.Logger.Warn("Suspecting a chain stall at block height %v (peer participation: %.2f < %.2f), so the missed block isn't counted", reqData.height-1, p, pv.Config.Monitoring.MinParticipation)
+			} else if err := pv.Missed(reqData.height); err != nil {
+				// Check if the threshold of too many missed blocks in a row is exceeded.
+				// In rejoin mode, the validator stays in the set on the last rank
+				// instead of shutting down, so the rank gate below refuses to sign.
+				pv.logThresholdExceeded(err)
+				if errors.Is(err, types.ErrMustShutdown) {
+					if !pv.Config.Base.Rejoin {
+						return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
+					}
+					if err := pv.rejoin(); err != nil {
+						pv.Logger.Error("couldn't rejoin the set: %v\n", err)
+						return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: types.ErrMustShutdown.Error()}), types.ErrMustShutdown
+					}
 				}
 			}
 		} else {
 			// If the commit was signed, reset the counter for missed blocks in a row
-			// and unlock it if it hasn't already been unlocked.
-			pv.Reset()
-			pv.UnlockCounter()
+			// and unlock it if it hasn't already been unlocked. A commitsig given before
+			// a connection event locked the counter doesn't say anything about the
+			// restored connection, though.
+			pv.Signed()
+			if reqData.height-1 > pv.connLockHeight {
+				pv.UnlockCounter()
+			} else if pv.IsCounterLocked() {
+				pv.Logger.Debug("Commitsig at block height %v was given before the counter was locked at block height %v", reqData.height-1, pv.connLockHeight)
+			}
 		}
+	} else if reqData.height < pv.BaseSignCtrled.GetCurrentHeight() {
+		// The validator went back to a lower height, e.g. after a restore from a
+		// snapshot. The height is rejected, which is logged and locks the counter if
+		// lock_on_height_regression is enabled. The request is still handled, so the
+		// ErrHeightRegression returned is expected.
+		_ = pv.BaseSignCtrled.SetCurrentHeight(reqData.height)
 	}
 
-	// Prevent the node from signing if it's not ranked first in the set.
-	if pv.GetRank() > 1 {
-		err := fmt.Errorf("no signing permission for %v on block height %v (rank: %v)", reqData.msgType, reqData.height, pv.GetRank())
-		return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
+	// Prevent the node from signing if it's not the active signer of the set, which is
+	// the node ranked first. Once promoted to rank 1, the node signs right away.
+	if pv.GetRank() != 1 {
+		if !pv.Config.Privval.UnsafeSignAnyRank {
+			err := fmt.Errorf("%w, rank %v", ErrNotActiveSigner, pv.GetRank())
+			pv.Logger.Warn("Refused to sign %v for block height %v: %v", reqData.msgType, reqData.height, err)
+			return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
+		}
+		pv.Logger.Warn("Signing %v for block height %v on rank %v, as unsafe_sign_any_rank is enabled! This risks double-signing!", reqData.msgType, reqData.height, pv.GetRank())
+	}
+
+	// In dry-run mode, the node does everything but sign.
+	if pv.Config.Privval.DryRun {
+		pv.Logger.Info("Would have signed %v for block height %v, round %v (dry run)", reqData.msgType, reqData.height, reqData.round)
+		return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: ErrDryRun.Error()}), ErrDryRun
 	}
 
+	// Watch-only nodes load the private key on promotion to rank 1. Without it, e.g.
+	// with unsafe_sign_any_rank on a lower rank, the node can't sign, so it must shut
+	// down.
+	if _, ok := pv.TMFilePV.(*WatchOnlyPV); ok {
+		pv.Logger.Error("couldn't sign on rank %v: %v\n", pv.GetRank(), ErrWatchOnly)
+		return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: types.ErrMustShutdown.Error()}), types.ErrMustShutdown
+	}
+
+	// Never sign a message whose height, round and step aren't higher than the ones of
+	// the last message signed, no matter what the signing backend would do. Only the
+	// exact message signed last is signed again.
+	step := msgTypeToStep(reqData.msgType)
+
 	switch msg.Sum.(type) {
 	case *tm_privvalproto.Message_SignVoteRequest:
 		req := msg.GetSignVoteRequest()
 
 		// The node has permission to sign the vote, so sign it.
-		if err := pv.TMFilePV.SignVote(pv.Config.Privval.ChainID, req.Vote); err != nil {
-			err := fmt.Errorf("failed to sign %v for block height %v: %v", req.Vote.Type, req.Vote.Height, err)
+		signBytes := tm_types.VoteSignBytes(pv.Config.Privval.ChainID, req.Vote)
+		signed := false
+		sig, err := pv.Watermark.sign(reqData.height, reqData.round, step, signBytes, func() ([]byte, error) {
+			if err := pv.TMFilePV.SignVote(pv.Config.Privval.ChainID, req.Vote); err != nil {
+				return nil, err
+			}
+			signed = true
+			return req.Vote.Signature, pv.updateStateChecksum()
+		})
+		if err != nil {
+			req.Vote.Signature = nil
+			err := fmt.Errorf("failed to sign %v for block height %v: %w", req.Vote.Type, req.Vote.Height, err)
 			return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
 		}
+		req.Vote.Signature = sig
+		if signed {
+			pv.recordSignature(req.Vote.Type, req.Vote.Height)
+		}
 
 		pv.Logger.Info("Signed %v for block height %v", req.Vote.Type, req.Vote.Height)
 		return buildResponse(wrapMsg(&tm_privvalproto.SignVoteRequest{Vote: req.Vote, ChainId: req.GetChainId()}), nil), nil
@@ -233,10 +353,24 @@ func handleSignRequest(ctx context.Context, msg *tm_privvalproto.Message, pv *SC
 		req := msg.GetSignProposalRequest()
 
 		// The node has permission to sign the proposal, so sign it.
-		if err := pv.TMFilePV.SignProposal(pv.Config.Privval.ChainID, req.Proposal); err != nil {
-			err := fmt.Errorf("failed to sign %v for block height %v: %v", req.Proposal.Type, req.Proposal.Height, err)
+		signBytes := tm_types.ProposalSignBytes(pv.Config.Privval.ChainID, req.Proposal)
+		signed := false
+		sig, err := pv.Watermark.sign(reqData.height, reqData.round, step, signBytes, func() ([]byte, error) {
+			if err := pv.TMFilePV.SignProposal(pv.Config.Privval.ChainID, req.Proposal); err != nil {
+				return nil, err
+			}
+			signed = true
+			return req.Proposal.Signature, pv.updateStateChecksum()
+		})
+		if err != nil {
+			req.Proposal.Signature = nil
+			err := fmt.Errorf("failed to sign %v for block height %v: %w", req.Proposal.Type, req.Proposal.Height, err)
 			return buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()}), err
 		}
+		req.Proposal.Signature = sig
+		if signed {
+			pv.recordSignature(req.Proposal.Type, req.Proposal.Height)
+		}
 
 		pv.Logger.Info("Signed %v for block height %v", req.Proposal.Type, req.Proposal.Height)
 		return buildResponse(wrapMsg(&tm_privvalproto.SignProposalRequest{Proposal: req.Proposal, ChainId: req.GetChainId()}), nil), nil
@@ -250,12 +384,30 @@ func handleSignRequest(ctx context.Context, msg *tm_privvalproto.Message, pv *SC
 func HandleRequest(ctx context.Context, msg *tm_privvalproto.Message, pv *SCFilePV) (*tm_privvalproto.Message, error) {
 	switch msg.Sum.(type) {
 	case *tm_privvalproto.Message_PingRequest:
+		pv.updateLastActivity()
 		return handlePingRequest(pv)
 	case *tm_privvalproto.Message_PubKeyRequest:
+		pv.updateLastActivity()
 		return handlePubKeyRequest(msg.GetPubKeyRequest(), pv)
 	case *tm_privvalproto.Message_SignVoteRequest, *tm_privvalproto.Message_SignProposalRequest:
+		pv.updateLastActivity()
 		return handleSignRequest(ctx, msg, pv)
 	default:
-		return nil, fmt.Errorf("unknown message: %v", msg)
+		return handleUnsupportedMsg(msg)
 	}
 }
+
+// handleUnsupportedMsg handles messages of unknown or unsupported types by returning
+// a PubKeyResponse carrying a RemoteSignerError. Since the type of response that the
+// validator expects is unknown, this makes sure it gets immediate feedback instead of
+// waiting for a response until it times out.
+func handleUnsupportedMsg(msg *tm_privvalproto.Message) (*tm_privvalproto.Message, error) {
+	err := fmt.Errorf("unsupported message type %T", msg.Sum)
+	return wrapMsg(&tm_privvalproto.PubKeyResponse{
+		PubKey: tm_cryptoproto.PublicKey{},
+		Error: &tm_privvalproto.RemoteSignerError{
+			Code:        CodeUnsupportedMsg,
+			Description: err.Error(),
+		},
+	}), err
+}
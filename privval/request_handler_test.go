@@ -1,14 +1,19 @@
 package privval
 
 import (
+	"bytes"
 	"context"
+	"encoding/hex"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/BlockscapeNetwork/signctrl/config"
 	"github.com/BlockscapeNetwork/signctrl/rpc"
 	"github.com/BlockscapeNetwork/signctrl/types"
 	"github.com/stretchr/testify/assert"
@@ -110,9 +115,14 @@ func testPingRequest(t *testing.T) *tm_privvalproto.Message {
 
 func TestHandlePingRequest(t *testing.T) {
 	pv := mockSCFilePV(t)
+	assert.True(t, pv.LastActivity().IsZero())
+
+	before := time.Now()
 	msg, err := HandleRequest(context.Background(), testPingRequest(t), pv)
 	assert.NotNil(t, msg)
 	assert.NoError(t, err)
+	assert.IsType(t, &tm_privvalproto.Message_PingResponse{}, msg.Sum)
+	assert.False(t, pv.LastActivity().Before(before))
 }
 
 func testPubKeyRequest(t *testing.T) *tm_privvalproto.Message {
@@ -139,6 +149,21 @@ func TestHandlePubKeyRequest_WrongChainID(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestHandlePubKeyRequest_NoChainID(t *testing.T) {
+	// Initialize mock SCFilePV with valid values.
+	pv := mockSCFilePV(t)
+
+	// Older validators don't send a chain ID with PubKeyRequests.
+	req := testPubKeyRequest(t)
+	req.GetPubKeyRequest().ChainId = ""
+
+	// Handle request.
+	msg, err := HandleRequest(context.Background(), req, pv)
+	assert.NotNil(t, msg)
+	assert.NoError(t, err)
+	assert.Nil(t, msg.GetPubKeyResponse().GetError())
+}
+
 func TestHandlePubKeyRequest_InvalidPubKey(t *testing.T) {
 	// Initialize mock SCFilePV with valid values.
 	pv := mockSCFilePV(t)
@@ -233,12 +258,14 @@ func TestGetSharedSignRequestData(t *testing.T) {
 	assert.Equal(t, testSignVoteRequest(t).GetSignVoteRequest().ChainId, data.chainID)
 	assert.Equal(t, testSignVoteRequest(t).GetSignVoteRequest().Vote.Type, data.msgType)
 	assert.Equal(t, testSignVoteRequest(t).GetSignVoteRequest().Vote.Height, data.height)
+	assert.Equal(t, testSignVoteRequest(t).GetSignVoteRequest().Vote.Round, data.round)
 
 	data = getSharedSignRequestData(testSignProposalRequest(t))
 	assert.NotNil(t, data)
 	assert.Equal(t, testSignProposalRequest(t).GetSignProposalRequest().ChainId, data.chainID)
 	assert.Equal(t, testSignProposalRequest(t).GetSignProposalRequest().Proposal.Type, data.msgType)
 	assert.Equal(t, testSignProposalRequest(t).GetSignProposalRequest().Proposal.Height, data.height)
+	assert.Equal(t, testSignProposalRequest(t).GetSignProposalRequest().Proposal.Round, data.round)
 }
 
 func TestBuildResponse(t *testing.T) {
@@ -284,14 +311,19 @@ func testBlockEndpoint(t *testing.T, port int, result *rpc.BlockResult, quitCh c
 		_, _ = rw.Write(bytes)
 	})
 
-	server := http.Server{Addr: fmt.Sprintf(":%v", port), Handler: mux}
-	defer server.Close()
-
-	listener, _ := net.Listen("tcp", server.Addr)
+	// Listen before returning so that requests don't race the server's start.
+	server := &http.Server{Addr: fmt.Sprintf(":%v", port), Handler: mux}
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
 	go func() {
 		_ = server.Serve(listener)
 	}()
-	<-quitCh
+	go func() {
+		<-quitCh
+		server.Close()
+	}()
 }
 
 func TestHandleSignRequest(t *testing.T) {
@@ -302,7 +334,7 @@ func TestHandleSignRequest(t *testing.T) {
 	port, _ := getFreePort(t)
 	pv.Config.Base.ValidatorListenAddressRPC = fmt.Sprintf("tcp://127.0.0.1:%v", port)
 	quitCh := make(chan struct{})
-	go testBlockEndpoint(t, port, testBlockResult(t), quitCh)
+	testBlockEndpoint(t, port, testBlockResult(t), quitCh)
 	defer close(quitCh)
 
 	// Initialize new file signer.
@@ -332,6 +364,25 @@ func TestHandleSignRequest_WrongChainID(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestHandleSignRequest_WrongChainIDWarning(t *testing.T) {
+	// Initialize mock SCFilePV with valid values.
+	pv := mockSCFilePV(t)
+	var buf bytes.Buffer
+	pv.Logger = types.NewSyncLogger(&buf, "", 0)
+	pv.Config.Privval.ChainID = "wrongchain"
+
+	// Both chain IDs are logged as a warning, and the request isn't signed.
+	for _, req := range []*tm_privvalproto.Message{testSignVoteRequest(t), testSignProposalRequest(t), testPubKeyRequest(t)} {
+		buf.Reset()
+		msg, err := HandleRequest(context.Background(), req, pv)
+		assert.Error(t, err)
+		assert.NotNil(t, msg)
+		assert.Contains(t, buf.String(), "[WARN]")
+		assert.Contains(t, buf.String(), "'testchain'")
+		assert.Contains(t, buf.String(), "'wrongchain'")
+	}
+}
+
 func TestHandleSignRequest_ObsoleteRank(t *testing.T) {
 	// Initialize mock SCFilePV with valid values.
 	pv := mockSCFilePV(t)
@@ -369,7 +420,7 @@ func TestHandleSignRequest_Missed(t *testing.T) {
 	port, _ := getFreePort(t)
 	pv.Config.Base.ValidatorListenAddressRPC = fmt.Sprintf("tcp://127.0.0.1:%v", port)
 	quitCh := make(chan struct{})
-	go testBlockEndpoint(t, port, testBlockResult(t), quitCh)
+	testBlockEndpoint(t, port, testBlockResult(t), quitCh)
 	defer close(quitCh)
 
 	// Initialize new file signer.
@@ -390,25 +441,247 @@ func TestHandleSignRequest_Missed(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestCommitParticipation(t *testing.T) {
+	commitsigs := testBlockResult(t).Result.Block.LastCommit.Signatures
+
+	// Without peers, the participation is always full.
+	assert.Equal(t, float64(1), commitParticipation(nil, &commitsigs))
+
+	peers := []tm_types.Address{[]byte("ALPHA-ADDR"), []byte("BETA-ADDR"), []byte("GAMMA-ADDR"), []byte("DELTA-ADDR")}
+	assert.Equal(t, 0.5, commitParticipation(peers, &commitsigs))
+	assert.Equal(t, float64(1), commitParticipation(peers[:2], &commitsigs))
+	assert.Equal(t, float64(0), commitParticipation(peers[2:], &commitsigs))
+}
+
+func TestHandleSignRequest_ChainStall(t *testing.T) {
+	pv := testWatermarkSCFilePV(t)
+	pv.UnlockCounter()
+
+	// Most of the peer validators missed the block as well, so it isn't counted.
+	pv.Config.Monitoring.PeerAddresses = []string{
+		hex.EncodeToString([]byte("ALPHA-ADDR")),
+		hex.EncodeToString([]byte("GAMMA-ADDR")),
+		hex.EncodeToString([]byte("DELTA-ADDR")),
+	}
+	_, err := HandleRequest(context.Background(), testSignProposalRequest(t), pv)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, pv.GetMissedInARow())
+
+	// Enough peer validators signed the block, so it is counted.
+	pv = testWatermarkSCFilePV(t)
+	pv.UnlockCounter()
+	pv.Config.Monitoring.PeerAddresses = []string{
+		hex.EncodeToString([]byte("ALPHA-ADDR")),
+		hex.EncodeToString([]byte("BETA-ADDR")),
+	}
+	_, err = HandleRequest(context.Background(), testSignProposalRequest(t), pv)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pv.GetMissedInARow())
+}
+
+// testUnlockOnSCFilePV returns a mock SCFilePV with the given unlock_on, whose block
+// endpoint serves a commit with or without the validator's commitsig.
+func testUnlockOnSCFilePV(t *testing.T, unlockOn string, signed bool) *SCFilePV {
+	t.Helper()
+	pv := mockSCFilePV(t)
+	pv.TMFilePV = tm_types.NewMockPV()
+	pv.Config.Base.UnlockOn = unlockOn
+
+	result := testBlockResult(t)
+	if signed {
+		pub, _ := pv.TMFilePV.GetPubKey()
+		commit := result.Result.Block.LastCommit
+		commit.Signatures = append(commit.Signatures, tm_types.CommitSig{
+			ValidatorAddress: pub.Address(),
+			Signature:        []byte("OWN-SIG"),
+		})
+	}
+
+	// Start mock endpoint for the block query.
+	port, _ := getFreePort(t)
+	pv.Config.Base.ValidatorListenAddressRPC = fmt.Sprintf("tcp://127.0.0.1:%v", port)
+	quitCh := make(chan struct{})
+	testBlockEndpoint(t, port, result, quitCh)
+	t.Cleanup(func() { close(quitCh) })
+
+	return pv
+}
+
+func TestHandleSignRequest_UnlockOn(t *testing.T) {
+	testCases := []struct {
+		unlockOn string
+		signed   bool
+		unlocked bool
+	}{
+		{config.UnlockOnOwnSignature, true, true},
+		{config.UnlockOnOwnSignature, false, false},
+		{config.UnlockOnAnyCommit, true, true},
+		{config.UnlockOnAnyCommit, false, true},
+	}
+	for _, tc := range testCases {
+		pv := testUnlockOnSCFilePV(t, tc.unlockOn, tc.signed)
+		assert.True(t, pv.IsCounterLocked())
+		_, _ = HandleRequest(context.Background(), testSignProposalRequest(t), pv)
+		assert.Equal(t, !tc.unlocked, pv.IsCounterLocked(), "unlock_on %v, signed: %v", tc.unlockOn, tc.signed)
+
+		// The commit that unlocks the counter is never counted as missed.
+		assert.Zero(t, pv.GetMissedInARow(), "unlock_on %v, signed: %v", tc.unlockOn, tc.signed)
+	}
+}
+
+// testWatermarkSCFilePV returns a mock SCFilePV that signs with a signing backend
+// without double-signing protection of its own.
+func testWatermarkSCFilePV(t *testing.T) *SCFilePV {
+	t.Helper()
+	return testWatermarkSCFilePVIn(t, t.TempDir())
+}
+
+// testWatermarkSCFilePVIn returns a testWatermarkSCFilePV with the given configuration
+// directory.
+func testWatermarkSCFilePVIn(t *testing.T, cfgDir string) *SCFilePV {
+	t.Helper()
+	pv := mockSCFilePVIn(t, cfgDir)
+	pv.TMFilePV = tm_types.NewMockPV()
+
+	// Start mock endpoint for the block query.
+	port, _ := getFreePort(t)
+	pv.Config.Base.ValidatorListenAddressRPC = fmt.Sprintf("tcp://127.0.0.1:%v", port)
+	quitCh := make(chan struct{})
+	testBlockEndpoint(t, port, testBlockResult(t), quitCh)
+	t.Cleanup(func() { close(quitCh) })
+
+	return pv
+}
+
+func TestHandleSignRequest_EqualHRS(t *testing.T) {
+	pv := testWatermarkSCFilePV(t)
+	msg, err := HandleRequest(context.Background(), testSignVoteRequest(t), pv)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, msg.GetSignedVoteResponse().Vote.Signature)
+
+	// A different vote for the same height, round and step is refused.
+	req := testSignVoteRequest(t)
+	req.GetSignVoteRequest().Vote.BlockID.Hash = tm_hash.Sum([]byte("OtherBlockIDHash"))
+	msg, err = HandleRequest(context.Background(), req, pv)
+	assert.Error(t, err)
+	assert.NotNil(t, msg.GetSignedVoteResponse().GetError())
+	assert.Empty(t, msg.GetSignedVoteResponse().Vote.Signature)
+}
+
+// countingPV counts the calls to its signing functions.
+type countingPV struct {
+	tm_types.PrivValidator
+	calls int
+}
+
+func (pv *countingPV) SignVote(chainID string, vote *tm_prototypes.Vote) error {
+	pv.calls++
+	return pv.PrivValidator.SignVote(chainID, vote)
+}
+
+func (pv *countingPV) SignProposal(chainID string, proposal *tm_prototypes.Proposal) error {
+	pv.calls++
+	return pv.PrivValidator.SignProposal(chainID, proposal)
+}
+
+func TestHandleSignRequest_IdenticalReplay(t *testing.T) {
+	pv := testWatermarkSCFilePV(t)
+	signer := &countingPV{PrivValidator: pv.TMFilePV}
+	pv.TMFilePV = signer
+
+	req := testSignVoteRequest(t)
+	msg, err := HandleRequest(context.Background(), req, pv)
+	assert.NoError(t, err)
+	sig := msg.GetSignedVoteResponse().Vote.Signature
+	assert.NotEmpty(t, sig)
+
+	// Replaying the identical request returns the same signature without signing again.
+	replay := testSignVoteRequest(t)
+	replay.GetSignVoteRequest().Vote.Timestamp = req.GetSignVoteRequest().Vote.Timestamp
+	msg, err = HandleRequest(context.Background(), replay, pv)
+	assert.NoError(t, err)
+	assert.Equal(t, sig, msg.GetSignedVoteResponse().Vote.Signature)
+	assert.Equal(t, 1, signer.calls)
+}
+
+func TestHandleSignRequest_LowerHRS(t *testing.T) {
+	pv := testWatermarkSCFilePV(t)
+	msg, err := HandleRequest(context.Background(), testSignVoteRequest(t), pv)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, msg.GetSignedVoteResponse().Vote.Signature)
+
+	// Lower step.
+	req := testSignVoteRequest(t)
+	req.GetSignVoteRequest().Vote.Type = tm_prototypes.PrevoteType
+	msg, err = HandleRequest(context.Background(), req, pv)
+	assert.Error(t, err)
+	assert.Empty(t, msg.GetSignedVoteResponse().Vote.Signature)
+
+	// Lower round.
+	req = testSignVoteRequest(t)
+	req.GetSignVoteRequest().Vote.Round = 0
+	msg, err = HandleRequest(context.Background(), req, pv)
+	assert.Error(t, err)
+	assert.Empty(t, msg.GetSignedVoteResponse().Vote.Signature)
+
+	// Lower height.
+	req = testSignVoteRequest(t)
+	req.GetSignVoteRequest().Vote.Height = 1
+	msg, err = HandleRequest(context.Background(), req, pv)
+	assert.Error(t, err)
+	assert.Empty(t, msg.GetSignedVoteResponse().Vote.Signature)
+
+	// The watermark is still at the last signed message.
+	assert.Equal(t, int64(2), pv.Watermark.Height)
+	assert.Equal(t, int32(1), pv.Watermark.Round)
+	assert.Equal(t, stepPrecommit, pv.Watermark.Step)
+}
+
+func TestHandleSignRequest_ConcurrentHRS(t *testing.T) {
+	pv := testWatermarkSCFilePV(t)
+
+	// Concurrent requests for different votes at the same height, round and step.
+	var wg sync.WaitGroup
+	var signed int32
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req := testSignVoteRequest(t)
+			req.GetSignVoteRequest().Vote.BlockID.Hash = tm_hash.Sum([]byte(fmt.Sprintf("BlockIDHash%v", i)))
+			if _, err := pv.safeHandleRequest(context.Background(), req); err == nil {
+				atomic.AddInt32(&signed, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(1), signed)
+}
+
 func TestHandleSignRequest_MustShutdown(t *testing.T) {
 	// Initialize mock SCFilePV with valid values.
 	pv := mockSCFilePV(t)
 
-	// Set threshold to 1, so the next missed block exceeds it, and also rank to 1
-	// so the promotion fails.
-	pv.BaseSignCtrled = *types.NewBaseSignCtrled(
+	// Set threshold to 2 and miss a block, so the next missed block exceeds it, and
+	// also rank to 1 so the promotion fails.
+	bsc, err := types.NewBaseSignCtrled(
 		pv.Logger,
-		1, // Threshold
+		2, // Threshold
 		1, // Rank
+		pv.Config.Base.SetSize,
 		pv,
 	)
+	assert.NoError(t, err)
+	pv.BaseSignCtrled = *bsc
 	pv.UnlockCounter()
+	assert.NoError(t, pv.Missed(1))
 
 	// Start mock endpoint for the block query.
 	port, _ := getFreePort(t)
 	pv.Config.Base.ValidatorListenAddressRPC = fmt.Sprintf("tcp://127.0.0.1:%v", port)
 	quitCh := make(chan struct{})
-	go testBlockEndpoint(t, port, testBlockResult(t), quitCh)
+	testBlockEndpoint(t, port, testBlockResult(t), quitCh)
 	defer close(quitCh)
 
 	// Handle the request.
@@ -438,7 +711,7 @@ func TestHandleSignRequest_RankTooLow(t *testing.T) {
 	// Start mock endpoint for the block query.
 	port, _ := getFreePort(t)
 	pv.Config.Base.ValidatorListenAddressRPC = fmt.Sprintf("tcp://127.0.0.1:%v", port)
-	go testBlockEndpoint(t, port, br, quitCh)
+	testBlockEndpoint(t, port, br, quitCh)
 	defer close(quitCh)
 
 	// Handle the request.
@@ -448,6 +721,91 @@ func TestHandleSignRequest_RankTooLow(t *testing.T) {
 	assert.Equal(t, 0, pv.GetMissedInARow())
 }
 
+func TestHandleSignRequest_RankGate(t *testing.T) {
+	pv := testWatermarkSCFilePV(t)
+	var buf bytes.Buffer
+	pv.Logger = types.NewSyncLogger(&buf, "", 0)
+	pv.BaseSignCtrled.SetRank(2)
+
+	// Sign requests are refused on rank 2.
+	for _, req := range []*tm_privvalproto.Message{testSignVoteRequest(t), testSignProposalRequest(t)} {
+		buf.Reset()
+		msg, err := HandleRequest(context.Background(), req, pv)
+		assert.EqualError(t, err, "not the active signer, rank 2")
+		assert.Contains(t, buf.String(), "[WARN]")
+		assert.Contains(t, buf.String(), "not the active signer, rank 2")
+		switch msg.Sum.(type) {
+		case *tm_privvalproto.Message_SignedVoteResponse:
+			assert.Empty(t, msg.GetSignedVoteResponse().Vote.Signature)
+			assert.NotNil(t, msg.GetSignedVoteResponse().GetError())
+		case *tm_privvalproto.Message_SignedProposalResponse:
+			assert.Equal(t, []byte("Signature"), msg.GetSignedProposalResponse().Proposal.Signature)
+			assert.NotNil(t, msg.GetSignedProposalResponse().GetError())
+		}
+	}
+
+	// PubKeyRequests are still answered.
+	msg, err := HandleRequest(context.Background(), testPubKeyRequest(t), pv)
+	assert.NoError(t, err)
+	assert.Nil(t, msg.GetPubKeyResponse().GetError())
+
+	// After the promotion to rank 1, the node signs.
+	err = pv.Promote()
+	assert.NoError(t, err)
+	msg, err = HandleRequest(context.Background(), testSignVoteRequest(t), pv)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, msg.GetSignedVoteResponse().Vote.Signature)
+}
+
+func TestHandleSignRequest_UnsafeSignAnyRank(t *testing.T) {
+	pv := testWatermarkSCFilePV(t)
+	var buf bytes.Buffer
+	pv.Logger = types.NewSyncLogger(&buf, "", 0)
+	pv.BaseSignCtrled.SetRank(2)
+	pv.Config.Privval.UnsafeSignAnyRank = true
+
+	// The node signs on rank 2, but warns about it.
+	msg, err := HandleRequest(context.Background(), testSignVoteRequest(t), pv)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, msg.GetSignedVoteResponse().Vote.Signature)
+	assert.Contains(t, buf.String(), "[WARN]")
+	assert.Contains(t, buf.String(), "unsafe_sign_any_rank")
+}
+
+func TestHandleSignRequest_DryRun(t *testing.T) {
+	pv := testWatermarkSCFilePV(t)
+	signer := &countingPV{PrivValidator: pv.TMFilePV}
+	pv.TMFilePV = signer
+	pv.Config.Privval.DryRun = true
+	pv.BaseSignCtrled.SetRank(2)
+
+	// No signatures are produced, neither before nor after the promotion.
+	for i := 0; i < 2; i++ {
+		msg, err := HandleRequest(context.Background(), testSignVoteRequest(t), pv)
+		assert.Error(t, err)
+		assert.Empty(t, msg.GetSignedVoteResponse().Vote.Signature)
+		assert.NotNil(t, msg.GetSignedVoteResponse().GetError())
+
+		msg, err = HandleRequest(context.Background(), testSignProposalRequest(t), pv)
+		assert.Error(t, err)
+		assert.Equal(t, []byte("Signature"), msg.GetSignedProposalResponse().Proposal.Signature)
+		assert.NotNil(t, msg.GetSignedProposalResponse().GetError())
+
+		if pv.GetRank() > 1 {
+			err = pv.Promote()
+			assert.NoError(t, err)
+		}
+	}
+	assert.Equal(t, 1, pv.GetRank())
+	assert.Equal(t, 0, signer.calls)
+
+	// The rank is still tracked, so requests are handled up to the signing.
+	msg, err := HandleRequest(context.Background(), testSignVoteRequest(t), pv)
+	assert.Equal(t, ErrDryRun, err)
+	assert.Empty(t, msg.GetSignedVoteResponse().Vote.Signature)
+	assert.Equal(t, int64(0), pv.Watermark.Height)
+}
+
 func TestHandleSignRequest_SignVoteErr(t *testing.T) {
 	// Initialize mock SCFilePV with valid values.
 	pv := mockSCFilePV(t)
@@ -466,7 +824,7 @@ func TestHandleSignRequest_SignVoteErr(t *testing.T) {
 	// Start mock endpoint for the block query.
 	port, _ := getFreePort(t)
 	pv.Config.Base.ValidatorListenAddressRPC = fmt.Sprintf("tcp://127.0.0.1:%v", port)
-	go testBlockEndpoint(t, port, br, quitCh)
+	testBlockEndpoint(t, port, br, quitCh)
 	defer close(quitCh)
 
 	// Initialize new file signer.
@@ -480,6 +838,111 @@ func TestHandleSignRequest_SignVoteErr(t *testing.T) {
 
 func TestHandleRequest_UnknownMessage(t *testing.T) {
 	msg, err := HandleRequest(context.Background(), &tm_privvalproto.Message{}, nil)
-	assert.Nil(t, msg)
+	assert.NotNil(t, msg)
 	assert.Error(t, err)
+	assert.Equal(t, CodeUnsupportedMsg, msg.GetPubKeyResponse().GetError().GetCode())
+	assert.Contains(t, msg.GetPubKeyResponse().GetError().GetDescription(), "unsupported message type")
+}
+
+func TestHandleRequest_ResponseTypes(t *testing.T) {
+	tests := []struct {
+		name     string
+		msg      *tm_privvalproto.Message
+		respType interface{}
+	}{
+		{"PingRequest", testPingRequest(t), &tm_privvalproto.Message_PingResponse{}},
+		{"PubKeyRequest", testPubKeyRequest(t), &tm_privvalproto.Message_PubKeyResponse{}},
+		{"SignVoteRequest", testSignVoteRequest(t), &tm_privvalproto.Message_SignedVoteResponse{}},
+		{"SignProposalRequest", testSignProposalRequest(t), &tm_privvalproto.Message_SignedProposalResponse{}},
+		{"EmptyMessage", &tm_privvalproto.Message{}, &tm_privvalproto.Message_PubKeyResponse{}},
+		{"PingResponse", wrapMsg(&tm_privvalproto.PingResponse{}), &tm_privvalproto.Message_PubKeyResponse{}},
+		{"SignedVoteResponse", wrapMsg(&tm_privvalproto.SignedVoteResponse{}), &tm_privvalproto.Message_PubKeyResponse{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pv := mockSCFilePV(t)
+			msg, _ := HandleRequest(context.Background(), test.msg, pv)
+			assert.NotNil(t, msg)
+			assert.IsType(t, test.respType, msg.Sum)
+		})
+	}
+}
+
+func TestHandleSignRequest_Rejoin(t *testing.T) {
+	// testVoteRequestAt returns a testSignVoteRequest for the given height.
+	testVoteRequestAt := func(height int64) *tm_privvalproto.Message {
+		req := testSignVoteRequest(t)
+		req.GetSignVoteRequest().Vote.Height = height
+		return req
+	}
+
+	// Without rejoin mode, rank 1 shuts down once the threshold is exceeded.
+	pv := testWatermarkSCFilePV(t)
+	pv.Config.Base.Threshold = 2
+	bsc, err := types.NewBaseSignCtrled(pv.Logger, 2, 1, pv.Config.Base.SetSize, pv)
+	assert.NoError(t, err)
+	pv.BaseSignCtrled = *bsc
+	pv.UnlockCounter()
+	_, err = HandleRequest(context.Background(), testVoteRequestAt(2), pv)
+	assert.NoError(t, err)
+	_, err = HandleRequest(context.Background(), testVoteRequestAt(3), pv)
+	assert.ErrorIs(t, err, types.ErrMustShutdown)
+
+	// In rejoin mode, it is demoted to the last rank instead and doesn't sign.
+	pv = testWatermarkSCFilePV(t)
+	pv.Config.Base.Rejoin = true
+	bsc, err = types.NewBaseSignCtrled(pv.Logger, 2, 1, pv.Config.Base.SetSize, pv)
+	assert.NoError(t, err)
+	pv.BaseSignCtrled = *bsc
+	pv.UnlockCounter()
+	_, err = HandleRequest(context.Background(), testVoteRequestAt(2), pv)
+	assert.NoError(t, err)
+	msg, err := HandleRequest(context.Background(), testVoteRequestAt(3), pv)
+	assert.ErrorIs(t, err, ErrNotActiveSigner)
+	assert.Empty(t, msg.GetSignedVoteResponse().Vote.Signature)
+	assert.Equal(t, 3, pv.GetRank())
+	assert.Equal(t, 0, pv.GetMissedInARow())
+
+	// The counter is locked, so further missed blocks don't promote the validator.
+	for h := int64(4); h < 8; h++ {
+		msg, err = HandleRequest(context.Background(), testVoteRequestAt(h), pv)
+		assert.ErrorIs(t, err, ErrNotActiveSigner)
+		assert.Empty(t, msg.GetSignedVoteResponse().Vote.Signature)
+	}
+	assert.Equal(t, 3, pv.GetRank())
+}
+
+func TestHandleSignRequest_HeightRegression(t *testing.T) {
+	pv := testWatermarkSCFilePV(t)
+	pv.SetLockOnHeightRegression(true)
+	pv.UnlockCounter()
+	req := testSignVoteRequest(t)
+	req.GetSignVoteRequest().Vote.Height = 3
+	_, _ = HandleRequest(context.Background(), req, pv)
+	assert.Equal(t, int64(3), pv.GetCurrentHeight())
+	assert.False(t, pv.IsCounterLocked())
+
+	// A lower height doesn't move the current height back, but locks the counter.
+	req = testSignVoteRequest(t)
+	req.GetSignVoteRequest().Vote.Height = 2
+	_, _ = HandleRequest(context.Background(), req, pv)
+	assert.Equal(t, int64(3), pv.GetCurrentHeight())
+	assert.True(t, pv.IsCounterLocked())
+	assert.Equal(t, int64(-1), pv.GetStateSnapshot().LastHeightGap)
+}
+
+func TestRejoin_WatchOnly(t *testing.T) {
+	pv, _ := testWatchOnlySCFilePV(t)
+	err := pv.Promote()
+	assert.NoError(t, err)
+	_, err = HandleRequest(context.Background(), testSignVoteRequest(t), pv)
+	assert.NoError(t, err)
+	assert.IsType(t, &tm_privval.FilePV{}, pv.TMFilePV)
+
+	// The private key is dropped again on rejoin.
+	err = pv.rejoin()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, pv.GetRank())
+	assert.IsType(t, &WatchOnlyPV{}, pv.TMFilePV)
 }
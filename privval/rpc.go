@@ -0,0 +1,101 @@
+package privval
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	tm_rpchttp "github.com/tendermint/tendermint/rpc/client/http"
+)
+
+// rpcClient returns the client of the full node's RPC endpoint configured in the rpc
+// section, which is constructed on first use and shared afterwards. If no endpoint is
+// configured, it returns nil without an error, and everything relying on it must be
+// skipped.
+func (pv *SCFilePV) rpcClient() (*tm_rpchttp.HTTP, error) {
+	pv.rpcOnce.Do(func() {
+		if pv.Config.RPC.Address != "" {
+			pv.rpcHTTP, pv.rpcErr = newRPCClient(pv.Config.RPC)
+		}
+	})
+
+	return pv.rpcHTTP, pv.rpcErr
+}
+
+// newRPCClient constructs the client of the RPC endpoint in the given configuration,
+// whose queries are given up after its timeout.
+func newRPCClient(cfg config.RPC) (*tm_rpchttp.HTTP, error) {
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if cfg.TLSCAFile != "" || cfg.TLSCertFile != "" {
+		if transport.TLSClientConfig, err = rpcTLSConfig(cfg); err != nil {
+			return nil, err
+		}
+	}
+	client, err := tm_rpchttp.NewWithClient(cfg.Address, "/websocket", &http.Client{Transport: transport, Timeout: timeout})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create the client of the RPC endpoint %v: %v", cfg.Address, err)
+	}
+
+	return client, nil
+}
+
+// rpcTLSConfig returns the TLS configuration for the RPC endpoint in the given
+// configuration, with its CA and client certificate, if they are set.
+func rpcTLSConfig(cfg config.RPC) (*tls.Config, error) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if cfg.TLSCAFile != "" {
+		caPEM, err := ioutil.ReadFile(cfg.TLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't load the RPC endpoint's TLS CA: %v", err)
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("couldn't load the RPC endpoint's TLS CA: no PEM encoded certificates in %v", cfg.TLSCAFile)
+		}
+	}
+	if cfg.TLSCertFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't load the TLS client certificate for the RPC endpoint: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
+
+// probeRPC queries the status of the full node's RPC endpoint once on startup and logs
+// its network, latest height and whether it is catching up. Without an endpoint, it
+// only logs that everything relying on it is disabled. An unreachable endpoint is
+// logged, but doesn't keep SignCTRL from starting.
+func (pv *SCFilePV) probeRPC(ctx context.Context) {
+	client, err := pv.rpcClient()
+	if err != nil {
+		pv.Logger.Error("%v", err)
+		return
+	}
+	if client == nil {
+		pv.Logger.Info("No rpc.address set, so SignCTRL doesn't query the chain through a full node")
+		return
+	}
+
+	address := pv.Config.Redacted().RPC.Address
+	status, err := client.Status(ctx)
+	if err != nil {
+		pv.Logger.Warn("Couldn't query the status of the RPC endpoint %v: %v", address, err)
+		return
+	}
+	pv.Logger.Info("RPC endpoint %v is on network %v at height %v (catching up: %v)", address, status.NodeInfo.Network, status.SyncInfo.LatestBlockHeight, status.SyncInfo.CatchingUp)
+	if status.NodeInfo.Network != pv.Config.Privval.ChainID {
+		pv.Logger.Warn("RPC endpoint %v is on network %v, but the chain ID is %v", address, status.NodeInfo.Network, pv.Config.Privval.ChainID)
+	}
+}
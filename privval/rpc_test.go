@@ -0,0 +1,94 @@
+package privval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_p2p "github.com/tendermint/tendermint/p2p"
+	tm_coretypes "github.com/tendermint/tendermint/rpc/core/types"
+)
+
+// testRPCServer returns an RPC endpoint that answers every request with the status of
+// a node on the given network at the given height.
+func testRPCServer(t *testing.T, network string, height int64, catchingUp bool) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID json.RawMessage `json:"id"`
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		result, err := tm_json.Marshal(&tm_coretypes.ResultStatus{
+			NodeInfo: tm_p2p.DefaultNodeInfo{Network: network},
+			SyncInfo: tm_coretypes.SyncInfo{LatestBlockHeight: height, CatchingUp: catchingUp},
+		})
+		assert.NoError(t, err)
+		fmt.Fprintf(rw, `{"jsonrpc":"2.0","id":%s,"result":%s}`, req.ID, result)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestRPCClient_NotConfigured(t *testing.T) {
+	pv := mockSCFilePV(t)
+	var buf bytes.Buffer
+	pv.Logger = types.NewSyncLogger(&buf, "", 0)
+	client, err := pv.rpcClient()
+	assert.NoError(t, err)
+	assert.Nil(t, client)
+
+	pv.probeRPC(context.Background())
+	assert.Contains(t, buf.String(), "No rpc.address set")
+}
+
+func TestProbeRPC(t *testing.T) {
+	srv := testRPCServer(t, "testchain", 42, true)
+	pv := mockSCFilePV(t)
+	var buf bytes.Buffer
+	pv.Logger = types.NewSyncLogger(&buf, "", 0)
+	pv.Config.RPC = config.RPC{Address: srv.URL, Timeout: config.DefaultRPCTimeout}
+
+	pv.probeRPC(context.Background())
+	assert.Contains(t, buf.String(), "is on network testchain at height 42 (catching up: true)")
+	assert.NotContains(t, buf.String(), "[WARN]")
+
+	// The client is only constructed once.
+	first, err := pv.rpcClient()
+	assert.NoError(t, err)
+	second, err := pv.rpcClient()
+	assert.NoError(t, err)
+	assert.Same(t, first, second)
+}
+
+func TestProbeRPC_OtherNetwork(t *testing.T) {
+	srv := testRPCServer(t, "otherchain", 42, false)
+	pv := mockSCFilePV(t)
+	var buf bytes.Buffer
+	pv.Logger = types.NewSyncLogger(&buf, "", 0)
+	pv.Config.RPC = config.RPC{Address: srv.URL, Timeout: config.DefaultRPCTimeout}
+
+	pv.probeRPC(context.Background())
+	assert.Contains(t, buf.String(), "is on network otherchain, but the chain ID is testchain")
+}
+
+func TestProbeRPC_Unreachable(t *testing.T) {
+	srv := testRPCServer(t, "testchain", 42, false)
+	srv.Close()
+	pv := mockSCFilePV(t)
+	var buf bytes.Buffer
+	pv.Logger = types.NewSyncLogger(&buf, "", 0)
+	pv.Config.RPC = config.RPC{Address: srv.URL, Timeout: config.DefaultRPCTimeout}
+
+	// An unreachable endpoint is only warned about.
+	pv.probeRPC(context.Background())
+	assert.Contains(t, buf.String(), "Couldn't query the status of the RPC endpoint")
+}
@@ -2,10 +2,17 @@ package privval
 
 import (
 	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"io"
 	"net"
 	"net/http"
+	"os"
 	"path/filepath"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/BlockscapeNetwork/signctrl/config"
@@ -13,6 +20,7 @@ import (
 	"github.com/BlockscapeNetwork/signctrl/types"
 	tm_protoio "github.com/tendermint/tendermint/libs/protoio"
 	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
+	tm_rpchttp "github.com/tendermint/tendermint/rpc/client/http"
 	tm_types "github.com/tendermint/tendermint/types"
 )
 
@@ -23,9 +31,27 @@ const (
 	// StateFile is Tendermint's default file name for the private validator's state.
 	StateFile = "priv_validator_state.json"
 
-	// maxRemoteSignerMsgSize determines the maximum size in bytes for the delimited
-	// reader.
-	maxRemoteSignerMsgSize = 1024 * 10
+	// PermLogFile determines the file permissions of the log file if SignCTRL creates
+	// it, which is only readable by the owner.
+	PermLogFile = os.FileMode(0600)
+
+	// maxPanicsInARow determines the number of panics in a row while handling requests
+	// after which SignCTRL is shut down.
+	maxPanicsInARow = 3
+
+	// drainTimeout is the time the run goroutines are given on shutdown to write the
+	// responses still in flight before the connections are closed.
+	drainTimeout = 2 * time.Second
+
+	// lastSignedCheckInterval determines how often the time since the validator's
+	// signature has last been seen is checked against threshold_duration.
+	lastSignedCheckInterval = time.Second
+)
+
+var (
+	// ErrTooManyPanics is returned if handling requests panicked too many times in a
+	// row, which leaves SignCTRL in a state that is not to be trusted anymore.
+	ErrTooManyPanics = errors.New("handling requests panicked too many times in a row")
 )
 
 // SCFilePV must implement the SignCtrled interface.
@@ -38,13 +64,187 @@ type SCFilePV struct {
 	types.BaseService
 	types.BaseSignCtrled
 
-	Logger     *types.SyncLogger
-	Config     config.Config
-	State      config.State
-	TMFilePV   tm_types.PrivValidator
-	SecretConn net.Conn
-	HTTP       *http.Server
-	Gauges     types.Gauges
+	Logger    *types.SyncLogger
+	Config    config.Config
+	State     config.State
+	Watermark *Watermark
+	TMFilePV  tm_types.PrivValidator
+	HTTP      *http.Server
+	Gauges    types.Gauges
+
+	// ConfigDir is the configuration directory the conn.key, the key and state files
+	// and the signctrl_state.json are read from and written to.
+	ConfigDir string
+
+	// Version is the version of SignCTRL, which is sent to the validator in the
+	// hello.
+	Version string
+
+	transport transport
+	conns     []*validatorConn
+	listener  net.Listener
+	acceptor  *connection.Acceptor
+	dial      func(ctx context.Context, address string) (net.Conn, error)
+	handle    func(context.Context, *tm_privvalproto.Message, *SCFilePV) (*tm_privvalproto.Message, error)
+	cancel    context.CancelFunc
+	runDone   <-chan struct{}
+
+	// exhaustedConns is the number of connections that have given up dialing the
+	// validator, as the retry policy has been exhausted.
+	exhaustedConns int32
+
+	// tlsCerts are the certificates used by the mtls transport, which are reloaded
+	// on SIGHUP.
+	tlsCerts *connection.TLSCerts
+
+	// connStats counts the traffic, dials and reconnects of the connections to the
+	// validators.
+	connStats connection.Stats
+
+	// sshTunnel is the tunnel through the SSH bastion host the validators are dialed
+	// through, if one is configured.
+	sshTunnel *connection.SSHTunnel
+
+	// connEvents reports the changes of the connections to the validators, which
+	// lock the counter for missed blocks in a row.
+	connEvents *connection.Events
+
+	// connLockHeight is the current height the counter has last been locked at by a
+	// connection event. Commitsigs in commits up to it have been given before the
+	// lock, so they don't unlock the counter. It is guarded by handleMtx.
+	connLockHeight int64
+
+	// stateMtx guards the State while it is saved.
+	stateMtx sync.Mutex
+
+	// handleMtx serializes the handling of requests from all validator connections,
+	// so that double-signing protection holds across connections.
+	handleMtx sync.Mutex
+	panics    int
+	breaker   breaker
+	restored  bool
+
+	activityMtx  sync.RWMutex
+	lastActivity time.Time
+
+	statsMtx sync.RWMutex
+	stats    SigningStats
+
+	// hooks keeps track of the hook commands still running.
+	hooks sync.WaitGroup
+
+	// rpcHTTP is the client of the full node's RPC endpoint, which is constructed
+	// once on first use by rpcClient, along with the error constructing it.
+	rpcOnce sync.Once
+	rpcHTTP *tm_rpchttp.HTTP
+	rpcErr  error
+
+	// peerListener is the listener the peers are answered on, if peer_laddr is set.
+	peerListener net.Listener
+
+	// clock tells the time for all time-based behavior, so that it can be faked in
+	// tests. It is shared with the BaseSignCtrled.
+	clock types.Clock
+
+	// configMtx guards the settings of the Config that are applied when it is
+	// reloaded at runtime, and reloadMtx serializes the reloads. loadConfig loads the
+	// configuration to reload.
+	configMtx  sync.RWMutex
+	reloadMtx  sync.Mutex
+	loadConfig func() (config.Config, error)
+}
+
+// validatorConn is the connection to one of the validators (or sentries) that
+// SignCTRL keeps a connection to. With failover enabled, there is only a single one
+// that moves on between all addresses.
+type validatorConn struct {
+	mtx        sync.Mutex
+	address    string
+	conn       net.Conn
+	reconnects int
+
+	// addrs are the addresses the connection fails over between, of which the one at
+	// index is the current one. idleTimeouts counts the idle timeouts in a row on it.
+	addrs        []string
+	index        int
+	idleTimeouts int
+
+	// activity is the time the validator has last been heard from.
+	activity time.Time
+
+	// responding is held by the run goroutine from reading a request until its
+	// response has been written, so that shutdown can wait for it.
+	responding sync.Mutex
+}
+
+// get returns the current connection to the validator.
+func (vc *validatorConn) get() net.Conn {
+	vc.mtx.Lock()
+	defer vc.mtx.Unlock()
+	return vc.conn
+}
+
+// set replaces the connection to the validator.
+func (vc *validatorConn) set(conn net.Conn) {
+	vc.mtx.Lock()
+	defer vc.mtx.Unlock()
+	vc.conn = conn
+}
+
+// getAddress returns the current address of the validator.
+func (vc *validatorConn) getAddress() string {
+	vc.mtx.Lock()
+	defer vc.mtx.Unlock()
+	return vc.address
+}
+
+// touch records that the validator has been heard from at the given time.
+func (vc *validatorConn) touch(t time.Time) {
+	vc.mtx.Lock()
+	defer vc.mtx.Unlock()
+	vc.activity = t
+}
+
+// lastActivity returns the time the validator has last been heard from.
+func (vc *validatorConn) lastActivity() time.Time {
+	vc.mtx.Lock()
+	defer vc.mtx.Unlock()
+	return vc.activity
+}
+
+// setAddress replaces the address of the validator.
+func (vc *validatorConn) setAddress(address string) {
+	vc.mtx.Lock()
+	defer vc.mtx.Unlock()
+	vc.address = address
+}
+
+// close closes the current connection to the validator. It is safe to be called from
+// outside of the connection's run goroutine, which unblocks any pending reads and
+// writes. The write side is shut down first, so that the validator reads the
+// last response in full.
+func (vc *validatorConn) close(logger *types.SyncLogger) {
+	vc.mtx.Lock()
+	defer vc.mtx.Unlock()
+	if vc.conn == nil {
+		return
+	}
+	if err := connection.CloseGracefully(vc.conn); err != nil {
+		logger.Debug("couldn't close connection to %v: %v", vc.address, err)
+	}
+}
+
+// drain unblocks a pending read on the connection to the validator without closing it,
+// so that a response in flight can still be written.
+func (vc *validatorConn) drain(logger *types.SyncLogger) {
+	vc.mtx.Lock()
+	defer vc.mtx.Unlock()
+	if vc.conn == nil {
+		return
+	}
+	if err := vc.conn.SetReadDeadline(time.Now()); err != nil {
+		logger.Debug("couldn't set read deadline on connection to %v: %v", vc.address, err)
+	}
 }
 
 // KeyFilePath returns the absolute path to the priv_validator_key.json file.
@@ -57,141 +257,724 @@ func StateFilePath(cfgDir string) string {
 	return filepath.Join(cfgDir, StateFile)
 }
 
-// NewSCFilePV creates a new instance of SCFilePV.
-func NewSCFilePV(logger *types.SyncLogger, cfg config.Config, state config.State, tmpv tm_types.PrivValidator, http *http.Server) *SCFilePV {
+// ResolveKeyFilePath returns the path to the priv_validator_key.json file set by
+// priv_validator_key_file, relative to the given configuration directory unless it is
+// absolute, or the one in the configuration directory if it isn't set.
+func ResolveKeyFilePath(cfgDir string, cfg config.PrivValidator) string {
+	if cfg.KeyFilePath == "" {
+		return KeyFilePath(cfgDir)
+	}
+
+	return config.ResolvePath(cfgDir, cfg.KeyFilePath)
+}
+
+// ResolveStateFilePath returns the path to the priv_validator_state.json file set by
+// priv_validator_state_file, relative to the given configuration directory unless it
+// is absolute, or the one in the configuration directory if it isn't set.
+func ResolveStateFilePath(cfgDir string, cfg config.PrivValidator) string {
+	if cfg.StateFilePath == "" {
+		return StateFilePath(cfgDir)
+	}
+
+	return config.ResolvePath(cfgDir, cfg.StateFilePath)
+}
+
+// NewLogger creates the logger configured in the given logging section. A log file is
+// opened for appending and created if it doesn't exist yet. It stays open for the
+// lifetime of the process.
+func NewLogger(cfg config.Logging) (*types.SyncLogger, error) {
+	var out io.Writer = os.Stderr
+	if cfg.Output != config.LogOutputStderr {
+		file, err := os.OpenFile(cfg.Output, os.O_APPEND|os.O_CREATE|os.O_WRONLY, PermLogFile)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't open log file: %v", err)
+		}
+		out = file
+	}
+
+	return types.NewLogger(out, cfg.Level, cfg.Format)
+}
+
+// NewSCFilePV creates a new instance of SCFilePV that keeps its files in the given
+// configuration directory. If the given logger is nil, the one configured in the
+// logging section is created (see NewLogger). An error is returned if the logger
+// can't be created or the configured threshold or start rank is invalid.
+func NewSCFilePV(logger *types.SyncLogger, cfgDir string, cfg config.Config, state config.State, tmpv tm_types.PrivValidator, http *http.Server) (*SCFilePV, error) {
+	if logger == nil {
+		var err error
+		if logger, err = NewLogger(cfg.Logging); err != nil {
+			return nil, err
+		}
+	}
 	pv := &SCFilePV{
-		Logger:   logger,
-		Config:   cfg,
-		State:    state,
-		TMFilePV: tmpv,
-		HTTP:     http,
+		Logger:    logger,
+		ConfigDir: cfgDir,
+		Config:    cfg,
+		State:     state,
+		Watermark: &Watermark{},
+		TMFilePV:  tmpv,
+		HTTP:      http,
+		clock:     types.RealClock{},
+	}
+	pv.dial = pv.dialValidator
+	if cfg.Privval.Mode == config.ModeListen {
+		pv.dial = pv.acceptValidator
+	}
+	if cfg.Privval.SSHHost != "" {
+		pv.dial = pv.dialValidatorSSH
+	}
+	if cfg.Privval.ProxyURL != "" {
+		pv.dial = pv.dialValidatorProxy
+	}
+	if cfg.Privval.Transport == config.TransportMTLS && cfg.Privval.Mode == config.ModeDial {
+		pv.dial = pv.dialValidatorTLS
+	}
+	if cfg.Privval.Hello {
+		pv.dial = pv.withHello(pv.dial)
+	}
+	pv.handle = HandleRequest
+	pv.loadConfig = config.Load
+	pv.transport = &socketTransport{pv: pv}
+	if cfg.Privval.Transport == config.TransportGRPC {
+		pv.transport = &grpcTransport{pv: pv}
 	}
 	pv.BaseService = *types.NewBaseService(
 		logger,
 		"SignCTRL",
 		pv,
 	)
-	pv.BaseSignCtrled = *types.NewBaseSignCtrled(
+	bsc, err := types.NewBaseSignCtrled(
 		logger,
 		pv.Config.Base.Threshold,
 		pv.Config.Base.StartRank,
+		pv.Config.Base.SetSize,
 		pv,
+		types.WithClock(pv.clock),
+		types.WithPeers(pv.Config.Base.PeerList()),
 	)
+	if err != nil {
+		return nil, err
+	}
+	pv.BaseSignCtrled = *bsc
+	pv.BaseSignCtrled.SetThresholdStagger(pv.Config.Base.ThresholdStagger)
+	pv.BaseSignCtrled.SetThresholds(pv.Config.Base.Thresholds)
+	pv.BaseSignCtrled.SetWindow(pv.Config.Base.WindowSize)
+	pv.BaseSignCtrled.SetRankStrategy(rankStrategy(pv.Config.Base))
+	pv.BaseSignCtrled.SetPromotionCooldown(pv.Config.Base.PromotionCooldownBlocks)
+	pv.BaseSignCtrled.SetMaxAutoPromotions(pv.Config.Base.MaxAutoPromotions, config.GetDuration(pv.Config.Base.AutoPromotionWindow))
+	pv.BaseSignCtrled.SetPostPromotionGrace(pv.Config.Base.PostPromotionGraceBlocks)
+	pv.BaseSignCtrled.SetCounterUnlockAfter(pv.Config.Base.CounterUnlockAfterBlocks)
+	pv.BaseSignCtrled.SetConsecutiveSignsToUnlock(pv.Config.Base.ConsecutiveSignsToUnlock)
+	pv.BaseSignCtrled.SetMaxHeightGap(pv.Config.Base.MaxHeightGap)
+	pv.BaseSignCtrled.SetLockOnHeightRegression(pv.Config.Base.LockOnHeightRegression)
+	pv.BaseSignCtrled.SetMissedBlockLogInterval(pv.Config.Base.MissedBlockLogInterval)
+	pv.BaseSignCtrled.SetBlockHistorySize(pv.Config.Monitoring.HistorySize)
+	pv.BaseSignCtrled.SetThresholdDuration(config.GetDuration(pv.Config.Base.ThresholdDuration))
 
-	return pv
+	return pv, nil
 }
 
-// run runs the main loop of SignCTRL. It handles incoming messages from the validator.
-// In order to stop the goroutine, Stop() can be called outside of run(). The goroutine
-// returns on its own once SignCTRL is forced to shut down.
-func (pv *SCFilePV) run() {
-	retryDialTimeout := config.GetRetryDialTime(pv.Config.Base.RetryDialAfter)
-	timeout := time.NewTimer(retryDialTimeout)
+// rankStrategy returns the strategy configured in the given base configuration. The
+// in_a_row strategy is combined with the window, if one is configured, so that
+// whichever fires first triggers a rank update.
+func rankStrategy(cfg config.Base) types.RankStrategy {
+	window := types.WindowStrategy{Threshold: cfg.WindowThreshold}
+	if cfg.RankStrategy == types.RankStrategyWindow {
+		return window
+	}
+	if cfg.WindowSize > 0 {
+		return types.AnyStrategy{types.InARowStrategy{}, window}
+	}
 
+	return types.InARowStrategy{}
+}
+
+// LastActivity returns the time at which the last request was received from the
+// validator. This includes PingRequests, so it tells whether the connection is still
+// alive, even if there are no new blocks.
+func (pv *SCFilePV) LastActivity() time.Time {
+	pv.activityMtx.RLock()
+	defer pv.activityMtx.RUnlock()
+	return pv.lastActivity
+}
+
+// updateLastActivity sets the time of the last activity of the validator to now.
+func (pv *SCFilePV) updateLastActivity() {
+	pv.activityMtx.Lock()
+	defer pv.activityMtx.Unlock()
+	pv.lastActivity = pv.clock.Now()
+}
+
+// SetClock makes the SCFilePV and its BaseSignCtrled use the given clock. It must be
+// called before SignCTRL is started.
+func (pv *SCFilePV) SetClock(clock types.Clock) {
+	pv.clock = clock
+	pv.BaseSignCtrled.SetClock(clock)
+}
+
+// peerAddresses returns the addresses of the peer validators to monitor. Invalid
+// addresses are skipped, as they are rejected when the configuration is loaded.
+func (pv *SCFilePV) peerAddresses() []tm_types.Address {
+	var peers []tm_types.Address
+	for _, addr := range pv.Config.Monitoring.PeerAddresses {
+		if bz, err := hex.DecodeString(addr); err == nil {
+			peers = append(peers, bz)
+		}
+	}
+
+	return peers
+}
+
+// isTimeoutErr checks whether the given error is caused by an exceeded deadline on
+// the connection to the validator.
+func isTimeoutErr(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
+
+// dialValidator keeps dialing the validator at the given address until success and
+// returns the connection. It gives up once the configured retry policy is exhausted,
+// or right away if the validator doesn't use one of the authorized keys. Dialing is
+// aborted once the given context is canceled.
+func (pv *SCFilePV) dialValidator(ctx context.Context, address string) (net.Conn, error) {
+	authorizedKeys, err := connection.ParseAuthorizedKeys(pv.Config.Privval.AuthorizedKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	return connection.RetryDial(ctx, pv.ConfigDir, address, pv.Config.Privval.SecretUnixConn, authorizedKeys, retryPolicy(pv.baseConfig(), pv.retryConfig(), &pv.connStats), pv.Logger)
+}
+
+// dialValidatorProxy keeps dialing the validator at the given address through the
+// configured SOCKS5 proxy until success and returns the secret connection. It gives up
+// once the configured retry policy is exhausted, or right away if the validator doesn't
+// use one of the authorized keys. Dialing is aborted once the given context is canceled.
+func (pv *SCFilePV) dialValidatorProxy(ctx context.Context, address string) (net.Conn, error) {
+	authorizedKeys, err := connection.ParseAuthorizedKeys(pv.Config.Privval.AuthorizedKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	return connection.RetryDialProxy(ctx, pv.ConfigDir, address, pv.Config.Privval.ProxyURL, authorizedKeys, retryPolicy(pv.baseConfig(), pv.retryConfig(), &pv.connStats), pv.Logger)
+}
+
+// retryPolicy returns the policy for dialing the validator configured in the given
+// retry section, with the timeouts of the given base section, which records the dial
+// attempts in the given stats. With failover enabled, a single address is given up
+// after failover_dial_attempts.
+func retryPolicy(cfg config.Base, retry config.Retry, stats *connection.Stats) connection.RetryPolicy {
+	policy := connection.RetryPolicy{
+		InitialInterval: config.GetDuration(retry.InitialInterval),
+		Multiplier:      retry.Multiplier,
+		MaxInterval:     config.GetDuration(retry.MaxInterval),
+		Jitter:          retry.Jitter,
+		MaxAttempts:     retry.MaxAttempts,
+		MaxElapsed:      config.GetDuration(retry.MaxElapsedTime),
+		DialTimeout:     config.GetDuration(cfg.DialTimeout),
+		KeepAlivePeriod: config.GetDuration(cfg.KeepAlivePeriod),
+		LogInterval:     config.GetDuration(cfg.FailureLogInterval),
+		Stats:           stats,
+	}
+	if cfg.Failover && (policy.MaxAttempts == 0 || policy.MaxAttempts > cfg.FailoverDialAttempts) {
+		policy.MaxAttempts = cfg.FailoverDialAttempts
+	}
+
+	return policy
+}
+
+// dialFailed handles the given error from dialing the validator, unless the service
+// has been stopped. Once all connections have given up dialing, as the retry policy
+// has been exhausted, or the validator uses an unauthorized key or another chain ID,
+// the validator retires and SignCTRL is stopped.
+func (pv *SCFilePV) dialFailed(ctx context.Context, err error) {
+	if ctx.Err() != nil {
+		return
+	}
+	pv.Logger.Error("couldn't dial validator: %v\n", err)
+	if !errors.Is(err, connection.ErrRetryExhausted) && !errors.Is(err, connection.ErrUnknownConnKey) && !errors.Is(err, connection.ErrHelloMismatch) {
+		return
+	}
+	if exhausted := int(atomic.AddInt32(&pv.exhaustedConns, 1)); exhausted < len(pv.conns) {
+		pv.Logger.Warn("Gave up on %v of %v validator connections, serving the others...", exhausted, len(pv.conns))
+		return
+	}
+
+	pv.Logger.Error("Gave up dialing all validators, shutting SignCTRL down...")
+	pv.retire(err)
+	if err := pv.Stop(); err != nil {
+		pv.Logger.Error("%v", err)
+	}
+}
+
+// acceptValidator waits until the acceptor admits a validator and returns its
+// connection. It is aborted once the listener is closed or the given context is
+// canceled.
+func (pv *SCFilePV) acceptValidator(ctx context.Context, _ string) (net.Conn, error) {
+	return pv.acceptor.Next(ctx)
+}
+
+// newAcceptor starts accepting connections from the validator on the listener. Only a
+// single validator is admitted at a time, which is either kept or preempted by the
+// next one, depending on listen_policy. For secret connections, only validators using
+// the configured connection key or one of the authorized keys are admitted. For mutual
+// TLS connections, only validators with a certificate signed by the configured CA are.
+func (pv *SCFilePV) newAcceptor() (*connection.Acceptor, error) {
+	preempt := pv.Config.Privval.ListenPolicy == config.ListenPolicyPreempt
+	if pv.Config.Privval.Transport == config.TransportMTLS {
+		return connection.NewTLSAcceptor(pv.listener, pv.tlsCerts, preempt, pv.Logger), nil
+	}
+
+	authorizedKeys, err := connection.ParseAuthorizedKeys(pv.Config.Privval.AuthorizedKeys)
+	if err != nil {
+		return nil, err
+	}
+	if pv.Config.Privval.ValidatorConnKey != "" {
+		key, err := connection.ParseConnPubKey(pv.Config.Privval.ValidatorConnKey)
+		if err != nil {
+			return nil, err
+		}
+		authorizedKeys = append(authorizedKeys, key)
+	}
+
+	return connection.NewAcceptor(pv.ConfigDir, pv.listener, pv.Config.Privval.SecretUnixConn, authorizedKeys, preempt, pv.Logger)
+}
+
+// reconnect closes the connection to the validator that has been lost for the given
+// reason and keeps dialing it until a new connection is established. In listen mode,
+// it keeps accepting connections instead. Both the disconnect and the reconnect are
+// reported as connection events, which lock the counter for missed blocks in a row,
+// so that no rank updates are based on stale information.
+func (pv *SCFilePV) reconnect(ctx context.Context, vc *validatorConn, reason error) error {
+	vc.reconnects++
+	pv.connStats.AddReconnect()
+	pv.Logger.Debug("Reconnecting to the validator at %v... (reconnect #%v)", vc.address, vc.reconnects)
+	pv.connEvents.Disconnected(vc.address, reason)
+
+	// Close the connection and establish a new one.
+	vc.close(pv.Logger)
+	conn, err := pv.dialConn(ctx, vc)
+	if err != nil {
+		return err
+	}
+	vc.set(pv.connStats.Wrap(conn))
+	vc.touch(pv.clock.Now())
+	pv.connEvents.Reconnected(vc.address)
+
+	return nil
+}
+
+// watchConnEvents handles the events of the connections to the validators until the
+// given context is canceled. Senders wait for their events to be handled, so the
+// counter is locked before the connection is used again.
+func (pv *SCFilePV) watchConnEvents(ctx context.Context, events *connection.Events) {
 	for {
 		select {
-		case <-pv.Quit():
-			pv.Logger.Debug("Terminating run goroutine: service stopped")
-			// Note: Don't use pv.Stop() in here, as it closes the pv.Quit() channel.
+		case <-ctx.Done():
 			return
 
-		case <-timeout.C:
-			pv.Logger.Info("Lost connection to the validator... (no message for %v)\n", retryDialTimeout.String())
+		case event := <-events.C():
+			pv.handleConnEvent(event)
+			event.Done()
+		}
+	}
+}
+
+// handleConnEvent locks the counter for missed blocks in a row if the given event is
+// a disconnect or a reconnect, as blocks might have been missed unnoticed in between.
+// It is only unlocked again once the validator's commitsig is found in a commit.
+func (pv *SCFilePV) handleConnEvent(event connection.Event) {
+	switch event.Kind {
+	case connection.EventConnected:
+		pv.Logger.Debug("Connected to the validator at %v", event.Address)
+		return
 
-			// Lock the counter for missed blocks in a row again.
-			pv.LockCounter()
+	case connection.EventDisconnected:
+		pv.Logger.Info("Locking the counter for missed blocks in a row, as the connection to the validator at %v has been lost (%v)", event.Address, event.Reason)
 
-			// Close the connection and establish a new one.
-			if err := pv.SecretConn.Close(); err != nil {
-				pv.Logger.Error("%v", err)
-			}
+	case connection.EventReconnected:
+		pv.Logger.Info("Locking the counter for missed blocks in a row, as the validator at %v has been reconnected", event.Address)
+
+	case connection.EventFailover:
+		pv.Logger.Info("Locking the counter for missed blocks in a row, as SignCTRL failed over from the validator at %v to the validator at %v", event.Previous, event.Address)
+	}
+
+	// The counter is shared by all connections, so it must not be touched while a
+	// request is handled.
+	pv.handleMtx.Lock()
+	pv.LockCounter()
+	pv.connLockHeight = pv.GetCurrentHeight()
+	pv.handleMtx.Unlock()
+}
+
+// closeConns closes the connections to all validators.
+func (pv *SCFilePV) closeConns() {
+	for _, vc := range pv.conns {
+		vc.close(pv.Logger)
+	}
+}
+
+// drainConns unblocks the pending reads of the run goroutines without closing their
+// connections, and waits until they have written the responses still in flight, for
+// drainTimeout at most. It must only be called once the service's context is canceled,
+// so that no further requests are handled.
+func (pv *SCFilePV) drainConns() {
+	for _, vc := range pv.conns {
+		vc.drain(pv.Logger)
+	}
+	drained := make(chan struct{})
+	go func() {
+		for _, vc := range pv.conns {
+			vc.responding.Lock()
+			vc.responding.Unlock()
+		}
+		close(drained)
+	}()
+	select {
+	case <-drained:
+	case <-time.After(drainTimeout):
+		pv.Logger.Warn("Closing the connections to the validators with responses still in flight after %v", drainTimeout)
+	}
+}
+
+// mustShutdown checks whether the given error returned from handling a request forces
+// SignCTRL to shut down.
+func mustShutdown(err error) bool {
+	return errors.Is(err, types.ErrMustShutdown) || errors.Is(err, ErrRankObsolete) || errors.Is(err, ErrTooManyPanics)
+}
+
+// safeHandleRequest handles the given request and recovers from panics that occur
+// while doing so. A panic is turned into an error, and if there were too many panics
+// in a row, ErrTooManyPanics is returned. Only one request is handled at a time, no
+// matter which connection it was received on.
+func (pv *SCFilePV) safeHandleRequest(ctx context.Context, msg *tm_privvalproto.Message) (resp *tm_privvalproto.Message, err error) {
+	pv.handleMtx.Lock()
+	defer pv.handleMtx.Unlock()
 
-			var err error
-			if pv.SecretConn, err = connection.RetryDial(
-				config.Dir(),
-				pv.Config.Base.ValidatorListenAddress,
-				pv.Logger,
-			); err != nil {
-				pv.Logger.Error("couldn't dial validator: %v\n", err)
-				// Note: Don't use pv.Stop() in here, as RetryDial can only be stopped via SIGINT/SIGTERM.
+	// Sign requests still queued once SignCTRL is stopping aren't signed anymore.
+	if ctx.Err() != nil {
+		if resp := buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: ErrShuttingDown.Error()}); resp != nil {
+			return resp, ErrShuttingDown
+		}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			pv.panics++
+			pv.Logger.Error("Recovered from panic while handling request (%v/%v): %v\n%s", pv.panics, maxPanicsInARow, r, debug.Stack())
+			if pv.panics >= maxPanicsInARow {
+				resp, err = buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: ErrTooManyPanics.Error()}), ErrTooManyPanics
 				return
 			}
+			err = fmt.Errorf("panic while handling request: %v", r)
+			resp = buildResponse(msg, &tm_privvalproto.RemoteSignerError{Description: err.Error()})
+		}
+	}()
+
+	resp, err = pv.handle(ctx, msg, pv)
+	pv.panics = 0
+
+	return resp, err
+}
+
+// handleSignRequest handles the given request in the protocol version it was received
+// in. Requests asking for features the protocol version offers, but SignCTRL doesn't
+// support, are rejected.
+func (pv *SCFilePV) handleSignRequest(ctx context.Context, req *SignRequest) (*SignResponse, error) {
+	if req.needsExtensionSignature() {
+		rse := &tm_privvalproto.RemoteSignerError{Description: ErrVoteExtensionsUnsupported.Error()}
+		return &SignResponse{Msg: buildResponse(req.Msg, rse), Version: req.Version}, ErrVoteExtensionsUnsupported
+	}
+
+	msg, err := pv.safeHandleRequest(ctx, req.Msg)
+	if msg == nil {
+		return nil, err
+	}
+
+	return &SignResponse{Msg: msg, Version: req.Version, VoteExtension: req.VoteExtension}, err
+}
+
+// run runs the main loop for a single validator connection. It handles incoming
+// messages from the validator. In order to stop the goroutine, Stop() can be called
+// outside of run(), which cancels the given context, unblocks pending reads and waits
+// for the response in flight before closing the connections. The goroutine returns on
+// its own once SignCTRL is forced to shut down.
+func (pv *SCFilePV) run(ctx context.Context, vc *validatorConn) {
+	idleTimeout := config.GetRetryDialTime(pv.Config.Base.RetryDialAfter)
+	writeTimeout := config.GetDuration(pv.Config.Base.WriteTimeout)
+
+	// Losing the connection over and over, e.g. to a validator that is down or
+	// stays silent during a chain halt, is only logged in summaries.
+	var lost *types.FailureLog
+	lose := func(reason error) {
+		if lost == nil {
+			lost = types.NewFailureLog(pv.Logger, pv.clock, config.GetDuration(pv.Config.Base.FailureLogInterval), fmt.Sprintf("the validator at %v", vc.getAddress()))
+		}
+		lost.Failure(reason)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			pv.Logger.Debug("Terminating run goroutine for %v: service stopped", vc.address)
+			// A connection might have been established after the connections were
+			// closed by OnStop, so make sure it's closed, too.
+			// Note: Don't use pv.Stop() in here, as it closes the pv.Quit() channel.
+			vc.close(pv.Logger)
+			return
 
 		default:
-			var msg tm_privvalproto.Message
-			r := tm_protoio.NewDelimitedReader(pv.SecretConn, maxRemoteSignerMsgSize)
-			if _, err := r.ReadMsg(&msg); err != nil {
-				if err != io.EOF {
-					pv.Logger.Error("couldn't read message: %v\n", err)
+			conn := vc.get()
+
+			// The read deadline is pushed back with every message read, so it only
+			// expires if the validator has been idle for too long.
+			if idleTimeout > 0 {
+				if err := conn.SetReadDeadline(time.Now().Add(idleTimeout)); err != nil {
+					pv.Logger.Debug("couldn't set read deadline: %v\n", err)
 				}
+			}
+
+			// Don't undo the deadline set by drainConns once SignCTRL is stopping.
+			if ctx.Err() != nil {
 				continue
 			}
 
-			timeout.Reset(retryDialTimeout)
+			var raw rawMsg
+			var req *SignRequest
+			r := tm_protoio.NewDelimitedReader(conn, pv.Config.Privval.MaxMsgSize)
+			_, err := r.ReadMsg(&raw)
+			if err == nil {
+				req, err = DecodeRequest(ProtocolVersion(pv.Config.Privval.ProtocolVersion), raw.bz)
+			}
+			if err != nil {
+				// The connection was closed due to the service being stopped.
+				if ctx.Err() != nil {
+					continue
+				}
 
-			ctx, cancel := context.WithCancel(context.Background())
-			resp, err := HandleRequest(ctx, &msg, pv)
-			w := tm_protoio.NewDelimitedWriter(pv.SecretConn)
-			if _, err := w.WriteMsg(resp); err != nil {
-				pv.Logger.Error("couldn't write message: %v\n", err)
+				// The connection is either idle or broken, so establish a new one.
+				if isTimeoutErr(err) {
+					vc.idleTimeouts++
+					lose(fmt.Errorf("no message for %v", idleTimeout.String()))
+				} else {
+					lose(err)
+				}
+				if err := pv.reconnect(ctx, vc, err); err != nil {
+					// Note: Only use pv.Stop() once all connections have given up, as the
+					// others are still served otherwise.
+					pv.dialFailed(ctx, err)
+					return
+				}
+				continue
+			}
+			vc.responding.Lock()
+			vc.idleTimeouts = 0
+			vc.touch(pv.clock.Now())
+			if lost != nil {
+				lost.Success()
+				lost = nil
 			}
+
+			reqCtx, cancel := context.WithCancel(ctx)
+			resp, err := pv.handleSignRequest(reqCtx, req)
+			var werr error
+			if resp != nil {
+				if writeTimeout > 0 {
+					if err := conn.SetWriteDeadline(time.Now().Add(writeTimeout)); err != nil {
+						pv.Logger.Debug("couldn't set write deadline: %v\n", err)
+					}
+				}
+				var bz []byte
+				if bz, werr = EncodeResponse(resp); werr != nil {
+					pv.Logger.Error("couldn't encode response: %v\n", werr)
+				} else if _, werr = tm_protoio.NewDelimitedWriter(conn).WriteMsg(&rawMsg{bz: bz}); werr != nil {
+					if isTimeoutErr(werr) {
+						pv.Logger.Error("couldn't write message within %v\n", writeTimeout.String())
+					} else {
+						pv.Logger.Error("couldn't write message: %v\n", werr)
+					}
+				}
+			}
+			vc.responding.Unlock()
+			pv.trackHandleResult(req.Msg, err)
 			if err != nil {
-				pv.Logger.Error("couldn't handle request: %v\n", err)
-				if err == types.ErrMustShutdown || err == ErrRankObsolete {
+				if mustShutdown(err) {
 					pv.Logger.Debug("Terminating run goroutine: %v\n", err)
+					pv.retire(err)
 					if err := pv.Stop(); err != nil {
 						pv.Logger.Error("%v", err)
 					}
-					if err := pv.SecretConn.Close(); err != nil {
-						pv.Logger.Error("%v", err)
-					}
 
 					cancel()
 					return
 				}
 			}
 			cancel()
+
+			// The connection is broken, so establish a new one.
+			if werr != nil && ctx.Err() == nil {
+				lose(werr)
+				if err := pv.reconnect(ctx, vc, werr); err != nil {
+					pv.dialFailed(ctx, err)
+					return
+				}
+			}
 		}
 	}
 }
 
-// OnStart starts the main loop of the SignCtrled PrivValidator.
+// serve dials the validator of the given connection and runs the main loop for it.
+// If probe_interval is set, the connection is probed while it is idle.
+func (pv *SCFilePV) serve(ctx context.Context, vc *validatorConn) {
+	conn, err := pv.dialConn(ctx, vc)
+	if err != nil {
+		// Closing the listener in listen mode aborts accepting connections.
+		pv.dialFailed(ctx, err)
+		return
+	}
+	vc.set(pv.connStats.Wrap(conn))
+	vc.touch(pv.clock.Now())
+	pv.connEvents.Connected(vc.address)
+	if pv.Config.Base.ProbeInterval != "" {
+		go pv.probe(ctx, vc)
+	}
+	pv.run(ctx, vc)
+}
+
+// updateStateChecksum saves the checksum of the priv_validator_state.json file if
+// checksums are enabled.
+func (pv *SCFilePV) updateStateChecksum() error {
+	if !pv.Config.Privval.StateChecksum {
+		return nil
+	}
+
+	return SaveStateChecksum(ResolveStateFilePath(pv.ConfigDir, pv.Config.Privval))
+}
+
+// connKeyInfo returns the fingerprint and the base64-encoded public key of the
+// connection key. Both are empty if the transport doesn't use one or it can't be
+// loaded, in which case dialing fails with the reason.
+func (pv *SCFilePV) connKeyInfo() (fingerprint string, pubKey string) {
+	if pv.Config.Privval.Transport != config.TransportSocket {
+		return "", ""
+	}
+	connKey, err := connection.LoadConnKey(pv.ConfigDir)
+	if err != nil {
+		return "", ""
+	}
+
+	return connection.ConnKeyFingerprint(connKey), connection.ConnPubKey(connKey)
+}
+
+// OnStart starts serving the validator's requests via the configured transport.
 // Implements the Service interface.
 func (pv *SCFilePV) OnStart() (err error) {
+	// Refuse to start with an invalid configuration, even if it wasn't loaded from the
+	// configuration file.
+	if err := pv.Config.Validate(); err != nil {
+		return fmt.Errorf("invalid configuration:\n%v", err)
+	}
+
+	pv.restoreCounter()
+	pv.initRank()
+	pv.restorePause()
 	pv.Logger.Info("Starting SignCTRL on rank %v...\n", pv.GetRank())
+	if fingerprint, pubKey := pv.connKeyInfo(); fingerprint != "" {
+		pv.Logger.Info("Connection key: %v (public key %v)", fingerprint, pubKey)
+	}
+	if pv.Logger.Enabled("DEBUG") {
+		for _, setting := range pv.Config.Settings() {
+			pv.Logger.Debug("Config: %v", setting)
+		}
+	}
+
+	if _, ok := pv.TMFilePV.(*WatchOnlyPV); ok {
+		if pv.GetRank() != 1 {
+			pv.Logger.Info("Running in watch-only mode, the private key is loaded on promotion to rank 1")
+		} else if err := pv.loadKey(); err != nil {
+			return fmt.Errorf("couldn't load private key on rank 1: %v", err)
+		}
+	}
+	if pv.Config.Privval.DryRun {
+		pv.Logger.Warn("Running in dry-run mode, so SignCTRL never signs!")
+	}
+	if pv.Config.Base.Rejoin {
+		pv.Logger.Info("Running in rejoin mode, the validator rejoins the set on rank %v instead of shutting down", pv.GetSetSize())
+	}
+	if pv.Config.Privval.Mode == config.ModeDial {
+		pv.Logger.Info("Dialing validators with retry policy: %v", retryPolicy(pv.baseConfig(), pv.retryConfig(), nil))
+	}
+	if pv.Config.Privval.UnsafeSignAnyRank {
+		pv.Logger.Warn("unsafe_sign_any_rank is enabled, so SignCTRL signs on any rank! Never use this in production, as it risks double-signing!")
+	}
+
+	// Refuse to start if another node of the set is on the same rank. The peers are
+	// answered first, so that two nodes starting at the same time both notice.
+	if err := pv.servePeers(); err != nil {
+		return err
+	}
+	if err := pv.checkPeerRanks(context.Background()); err != nil {
+		pv.closePeerListener()
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pv.cancel = cancel
+
+	// Trigger rank updates after threshold_duration without the validator's signature,
+	// even if no blocks arrive.
+	if pv.Config.Base.ThresholdDuration != "" {
+		go pv.watchLastSigned(ctx)
+	}
+
+	// Probe the full node's RPC endpoint, if one is set, without delaying the startup.
+	go pv.probeRPC(ctx)
+
+	// Reload the settings that can be changed at runtime on SIGHUP.
+	pv.reloadOnSIGHUP(ctx)
 
 	// Start http server.
 	if err := pv.StartHTTPServer(); err != nil {
 		return err
 	}
 
-	// Dial the validator.
-	if pv.SecretConn, err = connection.RetryDial(
-		config.Dir(),
-		pv.Config.Base.ValidatorListenAddress,
-		pv.Logger,
-	); err != nil {
+	// Lock the counter for missed blocks in a row whenever a connection to a
+	// validator is lost or established again.
+	pv.connEvents = connection.NewEvents()
+	go pv.watchConnEvents(ctx, pv.connEvents)
+
+	// Serve the validator's requests.
+	if pv.runDone, err = pv.transport.start(ctx); err != nil {
 		return err
 	}
 
-	// Run the main loop.
-	go pv.run()
-
 	return nil
 }
 
-// OnStop terminates the main loop of the SignCtrled PrivValidator.
+// OnStop terminates the main loops of the SignCtrled PrivValidator.
 // Implements the Service interface.
 func (pv *SCFilePV) OnStop() error {
 	pv.Logger.Info("Stopping SignCTRL on rank %v...\n", pv.GetRank())
 
+	// Terminate the main loops and unblock pending reads and connection events.
+	if pv.cancel != nil {
+		pv.cancel()
+	}
+	pv.connEvents.Close()
+	pv.transport.stop()
+	pv.closePeerListener()
+
 	// Close the http server.
 	pv.Logger.Info("Stopping the HTTP server...")
 	pv.HTTP.Close()
 
+	// Give the on_shutdown_cmd the chance to finish.
+	pv.waitHooks()
+
 	// Save rank to last_rank.json file if the shutdown was not self-induced.
+	pv.stateMtx.Lock()
+	defer pv.stateMtx.Unlock()
 	pv.State.LastRank = pv.GetRank()
-	if err := pv.State.Save(config.Dir()); err != nil {
+	if err := pv.State.Save(pv.ConfigDir); err != nil {
 		pv.Logger.Error("couldn't save state to %v: %v\n", config.StateFile, err)
 		return err
 	}
@@ -200,16 +983,215 @@ func (pv *SCFilePV) OnStop() error {
 }
 
 // OnMissedTooMany sets the prometheus gauge for the validator's counter for missed
-// blocks in a row.
+// blocks in a row after the given missed blocks exceeded the threshold.
 // Implements the SignCtrled interface.
-func (pv *SCFilePV) OnMissedTooMany() {
-	pv.Logger.Debug("Setting signctrl_missed_blocks_in_a_row gauge to %v\n", pv.GetMissedInARow())
+func (pv *SCFilePV) OnMissedTooMany(mc types.MissedContext) {
+	pv.Logger.Debug("Exceeded threshold at block height %v on rank %v (%v/%v), setting signctrl_missed_blocks_in_a_row gauge to %v\n", mc.Height, mc.Rank, mc.Missed, mc.Threshold, pv.GetMissedInARow())
 	pv.Gauges.MissedInARowGauge.Set(float64(pv.GetMissedInARow()))
 }
 
-// OnPromote sets the prometheus gauge for the validator's rank.
+// initRank sets the validator's rank on startup. The rank persisted in the
+// signctrl_state.json file is preferred over start_rank, so that a restart doesn't
+// undo rank updates, unless ignore_persisted_rank is enabled.
+func (pv *SCFilePV) initRank() {
+	rank := pv.Config.Base.StartRank
+	switch {
+	case pv.Config.Base.IgnorePersistedRank:
+		pv.Logger.Info("Using start_rank %v, as ignore_persisted_rank is enabled", rank)
+	case pv.State.LastRank < 1:
+		pv.Logger.Info("Using start_rank %v, as no rank has been persisted in %v yet", rank, config.StateFile)
+	case pv.State.LastRank > pv.Config.Base.SetSize:
+		rank = pv.Config.Base.SetSize
+		pv.Logger.Warn("Rank %v persisted in %v isn't in the set of %v anymore, using the last rank %v instead", pv.State.LastRank, config.StateFile, pv.Config.Base.SetSize, rank)
+	default:
+		rank = pv.State.LastRank
+		pv.Logger.Info("Using rank %v persisted in %v instead of start_rank %v", rank, config.StateFile, pv.Config.Base.StartRank)
+	}
+	pv.BaseSignCtrled.SetRank(rank)
+}
+
+// OnStateChange persists the validator's rank and counter for missed blocks in a row
+// to the signctrl_state.json file, so that they survive restarts.
+// Implements the SignCtrled interface.
+func (pv *SCFilePV) OnStateChange() {
+	pv.stateMtx.Lock()
+	defer pv.stateMtx.Unlock()
+	pv.State.LastRank = pv.GetRank()
+	pv.State.MissedInARow = pv.GetMissedInARow()
+	pv.State.CurrentHeight = pv.GetCurrentHeight()
+	pv.State.CounterLocked = pv.IsCounterLocked()
+	pv.State.Paused = pv.IsPaused()
+	pv.State.PauseReason = pv.GetPauseReason()
+	if reason := pv.GetPromoteReason(); reason != "" {
+		pv.State.LastPromoteReason = string(reason)
+	}
+	if err := pv.State.Save(pv.ConfigDir); err != nil {
+		pv.Logger.Error("couldn't persist state to %v: %v\n", config.StateFile, err)
+	}
+}
+
+// restoreCounter restores the counter for missed blocks in a row persisted in the
+// signctrl_state.json file, unless ignore_persisted_rank is enabled. Whether the
+// restored counter is stale is only known once the first block after the restart is
+// seen.
+func (pv *SCFilePV) restoreCounter() {
+	if pv.Config.Base.IgnorePersistedRank || pv.State.CurrentHeight < 1 {
+		return
+	}
+
+	pv.BaseSignCtrled.Restore(pv.State.MissedInARow, pv.State.CurrentHeight, pv.State.CounterLocked)
+	pv.restored = true
+	pv.Logger.Info("Restored %v missed blocks in a row at block height %v from %v", pv.State.MissedInARow, pv.State.CurrentHeight, config.StateFile)
+}
+
+// restorePause pauses the monitoring of missed blocks again if it has been paused
+// before a restart, so that a restart during a maintenance window doesn't resume it.
+func (pv *SCFilePV) restorePause() {
+	if pv.State.Paused {
+		pv.BaseSignCtrled.Pause(pv.State.PauseReason)
+	}
+}
+
+// checkRestoredCounter checks whether the counter restored on startup is stale at the
+// given height. The blocks between the restored height and the given one haven't been
+// checked, so if they could have exceeded the threshold unnoticed, the counter is
+// locked until a fresh commitsig from the validator arrives.
+func (pv *SCFilePV) checkRestoredCounter(height int64) {
+	pv.restored = false
+	unchecked := height - pv.GetCurrentHeight() - 1
+	if unchecked <= 0 || unchecked < int64(pv.GetThreshold()-pv.GetMissedInARow()) {
+		return
+	}
+
+	pv.Logger.Warn("Restored counter for missed blocks in a row is stale (%v unchecked blocks since block height %v)", unchecked, pv.GetCurrentHeight())
+	pv.Reset()
+	pv.LockCounter()
+}
+
+// retire moves the validator to the last rank of the set before SignCTRL shuts itself
+// down for the given reason, so that it can never come back on a rank the set has
+// moved on from. The on_shutdown_cmd is run afterwards.
+func (pv *SCFilePV) retire(reason error) {
+	pv.handleMtx.Lock()
+	defer pv.handleMtx.Unlock()
+	oldRank := pv.GetRank()
+	if err := pv.Demote(pv.GetSetSize()); err != nil {
+		pv.Logger.Error("couldn't retire to the last rank: %v\n", err)
+	}
+	pv.runHook("on_shutdown_cmd", pv.hooksConfig().OnShutdownCmd, hookEvent{
+		name:    hookEventShutdown,
+		height:  pv.GetCurrentHeight(),
+		oldRank: oldRank,
+		newRank: pv.GetRank(),
+		reason:  reason.Error(),
+	})
+}
+
+// logThresholdExceeded logs the context of the rank update or the shutdown caused by
+// the given error returned from checking for missed blocks or signatures.
+func (pv *SCFilePV) logThresholdExceeded(err error) {
+	var exceeded *types.ThresholdExceededError
+	var shutdown *types.MustShutdownError
+	switch {
+	case errors.As(err, &exceeded):
+		pv.Logger.Warn("Threshold exceeded at block height %v (%v/%v), promoted validator from rank %v to rank %v", exceeded.Height, exceeded.Missed, exceeded.Threshold, exceeded.OldRank, exceeded.NewRank)
+	case errors.As(err, &shutdown):
+		pv.Logger.Error("Threshold exceeded on rank 1, which can't be promoted anymore (final block height: %v)", shutdown.Height)
+	}
+}
+
+// watchLastSigned checks every lastSignedCheckInterval whether the validator's
+// signature hasn't been seen for longer than threshold_duration until the given
+// context is canceled. If the validator must shut down, SignCTRL is stopped.
+func (pv *SCFilePV) watchLastSigned(ctx context.Context) {
+	ticker := pv.clock.NewTicker(lastSignedCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C():
+			if err := pv.checkLastSigned(); mustShutdown(err) {
+				pv.Logger.Debug("Terminating watchLastSigned goroutine: %v\n", err)
+				pv.retire(err)
+				if err := pv.Stop(); err != nil {
+					pv.Logger.Error("%v", err)
+				}
+				return
+			}
+		}
+	}
+}
+
+// checkLastSigned triggers a rank update if the validator's signature hasn't been seen
+// for longer than threshold_duration. Just like with missed blocks, the validator
+// rejoins the set on the last rank in rejoin mode instead of shutting down. It is
+// serialized with the handling of requests, as both update the rank.
+func (pv *SCFilePV) checkLastSigned() error {
+	pv.handleMtx.Lock()
+	defer pv.handleMtx.Unlock()
+
+	err := pv.CheckLastSigned()
+	pv.logThresholdExceeded(err)
+	if !errors.Is(err, types.ErrMustShutdown) || !pv.Config.Base.Rejoin {
+		return err
+	}
+	if err := pv.rejoin(); err != nil {
+		pv.Logger.Error("couldn't rejoin the set: %v\n", err)
+		return types.ErrMustShutdown
+	}
+
+	return nil
+}
+
+// rejoin demotes the validator to the last rank of the set once it exceeded the
+// threshold on rank 1, instead of shutting it down. In watch-only mode, the private
+// key is dropped again until the validator is promoted back to rank 1.
+func (pv *SCFilePV) rejoin() error {
+	if err := pv.Demote(pv.GetSetSize()); err != nil {
+		return err
+	}
+	pv.Gauges.RankGauge.Set(float64(pv.GetRank()))
+	pv.Gauges.MissedInARowGauge.Set(0)
+	pv.Gauges.ThresholdGauge.Set(float64(pv.GetThreshold()))
+
+	if pv.Config.Privval.WatchOnly {
+		pub, err := pv.TMFilePV.GetPubKey()
+		if err != nil {
+			return err
+		}
+		pv.TMFilePV = &WatchOnlyPV{pubKey: pub}
+	}
+	pv.Logger.Warn("Rejoined the set on rank %v, signing only resumes once promoted back to rank 1", pv.GetRank())
+
+	return nil
+}
+
+// OnPromote sets the prometheus gauges for the validator's rank and the threshold on
+// it and runs the on_promote_cmd with the reason of the promotion. Watch-only nodes
+// load the private key on promotion to rank 1. If that fails, the node can't fill in
+// for the previous signer, so the promotion fails.
 // Implements the SignCtrled interface.
-func (pv *SCFilePV) OnPromote() {
+func (pv *SCFilePV) OnPromote(reason types.PromoteReason) error {
 	pv.Logger.Debug("Setting signctrl_rank gauge to %v\n", pv.GetRank())
 	pv.Gauges.RankGauge.Set(float64(pv.GetRank()))
+	pv.Gauges.ThresholdGauge.Set(float64(pv.GetThreshold()))
+	if _, ok := pv.TMFilePV.(*WatchOnlyPV); ok && pv.GetRank() == 1 {
+		if err := pv.loadKey(); err != nil {
+			pv.Logger.Error("couldn't load private key on rank 1: %v\n", err)
+			return err
+		}
+		pv.Logger.Info("Loaded private key on rank 1, ready to sign")
+	}
+	pv.runHook("on_promote_cmd", pv.hooksConfig().OnPromoteCmd, hookEvent{
+		name:    hookEventPromote,
+		height:  pv.GetCurrentHeight(),
+		oldRank: pv.GetRank() + 1,
+		newRank: pv.GetRank(),
+		reason:  string(reason),
+	})
+
+	return nil
 }
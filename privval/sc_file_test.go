@@ -1,18 +1,37 @@
 package privval
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/connection"
 	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/BlockscapeNetwork/signctrl/types/clocktest"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 	tm_crypto "github.com/tendermint/tendermint/crypto"
 	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_protoio "github.com/tendermint/tendermint/libs/protoio"
+	tm_p2pconn "github.com/tendermint/tendermint/p2p/conn"
 	tm_privval "github.com/tendermint/tendermint/privval"
+	tm_privvalproto "github.com/tendermint/tendermint/proto/tendermint/privval"
 	tm_prototypes "github.com/tendermint/tendermint/proto/tendermint/types"
 	tm_types "github.com/tendermint/tendermint/types"
 )
@@ -40,16 +59,51 @@ func testConfig(t *testing.T) config.Config {
 	t.Helper()
 	return config.Config{
 		Base: config.Base{
-			LogLevel:                  "INFO",
-			SetSize:                   2,
+			MissedBlockLogInterval:    10,
+			SetSize:                   3,
 			Threshold:                 10,
+			RankStrategy:              "in_a_row",
+			PostPromotionGraceBlocks:  1,
+			ConsecutiveSignsToUnlock:  1,
+			UnlockOn:                  "own_signature",
 			StartRank:                 1,
 			ValidatorListenAddress:    "tcp://127.0.0.1:3000",
 			ValidatorListenAddressRPC: "tcp://127.0.0.1:26657",
 			RetryDialAfter:            "15s",
+			WriteTimeout:              "5s",
+			DialTimeout:               "5s",
 		},
 		Privval: config.PrivValidator{
-			ChainID: "testchain",
+			ChainID:         "testchain",
+			MaxMsgSize:      10240,
+			Mode:            "dial",
+			ListenPolicy:    "reject",
+			Transport:       "socket",
+			ProtocolVersion: "auto",
+		},
+		Connection: config.Connection{
+			Retry: config.Retry{
+				InitialInterval: "1s",
+				MaxInterval:     "30s",
+				Multiplier:      2,
+				Jitter:          0.2,
+			},
+		},
+		Monitoring: config.Monitoring{
+			MinParticipation: 0.67,
+		},
+		Hooks: config.Hooks{
+			Timeout: "30s",
+		},
+		Logging: config.Logging{
+			Level:  "info",
+			Format: "plain",
+			Output: "stderr",
+		},
+		Alerts: config.Alerts{
+			Webhook:   config.AlertChannel{MinSeverity: "warning", RateLimit: 30},
+			Slack:     config.SlackChannel{AlertChannel: config.AlertChannel{URL: config.DefaultSlackURL, MinSeverity: "warning", RateLimit: 30}},
+			PagerDuty: config.AlertChannel{URL: config.DefaultPagerDutyURL, MinSeverity: "warning", RateLimit: 30},
 		},
 	}
 }
@@ -81,15 +135,40 @@ func testFilePV(t *testing.T) tm_types.PrivValidator {
 	}
 }
 
+// testGauges returns gauges that aren't registered with prometheus, so that they can
+// be created for each test.
+func testGauges(t *testing.T) types.Gauges {
+	t.Helper()
+	return types.Gauges{
+		RankGauge:         prometheus.NewGauge(prometheus.GaugeOpts{Name: "signctrl_rank"}),
+		MissedInARowGauge: prometheus.NewGauge(prometheus.GaugeOpts{Name: "signctrl_missed_blocks_in_a_row"}),
+		DegradedGauge:     prometheus.NewGauge(prometheus.GaugeOpts{Name: "signctrl_degraded"}),
+		ThresholdGauge:    prometheus.NewGauge(prometheus.GaugeOpts{Name: "signctrl_threshold"}),
+	}
+}
+
+// mockSCFilePV returns an SCFilePV with a temporary configuration directory, as rank
+// changes are persisted, so that the actual configuration directory is never touched.
 func mockSCFilePV(t *testing.T) *SCFilePV {
 	t.Helper()
-	return NewSCFilePV(
+	return mockSCFilePVIn(t, t.TempDir())
+}
+
+// mockSCFilePVIn returns an SCFilePV with the given configuration directory.
+func mockSCFilePVIn(t *testing.T, cfgDir string) *SCFilePV {
+	t.Helper()
+	pv, err := NewSCFilePV(
 		types.NewSyncLogger(ioutil.Discard, "", 0),
+		cfgDir,
 		testConfig(t),
 		testState(t),
 		testFilePV(t),
 		&http.Server{Addr: fmt.Sprintf(":%v", DefaultHTTPPort)},
 	)
+	assert.NoError(t, err)
+	pv.Gauges = testGauges(t)
+
+	return pv
 }
 
 func TestKeyFilePath(t *testing.T) {
@@ -101,3 +180,1165 @@ func TestStateFilePath(t *testing.T) {
 	path := StateFilePath("/tmp")
 	assert.Equal(t, "/tmp/priv_validator_state.json", path)
 }
+
+func TestResolveKeyFilePath(t *testing.T) {
+	var cfg config.PrivValidator
+	assert.Equal(t, "/tmp/priv_validator_key.json", ResolveKeyFilePath("/tmp", cfg))
+	cfg.KeyFilePath = "keys/key.json"
+	assert.Equal(t, "/tmp/keys/key.json", ResolveKeyFilePath("/tmp", cfg))
+	cfg.KeyFilePath = "/mnt/secure/key.json"
+	assert.Equal(t, "/mnt/secure/key.json", ResolveKeyFilePath("/tmp", cfg))
+}
+
+func TestResolveStateFilePath(t *testing.T) {
+	var cfg config.PrivValidator
+	assert.Equal(t, "/tmp/priv_validator_state.json", ResolveStateFilePath("/tmp", cfg))
+	cfg.StateFilePath = "data/state.json"
+	assert.Equal(t, "/tmp/data/state.json", ResolveStateFilePath("/tmp", cfg))
+	cfg.StateFilePath = "/var/lib/signctrl/state.json"
+	assert.Equal(t, "/var/lib/signctrl/state.json", ResolveStateFilePath("/tmp", cfg))
+}
+
+func TestNewSCFilePV_Logger(t *testing.T) {
+	// Without a logger, the one configured in the logging section is created.
+	cfgDir := t.TempDir()
+	cfg := testConfig(t)
+	cfg.Logging.Format = "json"
+	cfg.Logging.Output = filepath.Join(cfgDir, "signctrl.log")
+	pv, err := NewSCFilePV(nil, cfgDir, cfg, testState(t), testFilePV(t), &http.Server{})
+	assert.NoError(t, err)
+	pv.Logger.Debug("hidden")
+	pv.Logger.Info("shown")
+	bz, err := ioutil.ReadFile(cfg.Logging.Output)
+	assert.NoError(t, err)
+	assert.Contains(t, string(bz), `"level":"info","module":"signctrl","msg":"shown"`)
+	assert.NotContains(t, string(bz), "hidden")
+	info, err := os.Stat(cfg.Logging.Output)
+	assert.NoError(t, err)
+	assert.Equal(t, PermLogFile, info.Mode().Perm())
+
+	cfg.Logging.Output = filepath.Join(cfgDir, "logs", "signctrl.log")
+	_, err = NewSCFilePV(nil, cfgDir, cfg, testState(t), testFilePV(t), &http.Server{})
+	assert.Error(t, err)
+}
+
+type testNetErr struct {
+	timeout bool
+}
+
+func (e testNetErr) Error() string   { return "test net error" }
+func (e testNetErr) Timeout() bool   { return e.timeout }
+func (e testNetErr) Temporary() bool { return e.timeout }
+
+func TestIsTimeoutErr(t *testing.T) {
+	assert.True(t, isTimeoutErr(testNetErr{timeout: true}))
+	assert.False(t, isTimeoutErr(testNetErr{timeout: false}))
+	assert.False(t, isTimeoutErr(io.EOF))
+	assert.False(t, isTimeoutErr(errors.New("connection reset by peer")))
+}
+
+// testValidatorConn wraps the given connection into a validator connection.
+func testValidatorConn(t *testing.T, conn net.Conn) *validatorConn {
+	t.Helper()
+	return &validatorConn{address: "tcp://127.0.0.1:3000", conn: conn}
+}
+
+func TestRunReconnectOnEOF(t *testing.T) {
+	pv := mockSCFilePV(t)
+	signerConn, validatorConn := net.Pipe()
+	vc := testValidatorConn(t, signerConn)
+
+	// Count the dials, so that a busy loop on the closed connection is detected.
+	// The first redial succeeds, the second one aborts the run goroutine.
+	var dials int
+	redialConn, redialPeer := net.Pipe()
+	pv.dial = func(ctx context.Context, address string) (net.Conn, error) {
+		dials++
+		if dials == 1 {
+			return redialConn, nil
+		}
+		return nil, errors.New("dialing aborted")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		pv.run(context.Background(), vc)
+		close(done)
+	}()
+
+	// Closing the validator's end makes the signer read io.EOF.
+	validatorConn.Close()
+	time.Sleep(100 * time.Millisecond)
+	redialPeer.Close()
+
+	select {
+	case <-done:
+		assert.Equal(t, 2, dials)
+		assert.Equal(t, 2, vc.reconnects)
+	case <-time.After(time.Second):
+		t.Fatal("expected run() to return within 1s")
+	}
+}
+
+func TestRunFailureLog(t *testing.T) {
+	pv := mockSCFilePV(t)
+	var buf bytes.Buffer
+	pv.Logger = types.NewSyncLogger(&buf, "", 0)
+	pv.Config.Base.FailureLogInterval = "1h"
+	signerConn, validatorConn := net.Pipe()
+	vc := testValidatorConn(t, signerConn)
+
+	// The validator drops the first three connections right away.
+	var dials int
+	peers := make(chan net.Conn, 1)
+	pv.dial = func(ctx context.Context, address string) (net.Conn, error) {
+		dials++
+		if dials > 3 {
+			return nil, errors.New("dialing aborted")
+		}
+		conn, peer := net.Pipe()
+		if dials < 3 {
+			peer.Close()
+		} else {
+			peers <- peer
+		}
+		return conn, nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		pv.run(context.Background(), vc)
+		close(done)
+	}()
+	validatorConn.Close()
+
+	// The third connection is used again, which restores it.
+	var lastPeer net.Conn
+	select {
+	case lastPeer = <-peers:
+	case <-time.After(time.Second):
+		t.Fatal("expected a third dial within 1s")
+	}
+	_, err := tm_protoio.NewDelimitedWriter(lastPeer).WriteMsg(wrapMsg(&tm_privvalproto.PingRequest{}))
+	assert.NoError(t, err)
+	var resp tm_privvalproto.Message
+	_, err = tm_protoio.NewDelimitedReader(lastPeer, pv.Config.Privval.MaxMsgSize).ReadMsg(&resp)
+	assert.NoError(t, err)
+	lastPeer.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected run() to return within 1s")
+	}
+
+	// Only the first loss is logged until the connection is restored.
+	logs := buf.String()
+	assert.Equal(t, 2, strings.Count(logs, "Unable to reach the validator at tcp://127.0.0.1:3000"))
+	assert.Contains(t, logs, "Connection to the validator at tcp://127.0.0.1:3000 restored after")
+	assert.Contains(t, logs, "(3 failed attempts)")
+	assert.NotContains(t, logs, "Still unable to reach")
+}
+
+func TestDialFailed_Exhausted(t *testing.T) {
+	pv := mockSCFilePV(t)
+	var buf bytes.Buffer
+	pv.Logger = types.NewSyncLogger(&buf, "", 0)
+	pv.conns = []*validatorConn{{address: "tcp://127.0.0.1:3000"}, {address: "tcp://127.0.0.1:3001"}}
+	exhausted := &connection.RetryExhaustedError{Address: "tcp://127.0.0.1:3000", Attempts: 5, Err: errors.New("connection refused")}
+
+	// Errors other than an exhausted retry policy don't count, and neither do errors
+	// after the service has been stopped.
+	pv.dialFailed(context.Background(), errors.New("dialing aborted"))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	pv.dialFailed(ctx, exhausted)
+	assert.Equal(t, int32(0), pv.exhaustedConns)
+
+	// SignCTRL keeps serving the other connections until all of them have given up.
+	pv.dialFailed(context.Background(), exhausted)
+	assert.Equal(t, 1, pv.GetRank())
+	assert.Contains(t, buf.String(), "Gave up on 1 of 2 validator connections")
+
+	pv.dialFailed(context.Background(), exhausted)
+	assert.Equal(t, pv.GetSetSize(), pv.GetRank())
+	assert.Contains(t, buf.String(), "Gave up dialing all validators")
+	assert.Contains(t, buf.String(), "after 5 attempts")
+}
+
+func TestDialFailed_UnknownConnKey(t *testing.T) {
+	pv := mockSCFilePV(t)
+	var buf bytes.Buffer
+	pv.Logger = types.NewSyncLogger(&buf, "", 0)
+	pv.conns = []*validatorConn{{address: "tcp://127.0.0.1:3000"}}
+
+	// A validator using an unauthorized key is given up on right away.
+	pv.dialFailed(context.Background(), fmt.Errorf("%w 2KmYPwtTGfV5MqUWdRXC6bwS0NgxBG2+gCmgKEnjcFo=", connection.ErrUnknownConnKey))
+	assert.Equal(t, pv.GetSetSize(), pv.GetRank())
+	assert.Contains(t, buf.String(), "unauthorized connection key 2KmYPwtTGfV5MqUWdRXC6bwS0NgxBG2+gCmgKEnjcFo=")
+}
+
+func TestConnEventsLockCounter(t *testing.T) {
+	cfgDir := t.TempDir()
+
+	pv := mockSCFilePVIn(t, cfgDir)
+	pv.TMFilePV = tm_types.NewMockPV()
+	port, _ := getFreePort(t)
+	pv.HTTP = &http.Server{Addr: fmt.Sprintf(":%v", port)}
+
+	// The block endpoint serves commits with or without the validator's commitsig.
+	var signed int32
+	pub, _ := pv.TMFilePV.GetPubKey()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/block", func(rw http.ResponseWriter, r *http.Request) {
+		result := testBlockResult(t)
+		if atomic.LoadInt32(&signed) == 1 {
+			commit := result.Result.Block.LastCommit
+			commit.Signatures = append(commit.Signatures, tm_types.CommitSig{
+				ValidatorAddress: pub.Address(),
+				Signature:        []byte("OWN-SIG"),
+			})
+		}
+		bz, _ := tm_json.Marshal(result)
+		_, _ = rw.Write(bz)
+	})
+	rpcServer := httptest.NewServer(mux)
+	defer rpcServer.Close()
+	pv.Config.Base.ValidatorListenAddressRPC = "tcp://" + rpcServer.Listener.Addr().String()
+
+	// The first dial returns the connection that is killed, the second one the
+	// restored connection.
+	signerConn, validatorConn := net.Pipe()
+	redialConn, redialPeer := net.Pipe()
+	defer redialPeer.Close()
+	var dials int32
+	pv.dial = func(ctx context.Context, address string) (net.Conn, error) {
+		if atomic.AddInt32(&dials, 1) == 1 {
+			return signerConn, nil
+		}
+		return redialConn, nil
+	}
+
+	// vote sends a vote request for the given height on the given connection like the
+	// validator does and waits for the response.
+	vote := func(conn net.Conn, height int64) {
+		req := testSignVoteRequest(t)
+		req.GetSignVoteRequest().Vote.Height = height
+		_, err := tm_protoio.NewDelimitedWriter(conn).WriteMsg(req)
+		assert.NoError(t, err)
+		var resp tm_privvalproto.Message
+		_, err = tm_protoio.NewDelimitedReader(conn, pv.Config.Privval.MaxMsgSize).ReadMsg(&resp)
+		assert.NoError(t, err)
+	}
+
+	err := pv.Start()
+	assert.NoError(t, err)
+	defer func() { _ = pv.Stop() }()
+
+	// A commit with the validator's commitsig unlocks the counter.
+	atomic.StoreInt32(&signed, 1)
+	vote(validatorConn, 2)
+	assert.False(t, pv.IsCounterLocked())
+
+	// Killing the connection locks the counter until it is restored.
+	validatorConn.Close()
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&dials) == 2 }, time.Second, 10*time.Millisecond)
+	assert.True(t, pv.IsCounterLocked())
+	vote(redialPeer, 3)
+	assert.True(t, pv.IsCounterLocked())
+
+	// Commits without the validator's commitsig don't unlock it, but a fresh one with
+	// it does.
+	atomic.StoreInt32(&signed, 0)
+	vote(redialPeer, 4)
+	assert.True(t, pv.IsCounterLocked())
+	assert.Zero(t, pv.GetMissedInARow())
+	atomic.StoreInt32(&signed, 1)
+	vote(redialPeer, 5)
+	assert.False(t, pv.IsCounterLocked())
+}
+
+func TestStopTerminatesRun(t *testing.T) {
+	cfgDir := t.TempDir()
+
+	pv := mockSCFilePVIn(t, cfgDir)
+	port, _ := getFreePort(t)
+	pv.HTTP = &http.Server{Addr: fmt.Sprintf(":%v", port)}
+
+	// The validator never sends anything, so run() blocks on reading.
+	signerConn, validatorConn := net.Pipe()
+	defer validatorConn.Close()
+	pv.dial = func(ctx context.Context, address string) (net.Conn, error) {
+		return signerConn, nil
+	}
+
+	err := pv.Start()
+	assert.NoError(t, err)
+	err = pv.Stop()
+	assert.NoError(t, err)
+
+	select {
+	case <-pv.runDone:
+	case <-time.After(time.Second):
+		t.Fatal("expected run() to return within 1s")
+	}
+}
+
+func TestStopDrainsResponse(t *testing.T) {
+	cfgDir := t.TempDir()
+
+	pv := mockSCFilePVIn(t, cfgDir)
+	port, _ := getFreePort(t)
+	pv.HTTP = &http.Server{Addr: fmt.Sprintf(":%v", port)}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	pv.dial = func(ctx context.Context, address string) (net.Conn, error) {
+		return net.Dial("tcp", listener.Addr().String())
+	}
+
+	// The request is still being handled when SignCTRL is stopped.
+	handling, release := make(chan struct{}), make(chan struct{})
+	pv.handle = func(ctx context.Context, msg *tm_privvalproto.Message, pv *SCFilePV) (*tm_privvalproto.Message, error) {
+		close(handling)
+		<-release
+		return wrapMsg(&tm_privvalproto.PingResponse{}), nil
+	}
+	err = pv.Start()
+	assert.NoError(t, err)
+	conn, err := listener.Accept()
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = tm_protoio.NewDelimitedWriter(conn).WriteMsg(wrapMsg(&tm_privvalproto.PingRequest{}))
+	assert.NoError(t, err)
+	<-handling
+	stopped := make(chan error, 1)
+	go func() {
+		stopped <- pv.Stop()
+	}()
+	time.Sleep(100 * time.Millisecond)
+	close(release)
+
+	// The last response is written in full before the connection is closed.
+	r := tm_protoio.NewDelimitedReader(conn, pv.Config.Privval.MaxMsgSize)
+	var resp tm_privvalproto.Message
+	_, err = r.ReadMsg(&resp)
+	assert.NoError(t, err)
+	assert.IsType(t, &tm_privvalproto.Message_PingResponse{}, resp.Sum)
+	_, err = r.ReadMsg(&resp)
+	assert.ErrorIs(t, err, io.EOF)
+	assert.NoError(t, <-stopped)
+}
+
+func TestSafeHandleRequest_ShuttingDown(t *testing.T) {
+	pv := mockSCFilePV(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Sign requests still queued on shutdown are answered with an error.
+	resp, err := pv.safeHandleRequest(ctx, testSignVoteRequest(t))
+	assert.ErrorIs(t, err, ErrShuttingDown)
+	assert.Equal(t, ErrShuttingDown.Error(), resp.GetSignedVoteResponse().GetError().GetDescription())
+
+	// Any other request is still handled.
+	resp, err = pv.safeHandleRequest(ctx, wrapMsg(&tm_privvalproto.PingRequest{}))
+	assert.NoError(t, err)
+	assert.IsType(t, &tm_privvalproto.Message_PingResponse{}, resp.Sum)
+}
+
+func TestStartMultipleValidators(t *testing.T) {
+	cfgDir := t.TempDir()
+
+	pv := mockSCFilePVIn(t, cfgDir)
+	port, _ := getFreePort(t)
+	pv.HTTP = &http.Server{Addr: fmt.Sprintf(":%v", port)}
+	pv.Config.Base.ValidatorListenAddresses = []string{"tcp://127.0.0.1:3001"}
+
+	// Each address gets its own connection.
+	validatorConns := make(map[string]net.Conn)
+	signerConns := make(map[string]net.Conn)
+	for _, addr := range pv.Config.Base.ListenAddresses() {
+		signerConns[addr], validatorConns[addr] = net.Pipe()
+		defer validatorConns[addr].Close()
+	}
+	pv.dial = func(ctx context.Context, address string) (net.Conn, error) {
+		return signerConns[address], nil
+	}
+
+	// Requests must never be handled concurrently.
+	var inFlight, maxInFlight int32
+	pv.handle = func(ctx context.Context, msg *tm_privvalproto.Message, pv *SCFilePV) (*tm_privvalproto.Message, error) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		if n > atomic.LoadInt32(&maxInFlight) {
+			atomic.StoreInt32(&maxInFlight, n)
+		}
+		time.Sleep(50 * time.Millisecond)
+		return HandleRequest(ctx, msg, pv)
+	}
+
+	err := pv.Start()
+	assert.NoError(t, err)
+	assert.Len(t, pv.conns, 2)
+
+	// Both validators are served at the same time.
+	var wg sync.WaitGroup
+	for _, conn := range validatorConns {
+		wg.Add(1)
+		go func(conn net.Conn) {
+			defer wg.Done()
+			w := tm_protoio.NewDelimitedWriter(conn)
+			r := tm_protoio.NewDelimitedReader(conn, pv.Config.Privval.MaxMsgSize)
+			for i := 0; i < 3; i++ {
+				_, err := w.WriteMsg(wrapMsg(&tm_privvalproto.PingRequest{}))
+				assert.NoError(t, err)
+				var resp tm_privvalproto.Message
+				_, err = r.ReadMsg(&resp)
+				assert.NoError(t, err)
+				assert.IsType(t, &tm_privvalproto.Message_PingResponse{}, resp.Sum)
+			}
+		}(conn)
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&maxInFlight))
+
+	err = pv.Stop()
+	assert.NoError(t, err)
+
+	select {
+	case <-pv.runDone:
+	case <-time.After(time.Second):
+		t.Fatal("expected all run() goroutines to return within 1s")
+	}
+}
+
+func TestListenMode(t *testing.T) {
+	cfgDir := t.TempDir()
+	err := connection.CreateBase64ConnKey(cfgDir)
+	assert.NoError(t, err)
+
+	validatorKey := tm_ed25519.GenPrivKey()
+	laddrPort, _ := getFreePort(t)
+	cfg := testConfig(t)
+	cfg.Privval.Mode = config.ModeListen
+	cfg.Privval.ListenAddress = fmt.Sprintf("tcp://127.0.0.1:%v", laddrPort)
+	cfg.Privval.ValidatorConnKey = base64.StdEncoding.EncodeToString(validatorKey.PubKey().Bytes())
+
+	httpPort, _ := getFreePort(t)
+	pv, err := NewSCFilePV(types.NewSyncLogger(ioutil.Discard, "", 0), cfgDir, cfg, testState(t), testFilePV(t), &http.Server{Addr: fmt.Sprintf(":%v", httpPort)})
+	assert.NoError(t, err)
+	err = pv.Start()
+	assert.NoError(t, err)
+
+	// pingSCFilePV dials SignCTRL like the validator does and pings it. Until SignCTRL
+	// has noticed that the previous connection is gone, new ones are rejected, so it
+	// keeps trying.
+	pingSCFilePV := func() net.Conn {
+		ping := func() (net.Conn, error) {
+			conn, err := net.Dial("tcp", fmt.Sprintf("127.0.0.1:%v", laddrPort))
+			if err != nil {
+				return nil, err
+			}
+			secretConn, err := tm_p2pconn.MakeSecretConnection(conn, validatorKey)
+			if err != nil {
+				conn.Close()
+				return nil, err
+			}
+			if _, err := tm_protoio.NewDelimitedWriter(secretConn).WriteMsg(wrapMsg(&tm_privvalproto.PingRequest{})); err != nil {
+				secretConn.Close()
+				return nil, err
+			}
+			var resp tm_privvalproto.Message
+			if _, err := tm_protoio.NewDelimitedReader(secretConn, pv.Config.Privval.MaxMsgSize).ReadMsg(&resp); err != nil {
+				secretConn.Close()
+				return nil, err
+			}
+			assert.IsType(t, &tm_privvalproto.Message_PingResponse{}, resp.Sum)
+			return secretConn, nil
+		}
+
+		var conn net.Conn
+		var err error
+		for i := 0; i < 10; i++ {
+			if conn, err = ping(); err == nil {
+				break
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+		assert.NoError(t, err)
+
+		return conn
+	}
+
+	conn := pingSCFilePV()
+	pv.handleMtx.Lock()
+	pv.UnlockCounter()
+	pv.handleMtx.Unlock()
+
+	// The validator reconnects, which must lock the counter again.
+	conn.Close()
+	conn = pingSCFilePV()
+	defer conn.Close()
+	pv.handleMtx.Lock()
+	assert.Equal(t, types.ErrCounterLocked, pv.Missed(pv.GetCurrentHeight()+1))
+	pv.handleMtx.Unlock()
+
+	err = pv.Stop()
+	assert.NoError(t, err)
+
+	select {
+	case <-pv.runDone:
+	case <-time.After(time.Second):
+		t.Fatal("expected run() to return within 1s")
+	}
+}
+
+func TestListenModeUnix(t *testing.T) {
+	cfgDir := t.TempDir()
+
+	sockPath := filepath.Join(cfgDir, "privval.sock")
+	cfg := testConfig(t)
+	cfg.Privval.Mode = config.ModeListen
+	cfg.Privval.ListenAddress = "unix://" + sockPath
+
+	httpPort, _ := getFreePort(t)
+	pv, err := NewSCFilePV(types.NewSyncLogger(ioutil.Discard, "", 0), cfgDir, cfg, testState(t), testFilePV(t), &http.Server{Addr: fmt.Sprintf(":%v", httpPort)})
+	assert.NoError(t, err)
+	err = pv.Start()
+	assert.NoError(t, err)
+
+	fi, err := os.Stat(sockPath)
+	assert.NoError(t, err)
+	assert.Equal(t, connection.PermSocketFile, fi.Mode().Perm())
+
+	// The validator connects without a secret connection.
+	conn, err := net.Dial("unix", sockPath)
+	assert.NoError(t, err)
+	defer conn.Close()
+	_, err = tm_protoio.NewDelimitedWriter(conn).WriteMsg(wrapMsg(&tm_privvalproto.PingRequest{}))
+	assert.NoError(t, err)
+	var resp tm_privvalproto.Message
+	_, err = tm_protoio.NewDelimitedReader(conn, pv.Config.Privval.MaxMsgSize).ReadMsg(&resp)
+	assert.NoError(t, err)
+	assert.IsType(t, &tm_privvalproto.Message_PingResponse{}, resp.Sum)
+
+	err = pv.Stop()
+	assert.NoError(t, err)
+
+	select {
+	case <-pv.runDone:
+	case <-time.After(time.Second):
+		t.Fatal("expected run() to return within 1s")
+	}
+
+	// The socket file is cleaned up on shutdown.
+	_, err = os.Stat(sockPath)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestListenModePolicy(t *testing.T) {
+	for _, policy := range []string{config.ListenPolicyReject, config.ListenPolicyPreempt} {
+		t.Run(policy, func(t *testing.T) {
+			cfgDir := t.TempDir()
+
+			sockPath := filepath.Join(cfgDir, "privval.sock")
+			cfg := testConfig(t)
+			cfg.Privval.Mode = config.ModeListen
+			cfg.Privval.ListenAddress = "unix://" + sockPath
+			cfg.Privval.ListenPolicy = policy
+
+			httpPort, _ := getFreePort(t)
+			pv, err := NewSCFilePV(types.NewSyncLogger(ioutil.Discard, "", 0), cfgDir, cfg, testState(t), testFilePV(t), &http.Server{Addr: fmt.Sprintf(":%v", httpPort)})
+			assert.NoError(t, err)
+			err = pv.Start()
+			assert.NoError(t, err)
+			defer pv.Stop()
+
+			// ping pings SignCTRL on the given connection and returns whether it
+			// responded.
+			ping := func(conn net.Conn) bool {
+				_ = conn.SetDeadline(time.Now().Add(time.Second))
+				if _, err := tm_protoio.NewDelimitedWriter(conn).WriteMsg(wrapMsg(&tm_privvalproto.PingRequest{})); err != nil {
+					return false
+				}
+				var resp tm_privvalproto.Message
+				_, err := tm_protoio.NewDelimitedReader(conn, pv.Config.Privval.MaxMsgSize).ReadMsg(&resp)
+				return err == nil && resp.GetPingResponse() != nil
+			}
+
+			// A second validator connects while the first one is served.
+			first, err := net.Dial("unix", sockPath)
+			assert.NoError(t, err)
+			defer first.Close()
+			assert.True(t, ping(first))
+			second, err := net.Dial("unix", sockPath)
+			assert.NoError(t, err)
+			defer second.Close()
+
+			// Only one of them is ever served.
+			if policy == config.ListenPolicyReject {
+				assert.False(t, ping(second))
+				assert.True(t, ping(first))
+			} else {
+				assert.True(t, ping(second))
+				assert.False(t, ping(first))
+			}
+		})
+	}
+}
+
+// testTCPConnPair returns both ends of a loopback TCP connection.
+func testTCPConnPair(t *testing.T) (*net.TCPConn, *net.TCPConn) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	acceptCh := make(chan net.Conn, 1)
+	go func() {
+		conn, _ := listener.Accept()
+		acceptCh <- conn
+	}()
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	assert.NoError(t, err)
+
+	return conn.(*net.TCPConn), (<-acceptCh).(*net.TCPConn)
+}
+
+func TestRunTruncatedMessage(t *testing.T) {
+	pv := mockSCFilePV(t)
+	signerConn, validatorConn := testTCPConnPair(t)
+	defer validatorConn.Close()
+	vc := testValidatorConn(t, signerConn)
+	pv.dial = func(ctx context.Context, address string) (net.Conn, error) {
+		return nil, errors.New("dialing aborted")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		pv.run(context.Background(), vc)
+		close(done)
+	}()
+
+	// Announce a message of 10 bytes, but only send 3 of them.
+	_, err := validatorConn.Write([]byte{10, 1, 2, 3})
+	assert.NoError(t, err)
+	err = validatorConn.CloseWrite()
+	assert.NoError(t, err)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected run() to return within 1s")
+	}
+
+	// No response must have been written before the connection was closed.
+	resp, err := ioutil.ReadAll(validatorConn)
+	assert.NoError(t, err)
+	assert.Empty(t, resp)
+}
+
+func TestRunEmptyMessage(t *testing.T) {
+	pv := mockSCFilePV(t)
+	signerConn, validatorConn := testTCPConnPair(t)
+	vc := testValidatorConn(t, signerConn)
+	pv.dial = func(ctx context.Context, address string) (net.Conn, error) {
+		return nil, errors.New("dialing aborted")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		pv.run(context.Background(), vc)
+		close(done)
+	}()
+
+	// The empty message is responded to with an error, and the loop keeps serving
+	// the following PingRequest.
+	w := tm_protoio.NewDelimitedWriter(validatorConn)
+	_, err := w.WriteMsg(&tm_privvalproto.Message{})
+	assert.NoError(t, err)
+	_, err = w.WriteMsg(wrapMsg(&tm_privvalproto.PingRequest{}))
+	assert.NoError(t, err)
+
+	var resp tm_privvalproto.Message
+	r := tm_protoio.NewDelimitedReader(validatorConn, pv.Config.Privval.MaxMsgSize)
+	_, err = r.ReadMsg(&resp)
+	assert.NoError(t, err)
+	assert.Equal(t, CodeUnsupportedMsg, resp.GetPubKeyResponse().GetError().GetCode())
+
+	_, err = r.ReadMsg(&resp)
+	assert.NoError(t, err)
+	assert.IsType(t, &tm_privvalproto.Message_PingResponse{}, resp.Sum)
+
+	validatorConn.Close()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected run() to return within 1s")
+	}
+}
+
+func TestRunRecoverFromPanic(t *testing.T) {
+	pv := mockSCFilePV(t)
+	signerConn, validatorConn := testTCPConnPair(t)
+	vc := testValidatorConn(t, signerConn)
+	pv.dial = func(ctx context.Context, address string) (net.Conn, error) {
+		return nil, errors.New("dialing aborted")
+	}
+
+	// Only the first request panics.
+	var handled int
+	pv.handle = func(ctx context.Context, msg *tm_privvalproto.Message, pv *SCFilePV) (*tm_privvalproto.Message, error) {
+		handled++
+		if handled == 1 {
+			panic("test panic")
+		}
+		return HandleRequest(ctx, msg, pv)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		pv.run(context.Background(), vc)
+		close(done)
+	}()
+
+	// The first PingRequest panics and isn't responded to, but the loop keeps
+	// serving the second one.
+	w := tm_protoio.NewDelimitedWriter(validatorConn)
+	_, err := w.WriteMsg(wrapMsg(&tm_privvalproto.PingRequest{}))
+	assert.NoError(t, err)
+	_, err = w.WriteMsg(wrapMsg(&tm_privvalproto.PingRequest{}))
+	assert.NoError(t, err)
+
+	var resp tm_privvalproto.Message
+	r := tm_protoio.NewDelimitedReader(validatorConn, pv.Config.Privval.MaxMsgSize)
+	_, err = r.ReadMsg(&resp)
+	assert.NoError(t, err)
+	assert.IsType(t, &tm_privvalproto.Message_PingResponse{}, resp.Sum)
+
+	validatorConn.Close()
+	select {
+	case <-done:
+		assert.Equal(t, 2, handled)
+		assert.Equal(t, 0, pv.panics)
+	case <-time.After(time.Second):
+		t.Fatal("expected run() to return within 1s")
+	}
+}
+
+func TestRunTooManyPanics(t *testing.T) {
+	pv := mockSCFilePV(t)
+	signerConn, validatorConn := testTCPConnPair(t)
+	defer validatorConn.Close()
+	vc := testValidatorConn(t, signerConn)
+	pv.handle = func(ctx context.Context, msg *tm_privvalproto.Message, pv *SCFilePV) (*tm_privvalproto.Message, error) {
+		panic("test panic")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		pv.run(context.Background(), vc)
+		close(done)
+	}()
+
+	w := tm_protoio.NewDelimitedWriter(validatorConn)
+	for i := 0; i < maxPanicsInARow; i++ {
+		_, err := w.WriteMsg(wrapMsg(&tm_privvalproto.PingRequest{}))
+		assert.NoError(t, err)
+	}
+
+	select {
+	case <-done:
+		assert.Equal(t, maxPanicsInARow, pv.panics)
+
+		// The validator retires to the last rank before shutting down.
+		assert.Equal(t, pv.Config.Base.SetSize, pv.GetRank())
+		state, err := config.LoadOrGenState(pv.ConfigDir)
+		assert.NoError(t, err)
+		assert.Equal(t, pv.Config.Base.SetSize, state.LastRank)
+	case <-time.After(time.Second):
+		t.Fatal("expected run() to return within 1s")
+	}
+}
+
+func TestRunIdleTimeout(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.Config.Base.RetryDialAfter = "1s"
+	signerConn, validatorConn := net.Pipe()
+	defer validatorConn.Close()
+	vc := testValidatorConn(t, signerConn)
+
+	var dials int
+	pv.dial = func(ctx context.Context, address string) (net.Conn, error) {
+		dials++
+		return nil, errors.New("dialing aborted")
+	}
+
+	start := time.Now()
+	done := make(chan struct{})
+	go func() {
+		pv.run(context.Background(), vc)
+		close(done)
+	}()
+
+	// A PingRequest counts as activity and pushes the read deadline back.
+	time.Sleep(500 * time.Millisecond)
+	w := tm_protoio.NewDelimitedWriter(validatorConn)
+	_, err := w.WriteMsg(wrapMsg(&tm_privvalproto.PingRequest{}))
+	assert.NoError(t, err)
+
+	var resp tm_privvalproto.Message
+	r := tm_protoio.NewDelimitedReader(validatorConn, pv.Config.Privval.MaxMsgSize)
+	_, err = r.ReadMsg(&resp)
+	assert.NoError(t, err)
+
+	// The validator stays silent from now on, so SignCTRL reconnects.
+	select {
+	case <-done:
+		assert.Equal(t, 1, dials)
+		assert.GreaterOrEqual(t, int64(time.Since(start)), int64(1500*time.Millisecond))
+	case <-time.After(3 * time.Second):
+		t.Fatal("expected run() to return within 3s")
+	}
+}
+
+func TestRunWriteTimeout(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.Config.Base.WriteTimeout = "1s"
+	signerConn, validatorConn := net.Pipe()
+	defer validatorConn.Close()
+	vc := testValidatorConn(t, signerConn)
+
+	var dials int
+	pv.dial = func(ctx context.Context, address string) (net.Conn, error) {
+		dials++
+		return nil, errors.New("dialing aborted")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		pv.run(context.Background(), vc)
+		close(done)
+	}()
+
+	// The validator sends a PingRequest, but never reads the response.
+	w := tm_protoio.NewDelimitedWriter(validatorConn)
+	_, err := w.WriteMsg(wrapMsg(&tm_privvalproto.PingRequest{}))
+	assert.NoError(t, err)
+
+	select {
+	case <-done:
+		assert.Equal(t, 1, dials)
+	case <-time.After(3 * time.Second):
+		t.Fatal("expected run() to return within 3s")
+	}
+}
+
+// testSizedPubKeyRequest returns a PubKeyRequest whose encoded size is exactly the
+// given size in bytes.
+func testSizedPubKeyRequest(t *testing.T, size int) *tm_privvalproto.Message {
+	t.Helper()
+	for n := size; n > 0; n-- {
+		msg := wrapMsg(&tm_privvalproto.PubKeyRequest{ChainId: strings.Repeat("a", n)})
+		if msg.Size() == size {
+			return msg
+		}
+	}
+	t.Fatalf("couldn't build PubKeyRequest of %v bytes", size)
+	return nil
+}
+
+func TestRunMaxMsgSize(t *testing.T) {
+	pv := mockSCFilePV(t)
+	pv.Config.Privval.MaxMsgSize = 1024
+	signerConn, validatorConn := testTCPConnPair(t)
+	vc := testValidatorConn(t, signerConn)
+	pv.dial = func(ctx context.Context, address string) (net.Conn, error) {
+		return nil, errors.New("dialing aborted")
+	}
+
+	done := make(chan struct{})
+	go func() {
+		pv.run(context.Background(), vc)
+		close(done)
+	}()
+
+	// A message right at the limit is still handled and responded to.
+	w := tm_protoio.NewDelimitedWriter(validatorConn)
+	_, err := w.WriteMsg(testSizedPubKeyRequest(t, pv.Config.Privval.MaxMsgSize))
+	assert.NoError(t, err)
+
+	// The limit only applies to the requests SignCTRL reads. The response's error
+	// echoes the long chain ID, so it is read with Tendermint's own limit of 10 KiB.
+	var resp tm_privvalproto.Message
+	r := tm_protoio.NewDelimitedReader(validatorConn, 1024*10)
+	_, err = r.ReadMsg(&resp)
+	assert.NoError(t, err)
+	assert.IsType(t, &tm_privvalproto.Message_PubKeyResponse{}, resp.Sum)
+
+	// A message exceeding the limit by one byte can't be read, so SignCTRL drops the
+	// connection without responding.
+	_, err = w.WriteMsg(testSizedPubKeyRequest(t, pv.Config.Privval.MaxMsgSize+1))
+	assert.NoError(t, err)
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected run() to return within 1s")
+	}
+
+	_, err = r.ReadMsg(&resp)
+	assert.Error(t, err)
+	validatorConn.Close()
+}
+
+func TestConfigDir(t *testing.T) {
+	// The files are only written to the given configuration directory, never to the
+	// default one.
+	home := t.TempDir()
+	defer os.Setenv("HOME", os.Getenv("HOME"))
+	os.Setenv("HOME", home)
+	cfgDir := t.TempDir()
+	pv := mockSCFilePVIn(t, cfgDir)
+	pv.BaseSignCtrled.SetRank(2)
+	assert.NoError(t, pv.Promote())
+	assert.FileExists(t, config.StateFilePath(cfgDir))
+	assert.NoDirExists(t, filepath.Join(home, ".signctrl"))
+}
+
+func TestPersistedRank(t *testing.T) {
+	cfgDir := t.TempDir()
+
+	// Promote from rank 2 to rank 1.
+	pv := mockSCFilePVIn(t, cfgDir)
+	pv.BaseSignCtrled.SetRank(2)
+	err := pv.Promote()
+	assert.NoError(t, err)
+
+	// restart creates a new SCFilePV starting on rank 2 from the persisted state.
+	restart := func(ignorePersistedRank bool) (*SCFilePV, *bytes.Buffer) {
+		state, err := config.LoadOrGenState(cfgDir)
+		assert.NoError(t, err)
+		var buf bytes.Buffer
+		cfg := testConfig(t)
+		cfg.Base.StartRank = 2
+		cfg.Base.IgnorePersistedRank = ignorePersistedRank
+		pv, err := NewSCFilePV(types.NewSyncLogger(&buf, "", 0), cfgDir, cfg, state, testFilePV(t), &http.Server{})
+		assert.NoError(t, err)
+		pv.initRank()
+		return pv, &buf
+	}
+
+	// The promotion survives the restart.
+	pv, buf := restart(false)
+	assert.Equal(t, 1, pv.GetRank())
+	assert.Contains(t, buf.String(), "Using rank 1 persisted in signctrl_state.json instead of start_rank 2")
+
+	// Unless the persisted rank is ignored deliberately.
+	pv, buf = restart(true)
+	assert.Equal(t, 2, pv.GetRank())
+	assert.Contains(t, buf.String(), "Using start_rank 2, as ignore_persisted_rank is enabled")
+
+	// Without a persisted rank, start_rank is used.
+	pv = mockSCFilePVIn(t, cfgDir)
+	pv.State.LastRank = 0
+	pv.Config.Base.StartRank = 2
+	pv.initRank()
+	assert.Equal(t, 2, pv.GetRank())
+
+	// A persisted rank outside of a shrunk set falls back to the last rank.
+	var logBuf bytes.Buffer
+	pv = mockSCFilePVIn(t, cfgDir)
+	pv.Logger = types.NewSyncLogger(&logBuf, "", 0)
+	pv.State.LastRank = 5
+	pv.initRank()
+	assert.Equal(t, 3, pv.GetRank())
+	assert.Contains(t, logBuf.String(), "Rank 5 persisted in signctrl_state.json isn't in the set of 3 anymore, using the last rank 3 instead")
+}
+
+func TestRestoreCounter(t *testing.T) {
+	cfgDir := t.TempDir()
+
+	// handleAt handles a vote request for the given height.
+	handleAt := func(pv *SCFilePV, height int64) {
+		req := testSignVoteRequest(t)
+		req.GetSignVoteRequest().Vote.Height = height
+		_, err := HandleRequest(context.Background(), req, pv)
+		assert.NoError(t, err)
+	}
+
+	// Miss three blocks in a row.
+	pv := testWatermarkSCFilePVIn(t, cfgDir)
+	pv.UnlockCounter()
+	for h := int64(2); h <= 4; h++ {
+		handleAt(pv, h)
+	}
+	assert.Equal(t, 3, pv.GetMissedInARow())
+
+	// The counter is persisted on every change.
+	state, err := config.LoadOrGenState(cfgDir)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, state.MissedInARow)
+	assert.Equal(t, int64(4), state.CurrentHeight)
+	assert.False(t, state.CounterLocked)
+
+	// restart creates a new SCFilePV from the persisted state.
+	restart := func() *SCFilePV {
+		pv := testWatermarkSCFilePV(t)
+		pv.State = state
+		pv.restoreCounter()
+		return pv
+	}
+
+	// After a restart, the streak continues.
+	pv = restart()
+	assert.Equal(t, 3, pv.GetMissedInARow())
+	assert.Equal(t, int64(4), pv.GetCurrentHeight())
+	assert.False(t, pv.IsCounterLocked())
+	handleAt(pv, 5)
+	assert.Equal(t, 4, pv.GetMissedInARow())
+
+	// If the unchecked blocks could have exceeded the threshold, the counter is stale.
+	pv = restart()
+	handleAt(pv, 4+int64(pv.GetThreshold()-3)+1)
+	assert.Equal(t, 0, pv.GetMissedInARow())
+	assert.True(t, pv.IsCounterLocked())
+
+	// Ignoring the persisted rank ignores the persisted counter as well.
+	pv = testWatermarkSCFilePV(t)
+	pv.State = state
+	pv.Config.Base.IgnorePersistedRank = true
+	pv.restoreCounter()
+	assert.Equal(t, 0, pv.GetMissedInARow())
+	assert.True(t, pv.IsCounterLocked())
+}
+
+func TestRestorePause(t *testing.T) {
+	cfgDir := t.TempDir()
+
+	// The pause is persisted.
+	pv := mockSCFilePVIn(t, cfgDir)
+	pv.BaseSignCtrled.Pause("chain upgrade")
+	state, err := config.LoadOrGenState(cfgDir)
+	assert.NoError(t, err)
+	assert.True(t, state.Paused)
+	assert.Equal(t, "chain upgrade", state.PauseReason)
+
+	// After a restart, the monitoring is still paused.
+	pv = mockSCFilePVIn(t, cfgDir)
+	pv.State = state
+	pv.restorePause()
+	assert.True(t, pv.IsPaused())
+	assert.Equal(t, "chain upgrade", pv.GetPauseReason())
+
+	// Resuming is persisted as well.
+	pv.BaseSignCtrled.Resume()
+	state, err = config.LoadOrGenState(cfgDir)
+	assert.NoError(t, err)
+	assert.False(t, state.Paused)
+	assert.Empty(t, state.PauseReason)
+	assert.True(t, state.CounterLocked)
+}
+
+func TestPersistPromoteReason(t *testing.T) {
+	cfgDir := t.TempDir()
+
+	pv := mockSCFilePVIn(t, cfgDir)
+	pv.BaseSignCtrled.SetRank(3)
+	assert.NoError(t, pv.PromoteWithReason(types.PromoteReasonManual))
+	state, err := config.LoadOrGenState(cfgDir)
+	assert.NoError(t, err)
+	assert.Equal(t, "manual", state.LastPromoteReason)
+	assert.Equal(t, "manual", pv.status().PromoteReason)
+
+	// A demotion keeps the reason of the last promotion.
+	assert.NoError(t, pv.BaseSignCtrled.Demote(3))
+	state, err = config.LoadOrGenState(cfgDir)
+	assert.NoError(t, err)
+	assert.Equal(t, "manual", state.LastPromoteReason)
+}
+
+func TestCheckLastSigned(t *testing.T) {
+	// On rank 2, no signature for too long promotes the validator.
+	pv := mockSCFilePV(t)
+	pv.BaseSignCtrled.SetRank(2)
+	pv.UnlockCounter()
+	pv.SetThresholdDuration(time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	err := pv.checkLastSigned()
+	assert.ErrorIs(t, err, types.ErrThresholdExceeded)
+	assert.Equal(t, 1, pv.GetRank())
+
+	// On rank 1, the validator must shut down.
+	time.Sleep(10 * time.Millisecond)
+	err = pv.checkLastSigned()
+	assert.ErrorIs(t, err, types.ErrMustShutdown)
+
+	// Unless it rejoins the set on the last rank.
+	pv.Config.Base.Rejoin = true
+	pv.UnlockCounter()
+	pv.SetThresholdDuration(time.Millisecond)
+	time.Sleep(10 * time.Millisecond)
+	err = pv.checkLastSigned()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, pv.GetRank())
+	assert.True(t, pv.IsCounterLocked())
+}
+
+func TestRankStrategy(t *testing.T) {
+	base := testConfig(t).Base
+	assert.Equal(t, types.InARowStrategy{}, rankStrategy(base))
+
+	base.WindowSize = 10
+	base.WindowThreshold = 7
+	assert.Equal(t, types.AnyStrategy{types.InARowStrategy{}, types.WindowStrategy{Threshold: 7}}, rankStrategy(base))
+
+	base.RankStrategy = types.RankStrategyWindow
+	assert.Equal(t, types.WindowStrategy{Threshold: 7}, rankStrategy(base))
+}
+
+func TestLogThresholdExceeded(t *testing.T) {
+	pv := mockSCFilePV(t)
+	var buf bytes.Buffer
+	pv.Logger = types.NewSyncLogger(&buf, "", 0)
+
+	pv.logThresholdExceeded(&types.ThresholdExceededError{Height: 10, OldRank: 3, NewRank: 2, Missed: 5, Threshold: 5})
+	assert.Contains(t, buf.String(), "Threshold exceeded at block height 10 (5/5), promoted validator from rank 3 to rank 2")
+	pv.logThresholdExceeded(fmt.Errorf("wrapped: %w", &types.MustShutdownError{Height: 11}))
+	assert.Contains(t, buf.String(), "final block height: 11")
+
+	// Other errors aren't logged.
+	buf.Reset()
+	pv.logThresholdExceeded(types.ErrCounterLocked)
+	assert.Empty(t, buf.String())
+}
+
+func TestMustShutdown(t *testing.T) {
+	assert.True(t, mustShutdown(&types.MustShutdownError{Height: 1}))
+	assert.True(t, mustShutdown(ErrRankObsolete))
+	assert.False(t, mustShutdown(&types.ThresholdExceededError{}))
+}
+
+func TestWatchLastSigned(t *testing.T) {
+	pv := mockSCFilePV(t)
+	clock := clocktest.New(time.Now())
+	pv.SetClock(clock)
+	pv.UnlockCounter()
+	pv.SetThresholdDuration(time.Minute)
+
+	done := make(chan struct{})
+	go func() {
+		pv.watchLastSigned(context.Background())
+		close(done)
+	}()
+	assert.Eventually(t, func() bool { return clock.Waiters() == 1 }, time.Second, time.Millisecond)
+
+	// Rank 1 retires to the last rank once it hasn't signed for too long, even
+	// without any blocks arriving, which the fake clock triggers without waiting.
+	clock.Advance(time.Minute)
+	select {
+	case <-done:
+		assert.Equal(t, pv.Config.Base.SetSize, pv.GetRank())
+	case <-time.After(time.Second):
+		t.Fatal("expected watchLastSigned() to return within 1s")
+	}
+}
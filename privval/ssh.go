@@ -0,0 +1,34 @@
+package privval
+
+import (
+	"context"
+	"net"
+
+	"github.com/BlockscapeNetwork/signctrl/connection"
+)
+
+// openSSHTunnel sets up the tunnel through the configured SSH bastion host. The SSH
+// session itself is only established on the first dial.
+func (pv *SCFilePV) openSSHTunnel() error {
+	cfg := pv.Config.Privval
+	tunnel, err := connection.NewSSHTunnel(cfg.SSHHost, cfg.SSHUser, cfg.SSHKeyFile, cfg.SSHKnownHostsFile)
+	if err != nil {
+		return err
+	}
+	pv.sshTunnel = tunnel
+
+	return nil
+}
+
+// dialValidatorSSH keeps dialing the validator at the given address through the SSH
+// tunnel until success and returns the secret connection. It gives up once the
+// configured retry policy is exhausted, or right away if the validator doesn't use one
+// of the authorized keys. Dialing is aborted once the given context is canceled.
+func (pv *SCFilePV) dialValidatorSSH(ctx context.Context, address string) (net.Conn, error) {
+	authorizedKeys, err := connection.ParseAuthorizedKeys(pv.Config.Privval.AuthorizedKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	return connection.RetryDialSSH(ctx, pv.ConfigDir, address, pv.sshTunnel, authorizedKeys, retryPolicy(pv.baseConfig(), pv.retryConfig(), &pv.connStats), pv.Logger)
+}
@@ -0,0 +1,26 @@
+package privval
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSSHTunnel_InvalidKey(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Privval.SSHHost = "127.0.0.1:22"
+	cfg.Privval.SSHUser = "signctrl"
+	cfg.Privval.SSHKeyFile = "/nonexistent/id_ed25519"
+	cfg.Privval.SSHKnownHostsFile = "/nonexistent/known_hosts"
+
+	pv, err := NewSCFilePV(types.NewSyncLogger(ioutil.Discard, "", 0), t.TempDir(), cfg, testState(t), testFilePV(t), &http.Server{})
+	assert.NoError(t, err)
+	done, err := pv.transport.start(context.Background())
+	assert.Nil(t, done)
+	assert.Error(t, err)
+	assert.Nil(t, pv.sshTunnel)
+}
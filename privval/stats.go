@@ -0,0 +1,68 @@
+package privval
+
+import (
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	tm_prototypes "github.com/tendermint/tendermint/proto/tendermint/types"
+)
+
+const (
+	// statsLogInterval determines the number of blocks after which the signing
+	// statistics are logged.
+	statsLogInterval = 1000
+)
+
+// SigningStats defines the statistics of the messages signed since SignCTRL started.
+type SigningStats struct {
+	Prevotes         int64     `json:"prevotes"`
+	Precommits       int64     `json:"precommits"`
+	Proposals        int64     `json:"proposals"`
+	LastSignedHeight int64     `json:"last_signed_height"`
+	LastSignedAt     time.Time `json:"last_signed_at"`
+}
+
+// GetSigningStats returns the statistics of the messages signed since SignCTRL
+// started.
+func (pv *SCFilePV) GetSigningStats() SigningStats {
+	pv.statsMtx.RLock()
+	defer pv.statsMtx.RUnlock()
+	return pv.stats
+}
+
+// GetConnStats returns the statistics of the connections to the validators since
+// SignCTRL started.
+func (pv *SCFilePV) GetConnStats() types.ConnStats {
+	return pv.connStats.Snapshot()
+}
+
+// recordSignature adds a message of the given type signed for the given height to the
+// signing statistics.
+func (pv *SCFilePV) recordSignature(msgType tm_prototypes.SignedMsgType, height int64) {
+	pv.statsMtx.Lock()
+	defer pv.statsMtx.Unlock()
+
+	switch msgType {
+	case tm_prototypes.PrevoteType:
+		pv.stats.Prevotes++
+	case tm_prototypes.PrecommitType:
+		pv.stats.Precommits++
+	case tm_prototypes.ProposalType:
+		pv.stats.Proposals++
+	}
+	pv.stats.LastSignedHeight = height
+	pv.stats.LastSignedAt = pv.clock.Now()
+}
+
+// logSigningStats logs the signing statistics every statsLogInterval blocks.
+func (pv *SCFilePV) logSigningStats(height int64) {
+	if height%statsLogInterval != 0 {
+		return
+	}
+
+	stats := pv.GetSigningStats()
+	pv.Logger.Info(
+		"Signed %v prevotes, %v precommits and %v proposals since start (last signed height: %v)",
+		stats.Prevotes, stats.Precommits, stats.Proposals, stats.LastSignedHeight,
+	)
+}
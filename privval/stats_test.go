@@ -0,0 +1,102 @@
+package privval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	tm_prototypes "github.com/tendermint/tendermint/proto/tendermint/types"
+)
+
+func TestRecordSignature(t *testing.T) {
+	pv := mockSCFilePV(t)
+	assert.Equal(t, SigningStats{}, pv.GetSigningStats())
+
+	pv.recordSignature(tm_prototypes.PrevoteType, 1)
+	pv.recordSignature(tm_prototypes.PrecommitType, 1)
+	pv.recordSignature(tm_prototypes.ProposalType, 2)
+	pv.recordSignature(tm_prototypes.PrevoteType, 2)
+
+	stats := pv.GetSigningStats()
+	assert.Equal(t, int64(2), stats.Prevotes)
+	assert.Equal(t, int64(1), stats.Precommits)
+	assert.Equal(t, int64(1), stats.Proposals)
+	assert.Equal(t, int64(2), stats.LastSignedHeight)
+	assert.WithinDuration(t, time.Now(), stats.LastSignedAt, time.Second)
+}
+
+func TestSigningStatsJSON(t *testing.T) {
+	stats := SigningStats{Prevotes: 1, Precommits: 2, Proposals: 3, LastSignedHeight: 4, LastSignedAt: time.Now().UTC()}
+	bz, err := json.Marshal(stats)
+	assert.NoError(t, err)
+
+	var decoded SigningStats
+	err = json.Unmarshal(bz, &decoded)
+	assert.NoError(t, err)
+	assert.True(t, stats.LastSignedAt.Equal(decoded.LastSignedAt))
+	decoded.LastSignedAt = stats.LastSignedAt
+	assert.Equal(t, stats, decoded)
+}
+
+func TestLogSigningStats(t *testing.T) {
+	pv := mockSCFilePV(t)
+	var buf bytes.Buffer
+	pv.Logger = types.NewSyncLogger(&buf, "", 0)
+	pv.recordSignature(tm_prototypes.PrevoteType, 999)
+
+	pv.logSigningStats(999)
+	assert.Empty(t, buf.String())
+
+	pv.logSigningStats(statsLogInterval)
+	assert.Contains(t, buf.String(), "Signed 1 prevotes, 0 precommits and 0 proposals")
+}
+
+func TestHandleSignRequest_SigningStats(t *testing.T) {
+	pv := testWatermarkSCFilePV(t)
+
+	_, err := HandleRequest(context.Background(), testSignProposalRequest(t), pv)
+	assert.NoError(t, err)
+	req := testSignVoteRequest(t)
+	_, err = HandleRequest(context.Background(), req, pv)
+	assert.NoError(t, err)
+
+	// Replays don't count, as nothing is signed.
+	replay := testSignVoteRequest(t)
+	replay.GetSignVoteRequest().Vote.Timestamp = req.GetSignVoteRequest().Vote.Timestamp
+	_, err = HandleRequest(context.Background(), replay, pv)
+	assert.NoError(t, err)
+
+	// Refused requests don't count either.
+	_, err = HandleRequest(context.Background(), testSignProposalRequest(t), pv)
+	assert.Error(t, err)
+
+	stats := pv.GetSigningStats()
+	assert.Equal(t, int64(0), stats.Prevotes)
+	assert.Equal(t, int64(1), stats.Precommits)
+	assert.Equal(t, int64(1), stats.Proposals)
+	assert.Equal(t, int64(2), stats.LastSignedHeight)
+}
+
+func TestGetConnStats(t *testing.T) {
+	pv := mockSCFilePV(t)
+	assert.Equal(t, types.ConnStats{}, pv.GetConnStats())
+
+	local, remote := net.Pipe()
+	defer remote.Close()
+	conn := pv.connStats.Wrap(local)
+	defer conn.Close()
+	go func() { _, _ = remote.Write([]byte("ping")) }()
+	_, err := conn.Read(make([]byte, 4))
+	assert.NoError(t, err)
+	pv.connStats.AddReconnect()
+
+	stats := pv.GetConnStats()
+	assert.Equal(t, uint64(4), stats.BytesRead)
+	assert.Equal(t, uint64(1), stats.Reconnects)
+	assert.Equal(t, stats, pv.status().Conn)
+}
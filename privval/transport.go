@@ -0,0 +1,104 @@
+package privval
+
+import (
+	"context"
+	"sync"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	"github.com/BlockscapeNetwork/signctrl/connection"
+)
+
+// transport delivers the validator's requests to the SCFilePV and its responses back
+// to the validator. All transports hand requests to SCFilePV.safeHandleRequest, so
+// they share the same rank checks and double-signing protection.
+type transport interface {
+	// start starts serving the validator's requests without blocking. The returned
+	// channel is closed once serving has stopped.
+	start(ctx context.Context) (<-chan struct{}, error)
+
+	// stop stops serving the validator's requests and unblocks pending reads and
+	// writes.
+	stop()
+}
+
+// socketTransport serves the validator's requests via Tendermint's raw socket
+// protocol, either by dialing the validators or by listening for the validator. The
+// connections are either secret connections or, for the mtls transport, mutual TLS
+// connections.
+type socketTransport struct {
+	pv *SCFilePV
+}
+
+// start dials all validators and runs a main loop for each of them. In listen mode,
+// there is only a single connection accepted from the validator. With failover
+// enabled, there is only a single connection failing over between the validators.
+// Implements the transport interface.
+func (t *socketTransport) start(ctx context.Context) (<-chan struct{}, error) {
+	pv := t.pv
+	pv.conns = nil
+	pv.exhaustedConns = 0
+	if pv.Config.Privval.Transport == config.TransportMTLS {
+		if err := pv.loadTLSCerts(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if pv.Config.Privval.SSHHost != "" {
+		if err := pv.openSSHTunnel(); err != nil {
+			return nil, err
+		}
+	}
+	if pv.Config.Privval.Mode == config.ModeListen {
+		listener, err := connection.Listen(pv.Config.Privval.ListenAddress)
+		if err != nil {
+			return nil, err
+		}
+		pv.listener = listener
+		if pv.acceptor, err = pv.newAcceptor(); err != nil {
+			listener.Close()
+			return nil, err
+		}
+		pv.conns = append(pv.conns, &validatorConn{address: pv.Config.Privval.ListenAddress})
+	} else if pv.Config.Base.Failover {
+		pv.conns = append(pv.conns, pv.newFailoverConn())
+	} else {
+		for _, addr := range pv.Config.Base.ListenAddresses() {
+			pv.conns = append(pv.conns, &validatorConn{address: addr})
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, vc := range pv.conns {
+		wg.Add(1)
+		go func(vc *validatorConn) {
+			defer wg.Done()
+			pv.serve(ctx, vc)
+		}(vc)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	return done, nil
+}
+
+// stop closes the listener and all connections to the validators. The responses still
+// in flight are written before, so that the validator doesn't see them truncated.
+// Implements the transport interface.
+func (t *socketTransport) stop() {
+	pv := t.pv
+	if pv.listener != nil {
+		if err := pv.listener.Close(); err != nil {
+			pv.Logger.Debug("couldn't close listener: %v", err)
+		}
+	}
+	pv.drainConns()
+	pv.closeConns()
+	if pv.sshTunnel != nil {
+		if err := pv.sshTunnel.Close(); err != nil {
+			pv.Logger.Debug("couldn't close SSH tunnel: %v", err)
+		}
+	}
+}
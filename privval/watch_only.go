@@ -0,0 +1,175 @@
+package privval
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	tm_crypto "github.com/tendermint/tendermint/crypto"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_privval "github.com/tendermint/tendermint/privval"
+	tm_prototypes "github.com/tendermint/tendermint/proto/tendermint/types"
+	tm_types "github.com/tendermint/tendermint/types"
+)
+
+const (
+	// PubKeyFile is the file name of the file that holds the validator's public key
+	// for watch-only mode. It has the format of the priv_validator_key.json file
+	// without the private key.
+	PubKeyFile = "pub_validator_key.json"
+)
+
+var (
+	// ErrWatchOnly is returned if a watch-only node is asked to sign.
+	ErrWatchOnly = errors.New("watch-only node has no private key to sign with")
+)
+
+// PubKeyFilePath returns the absolute path to the pub_validator_key.json file.
+func PubKeyFilePath(cfgDir string) string {
+	return filepath.Join(cfgDir, PubKeyFile)
+}
+
+// WatchOnlyPV is a PrivValidator that only knows the validator's public key. It is
+// used by backups in watch-only mode, which don't keep the private key on disk until
+// they are promoted to rank 1.
+// Implements the tm_types.PrivValidator interface.
+type WatchOnlyPV struct {
+	pubKey tm_crypto.PubKey
+}
+
+// WatchOnlyPV must implement the tm_types.PrivValidator interface.
+var _ tm_types.PrivValidator = new(WatchOnlyPV)
+
+// loadFilePVKey loads the contents of a priv_validator_key.json file and verifies
+// that its address matches its keys. The private key may be missing.
+func loadFilePVKey(path string) (tm_privval.FilePVKey, error) {
+	bz, err := ioutil.ReadFile(path)
+	if err != nil {
+		return tm_privval.FilePVKey{}, err
+	}
+
+	var key tm_privval.FilePVKey
+	if err := tm_json.Unmarshal(bz, &key); err != nil {
+		return tm_privval.FilePVKey{}, fmt.Errorf("couldn't parse %v: %v", path, err)
+	}
+	if key.PubKey == nil {
+		return tm_privval.FilePVKey{}, fmt.Errorf("%v contains no public key", path)
+	}
+	if !bytes.Equal(key.Address, key.PubKey.Address()) {
+		return tm_privval.FilePVKey{}, fmt.Errorf("address in %v doesn't match its public key", path)
+	}
+	if key.PrivKey != nil && !key.PrivKey.PubKey().Equals(key.PubKey) {
+		return tm_privval.FilePVKey{}, fmt.Errorf("private key in %v doesn't match its public key", path)
+	}
+
+	return key, nil
+}
+
+// LoadWatchOnlyPV loads the validator's public key from the given file, which is
+// either a pub_validator_key.json file or a priv_validator_key.json file. Any private
+// key in the file is ignored.
+func LoadWatchOnlyPV(path string) (*WatchOnlyPV, error) {
+	key, err := loadFilePVKey(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WatchOnlyPV{pubKey: key.PubKey}, nil
+}
+
+// GetPubKey returns the validator's public key.
+// Implements the tm_types.PrivValidator interface.
+func (pv *WatchOnlyPV) GetPubKey() (tm_crypto.PubKey, error) {
+	return pv.pubKey, nil
+}
+
+// SignVote always fails, as there is no private key to sign with.
+// Implements the tm_types.PrivValidator interface.
+func (pv *WatchOnlyPV) SignVote(chainID string, vote *tm_prototypes.Vote) error {
+	return ErrWatchOnly
+}
+
+// SignProposal always fails, as there is no private key to sign with.
+// Implements the tm_types.PrivValidator interface.
+func (pv *WatchOnlyPV) SignProposal(chainID string, proposal *tm_prototypes.Proposal) error {
+	return ErrWatchOnly
+}
+
+// loadFilePV loads a FilePV from the given key and state files. Unlike
+// tm_privval.LoadFilePV, it returns an error instead of exiting the process if the
+// files can't be loaded. A missing state file is treated as a fresh state.
+func loadFilePV(keyPath, statePath string) (*tm_privval.FilePV, error) {
+	key, err := loadFilePVKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	if key.PrivKey == nil {
+		return nil, fmt.Errorf("%v contains no private key", keyPath)
+	}
+	pv := tm_privval.NewFilePV(key.PrivKey, keyPath, statePath)
+
+	bz, err := ioutil.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return pv, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var state tm_privval.FilePVLastSignState
+	if err := tm_json.Unmarshal(bz, &state); err != nil {
+		return nil, fmt.Errorf("couldn't parse %v: %v", statePath, err)
+	}
+	pv.LastSignState.Height = state.Height
+	pv.LastSignState.Round = state.Round
+	pv.LastSignState.Step = state.Step
+	pv.LastSignState.Signature = state.Signature
+	pv.LastSignState.SignBytes = state.SignBytes
+
+	return pv, nil
+}
+
+// loadKey replaces the watch-only signer by a FilePV once the node is promoted to
+// rank 1. The key_hook is run first, so that operators can make the private key
+// available, e.g. by mounting an encrypted volume. Like the other hooks, it is killed
+// once the hooks timeout is over. The private key must belong to the public key the
+// node has been watching with.
+func (pv *SCFilePV) loadKey() error {
+	cfg := pv.Config.Privval
+	if cfg.KeyHook != "" {
+		pv.Logger.Info("Running key_hook to make the private key available...")
+		timeout := config.GetDuration(pv.hooksConfig().Timeout)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		out, err := exec.CommandContext(ctx, "sh", "-c", cfg.KeyHook).CombinedOutput()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("killed after %v", timeout)
+		}
+		if err != nil {
+			return fmt.Errorf("key_hook failed: %v (%v)", err, strings.TrimSpace(string(out)))
+		}
+	}
+
+	keyPath := cfg.KeyFile
+	if keyPath == "" {
+		keyPath = ResolveKeyFilePath(pv.ConfigDir, cfg)
+	}
+	signer, err := loadFilePV(keyPath, ResolveStateFilePath(pv.ConfigDir, cfg))
+	if err != nil {
+		return err
+	}
+
+	pub, _ := pv.TMFilePV.GetPubKey()
+	if signerPub, _ := signer.GetPubKey(); !signerPub.Equals(pub) {
+		return fmt.Errorf("private key in %v doesn't belong to the watched public key", keyPath)
+	}
+	pv.TMFilePV = signer
+
+	return nil
+}
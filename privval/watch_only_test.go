@@ -0,0 +1,190 @@
+package privval
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/stretchr/testify/assert"
+	tm_ed25519 "github.com/tendermint/tendermint/crypto/ed25519"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_privval "github.com/tendermint/tendermint/privval"
+)
+
+// testPubKeyFile writes the public part of the given key file to a
+// pub_validator_key.json file in the given directory.
+func testPubKeyFile(t *testing.T, cfgDir string, key tm_privval.FilePVKey) string {
+	t.Helper()
+	bz, err := tm_json.MarshalIndent(tm_privval.FilePVKey{Address: key.Address, PubKey: key.PubKey}, "", "  ")
+	assert.NoError(t, err)
+	err = ioutil.WriteFile(PubKeyFilePath(cfgDir), bz, 0600)
+	assert.NoError(t, err)
+	return PubKeyFilePath(cfgDir)
+}
+
+func TestPubKeyFilePath(t *testing.T) {
+	path := PubKeyFilePath("/tmp")
+	assert.Equal(t, "/tmp/pub_validator_key.json", path)
+}
+
+func TestLoadWatchOnlyPV(t *testing.T) {
+	cfgDir := t.TempDir()
+	filePV := tm_privval.GenFilePV(KeyFilePath(cfgDir), StateFilePath(cfgDir))
+	filePV.Save()
+
+	// From a pub_validator_key.json file.
+	pv, err := LoadWatchOnlyPV(testPubKeyFile(t, cfgDir, filePV.Key))
+	assert.NoError(t, err)
+	pub, err := pv.GetPubKey()
+	assert.NoError(t, err)
+	assert.Equal(t, filePV.Key.PubKey, pub)
+
+	// From a priv_validator_key.json file, ignoring the private key.
+	pv, err = LoadWatchOnlyPV(KeyFilePath(cfgDir))
+	assert.NoError(t, err)
+	pub, _ = pv.GetPubKey()
+	assert.Equal(t, filePV.Key.PubKey, pub)
+
+	// Address doesn't match the public key.
+	filePV.Key.Address = tm_ed25519.GenPrivKey().PubKey().Address()
+	pv, err = LoadWatchOnlyPV(testPubKeyFile(t, cfgDir, filePV.Key))
+	assert.Nil(t, pv)
+	assert.Error(t, err)
+
+	// Missing file.
+	pv, err = LoadWatchOnlyPV(filepath.Join(cfgDir, "nonexistent.json"))
+	assert.Nil(t, pv)
+	assert.Error(t, err)
+}
+
+func TestWatchOnlyPV_Sign(t *testing.T) {
+	pv := &WatchOnlyPV{pubKey: tm_ed25519.GenPrivKey().PubKey()}
+	assert.Equal(t, ErrWatchOnly, pv.SignVote("testchain", testVote(t)))
+	assert.Equal(t, ErrWatchOnly, pv.SignProposal("testchain", testProposal(t)))
+}
+
+func TestLoadFilePV(t *testing.T) {
+	cfgDir := t.TempDir()
+	filePV := tm_privval.GenFilePV(KeyFilePath(cfgDir), StateFilePath(cfgDir))
+	filePV.LastSignState.Height = 10
+	filePV.Save()
+
+	// The last sign state is loaded along with the key.
+	pv, err := loadFilePV(KeyFilePath(cfgDir), StateFilePath(cfgDir))
+	assert.NoError(t, err)
+	assert.Equal(t, filePV.Key.PrivKey, pv.Key.PrivKey)
+	assert.Equal(t, int64(10), pv.LastSignState.Height)
+
+	// A missing state file is a fresh state.
+	pv, err = loadFilePV(KeyFilePath(cfgDir), filepath.Join(cfgDir, "nonexistent.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), pv.LastSignState.Height)
+
+	// A key file without private key can't be signed with.
+	pv, err = loadFilePV(testPubKeyFile(t, cfgDir, filePV.Key), StateFilePath(cfgDir))
+	assert.Nil(t, pv)
+	assert.Error(t, err)
+}
+
+// testWatchOnlySCFilePV returns a mock SCFilePV on rank 2 in watch-only mode, whose
+// private key is in the priv_validator_key.json file in the returned directory.
+func testWatchOnlySCFilePV(t *testing.T) (*SCFilePV, string) {
+	t.Helper()
+	keyDir := t.TempDir()
+	filePV := tm_privval.GenFilePV(KeyFilePath(keyDir), StateFilePath(keyDir))
+	filePV.Save()
+
+	cfgDir := t.TempDir()
+
+	watchOnlyPV, err := LoadWatchOnlyPV(testPubKeyFile(t, cfgDir, filePV.Key))
+	assert.NoError(t, err)
+
+	pv := testWatermarkSCFilePV(t)
+	pv.ConfigDir = cfgDir
+	pv.TMFilePV = watchOnlyPV
+	pv.Config.Privval.WatchOnly = true
+	pv.Config.Privval.KeyFile = KeyFilePath(keyDir)
+	pv.BaseSignCtrled.SetRank(2)
+
+	return pv, keyDir
+}
+
+func TestHandleSignRequest_WatchOnly(t *testing.T) {
+	pv, _ := testWatchOnlySCFilePV(t)
+
+	// The public key is known on rank 2, but nothing is signed.
+	msg, err := HandleRequest(context.Background(), testPubKeyRequest(t), pv)
+	assert.NoError(t, err)
+	assert.Nil(t, msg.GetPubKeyResponse().GetError())
+
+	_, err = HandleRequest(context.Background(), testSignVoteRequest(t), pv)
+	assert.Error(t, err)
+	assert.IsType(t, &WatchOnlyPV{}, pv.TMFilePV)
+
+	// On promotion to rank 1, the private key is loaded and the vote is signed.
+	err = pv.Promote()
+	assert.NoError(t, err)
+	msg, err = HandleRequest(context.Background(), testSignVoteRequest(t), pv)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, msg.GetSignedVoteResponse().Vote.Signature)
+	assert.IsType(t, &tm_privval.FilePV{}, pv.TMFilePV)
+}
+
+func TestOnPromote_WatchOnlyKeyHook(t *testing.T) {
+	pv, keyDir := testWatchOnlySCFilePV(t)
+
+	// The hook makes the key file available on promotion, before anything is signed.
+	hiddenKeyFile := filepath.Join(keyDir, "hidden.json")
+	err := os.Rename(pv.Config.Privval.KeyFile, hiddenKeyFile)
+	assert.NoError(t, err)
+	pv.Config.Privval.KeyHook = fmt.Sprintf("cp %v %v", hiddenKeyFile, pv.Config.Privval.KeyFile)
+	err = pv.Promote()
+	assert.NoError(t, err)
+	assert.IsType(t, &tm_privval.FilePV{}, pv.TMFilePV)
+
+	msg, err := HandleRequest(context.Background(), testSignVoteRequest(t), pv)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, msg.GetSignedVoteResponse().Vote.Signature)
+}
+
+func TestOnPromote_WatchOnlyFailSafe(t *testing.T) {
+	// Missing key file.
+	pv, _ := testWatchOnlySCFilePV(t)
+	pv.Config.Privval.KeyFile = filepath.Join(t.TempDir(), "nonexistent.json")
+	err := pv.Promote()
+	assert.ErrorIs(t, err, types.ErrMustShutdown)
+	msg, err := HandleRequest(context.Background(), testSignVoteRequest(t), pv)
+	assert.Equal(t, types.ErrMustShutdown, err)
+	assert.Empty(t, msg.GetSignedVoteResponse().Vote.Signature)
+
+	// Failing key hook.
+	pv, _ = testWatchOnlySCFilePV(t)
+	pv.Config.Privval.KeyHook = "exit 1"
+	err = pv.Promote()
+	assert.ErrorIs(t, err, types.ErrMustShutdown)
+	assert.Contains(t, err.Error(), "key_hook failed: exit status 1")
+
+	// Hanging key hook.
+	pv, _ = testWatchOnlySCFilePV(t)
+	pv.Config.Hooks.Timeout = "1s"
+	pv.Config.Privval.KeyHook = "exec sleep 10"
+	start := time.Now()
+	err = pv.Promote()
+	assert.ErrorIs(t, err, types.ErrMustShutdown)
+	assert.Contains(t, err.Error(), "key_hook failed: killed after 1s")
+	assert.Less(t, int64(time.Since(start)), int64(5*time.Second))
+
+	// Private key of another validator.
+	pv, _ = testWatchOnlySCFilePV(t)
+	otherDir := t.TempDir()
+	tm_privval.GenFilePV(KeyFilePath(otherDir), StateFilePath(otherDir)).Save()
+	pv.Config.Privval.KeyFile = KeyFilePath(otherDir)
+	err = pv.Promote()
+	assert.ErrorIs(t, err, types.ErrMustShutdown)
+	assert.IsType(t, &WatchOnlyPV{}, pv.TMFilePV)
+}
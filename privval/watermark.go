@@ -0,0 +1,167 @@
+package privval
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/BlockscapeNetwork/signctrl/config"
+	tm_json "github.com/tendermint/tendermint/libs/json"
+	tm_prototypes "github.com/tendermint/tendermint/proto/tendermint/types"
+)
+
+const (
+	// WatermarkFile is the file name of the file that persists the height, round and
+	// step of the last message signed by SignCTRL.
+	WatermarkFile = "signctrl_watermark.json"
+
+	// PermWatermarkFile determines the default file permissions for the
+	// signctrl_watermark.json file.
+	PermWatermarkFile = os.FileMode(0600)
+)
+
+// Steps of the consensus round, in the order they are signed in.
+const (
+	stepNone      int8 = 0
+	stepPropose   int8 = 1
+	stepPrevote   int8 = 2
+	stepPrecommit int8 = 3
+)
+
+// msgTypeToStep returns the consensus step of the given message type.
+func msgTypeToStep(msgType tm_prototypes.SignedMsgType) int8 {
+	switch msgType {
+	case tm_prototypes.ProposalType:
+		return stepPropose
+	case tm_prototypes.PrevoteType:
+		return stepPrevote
+	case tm_prototypes.PrecommitType:
+		return stepPrecommit
+	default:
+		return stepNone
+	}
+}
+
+// Watermark is the height, round and step (HRS) of the last message signed by
+// SignCTRL. It protects against double-signing independently of the signing backend,
+// as no message is signed unless its HRS is higher than the watermark. The only
+// exception is the exact same message signed last, for which the signature is
+// returned again.
+type Watermark struct {
+	Height    int64  `json:"height"`
+	Round     int32  `json:"round"`
+	Step      int8   `json:"step"`
+	SignBytes []byte `json:"signbytes,omitempty"`
+	Signature []byte `json:"signature,omitempty"`
+
+	mtx  sync.Mutex
+	path string // The watermark is only kept in memory if empty.
+}
+
+// WatermarkFilePath returns the absolute path to the signctrl_watermark.json file.
+func WatermarkFilePath(cfgDir string) string {
+	return filepath.Join(cfgDir, WatermarkFile)
+}
+
+// LoadOrGenWatermark loads the watermark from the signctrl_watermark.json file if it
+// exists, or generates a new one.
+func LoadOrGenWatermark(cfgDir string) (*Watermark, error) {
+	path := WatermarkFilePath(cfgDir)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		wm := &Watermark{path: path}
+		if err := wm.save(); err != nil {
+			return nil, err
+		}
+
+		return wm, nil
+	}
+
+	bz, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Never fall back to an empty watermark if the file is corrupted, as that would
+	// allow for double-signing.
+	wm := &Watermark{path: path}
+	if err := tm_json.Unmarshal(bz, wm); err != nil {
+		return nil, fmt.Errorf("%v is corrupted and needs to be restored manually: %v", path, err)
+	}
+
+	return wm, nil
+}
+
+// save saves the watermark to the signctrl_watermark.json file.
+func (w *Watermark) save() error {
+	if w.path == "" {
+		return nil
+	}
+
+	bz, err := tm_json.MarshalIndent(w, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	return config.WriteFileAtomic(w.path, bz, PermWatermarkFile)
+}
+
+// check returns an error if the given HRS is not higher than the watermark.
+func (w *Watermark) check(height int64, round int32, step int8) error {
+	switch {
+	case height < w.Height:
+		return fmt.Errorf("height regression (got %v, last signed %v)", height, w.Height)
+	case height > w.Height:
+		return nil
+	case round < w.Round:
+		return fmt.Errorf("round regression at height %v (got %v, last signed %v)", height, round, w.Round)
+	case round > w.Round:
+		return nil
+	case step < w.Step:
+		return fmt.Errorf("step regression at height %v, round %v (got %v, last signed %v)", height, round, step, w.Step)
+	case step > w.Step:
+		return nil
+	default:
+		return fmt.Errorf("already signed at height %v, round %v, step %v", height, round, step)
+	}
+}
+
+// isReplay checks whether the given message is the exact same message that was
+// signed last.
+func (w *Watermark) isReplay(height int64, round int32, step int8, signBytes []byte) bool {
+	return height == w.Height && round == w.Round && step == w.Step &&
+		len(w.Signature) > 0 && bytes.Equal(signBytes, w.SignBytes)
+}
+
+// sign calls the given signing function if the given HRS is higher than the
+// watermark, and advances the watermark to it only if the message was signed
+// successfully. If the given sign bytes are the ones signed last, the signature
+// produced back then is returned without calling the signing function. Concurrent
+// calls are serialized, so that at most one message is signed for each HRS.
+func (w *Watermark) sign(height int64, round int32, step int8, signBytes []byte, signFn func() ([]byte, error)) ([]byte, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	if w.isReplay(height, round, step, signBytes) {
+		return w.Signature, nil
+	}
+	if err := w.check(height, round, step); err != nil {
+		return nil, err
+	}
+	sig, err := signFn()
+	if err != nil {
+		return nil, err
+	}
+
+	// The watermark is advanced in memory even if it couldn't be persisted, but the
+	// signature must not be handed out, as the watermark would be lost on restart.
+	w.Height, w.Round, w.Step = height, round, step
+	w.SignBytes, w.Signature = signBytes, sig
+	if err := w.save(); err != nil {
+		return nil, fmt.Errorf("couldn't save %v: %v", WatermarkFile, err)
+	}
+
+	return sig, nil
+}
@@ -0,0 +1,149 @@
+package privval
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tm_prototypes "github.com/tendermint/tendermint/proto/tendermint/types"
+)
+
+func TestMsgTypeToStep(t *testing.T) {
+	assert.Equal(t, stepPropose, msgTypeToStep(tm_prototypes.ProposalType))
+	assert.Equal(t, stepPrevote, msgTypeToStep(tm_prototypes.PrevoteType))
+	assert.Equal(t, stepPrecommit, msgTypeToStep(tm_prototypes.PrecommitType))
+	assert.Equal(t, stepNone, msgTypeToStep(tm_prototypes.UnknownType))
+}
+
+func TestWatermarkFilePath(t *testing.T) {
+	path := WatermarkFilePath("/tmp")
+	assert.Equal(t, "/tmp/signctrl_watermark.json", path)
+}
+
+func TestLoadOrGenWatermark(t *testing.T) {
+	cfgDir := t.TempDir()
+
+	// Generate.
+	wm, err := LoadOrGenWatermark(cfgDir)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), wm.Height)
+	assert.FileExists(t, WatermarkFilePath(cfgDir))
+
+	// Advancing the watermark persists it.
+	_, err = wm.sign(2, 1, stepPrevote, []byte("SignBytes"), func() ([]byte, error) { return []byte("Signature"), nil })
+	assert.NoError(t, err)
+
+	// Load.
+	wm, err = LoadOrGenWatermark(cfgDir)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), wm.Height)
+	assert.Equal(t, int32(1), wm.Round)
+	assert.Equal(t, stepPrevote, wm.Step)
+	assert.Equal(t, []byte("SignBytes"), wm.SignBytes)
+	assert.Equal(t, []byte("Signature"), wm.Signature)
+}
+
+func TestLoadOrGenWatermark_Corrupted(t *testing.T) {
+	cfgDir := t.TempDir()
+	wm, err := LoadOrGenWatermark(cfgDir)
+	assert.NoError(t, err)
+	_, err = wm.sign(2, 1, stepPrevote, []byte("SignBytes"), func() ([]byte, error) { return []byte("Signature"), nil })
+	assert.NoError(t, err)
+
+	// Truncate the file as if the machine lost power mid-write.
+	bz, err := ioutil.ReadFile(WatermarkFilePath(cfgDir))
+	assert.NoError(t, err)
+	for _, truncated := range [][]byte{bz[:len(bz)/2], {}} {
+		err = ioutil.WriteFile(WatermarkFilePath(cfgDir), truncated, PermWatermarkFile)
+		assert.NoError(t, err)
+
+		// Loading must fail instead of resetting the watermark.
+		wm, err = LoadOrGenWatermark(cfgDir)
+		assert.Nil(t, wm)
+		assert.Error(t, err)
+
+		loaded, err := ioutil.ReadFile(WatermarkFilePath(cfgDir))
+		assert.NoError(t, err)
+		assert.Equal(t, truncated, loaded)
+	}
+}
+
+func TestWatermarkCheck(t *testing.T) {
+	wm := &Watermark{Height: 2, Round: 1, Step: stepPrevote}
+
+	// Higher HRS.
+	assert.NoError(t, wm.check(3, 0, stepPropose))
+	assert.NoError(t, wm.check(2, 2, stepPropose))
+	assert.NoError(t, wm.check(2, 1, stepPrecommit))
+
+	// Equal HRS.
+	assert.Error(t, wm.check(2, 1, stepPrevote))
+
+	// Lower HRS.
+	assert.Error(t, wm.check(1, 5, stepPrecommit))
+	assert.Error(t, wm.check(2, 0, stepPrecommit))
+	assert.Error(t, wm.check(2, 1, stepPropose))
+}
+
+func TestWatermarkSign(t *testing.T) {
+	wm := &Watermark{}
+
+	// The watermark is advanced after a successful sign.
+	sig, err := wm.sign(2, 1, stepPrevote, []byte("SignBytes"), func() ([]byte, error) { return []byte("Signature"), nil })
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("Signature"), sig)
+	assert.Equal(t, int64(2), wm.Height)
+
+	// The watermark is not advanced if signing fails.
+	_, err = wm.sign(2, 1, stepPrecommit, []byte("OtherSignBytes"), func() ([]byte, error) { return nil, errors.New("signing failed") })
+	assert.Error(t, err)
+	assert.Equal(t, stepPrevote, wm.Step)
+
+	// The signing function is not called for equal or lower HRS.
+	called := false
+	signFn := func() ([]byte, error) { called = true; return []byte("OtherSignature"), nil }
+	_, err = wm.sign(2, 1, stepPrevote, []byte("OtherSignBytes"), signFn)
+	assert.Error(t, err)
+	_, err = wm.sign(1, 1, stepPrecommit, []byte("OtherSignBytes"), signFn)
+	assert.Error(t, err)
+	assert.False(t, called)
+
+	// The signature of identical sign bytes at the same HRS is returned again,
+	// without calling the signing function.
+	sig, err = wm.sign(2, 1, stepPrevote, []byte("SignBytes"), signFn)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("Signature"), sig)
+	assert.False(t, called)
+
+	// Identical sign bytes at a lower HRS are still refused.
+	_, err = wm.sign(2, 0, stepPrevote, []byte("SignBytes"), signFn)
+	assert.Error(t, err)
+	assert.False(t, called)
+}
+
+func TestWatermarkSign_Concurrent(t *testing.T) {
+	wm := &Watermark{}
+
+	// Only one of many concurrent requests for the same HRS is signed.
+	var wg sync.WaitGroup
+	var mtx sync.Mutex
+	signed := 0
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, _ = wm.sign(2, 1, stepPrecommit, []byte(fmt.Sprintf("SignBytes%v", i)), func() ([]byte, error) {
+				mtx.Lock()
+				defer mtx.Unlock()
+				signed++
+				return []byte("Signature"), nil
+			})
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, signed)
+}
@@ -80,17 +80,18 @@ func TestQueryBlock_Cancel(t *testing.T) {
 func TestQueryBlock(t *testing.T) {
 	port, _ := getFreePort(t)
 	addr := fmt.Sprintf("tcp://127.0.0.1:%v", port)
-	go func() {
-		http.HandleFunc("/block", func(rw http.ResponseWriter, r *http.Request) {
-			height := r.URL.Query().Get("height")
-			assert.Equal(t, "1", height)
+	http.HandleFunc("/block", func(rw http.ResponseWriter, r *http.Request) {
+		height := r.URL.Query().Get("height")
+		assert.Equal(t, "1", height)
 
-			bytes, _ := tm_json.Marshal(testBlockResult(t))
-			_, _ = rw.Write(bytes)
-		})
-		if err := http.ListenAndServe(strings.TrimPrefix(addr, "tcp://"), nil); err != nil {
-			return
-		}
+		bytes, _ := tm_json.Marshal(testBlockResult(t))
+		_, _ = rw.Write(bytes)
+	})
+	listener, err := net.Listen("tcp", strings.TrimPrefix(addr, "tcp://"))
+	assert.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		_ = http.Serve(listener, nil)
 	}()
 
 	rb, err := QueryBlock(context.Background(), addr, 1, types.NewSyncLogger(ioutil.Discard, "", 0))
@@ -0,0 +1,79 @@
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	// ErrPromotionSuppressed is returned when a rank update is due, but the validator
+	// isn't promoted, as it has already been promoted automatically too many times
+	// within the auto-promotion window.
+	ErrPromotionSuppressed = errors.New("promotion suppressed due to too many automatic promotions")
+)
+
+// SetMaxAutoPromotions sets the number of automatic promotions within the given window
+// after which further automatic promotions are suppressed until the oldest of them
+// falls out of the window. Manual promotions are always possible. A maximum of 0
+// disables it.
+func (bsc *BaseSignCtrled) SetMaxAutoPromotions(max int, window time.Duration) {
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+	bsc.maxAutoPromotions = max
+	bsc.autoPromotionWindow = window
+}
+
+// IsPromotionSuppressed returns whether automatic promotions have been suppressed the
+// last time one was due.
+func (bsc *BaseSignCtrled) IsPromotionSuppressed() bool {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+	return bsc.promotionSuppressed
+}
+
+// autoPromotionSuppressed checks whether an automatic promotion due at the given height
+// must be suppressed. The first suppression is logged as an error and emitted as an
+// EventPromotionSuppressed. Rank 1 is never suppressed, as it must shut down as soon
+// as the validator below it is promoted. The caller must hold the lock.
+func (bsc *BaseSignCtrled) autoPromotionSuppressed(height int64) bool {
+	if bsc.maxAutoPromotions == 0 || bsc.rank == 1 {
+		return false
+	}
+
+	// Forget the promotions that fell out of the window.
+	cutoff := bsc.clock.Now().Add(-bsc.autoPromotionWindow)
+	recent := bsc.autoPromotions[:0]
+	for _, at := range bsc.autoPromotions {
+		if at.After(cutoff) {
+			recent = append(recent, at)
+		}
+	}
+	bsc.autoPromotions = recent
+
+	if len(bsc.autoPromotions) < bsc.maxAutoPromotions {
+		if bsc.promotionSuppressed {
+			bsc.Logger.Info("Automatic promotions are enabled again, as fewer than %v have taken place within the last %v", bsc.maxAutoPromotions, bsc.autoPromotionWindow)
+			bsc.promotionSuppressed = false
+		}
+		return false
+	}
+	if bsc.promotionSuppressed {
+		bsc.Logger.Debug("Still suppressing automatic promotion at block height %v", height)
+		return true
+	}
+
+	bsc.Logger.Error("Suppressing automatic promotion at block height %v, as the validator has already been promoted automatically %v times within the last %v! Something is likely wrong with the set, so check it and promote the validator manually if needed!", height, len(bsc.autoPromotions), bsc.autoPromotionWindow)
+	bsc.promotionSuppressed = true
+	bsc.emit(EventPromotionSuppressed, height)
+
+	return true
+}
+
+// recordAutoPromotion adds an automatic promotion to the ones in the window. The
+// caller must hold the lock.
+func (bsc *BaseSignCtrled) recordAutoPromotion() {
+	if bsc.maxAutoPromotions == 0 {
+		return
+	}
+	bsc.autoPromotions = append(bsc.autoPromotions, bsc.clock.Now())
+}
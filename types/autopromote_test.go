@@ -0,0 +1,80 @@
+package types_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/BlockscapeNetwork/signctrl/types/clocktest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxAutoPromotions(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	bsc, err := types.NewBaseSignCtrled(nil, 2, 3, 3, nil, types.WithClock(clock))
+	assert.NoError(t, err)
+	bsc.SetMaxAutoPromotions(1, time.Hour)
+	events := bsc.Subscribe(10)
+	bsc.UnlockCounter()
+
+	assert.NoError(t, bsc.Missed(2))
+	assert.ErrorIs(t, bsc.Missed(3), types.ErrThresholdExceeded)
+	assert.Equal(t, 2, bsc.GetRank())
+
+	// The second automatic promotion within the window is suppressed, but the blocks
+	// are still counted.
+	clock.Advance(30 * time.Minute)
+	assert.NoError(t, bsc.Missed(5))
+	assert.ErrorIs(t, bsc.Missed(6), types.ErrPromotionSuppressed)
+	assert.ErrorIs(t, bsc.Missed(7), types.ErrPromotionSuppressed)
+	assert.Equal(t, 2, bsc.GetRank())
+	assert.Equal(t, 3, bsc.GetMissedInARow())
+	assert.True(t, bsc.IsPromotionSuppressed())
+	assert.True(t, bsc.GetStateSnapshot().PromotionSuppressed)
+
+	// The alert is only emitted once.
+	var suppressed int
+	for len(events) > 0 {
+		if e := <-events; e.Kind == types.EventPromotionSuppressed {
+			assert.Equal(t, int64(6), e.Height)
+			suppressed++
+		}
+	}
+	assert.Equal(t, 1, suppressed)
+
+	// Once the first promotion falls out of the window, the pending promotion takes
+	// place on the next missed block.
+	clock.Advance(30 * time.Minute)
+	assert.ErrorIs(t, bsc.Missed(8), types.ErrThresholdExceeded)
+	assert.Equal(t, 1, bsc.GetRank())
+	assert.False(t, bsc.IsPromotionSuppressed())
+}
+
+func TestMaxAutoPromotions_Manual(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	bsc, err := types.NewBaseSignCtrled(nil, 2, 3, 3, nil, types.WithClock(clock))
+	assert.NoError(t, err)
+	bsc.SetMaxAutoPromotions(1, time.Hour)
+	bsc.UnlockCounter()
+
+	assert.NoError(t, bsc.Missed(2))
+	assert.ErrorIs(t, bsc.Missed(3), types.ErrThresholdExceeded)
+	assert.NoError(t, bsc.Missed(5))
+	assert.ErrorIs(t, bsc.Missed(6), types.ErrPromotionSuppressed)
+
+	// Manual promotions are still possible while automatic ones are suppressed.
+	assert.NoError(t, bsc.PromoteWithReason(types.PromoteReasonManual))
+	assert.Equal(t, 1, bsc.GetRank())
+}
+
+func TestMaxAutoPromotions_Disabled(t *testing.T) {
+	bsc, err := types.NewBaseSignCtrled(nil, 2, 3, 3, nil)
+	assert.NoError(t, err)
+	bsc.UnlockCounter()
+
+	assert.NoError(t, bsc.Missed(2))
+	assert.ErrorIs(t, bsc.Missed(3), types.ErrThresholdExceeded)
+	assert.NoError(t, bsc.Missed(5))
+	assert.ErrorIs(t, bsc.Missed(6), types.ErrThresholdExceeded)
+	assert.Equal(t, 1, bsc.GetRank())
+}
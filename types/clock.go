@@ -0,0 +1,81 @@
+package types
+
+import "time"
+
+// Clock tells the time and creates timers and tickers, so that time-based behavior
+// can be tested with a fake clock instead of waiting for the real one.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer sends the time on its channel once it fires, like a time.Timer.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// Ticker sends the time on its channel every time it ticks, like a time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+}
+
+// RealClock is the Clock of the system. It is used by default.
+type RealClock struct{}
+
+// Now returns the current time.
+// Implements the Clock interface.
+func (RealClock) Now() time.Time {
+	return time.Now()
+}
+
+// NewTimer creates a time.Timer that fires after the given duration.
+// Implements the Clock interface.
+func (RealClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+// NewTicker creates a time.Ticker that ticks in the given interval.
+// Implements the Clock interface.
+func (RealClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+// realTimer is a Timer backed by a time.Timer.
+type realTimer struct {
+	timer *time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time { return t.timer.C }
+func (t realTimer) Stop() bool          { return t.timer.Stop() }
+
+// realTicker is a Ticker backed by a time.Ticker.
+type realTicker struct {
+	ticker *time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time { return t.ticker.C }
+func (t realTicker) Stop()               { t.ticker.Stop() }
+
+// WithClock makes a BaseSignCtrled use the given clock instead of the real one.
+func WithClock(clock Clock) Option {
+	return func(bsc *BaseSignCtrled) {
+		bsc.clock = clock
+	}
+}
+
+// SetClock makes the BaseSignCtrled use the given clock from now on.
+func (bsc *BaseSignCtrled) SetClock(clock Clock) {
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+	bsc.clock = clock
+}
+
+// GetClock returns the clock the BaseSignCtrled uses.
+func (bsc *BaseSignCtrled) GetClock() Clock {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+	return bsc.clock
+}
@@ -0,0 +1,43 @@
+package types_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/BlockscapeNetwork/signctrl/types/clocktest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithClock_CheckLastSigned(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	bsc, err := types.NewBaseSignCtrled(nil, 2, 2, 3, nil, types.WithClock(clock))
+	assert.NoError(t, err)
+	assert.Equal(t, clock, bsc.GetClock())
+	bsc.SetThresholdDuration(time.Minute)
+	bsc.UnlockCounter()
+
+	// The threshold duration is only exceeded once the fake clock has been advanced,
+	// without waiting for it.
+	clock.Advance(59 * time.Second)
+	assert.NoError(t, bsc.CheckLastSigned())
+	assert.Equal(t, 2, bsc.GetRank())
+	clock.Advance(time.Second)
+	assert.ErrorIs(t, bsc.CheckLastSigned(), types.ErrThresholdExceeded)
+	assert.Equal(t, 1, bsc.GetRank())
+}
+
+func TestSetClock_LockedAt(t *testing.T) {
+	bsc, err := types.NewBaseSignCtrled(nil, 2, 2, 3, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, types.RealClock{}, bsc.GetClock())
+
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := clocktest.New(start)
+	bsc.SetClock(clock)
+	bsc.UnlockCounter()
+	clock.Advance(time.Hour)
+	bsc.LockCounter()
+	assert.Equal(t, start, bsc.GetStateSnapshot().LastUnlockedAt)
+	assert.Equal(t, start.Add(time.Hour), bsc.GetStateSnapshot().LastLockedAt)
+}
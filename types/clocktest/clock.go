@@ -0,0 +1,137 @@
+// Package clocktest provides a fake types.Clock, so that time-based behavior can be
+// tested deterministically without waiting for the real clock.
+package clocktest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+)
+
+// Clock is a fake types.Clock whose time only moves when it is advanced. It is safe for
+// concurrent use.
+type Clock struct {
+	mtx     sync.Mutex
+	now     time.Time
+	waiters []*waiter
+}
+
+// Clock must implement the types.Clock interface.
+var _ types.Clock = new(Clock)
+
+// waiter is a timer or ticker of the fake clock. Tickers have a period, timers don't.
+type waiter struct {
+	clock  *Clock
+	ch     chan time.Time
+	at     time.Time
+	period time.Duration
+}
+
+// New creates a new fake clock starting at the given time.
+func New(now time.Time) *Clock {
+	return &Clock{now: now}
+}
+
+// Now returns the fake clock's current time.
+// Implements the types.Clock interface.
+func (c *Clock) Now() time.Time {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.now
+}
+
+// NewTimer creates a timer that fires once the clock has been advanced by the given
+// duration.
+// Implements the types.Clock interface.
+func (c *Clock) NewTimer(d time.Duration) types.Timer {
+	return timer{c.add(d, 0)}
+}
+
+// NewTicker creates a ticker that ticks every time the clock has been advanced by the
+// given interval. Just like time.NewTicker, it panics if the interval isn't positive.
+// Implements the types.Clock interface.
+func (c *Clock) NewTicker(d time.Duration) types.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for clocktest.Clock.NewTicker")
+	}
+
+	return ticker{c.add(d, d)}
+}
+
+// Advance moves the clock forward by the given duration and fires all timers and
+// tickers that are due. Just like the real ones, they drop the ticks that haven't
+// been received yet.
+func (c *Clock) Advance(d time.Duration) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.now = c.now.Add(d)
+
+	active := c.waiters[:0]
+	for _, w := range c.waiters {
+		if w.at.After(c.now) {
+			active = append(active, w)
+			continue
+		}
+		select {
+		case w.ch <- c.now:
+		default:
+		}
+		if w.period == 0 {
+			continue
+		}
+		for !w.at.After(c.now) {
+			w.at = w.at.Add(w.period)
+		}
+		active = append(active, w)
+	}
+	c.waiters = active
+}
+
+// Waiters returns the number of timers and tickers that haven't fired or been stopped
+// yet, so that tests can wait for them to be created before advancing the clock.
+func (c *Clock) Waiters() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return len(c.waiters)
+}
+
+// add adds a timer or ticker that is due after the given duration.
+func (c *Clock) add(d time.Duration, period time.Duration) *waiter {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	w := &waiter{clock: c, ch: make(chan time.Time, 1), at: c.now.Add(d), period: period}
+	c.waiters = append(c.waiters, w)
+
+	return w
+}
+
+// stop removes the timer or ticker from the clock and returns whether it was active.
+func (w *waiter) stop() bool {
+	w.clock.mtx.Lock()
+	defer w.clock.mtx.Unlock()
+	for i, other := range w.clock.waiters {
+		if other == w {
+			w.clock.waiters = append(w.clock.waiters[:i], w.clock.waiters[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// timer is a types.Timer of the fake clock.
+type timer struct {
+	*waiter
+}
+
+func (t timer) C() <-chan time.Time { return t.ch }
+func (t timer) Stop() bool          { return t.stop() }
+
+// ticker is a types.Ticker of the fake clock.
+type ticker struct {
+	*waiter
+}
+
+func (t ticker) C() <-chan time.Time { return t.ch }
+func (t ticker) Stop()               { t.stop() }
@@ -0,0 +1,67 @@
+package clocktest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fired checks whether the given channel has received a time.
+func fired(ch <-chan time.Time) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestClock_Timer(t *testing.T) {
+	start := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := New(start)
+	timer := clock.NewTimer(time.Minute)
+	assert.Equal(t, 1, clock.Waiters())
+
+	clock.Advance(59 * time.Second)
+	assert.False(t, fired(timer.C()))
+	clock.Advance(time.Second)
+	assert.True(t, fired(timer.C()))
+	assert.Equal(t, start.Add(time.Minute), clock.Now())
+
+	// A timer only fires once.
+	assert.Zero(t, clock.Waiters())
+	clock.Advance(time.Hour)
+	assert.False(t, fired(timer.C()))
+	assert.False(t, timer.Stop())
+
+	// Stopped timers never fire.
+	timer = clock.NewTimer(time.Minute)
+	assert.True(t, timer.Stop())
+	clock.Advance(time.Hour)
+	assert.False(t, fired(timer.C()))
+}
+
+func TestClock_Ticker(t *testing.T) {
+	clock := New(time.Now())
+	ticker := clock.NewTicker(time.Second)
+
+	clock.Advance(time.Second)
+	assert.True(t, fired(ticker.C()))
+	clock.Advance(500 * time.Millisecond)
+	assert.False(t, fired(ticker.C()))
+	clock.Advance(500 * time.Millisecond)
+	assert.True(t, fired(ticker.C()))
+
+	// Ticks that haven't been received are dropped.
+	clock.Advance(5 * time.Second)
+	assert.True(t, fired(ticker.C()))
+	assert.False(t, fired(ticker.C()))
+
+	ticker.Stop()
+	assert.Zero(t, clock.Waiters())
+	clock.Advance(time.Second)
+	assert.False(t, fired(ticker.C()))
+
+	assert.Panics(t, func() { clock.NewTicker(0) })
+}
@@ -0,0 +1,23 @@
+package types
+
+import "time"
+
+// ConnStats are the statistics of the connections to the validators since SignCTRL
+// started.
+type ConnStats struct {
+	// BytesRead and BytesWritten count the bytes of the messages read from and
+	// written to the connections, excluding the overhead of secret connections.
+	BytesRead    uint64 `json:"bytes_read"`
+	BytesWritten uint64 `json:"bytes_written"`
+
+	// Dials counts the attempts to dial a validator, of which FailedDials failed.
+	Dials       uint64 `json:"dials"`
+	FailedDials uint64 `json:"failed_dials"`
+
+	// Reconnects counts the connections that have been lost and dialed again.
+	Reconnects uint64 `json:"reconnects"`
+
+	// LastHandshake is the time the last successful dial took to connect and complete
+	// the handshake.
+	LastHandshake time.Duration `json:"last_handshake"`
+}
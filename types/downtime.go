@@ -0,0 +1,74 @@
+package types
+
+import "time"
+
+// blockTimeWeight is the weight of the latest inter-block interval in the
+// exponentially weighted average block time.
+const blockTimeWeight = 0.1
+
+// recordBlockTime updates the average block time with the interval since the last
+// height has been set, spread over the heights in between. The first height only
+// records the time, and a height that has already been set is ignored. It must be
+// called before the height is set. The caller must hold the lock.
+func (bsc *BaseSignCtrled) recordBlockTime(height int64) {
+	if bsc.heightSeen && height <= bsc.currentHeight {
+		return
+	}
+	now := bsc.clock.Now()
+	if bsc.heightSeen {
+		interval := now.Sub(bsc.lastHeightAt) / time.Duration(height-bsc.currentHeight)
+		if bsc.avgBlockTime == 0 {
+			bsc.avgBlockTime = interval
+		} else {
+			bsc.avgBlockTime += time.Duration(blockTimeWeight * float64(interval-bsc.avgBlockTime))
+		}
+	}
+	bsc.lastHeightAt = now
+}
+
+// recordDowntime adds a counted missed block to the lifetime stats. The caller must
+// hold the lock.
+func (bsc *BaseSignCtrled) recordDowntime() {
+	bsc.totalMissed++
+	if bsc.missedInARow > bsc.longestStreak {
+		bsc.longestStreak = bsc.missedInARow
+	}
+}
+
+// estimatedDowntime returns the total number of missed blocks multiplied by the
+// average block time. The caller must hold the lock.
+func (bsc *BaseSignCtrled) estimatedDowntime() time.Duration {
+	return time.Duration(bsc.totalMissed) * bsc.avgBlockTime
+}
+
+// GetTotalMissed returns the total number of missed blocks counted since the start.
+func (bsc *BaseSignCtrled) GetTotalMissed() int64 {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+	return bsc.totalMissed
+}
+
+// GetLongestMissedStreak returns the highest number of blocks missed in a row since
+// the start.
+func (bsc *BaseSignCtrled) GetLongestMissedStreak() int {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+	return bsc.longestStreak
+}
+
+// GetAvgBlockTime returns the exponentially weighted average of the time between
+// blocks, which is 0 until two heights have been set.
+func (bsc *BaseSignCtrled) GetAvgBlockTime() time.Duration {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+	return bsc.avgBlockTime
+}
+
+// GetEstimatedDowntime returns the estimated time the validator has been down for
+// since the start, which is the total number of missed blocks multiplied by the
+// average block time.
+func (bsc *BaseSignCtrled) GetEstimatedDowntime() time.Duration {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+	return bsc.estimatedDowntime()
+}
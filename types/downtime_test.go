@@ -0,0 +1,58 @@
+package types_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/BlockscapeNetwork/signctrl/types/clocktest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDowntime(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	bsc, err := types.NewBaseSignCtrled(nil, 5, 2, 3, nil, types.WithClock(clock))
+	assert.NoError(t, err)
+	bsc.UnlockCounter()
+
+	// The first height only records the time.
+	assert.NoError(t, bsc.SetCurrentHeight(1))
+	assert.Zero(t, bsc.GetAvgBlockTime())
+
+	// The interval is spread over skipped heights, and setting a height again doesn't
+	// count as a block.
+	clock.Advance(12 * time.Second)
+	assert.NoError(t, bsc.SetCurrentHeight(3))
+	assert.Equal(t, 6*time.Second, bsc.GetAvgBlockTime())
+	clock.Advance(time.Second)
+	assert.NoError(t, bsc.SetCurrentHeight(3))
+	assert.Equal(t, 6*time.Second, bsc.GetAvgBlockTime())
+
+	// Later intervals are weighted in exponentially.
+	clock.Advance(15 * time.Second)
+	assert.NoError(t, bsc.SetCurrentHeight(4))
+	assert.Equal(t, 7*time.Second, bsc.GetAvgBlockTime())
+
+	for _, height := range []int64{4, 5} {
+		assert.NoError(t, bsc.Missed(height))
+	}
+	bsc.Signed()
+	for _, height := range []int64{6, 7, 8} {
+		assert.NoError(t, bsc.Missed(height))
+	}
+	bsc.Signed()
+
+	snapshot := bsc.GetStateSnapshot()
+	assert.Equal(t, 0, snapshot.MissedInARow)
+	assert.Equal(t, int64(5), snapshot.TotalMissed)
+	assert.Equal(t, 3, snapshot.LongestMissedStreak)
+	assert.Equal(t, 7*time.Second, snapshot.AvgBlockTime)
+	assert.Equal(t, 35*time.Second, snapshot.EstimatedDowntime)
+	assert.Equal(t, snapshot.EstimatedDowntime, bsc.GetEstimatedDowntime())
+
+	// Blocks aren't counted as missed while the counter is locked.
+	bsc.LockCounter()
+	assert.ErrorIs(t, bsc.Missed(9), types.ErrCounterLocked)
+	assert.Equal(t, int64(5), bsc.GetTotalMissed())
+	assert.Equal(t, 3, bsc.GetLongestMissedStreak())
+}
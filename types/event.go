@@ -0,0 +1,96 @@
+package types
+
+import "time"
+
+// EventKind is the kind of state transition an Event reports.
+type EventKind string
+
+const (
+	// EventMissed is emitted when a missed block has been counted.
+	EventMissed EventKind = "missed"
+
+	// EventCounterLocked is emitted when the counter for missed blocks in a row is
+	// locked.
+	EventCounterLocked EventKind = "counter_locked"
+
+	// EventCounterUnlocked is emitted when the counter for missed blocks in a row is
+	// unlocked.
+	EventCounterUnlocked EventKind = "counter_unlocked"
+
+	// EventPromoted is emitted when the validator has been promoted.
+	EventPromoted EventKind = "promoted"
+
+	// EventDemoted is emitted when the validator has been demoted.
+	EventDemoted EventKind = "demoted"
+
+	// EventMustShutdown is emitted when the validator can't be promoted anymore and
+	// must be shut down.
+	EventMustShutdown EventKind = "must_shutdown"
+
+	// EventPaused is emitted when the monitoring of missed blocks is paused.
+	EventPaused EventKind = "paused"
+
+	// EventResumed is emitted when the monitoring of missed blocks is resumed.
+	EventResumed EventKind = "resumed"
+
+	// EventPromotionSuppressed is emitted when automatic promotions start being
+	// suppressed due to too many of them within the auto-promotion window.
+	EventPromotionSuppressed EventKind = "promotion_suppressed"
+)
+
+// Event reports a state transition of a BaseSignCtrled. Rank and MissedInARow are the
+// values right after the transition. Reason is only set for promotions.
+type Event struct {
+	Kind         EventKind
+	Height       int64
+	Rank         int
+	MissedInARow int
+	Reason       PromoteReason
+	Time         time.Time
+}
+
+// Subscribe returns a channel that receives the events of the validator's state
+// transitions, buffering up to the given number of events. Events are never waited
+// for to be received, so that the signing path is never blocked. If the buffer is
+// full, the event is dropped and counted instead.
+func (bsc *BaseSignCtrled) Subscribe(size int) <-chan Event {
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+	ch := make(chan Event, size)
+	bsc.subscribers = append(bsc.subscribers, ch)
+
+	return ch
+}
+
+// GetDroppedEvents returns the number of events that have been dropped because a
+// subscriber's buffer was full.
+func (bsc *BaseSignCtrled) GetDroppedEvents() uint64 {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+	return bsc.droppedEvents
+}
+
+// emit sends an event of the given kind at the given height to all subscribers
+// without blocking. The caller must hold the lock.
+func (bsc *BaseSignCtrled) emit(kind EventKind, height int64) {
+	if len(bsc.subscribers) == 0 {
+		return
+	}
+	event := Event{
+		Kind:         kind,
+		Height:       height,
+		Rank:         bsc.rank,
+		MissedInARow: bsc.missedInARow,
+		Time:         bsc.clock.Now(),
+	}
+	if kind == EventPromoted {
+		event.Reason = bsc.promoteReason
+	}
+	for _, ch := range bsc.subscribers {
+		select {
+		case ch <- event:
+		default:
+			bsc.droppedEvents++
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// receive returns the events buffered in the given channel without their time.
+func receive(t *testing.T, events <-chan Event) []Event {
+	t.Helper()
+	var received []Event
+	for {
+		select {
+		case e := <-events:
+			assert.False(t, e.Time.IsZero())
+			e.Time = time.Time{}
+			received = append(received, e)
+		default:
+			return received
+		}
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 2, 2, 3, sc)
+	events := sc.Subscribe(16)
+
+	sc.UnlockCounter()
+	assert.NoError(t, sc.miss())
+	assert.ErrorIs(t, sc.miss(), ErrThresholdExceeded)
+	assert.ErrorIs(t, sc.miss(), ErrAlreadyCounted)
+	assert.NoError(t, sc.miss())
+	assert.ErrorIs(t, sc.miss(), ErrMustShutdown)
+	assert.NoError(t, sc.Demote(2))
+	sc.LockCounter()
+
+	assert.Equal(t, []Event{
+		{Kind: EventCounterUnlocked, Height: 1, Rank: 2},
+		{Kind: EventMissed, Height: 1, Rank: 2, MissedInARow: 1},
+		{Kind: EventMissed, Height: 2, Rank: 2, MissedInARow: 2},
+		{Kind: EventPromoted, Height: 2, Rank: 1, Reason: PromoteReasonThresholdExceeded},
+		{Kind: EventMissed, Height: 4, Rank: 1, MissedInARow: 1},
+		{Kind: EventMissed, Height: 5, Rank: 1, MissedInARow: 2},
+		{Kind: EventMustShutdown, Height: 5, Rank: 1, MissedInARow: 2},
+		{Kind: EventDemoted, Height: 1, Rank: 2},
+	}, receive(t, events))
+	assert.Zero(t, sc.GetDroppedEvents())
+
+	// Locking an already locked counter isn't a state transition.
+	sc.LockCounter()
+	assert.Empty(t, receive(t, events))
+}
+
+func TestSubscribe_PromoteReason(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 2, 3, 3, sc)
+	events := sc.Subscribe(16)
+
+	assert.NoError(t, sc.PromoteWithReason(PromoteReasonManual))
+	assert.NoError(t, sc.PromoteWithReason(PromoteReasonPeerCoordination))
+	assert.ErrorIs(t, sc.PromoteWithReason(PromoteReasonManual), ErrMustShutdown)
+
+	// Only promotions carry a reason.
+	assert.Equal(t, []Event{
+		{Kind: EventPromoted, Height: 1, Rank: 2, Reason: PromoteReasonManual},
+		{Kind: EventPromoted, Height: 1, Rank: 1, Reason: PromoteReasonPeerCoordination},
+		{Kind: EventMustShutdown, Height: 1, Rank: 1},
+	}, receive(t, events))
+}
+
+func TestSubscribe_Dropped(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 10, 2, 3, sc)
+	full := sc.Subscribe(1)
+	events := sc.Subscribe(10)
+
+	// A full subscriber neither blocks nor keeps others from receiving events.
+	sc.UnlockCounter()
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, sc.miss())
+	}
+	assert.Len(t, receive(t, full), 1)
+	assert.Len(t, receive(t, events), 4)
+	assert.Equal(t, uint64(3), sc.GetDroppedEvents())
+}
@@ -0,0 +1,58 @@
+package types
+
+import "time"
+
+// FailureLog coalesces the logs of repeated failures to reach the same peer, e.g. a
+// validator that is down for an hour, so that they don't bury other logs. The first
+// failure is logged at error level, followed by a summary at most every interval as
+// long as the failures go on, and a final line once the peer is reached again. An
+// interval of 0 logs a summary on every failure. It isn't safe for concurrent use.
+type FailureLog struct {
+	logger   *SyncLogger
+	clock    Clock
+	interval time.Duration
+	peer     string
+
+	failures   int
+	first      time.Time
+	lastLogged time.Time
+}
+
+// NewFailureLog returns a FailureLog for the given peer, e.g. tcp://127.0.0.1:3000,
+// which logs summaries to the given logger at most every interval.
+func NewFailureLog(logger *SyncLogger, clock Clock, interval time.Duration, peer string) *FailureLog {
+	return &FailureLog{logger: logger, clock: clock, interval: interval, peer: peer}
+}
+
+// Failure records a failure to reach the peer due to the given error.
+func (l *FailureLog) Failure(err error) {
+	now := l.clock.Now()
+	l.failures++
+	if l.failures == 1 {
+		l.first, l.lastLogged = now, now
+		l.logger.Error("Unable to reach %v: %v", l.peer, err)
+		return
+	}
+	if now.Sub(l.lastLogged) < l.interval {
+		return
+	}
+
+	l.lastLogged = now
+	l.logger.Warn("Still unable to reach %v, %v attempts over %v (%v)", l.peer, l.failures, now.Sub(l.first).Round(time.Second), err)
+}
+
+// Success records that the peer has been reached again, which ends the failures. If
+// there were any, it is logged how long they went on.
+func (l *FailureLog) Success() {
+	if l.failures == 0 {
+		return
+	}
+
+	l.logger.Info("Connection to %v restored after %v (%v failed attempts)", l.peer, l.clock.Now().Sub(l.first).Round(time.Second), l.failures)
+	l.failures = 0
+}
+
+// Failures returns the number of failures since the peer was last reached.
+func (l *FailureLog) Failures() int {
+	return l.failures
+}
@@ -0,0 +1,56 @@
+package types_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BlockscapeNetwork/signctrl/types"
+	"github.com/BlockscapeNetwork/signctrl/types/clocktest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailureLog(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	var buf bytes.Buffer
+	l := types.NewFailureLog(types.NewSyncLogger(&buf, "", 0), clock, time.Minute, "tcp://127.0.0.1:3000")
+
+	// Success without failures isn't logged.
+	l.Success()
+	assert.Empty(t, buf.String())
+
+	// The first failure is logged as an error, the following ones only once a minute.
+	err := errors.New("connection refused")
+	for i := 0; i < 120; i++ {
+		l.Failure(err)
+		clock.Advance(time.Second)
+	}
+	assert.Equal(t, 120, l.Failures())
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, "[ERR]   signctrl: Unable to reach tcp://127.0.0.1:3000: connection refused", lines[0])
+	assert.Equal(t, "[WARN]  signctrl: Still unable to reach tcp://127.0.0.1:3000, 61 attempts over 1m0s (connection refused)", lines[1])
+
+	// Success ends the failures.
+	buf.Reset()
+	l.Success()
+	assert.Equal(t, "[INFO]  signctrl: Connection to tcp://127.0.0.1:3000 restored after 2m0s (120 failed attempts)\n", buf.String())
+	assert.Zero(t, l.Failures())
+
+	// The next failure is logged as an error again.
+	buf.Reset()
+	l.Failure(err)
+	assert.Contains(t, buf.String(), "[ERR]   signctrl: Unable to reach")
+}
+
+func TestFailureLog_NoInterval(t *testing.T) {
+	clock := clocktest.New(time.Now())
+	var buf bytes.Buffer
+	l := types.NewFailureLog(types.NewSyncLogger(&buf, "", 0), clock, 0, "tcp://127.0.0.1:3000")
+	for i := 0; i < 3; i++ {
+		l.Failure(errors.New("connection refused"))
+	}
+	assert.Equal(t, 3, strings.Count(buf.String(), "\n"))
+}
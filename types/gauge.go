@@ -9,6 +9,8 @@ import (
 type Gauges struct {
 	RankGauge         prometheus.Gauge
 	MissedInARowGauge prometheus.Gauge
+	DegradedGauge     prometheus.Gauge
+	ThresholdGauge    prometheus.Gauge
 }
 
 // RegisterGauges registers SignCTRL's prometheus gauges and returns them.
@@ -22,6 +24,72 @@ func RegisterGauges() Gauges {
 		Name: "signctrl_missed_blocks_in_a_row",
 		Help: "Number of blocks missed in a row",
 	})
+	g.DegradedGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "signctrl_degraded",
+		Help: "Whether the signer is degraded due to too many failures in a row.",
+	})
+	g.ThresholdGauge = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "signctrl_threshold",
+		Help: "Effective threshold of blocks missed in a row on the current rank.",
+	})
 
 	return g
 }
+
+// RegisterMetrics registers the prometheus counters of a BaseSignCtrled's state
+// transitions and returns them as Metrics, which share the gauge for the counter for
+// missed blocks in a row with the given gauges.
+func RegisterMetrics(g Gauges) Metrics {
+	return Metrics{
+		BlocksObserved: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "signctrl_blocks_observed_total",
+			Help: "Number of blocks observed, whether signed, missed or not counted.",
+		}),
+		BlocksMissed: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "signctrl_blocks_missed_total",
+			Help: "Number of missed blocks that have been counted.",
+		}),
+		Resets: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "signctrl_counter_resets_total",
+			Help: "Number of resets of the counter for missed blocks in a row.",
+		}),
+		Promotions: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "signctrl_promotions_total",
+			Help: "Number of promotions of the SignCTRL validator.",
+		}),
+		LockedSeconds: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "signctrl_counter_locked_seconds_total",
+			Help: "Number of seconds the counter for missed blocks in a row has been locked for.",
+		}),
+		MissedInARow: g.MissedInARowGauge,
+	}
+}
+
+// RegisterConnMetrics registers the prometheus metrics of the connections to the
+// validators, which are read from the given function on every scrape.
+func RegisterConnMetrics(stats func() ConnStats) {
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "signctrl_conn_read_bytes_total",
+		Help: "Number of bytes read from the connections to the validators.",
+	}, func() float64 { return float64(stats().BytesRead) })
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "signctrl_conn_written_bytes_total",
+		Help: "Number of bytes written to the connections to the validators.",
+	}, func() float64 { return float64(stats().BytesWritten) })
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "signctrl_conn_dials_total",
+		Help: "Number of attempts to dial a validator.",
+	}, func() float64 { return float64(stats().Dials) })
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "signctrl_conn_failed_dials_total",
+		Help: "Number of failed attempts to dial a validator.",
+	}, func() float64 { return float64(stats().FailedDials) })
+	promauto.NewCounterFunc(prometheus.CounterOpts{
+		Name: "signctrl_conn_reconnects_total",
+		Help: "Number of reconnects to a validator.",
+	}, func() float64 { return float64(stats().Reconnects) })
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "signctrl_conn_last_handshake_seconds",
+		Help: "Time the last successful dial took to connect and complete the handshake.",
+	}, func() float64 { return stats().LastHandshake.Seconds() })
+}
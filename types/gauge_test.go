@@ -3,6 +3,7 @@ package types
 import (
 	"testing"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -10,4 +11,17 @@ func TestRegisterGauges(t *testing.T) {
 	g := RegisterGauges()
 	assert.NotNil(t, g.RankGauge)
 	assert.NotNil(t, g.MissedInARowGauge)
+	assert.NotNil(t, g.DegradedGauge)
+	assert.NotNil(t, g.ThresholdGauge)
+}
+
+func TestRegisterMetrics(t *testing.T) {
+	g := Gauges{MissedInARowGauge: prometheus.NewGauge(prometheus.GaugeOpts{Name: "test"})}
+	m := RegisterMetrics(g)
+	assert.NotNil(t, m.BlocksObserved)
+	assert.NotNil(t, m.BlocksMissed)
+	assert.NotNil(t, m.Resets)
+	assert.NotNil(t, m.Promotions)
+	assert.NotNil(t, m.LockedSeconds)
+	assert.Equal(t, g.MissedInARowGauge, m.MissedInARow)
 }
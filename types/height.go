@@ -0,0 +1,60 @@
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrHeightRegression is returned when the current height is set to a height lower
+	// than the current one, e.g. because the validator replays old heights after it
+	// has been restored from a snapshot.
+	ErrHeightRegression = errors.New("height is lower than the current height")
+)
+
+// SetMaxHeightGap sets the number of heights the current height may skip before the
+// counter for missed blocks in a row is locked, as the commits of the skipped heights
+// haven't been checked. A number of 0 disables it.
+func (bsc *BaseSignCtrled) SetMaxHeightGap(heights int) {
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+	bsc.maxHeightGap = int64(heights)
+}
+
+// SetLockOnHeightRegression sets whether the counter for missed blocks in a row is
+// locked when a height lower than the current one is rejected.
+func (bsc *BaseSignCtrled) SetLockOnHeightRegression(lock bool) {
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+	bsc.lockOnRegression = lock
+}
+
+// checkHeight checks the given height against the current one before it is set. It
+// records the gap between them, locks the counter if the height went backwards or
+// skipped too many heights, and returns ErrHeightRegression if it went backwards.
+// The caller must hold the lock.
+func (bsc *BaseSignCtrled) checkHeight(height int64) error {
+	if height < bsc.currentHeight {
+		bsc.lastGap = height - bsc.currentHeight
+		bsc.lastGapAt = bsc.currentHeight
+		bsc.Logger.Warn("Rejected block height %v, as it is lower than the current height %v, which the validator might replay after a restore from a snapshot", height, bsc.currentHeight)
+		if bsc.lockOnRegression && !bsc.counterLocked {
+			bsc.Logger.Info("Looking for first commitsig from validator after the height went backwards, stop counting missed blocks in a row...")
+			bsc.lockCounter()
+		}
+		return fmt.Errorf("%w: %v < %v", ErrHeightRegression, height, bsc.currentHeight)
+	}
+
+	skipped := height - bsc.currentHeight - 1
+	if !bsc.heightSeen || skipped < 1 {
+		return nil
+	}
+	bsc.lastGap = skipped
+	bsc.lastGapAt = height
+	if bsc.maxHeightGap > 0 && skipped > bsc.maxHeightGap && !bsc.counterLocked {
+		bsc.Logger.Warn("Block height jumped from %v to %v, so the commits of %v heights have likely been missed, looking for first commitsig from validator before counting missed blocks in a row...", bsc.currentHeight, height, skipped)
+		bsc.lockCounter()
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package types
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetCurrentHeight_Regression(t *testing.T) {
+	var buf bytes.Buffer
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 3, 2, 3, sc)
+	sc.Logger = NewSyncLogger(&buf, "", 0)
+	sc.UnlockCounter()
+	assert.NoError(t, sc.SetCurrentHeight(10))
+	assert.NoError(t, sc.SetCurrentHeight(10))
+
+	// Lower heights are rejected, but don't lock the counter by default.
+	assert.ErrorIs(t, sc.SetCurrentHeight(8), ErrHeightRegression)
+	assert.Equal(t, int64(10), sc.GetCurrentHeight())
+	assert.False(t, sc.IsCounterLocked())
+	assert.Contains(t, buf.String(), "Rejected block height 8, as it is lower than the current height 10")
+	snapshot := sc.GetStateSnapshot()
+	assert.Equal(t, int64(-2), snapshot.LastHeightGap)
+	assert.Equal(t, int64(10), snapshot.LastHeightGapAt)
+
+	sc.SetLockOnHeightRegression(true)
+	assert.ErrorIs(t, sc.SetCurrentHeight(9), ErrHeightRegression)
+	assert.Equal(t, int64(10), sc.GetCurrentHeight())
+	assert.True(t, sc.IsCounterLocked())
+}
+
+func TestSetCurrentHeight_Gap(t *testing.T) {
+	var buf bytes.Buffer
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 3, 2, 3, sc)
+	sc.Logger = NewSyncLogger(&buf, "", 0)
+	sc.SetMaxHeightGap(2)
+	sc.UnlockCounter()
+
+	// The first height isn't a gap, no matter how far it is from the initial one.
+	assert.NoError(t, sc.SetCurrentHeight(100))
+	assert.False(t, sc.IsCounterLocked())
+	assert.Zero(t, sc.GetStateSnapshot().LastHeightGap)
+
+	// Skipping up to the maximum gap is recorded, but doesn't lock the counter.
+	assert.NoError(t, sc.SetCurrentHeight(103))
+	assert.False(t, sc.IsCounterLocked())
+	snapshot := sc.GetStateSnapshot()
+	assert.Equal(t, int64(2), snapshot.LastHeightGap)
+	assert.Equal(t, int64(103), snapshot.LastHeightGapAt)
+
+	// Skipping more than that locks it.
+	assert.NoError(t, sc.SetCurrentHeight(107))
+	assert.Equal(t, int64(107), sc.GetCurrentHeight())
+	assert.True(t, sc.IsCounterLocked())
+	assert.Equal(t, int64(3), sc.GetStateSnapshot().LastHeightGap)
+	assert.Contains(t, buf.String(), "Block height jumped from 103 to 107, so the commits of 3 heights have likely been missed")
+	assert.ErrorIs(t, sc.Missed(107), ErrCounterLocked)
+}
+
+func TestSetCurrentHeight_GapDisabled(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 3, 2, 3, sc)
+	sc.UnlockCounter()
+	assert.NoError(t, sc.SetCurrentHeight(10))
+	assert.NoError(t, sc.SetCurrentHeight(1000))
+	assert.False(t, sc.IsCounterLocked())
+	assert.Equal(t, int64(989), sc.GetStateSnapshot().LastHeightGap)
+}
@@ -0,0 +1,78 @@
+package types
+
+import "time"
+
+// DefaultBlockHistorySize is the number of blocks a BaseSignCtrled keeps in its block
+// history by default.
+const DefaultBlockHistorySize = 1000
+
+// BlockOutcome is the outcome of a block for the validator.
+type BlockOutcome string
+
+const (
+	// BlockSigned is the outcome of a block whose commit contains the validator's
+	// signature.
+	BlockSigned BlockOutcome = "signed"
+
+	// BlockMissed is the outcome of a block that has been counted as missed.
+	BlockMissed BlockOutcome = "missed"
+
+	// BlockLocked is the outcome of a block that has been missed while the counter for
+	// missed blocks in a row was locked, so that it hasn't been counted.
+	BlockLocked BlockOutcome = "locked"
+)
+
+// BlockRecord is the outcome of a block at the wall-clock time it has been seen.
+type BlockRecord struct {
+	Height  int64        `json:"height"`
+	Outcome BlockOutcome `json:"outcome"`
+	Time    time.Time    `json:"time"`
+}
+
+// SetBlockHistorySize sets the number of last blocks kept in the block history and
+// clears it. A size of 0 disables the block history.
+func (bsc *BaseSignCtrled) SetBlockHistorySize(size int) {
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+	bsc.historySize = size
+	bsc.clearHistory()
+}
+
+// GetBlockHistory returns up to limit of the last blocks in the block history, oldest
+// first. A limit of 0 or lower returns all of them.
+func (bsc *BaseSignCtrled) GetBlockHistory(limit int) []BlockRecord {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+	n := len(bsc.blockHistory)
+	if limit <= 0 || limit > n {
+		limit = n
+	}
+	records := make([]BlockRecord, 0, limit)
+	for i := n - limit; i < n; i++ {
+		// Once the ring buffer is full, historyNext points to the oldest record.
+		records = append(records, bsc.blockHistory[(bsc.historyNext+i)%n])
+	}
+
+	return records
+}
+
+// recordBlock adds the outcome of the block at the given height to the block history,
+// replacing the oldest one if it is full. The caller must hold the lock.
+func (bsc *BaseSignCtrled) recordBlock(height int64, outcome BlockOutcome) {
+	if bsc.historySize <= 0 {
+		return
+	}
+	record := BlockRecord{Height: height, Outcome: outcome, Time: bsc.clock.Now()}
+	if len(bsc.blockHistory) < bsc.historySize {
+		bsc.blockHistory = append(bsc.blockHistory, record)
+		return
+	}
+	bsc.blockHistory[bsc.historyNext] = record
+	bsc.historyNext = (bsc.historyNext + 1) % bsc.historySize
+}
+
+// clearHistory forgets all blocks in the block history. The caller must hold the lock.
+func (bsc *BaseSignCtrled) clearHistory() {
+	bsc.blockHistory = nil
+	bsc.historyNext = 0
+}
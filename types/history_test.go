@@ -0,0 +1,70 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// outcomes returns the heights and outcomes of the given records.
+func outcomes(records []BlockRecord) map[int64]BlockOutcome {
+	m := make(map[int64]BlockOutcome, len(records))
+	for _, r := range records {
+		m[r.Height] = r.Outcome
+	}
+	return m
+}
+
+func TestGetBlockHistory(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 10, 2, 3, sc)
+	assert.Empty(t, sc.GetBlockHistory(0))
+
+	assert.ErrorIs(t, sc.miss(), ErrCounterLocked)
+	assert.ErrorIs(t, sc.Missed(1), ErrCounterLocked)
+	sc.SetCurrentHeight(2)
+	sc.Signed()
+	sc.UnlockCounter()
+	sc.height = 2
+	assert.NoError(t, sc.miss())
+
+	records := sc.GetBlockHistory(0)
+	assert.Equal(t, map[int64]BlockOutcome{1: BlockLocked, 2: BlockSigned, 3: BlockMissed}, outcomes(records))
+	assert.Equal(t, []int64{1, 2, 3}, []int64{records[0].Height, records[1].Height, records[2].Height})
+	for _, r := range records {
+		assert.False(t, r.Time.IsZero())
+	}
+
+	// The limit returns the last blocks.
+	records = sc.GetBlockHistory(2)
+	assert.Equal(t, map[int64]BlockOutcome{2: BlockSigned, 3: BlockMissed}, outcomes(records))
+	assert.Equal(t, int64(2), records[0].Height)
+	assert.Len(t, sc.GetBlockHistory(100), 3)
+
+	// The block history is cleared on demotion.
+	assert.NoError(t, sc.Demote(3))
+	assert.Empty(t, sc.GetBlockHistory(0))
+}
+
+func TestGetBlockHistory_RingBuffer(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 100, 2, 3, sc)
+	sc.SetBlockHistorySize(5)
+	sc.UnlockCounter()
+
+	// Memory use is bounded, as the oldest blocks are replaced.
+	for i := 0; i < 12; i++ {
+		assert.NoError(t, sc.miss())
+	}
+	records := sc.GetBlockHistory(0)
+	assert.Len(t, records, 5)
+	for i, r := range records {
+		assert.Equal(t, int64(8+i), r.Height)
+	}
+	assert.Equal(t, []BlockRecord{records[3], records[4]}, sc.GetBlockHistory(2))
+
+	// A size of 0 disables the block history.
+	sc.SetBlockHistorySize(0)
+	assert.NoError(t, sc.miss())
+	assert.Empty(t, sc.GetBlockHistory(0))
+}
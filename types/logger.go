@@ -1,23 +1,74 @@
 package types
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/hashicorp/logutils"
 )
 
+const (
+	// LogFormatPlain logs each message as a line tagged with its level, e.g.
+	// "[INFO]  signctrl: message".
+	LogFormatPlain = "plain"
+
+	// LogFormatJSON logs each message as a JSON object on its own line with the
+	// time, level and message.
+	LogFormatJSON = "json"
+)
+
 var (
 	// LogLevels defines the loglevels for SignCTRL logs.
 	LogLevels = []logutils.LogLevel{"DEBUG", "INFO", "WARN", "ERR"}
+
+	// LogFormats are the supported formats of SignCTRL logs.
+	LogFormats = []string{LogFormatPlain, LogFormatJSON}
 )
 
+// ParseLogLevel returns the level in LogLevels for the given level name, which is
+// either one of them or debug, info, warn or error, in any case.
+func ParseLogLevel(level string) (logutils.LogLevel, error) {
+	upper := strings.ToUpper(level)
+	if upper == "ERROR" {
+		upper = "ERR"
+	}
+	for _, l := range LogLevels {
+		if string(l) == upper {
+			return l, nil
+		}
+	}
+
+	return "", fmt.Errorf("unknown log level %q", level)
+}
+
+// levelIndex returns the position of the given level in LogLevels, i.e. its severity.
+func levelIndex(level logutils.LogLevel) int {
+	for i, l := range LogLevels {
+		if l == level {
+			return i
+		}
+	}
+
+	return 0
+}
+
 // SyncLogger wraps a standard log.Logger and makes it synchronous.
 type SyncLogger struct {
 	sync.Mutex
 	logger *log.Logger
+
+	// json makes the logger log in the LogFormatJSON format.
+	json bool
+
+	// minLevel is the position of the minimum level logged in LogLevels. It is
+	// accessed atomically, so that messages below it are dropped without locking.
+	minLevel int32
 }
 
 // NewSyncLogger creates a new synchronous logger.
@@ -25,39 +76,107 @@ func NewSyncLogger(out io.Writer, prefix string, flag int) *SyncLogger {
 	return &SyncLogger{logger: log.New(out, prefix, flag)}
 }
 
+// NewLogger creates a new synchronous logger that writes the messages of at least the
+// given level (see ParseLogLevel) to out in the given format (see LogFormats).
+func NewLogger(out io.Writer, level string, format string) (*SyncLogger, error) {
+	minLevel, err := ParseLogLevel(level)
+	if err != nil {
+		return nil, err
+	}
+	if format != LogFormatPlain && format != LogFormatJSON {
+		return nil, fmt.Errorf("unknown log format %q, it must be one of the following: %v", format, LogFormats)
+	}
+
+	return &SyncLogger{
+		logger:   log.New(out, "", 0),
+		json:     format == LogFormatJSON,
+		minLevel: int32(levelIndex(minLevel)),
+	}, nil
+}
+
 // SetOutput sets the output destination for the standard logger.
 func (sl *SyncLogger) SetOutput(w io.Writer) {
 	sl.logger.SetOutput(w)
 }
 
-// Debug calls sl.Output to print a debug message to the logger.
-func (sl *SyncLogger) Debug(format string, v ...interface{}) {
+// SetLevel sets the minimum level of the messages that are logged at runtime (see
+// ParseLogLevel), including the one of a logutils.LevelFilter the output is filtered
+// by. It returns false if the level is unknown.
+func (sl *SyncLogger) SetLevel(level string) bool {
+	minLevel, err := ParseLogLevel(level)
+	if err != nil {
+		return false
+	}
 	sl.Lock()
 	defer sl.Unlock()
-	taggedFormat := fmt.Sprintf("[DEBUG] signctrl: %v", format)
-	_ = sl.logger.Output(2, fmt.Sprintf(taggedFormat, v...))
+	atomic.StoreInt32(&sl.minLevel, int32(levelIndex(minLevel)))
+	if filter, ok := sl.logger.Writer().(*logutils.LevelFilter); ok {
+		filter.SetMinLevel(minLevel)
+	}
+
+	return true
 }
 
-// Info calls sl.Output to print an info message to the logger.
-func (sl *SyncLogger) Info(format string, v ...interface{}) {
+// Enabled checks whether messages of the given level are logged, so that callers can
+// skip building expensive messages that would be dropped anyway.
+func (sl *SyncLogger) Enabled(level logutils.LogLevel) bool {
+	return int32(levelIndex(level)) >= atomic.LoadInt32(&sl.minLevel)
+}
+
+// logEntry is a message logged in the LogFormatJSON format.
+type logEntry struct {
+	Time   string `json:"time"`
+	Level  string `json:"level"`
+	Module string `json:"module"`
+	Msg    string `json:"msg"`
+}
+
+// output prints the given message with the given level and tag to the logger, unless
+// its level is below the minimum level, in which case it isn't even formatted.
+func (sl *SyncLogger) output(level logutils.LogLevel, tag string, format string, v ...interface{}) {
+	if !sl.Enabled(level) {
+		return
+	}
 	sl.Lock()
 	defer sl.Unlock()
-	taggedFormat := fmt.Sprintf("[INFO]  signctrl: %v", format)
-	_ = sl.logger.Output(2, fmt.Sprintf(taggedFormat, v...))
+	if !sl.json {
+		taggedFormat := fmt.Sprintf("%v signctrl: %v", tag, format)
+		_ = sl.logger.Output(3, fmt.Sprintf(taggedFormat, v...))
+		return
+	}
+
+	name := strings.ToLower(string(level))
+	if level == "ERR" {
+		name = "error"
+	}
+	bz, err := json.Marshal(logEntry{
+		Time:   time.Now().UTC().Format(time.RFC3339Nano),
+		Level:  name,
+		Module: "signctrl",
+		Msg:    fmt.Sprintf(format, v...),
+	})
+	if err != nil {
+		return
+	}
+	_ = sl.logger.Output(3, string(bz))
+}
+
+// Debug calls sl.Output to print a debug message to the logger.
+func (sl *SyncLogger) Debug(format string, v ...interface{}) {
+	sl.output("DEBUG", "[DEBUG]", format, v...)
+}
+
+// Info calls sl.Output to print an info message to the logger.
+func (sl *SyncLogger) Info(format string, v ...interface{}) {
+	sl.output("INFO", "[INFO] ", format, v...)
 }
 
 // Warn calls sl.Output to print a warning message to the logger.
 func (sl *SyncLogger) Warn(format string, v ...interface{}) {
-	sl.Lock()
-	defer sl.Unlock()
-	taggedFormat := fmt.Sprintf("[WARN]  signctrl: %v", format)
-	_ = sl.logger.Output(2, fmt.Sprintf(taggedFormat, v...))
+	sl.output("WARN", "[WARN] ", format, v...)
 }
 
 // Error calls sl.Output to print an error message to the logger.
 func (sl *SyncLogger) Error(format string, v ...interface{}) {
-	sl.Lock()
-	defer sl.Unlock()
-	taggedFormat := fmt.Sprintf("[ERR]   signctrl: %v", format)
-	_ = sl.logger.Output(2, fmt.Sprintf(taggedFormat, v...))
+	sl.output("ERR", "[ERR]  ", format, v...)
 }
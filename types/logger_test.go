@@ -1,8 +1,17 @@
 package types
 
 import (
+	"bytes"
+	"encoding/json"
 	"os"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/hashicorp/logutils"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestSyncLoggerDebug(t *testing.T) {
@@ -32,3 +41,126 @@ func TestSyncLoggerError(t *testing.T) {
 	// Output:
 	// [ERR] signctrl: Debug test msg
 }
+
+func TestSyncLoggerSetLevel(t *testing.T) {
+	var buf bytes.Buffer
+	sl := NewSyncLogger(os.Stderr, "", 0)
+	assert.False(t, sl.SetLevel("VERBOSE"))
+
+	sl.SetOutput(&logutils.LevelFilter{Levels: LogLevels, MinLevel: "INFO", Writer: &buf})
+	sl.Debug("hidden")
+	assert.True(t, sl.SetLevel("DEBUG"))
+	sl.Debug("shown")
+	assert.Equal(t, "[DEBUG] signctrl: shown\n", buf.String())
+
+	// The level is also applied without a LevelFilter, in any case.
+	buf.Reset()
+	sl.SetOutput(&buf)
+	assert.True(t, sl.SetLevel("error"))
+	sl.Warn("hidden")
+	sl.Error("shown")
+	assert.Equal(t, "[ERR]   signctrl: shown\n", buf.String())
+}
+
+func TestSyncLoggerEnabled(t *testing.T) {
+	sl := NewSyncLogger(os.Stderr, "", 0)
+	for _, level := range LogLevels {
+		assert.True(t, sl.Enabled(level), level)
+	}
+	assert.True(t, sl.SetLevel("warn"))
+	assert.False(t, sl.Enabled("DEBUG"))
+	assert.False(t, sl.Enabled("INFO"))
+	assert.True(t, sl.Enabled("WARN"))
+	assert.True(t, sl.Enabled("ERR"))
+
+	// Messages below the minimum level aren't formatted at all.
+	var buf bytes.Buffer
+	sl.SetOutput(&buf)
+	formatted := false
+	sl.Info("%v", stringerFunc(func() string { formatted = true; return "hidden" }))
+	assert.False(t, formatted)
+	assert.Empty(t, buf.String())
+}
+
+// stringerFunc implements fmt.Stringer with a function.
+type stringerFunc func() string
+
+func (f stringerFunc) String() string { return f() }
+
+func TestSyncLogger_Concurrent(t *testing.T) {
+	var buf bytes.Buffer
+	sl, err := NewLogger(&buf, "debug", LogFormatPlain)
+	assert.NoError(t, err)
+
+	// Logging while the level is changed must neither race nor interleave lines.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				sl.Debug("debug %v-%v", i, j)
+				sl.Info("info %v-%v", i, j)
+				sl.Warn("warn %v-%v", i, j)
+				sl.Error("error %v-%v", i, j)
+			}
+		}(i)
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 100; j++ {
+			sl.SetLevel(string(LogLevels[j%len(LogLevels)]))
+		}
+	}()
+	wg.Wait()
+
+	line := regexp.MustCompile(`^\[(DEBUG|INFO|WARN|ERR)\] +signctrl: (debug|info|warn|error) [0-7]-[0-9]+$`)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	for _, l := range lines {
+		assert.Regexp(t, line, l)
+	}
+
+	// Errors are never dropped.
+	assert.Equal(t, 800, strings.Count(buf.String(), "signctrl: error"))
+}
+
+func TestNewLogger(t *testing.T) {
+	_, err := NewLogger(os.Stderr, "verbose", LogFormatPlain)
+	assert.Error(t, err)
+	_, err = NewLogger(os.Stderr, "info", "xml")
+	assert.Error(t, err)
+
+	// Debug messages are suppressed at the info level.
+	var buf bytes.Buffer
+	sl, err := NewLogger(&buf, "info", LogFormatPlain)
+	assert.NoError(t, err)
+	sl.Debug("hidden")
+	sl.Info("shown %v", 1)
+	assert.Equal(t, "[INFO]  signctrl: shown 1\n", buf.String())
+}
+
+func TestNewLogger_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	sl, err := NewLogger(&buf, "debug", LogFormatJSON)
+	assert.NoError(t, err)
+	sl.Debug("debug %q", "msg")
+	sl.Error("error msg")
+
+	// Every line is a JSON object of its own.
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assert.Len(t, lines, 2)
+	var entries []map[string]string
+	for _, line := range lines {
+		var entry map[string]string
+		assert.NoError(t, json.Unmarshal([]byte(line), &entry), line)
+		_, err := time.Parse(time.RFC3339Nano, entry["time"])
+		assert.NoError(t, err)
+		delete(entry, "time")
+		entries = append(entries, entry)
+	}
+	assert.Equal(t, []map[string]string{
+		{"level": "debug", "module": "signctrl", "msg": `debug "msg"`},
+		{"level": "error", "module": "signctrl", "msg": "error msg"},
+	}, entries)
+}
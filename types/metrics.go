@@ -0,0 +1,64 @@
+package types
+
+// Counter is a cumulative metric that only goes up, e.g. a prometheus.Counter.
+type Counter interface {
+	Add(float64)
+}
+
+// Gauge is a metric that can go up and down, e.g. a prometheus.Gauge.
+type Gauge interface {
+	Set(float64)
+}
+
+// Metrics are the counters and gauges a BaseSignCtrled updates on its state
+// transitions.
+type Metrics struct {
+	// BlocksObserved counts the blocks seen, whether they have been signed, missed or
+	// not counted, e.g. while the counter is locked.
+	BlocksObserved Counter
+
+	// BlocksMissed counts the missed blocks that have been counted.
+	BlocksMissed Counter
+
+	// Resets counts the resets of the counter for missed blocks in a row.
+	Resets Counter
+
+	// Promotions counts the validator's promotions.
+	Promotions Counter
+
+	// LockedSeconds counts the seconds the counter for missed blocks in a row has been
+	// locked for. It is updated once the counter is unlocked.
+	LockedSeconds Counter
+
+	// MissedInARow is the current counter for missed blocks in a row.
+	MissedInARow Gauge
+}
+
+// nopMetric is a Counter and Gauge that discards all updates.
+type nopMetric struct{}
+
+func (nopMetric) Add(float64) {}
+func (nopMetric) Set(float64) {}
+
+// NopMetrics returns metrics that discard all updates. They are used by default.
+func NopMetrics() Metrics {
+	return Metrics{
+		BlocksObserved: nopMetric{},
+		BlocksMissed:   nopMetric{},
+		Resets:         nopMetric{},
+		Promotions:     nopMetric{},
+		LockedSeconds:  nopMetric{},
+		MissedInARow:   nopMetric{},
+	}
+}
+
+// Option configures a BaseSignCtrled on creation.
+type Option func(*BaseSignCtrled)
+
+// WithMetrics makes a BaseSignCtrled update the given metrics on its state
+// transitions. All of them must be set.
+func WithMetrics(metrics Metrics) Option {
+	return func(bsc *BaseSignCtrled) {
+		bsc.metrics = metrics
+	}
+}
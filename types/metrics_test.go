@@ -0,0 +1,94 @@
+package types
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeMetric records the value of a Counter or Gauge.
+type fakeMetric struct {
+	value float64
+}
+
+func (m *fakeMetric) Add(v float64) { m.value += v }
+func (m *fakeMetric) Set(v float64) { m.value = v }
+
+// fakeMetrics records the values of Metrics.
+type fakeMetrics struct {
+	blocksObserved, blocksMissed, resets, promotions, lockedSeconds, missedInARow fakeMetric
+}
+
+func (m *fakeMetrics) metrics() Metrics {
+	return Metrics{
+		BlocksObserved: &m.blocksObserved,
+		BlocksMissed:   &m.blocksMissed,
+		Resets:         &m.resets,
+		Promotions:     &m.promotions,
+		LockedSeconds:  &m.lockedSeconds,
+		MissedInARow:   &m.missedInARow,
+	}
+}
+
+func TestWithMetrics(t *testing.T) {
+	var m fakeMetrics
+	sc := &testSignCtrled{}
+	bsc, err := NewBaseSignCtrled(nil, 2, 3, 3, sc, WithMetrics(m.metrics()))
+	assert.NoError(t, err)
+	sc.BaseSignCtrled = *bsc
+
+	// Blocks missed while the counter is locked are observed, but not counted.
+	assert.ErrorIs(t, sc.miss(), ErrCounterLocked)
+	assert.Equal(t, 1.0, m.blocksObserved.value)
+	assert.Zero(t, m.blocksMissed.value)
+
+	// The time spent locked is added on unlock.
+	time.Sleep(10 * time.Millisecond)
+	sc.UnlockCounter()
+	assert.GreaterOrEqual(t, m.lockedSeconds.value, 0.01)
+
+	assert.NoError(t, sc.miss())
+	assert.Equal(t, 2.0, m.blocksObserved.value)
+	assert.Equal(t, 1.0, m.blocksMissed.value)
+	assert.Equal(t, 1.0, m.missedInARow.value)
+
+	// A promotion resets the counter.
+	assert.ErrorIs(t, sc.miss(), ErrThresholdExceeded)
+	assert.Equal(t, 3.0, m.blocksObserved.value)
+	assert.Equal(t, 2.0, m.blocksMissed.value)
+	assert.Equal(t, 1.0, m.promotions.value)
+	assert.Equal(t, 1.0, m.resets.value)
+	assert.Zero(t, m.missedInARow.value)
+
+	// Blocks skipped in the grace period are observed, too.
+	assert.ErrorIs(t, sc.miss(), ErrAlreadyCounted)
+	assert.Equal(t, 4.0, m.blocksObserved.value)
+	assert.Equal(t, 2.0, m.blocksMissed.value)
+
+	// Signed blocks are observed and reset the counter.
+	assert.NoError(t, sc.miss())
+	sc.SetCurrentHeight(sc.height + 1)
+	sc.Signed()
+	assert.Equal(t, 6.0, m.blocksObserved.value)
+	assert.Equal(t, 3.0, m.blocksMissed.value)
+	assert.Equal(t, 2.0, m.resets.value)
+	assert.Zero(t, m.missedInARow.value)
+
+	// Locking the counter again restarts the time spent locked.
+	sc.LockCounter()
+	locked := m.lockedSeconds.value
+	sc.UnlockCounter()
+	assert.Less(t, m.lockedSeconds.value-locked, 0.01)
+	assert.Equal(t, 1.0, m.promotions.value)
+}
+
+func TestNopMetrics(t *testing.T) {
+	// The default metrics discard all updates.
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 2, 2, 3, sc)
+	sc.UnlockCounter()
+	assert.NoError(t, sc.miss())
+	assert.ErrorIs(t, sc.miss(), ErrThresholdExceeded)
+	assert.Equal(t, NopMetrics(), sc.metrics)
+}
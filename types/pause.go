@@ -0,0 +1,98 @@
+package types
+
+import (
+	"errors"
+	"time"
+)
+
+// PauseReminderInterval is the interval in which a reminder is logged while the
+// monitoring of missed blocks is paused.
+const PauseReminderInterval = 5 * time.Minute
+
+var (
+	// ErrPaused is returned when a missed block isn't counted because the monitoring
+	// of missed blocks is paused.
+	ErrPaused = errors.New("monitoring of missed blocks is paused")
+)
+
+// Pause pauses the monitoring of missed blocks for the given reason, e.g. during a
+// coordinated chain upgrade, so that no blocks are counted and no rank updates are
+// triggered until it is resumed. Unlike the counter lock, it is never lifted
+// automatically, so a reminder is logged every PauseReminderInterval.
+func (bsc *BaseSignCtrled) Pause(reason string) {
+	bsc.mtx.Lock()
+	if bsc.paused {
+		bsc.pauseReason = reason
+		bsc.mtx.Unlock()
+		bsc.notifyStateChange()
+		return
+	}
+	bsc.Logger.Warn("Paused monitoring of missed blocks: %v", reason)
+	bsc.paused = true
+	bsc.pauseReason = reason
+	bsc.pausedAt = bsc.clock.Now()
+	bsc.pauseStop = make(chan struct{})
+	go bsc.remindPaused(bsc.pauseStop, bsc.clock.NewTicker(bsc.pauseReminder))
+	bsc.emit(EventPaused, bsc.currentHeight)
+	bsc.mtx.Unlock()
+
+	bsc.notifyStateChange()
+}
+
+// Resume resumes the monitoring of missed blocks. The counter for missed blocks in a
+// row is locked, so that it only counts again once a fresh commitsig from the
+// validator has been seen.
+func (bsc *BaseSignCtrled) Resume() {
+	bsc.mtx.Lock()
+	if !bsc.paused {
+		bsc.mtx.Unlock()
+		return
+	}
+	bsc.Logger.Info("Resumed monitoring of missed blocks after %v, looking for first commitsig from validator before counting missed blocks in a row...", bsc.clock.Now().Sub(bsc.pausedAt).Round(time.Second))
+	bsc.paused = false
+	bsc.pauseReason = ""
+	close(bsc.pauseStop)
+	bsc.counterLocked = true
+	bsc.lockedAt = bsc.clock.Now()
+	bsc.lockedBlocks = 0
+	bsc.signStreak = 0
+	bsc.clearWindow()
+	bsc.emit(EventResumed, bsc.currentHeight)
+	bsc.mtx.Unlock()
+
+	bsc.notifyStateChange()
+}
+
+// IsPaused checks whether the monitoring of missed blocks is paused.
+func (bsc *BaseSignCtrled) IsPaused() bool {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+	return bsc.paused
+}
+
+// GetPauseReason returns the reason the monitoring of missed blocks is paused for, or
+// an empty string if it isn't paused.
+func (bsc *BaseSignCtrled) GetPauseReason() string {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+	return bsc.pauseReason
+}
+
+// remindPaused logs a reminder on every tick of the given ticker that the monitoring
+// of missed blocks is paused until the given channel is closed.
+func (bsc *BaseSignCtrled) remindPaused(stop <-chan struct{}, ticker Ticker) {
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+
+		case <-ticker.C():
+			bsc.mtx.RLock()
+			reason, since := bsc.pauseReason, bsc.clock.Now().Sub(bsc.pausedAt).Round(time.Second)
+			bsc.mtx.RUnlock()
+			bsc.Logger.Warn("Monitoring of missed blocks is still paused for %v (%v), no rank updates are triggered until it is resumed", since, reason)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package types
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// syncBuffer is a bytes.Buffer that is safe for concurrent use.
+type syncBuffer struct {
+	mtx sync.Mutex
+	buf bytes.Buffer
+}
+
+func (sb *syncBuffer) Write(p []byte) (int, error) {
+	sb.mtx.Lock()
+	defer sb.mtx.Unlock()
+	return sb.buf.Write(p)
+}
+
+func (sb *syncBuffer) String() string {
+	sb.mtx.Lock()
+	defer sb.mtx.Unlock()
+	return sb.buf.String()
+}
+
+func TestPause(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 3, 2, 3, sc)
+	events := sc.Subscribe(10)
+	sc.UnlockCounter()
+	assert.NoError(t, sc.miss())
+
+	// While paused, the counter is frozen.
+	sc.Pause("chain upgrade")
+	assert.True(t, sc.IsPaused())
+	assert.Equal(t, "chain upgrade", sc.GetPauseReason())
+	for i := 0; i < 5; i++ {
+		assert.ErrorIs(t, sc.miss(), ErrPaused)
+	}
+	sc.Signed()
+	assert.Equal(t, 1, sc.GetMissedInARow())
+	assert.Equal(t, 2, sc.GetRank())
+	assert.Equal(t, StateSnapshot{Rank: 2, SetSize: 3, Threshold: 3, MissedInARow: 1, CurrentHeight: 1, Paused: true, PauseReason: "chain upgrade", TotalMissed: 1, LongestMissedStreak: 1, LastUnlockedAt: sc.GetStateSnapshot().LastUnlockedAt}, sc.GetStateSnapshot())
+
+	// Resuming locks the counter until a fresh commitsig arrives.
+	sc.Resume()
+	assert.False(t, sc.IsPaused())
+	assert.Empty(t, sc.GetPauseReason())
+	assert.True(t, sc.IsCounterLocked())
+	assert.ErrorIs(t, sc.miss(), ErrCounterLocked)
+	sc.Signed()
+	sc.UnlockCounter()
+	assert.NoError(t, sc.miss())
+
+	kinds := []EventKind{}
+	for _, e := range receive(t, events) {
+		kinds = append(kinds, e.Kind)
+	}
+	assert.Equal(t, []EventKind{EventCounterUnlocked, EventMissed, EventPaused, EventResumed, EventCounterUnlocked, EventMissed}, kinds)
+
+	// Resuming without being paused does nothing.
+	sc.Resume()
+	assert.False(t, sc.IsCounterLocked())
+}
+
+func TestPause_Reminder(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 3, 2, 3, sc)
+	var buf syncBuffer
+	sc.Logger = NewSyncLogger(&buf, "", 0)
+	sc.pauseReminder = 10 * time.Millisecond
+
+	sc.Pause("chain upgrade")
+	assert.Eventually(t, func() bool {
+		return strings.Count(buf.String(), "still paused") >= 2
+	}, time.Second, 5*time.Millisecond)
+	assert.Contains(t, buf.String(), "(chain upgrade)")
+
+	// No more reminders are logged once resumed.
+	sc.Resume()
+	time.Sleep(20 * time.Millisecond)
+	reminders := strings.Count(buf.String(), "still paused")
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, reminders, strings.Count(buf.String(), "still paused"))
+}
@@ -0,0 +1,25 @@
+package types
+
+// Peer is another SignCTRL node of the set.
+type Peer struct {
+	// Address is the host:port address the peer is reachable on.
+	Address string `json:"address"`
+
+	// ConnPubKey is the base64 or bech32 encoded public key the peer uses for secret
+	// connections.
+	ConnPubKey string `json:"conn_pub_key"`
+}
+
+// WithPeers makes a BaseSignCtrled know about the other SignCTRL nodes of the set.
+func WithPeers(peers []Peer) Option {
+	return func(bsc *BaseSignCtrled) {
+		bsc.peers = append([]Peer(nil), peers...)
+	}
+}
+
+// GetPeers returns the other SignCTRL nodes of the set.
+func (bsc *BaseSignCtrled) GetPeers() []Peer {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+	return append([]Peer(nil), bsc.peers...)
+}
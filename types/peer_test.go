@@ -0,0 +1,31 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithPeers(t *testing.T) {
+	sc := &testSignCtrled{}
+	bsc, err := NewBaseSignCtrled(nil, 5, 2, 3, sc)
+	assert.NoError(t, err)
+	assert.Empty(t, bsc.GetPeers())
+	assert.Empty(t, bsc.GetStateSnapshot().Peers)
+
+	peers := []Peer{
+		{Address: "10.0.0.1:26660", ConnPubKey: "key1"},
+		{Address: "10.0.0.2:26660", ConnPubKey: "key2"},
+	}
+	bsc, err = NewBaseSignCtrled(nil, 5, 2, 3, sc, WithPeers(peers))
+	assert.NoError(t, err)
+	assert.Equal(t, peers, bsc.GetPeers())
+	assert.Equal(t, peers, bsc.GetStateSnapshot().Peers)
+	assert.Equal(t, 3, bsc.GetStateSnapshot().SetSize)
+
+	// The peers are copied, so that they can't be changed from outside.
+	peers[0].Address = "10.0.0.3:26660"
+	bsc.GetPeers()[1].Address = "10.0.0.4:26660"
+	assert.Equal(t, "10.0.0.1:26660", bsc.GetPeers()[0].Address)
+	assert.Equal(t, "10.0.0.2:26660", bsc.GetPeers()[1].Address)
+}
@@ -2,7 +2,20 @@ package types
 
 import (
 	"errors"
+	"fmt"
 	"io/ioutil"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMissedBlockLogInterval is the default interval in which missed blocks are
+	// logged between the first and the last ones before the threshold.
+	DefaultMissedBlockLogInterval = 10
+
+	// missedBlockLogEdge is the number of first and last missed blocks before the
+	// threshold that are always logged.
+	missedBlockLogEdge = 3
 )
 
 var (
@@ -17,46 +30,570 @@ var (
 	// ErrCounterLocked is returned when the counter for missed blocks in a row is
 	// still locked due to SignCTRL not having seen a signed block from rank 1.
 	ErrCounterLocked = errors.New("waiting for first commitsig from validator to unlock counter for missed blocks in a row")
+
+	// ErrAlreadyCounted is returned when a missed block is counted for a height that
+	// has already been counted or skipped, or that lies below the current height.
+	ErrAlreadyCounted = errors.New("missed block has already been counted for this height")
+
+	// ErrInvalidThreshold is returned when a BaseSignCtrled is created with a threshold
+	// that never lets the validator see the signature of the new rank 1 in time.
+	ErrInvalidThreshold = errors.New("threshold must be 2 or higher")
+
+	// ErrInvalidRank is returned when a BaseSignCtrled is created with a rank that
+	// doesn't exist in its set.
+	ErrInvalidRank = errors.New("rank must be between 1 and the set size")
+
+	// ErrInvalidSetSize is returned when a BaseSignCtrled is created with a set that
+	// has no ranks.
+	ErrInvalidSetSize = errors.New("set size must be 1 or higher")
+)
+
+// ThresholdExceededError is returned when a threshold is exceeded and the validator
+// has been promoted. It carries the context of the rank update and wraps
+// ErrThresholdExceeded, so that it can be checked for with errors.Is.
+type ThresholdExceededError struct {
+	Height    int64
+	OldRank   int
+	NewRank   int
+	Missed    int
+	Threshold int
+}
+
+// Error implements the error interface.
+func (e *ThresholdExceededError) Error() string {
+	return fmt.Sprintf("%v (%v/%v) at block height %v, promoted validator (%v -> %v)", ErrThresholdExceeded, e.Missed, e.Threshold, e.Height, e.OldRank, e.NewRank)
+}
+
+// Unwrap returns ErrThresholdExceeded.
+func (e *ThresholdExceededError) Unwrap() error {
+	return ErrThresholdExceeded
+}
+
+// MustShutdownError is returned when a threshold is exceeded on rank 1. It carries
+// the final block height of the validator and wraps ErrMustShutdown, so that it can be
+// checked for with errors.Is.
+type MustShutdownError struct {
+	Height int64
+}
+
+// Error implements the error interface.
+func (e *MustShutdownError) Error() string {
+	return fmt.Sprintf("%v (final block height %v)", ErrMustShutdown, e.Height)
+}
+
+// Unwrap returns ErrMustShutdown.
+func (e *MustShutdownError) Unwrap() error {
+	return ErrMustShutdown
+}
+
+// StateSnapshot is a snapshot of the state of a BaseSignCtrled at one point in time.
+type StateSnapshot struct {
+	Rank             int       `json:"rank"`
+	SetSize          int       `json:"set_size"`
+	Threshold        int       `json:"threshold"`
+	MissedInARow     int       `json:"missed_in_a_row"`
+	CurrentHeight    int64     `json:"current_height"`
+	CounterLocked    bool      `json:"counter_locked"`
+	LastSignedHeight int64     `json:"last_signed_height"`
+	LastLockedAt     time.Time `json:"last_locked_at"`
+	LastUnlockedAt   time.Time `json:"last_unlocked_at"`
+	Paused           bool      `json:"paused"`
+	PauseReason      string    `json:"pause_reason"`
+
+	// PromoteReason is the reason of the last promotion, if there has been one.
+	PromoteReason PromoteReason `json:"promote_reason"`
+
+	// LastHeightGap is the number of heights skipped up to LastHeightGapAt, or the
+	// negative difference to the height rejected at LastHeightGapAt if it went
+	// backwards. Both are 0 if no gap has been observed.
+	LastHeightGap   int64 `json:"last_height_gap"`
+	LastHeightGapAt int64 `json:"last_height_gap_at"`
+
+	// TotalMissed and LongestMissedStreak are the total number of missed blocks
+	// counted since the start and the highest number of them in a row.
+	// EstimatedDowntime is TotalMissed multiplied by AvgBlockTime, the weighted
+	// average of the time between blocks.
+	TotalMissed         int64         `json:"total_missed"`
+	LongestMissedStreak int           `json:"longest_missed_streak"`
+	AvgBlockTime        time.Duration `json:"avg_block_time"`
+	EstimatedDowntime   time.Duration `json:"estimated_downtime"`
+
+	// PromotionSuppressed is set while automatic promotions are suppressed due to
+	// too many of them within the auto-promotion window.
+	PromotionSuppressed bool `json:"promotion_suppressed"`
+
+	// Peers are the other SignCTRL nodes of the set, if they are known.
+	Peers []Peer `json:"peers"`
+}
+
+// PromoteReason is the reason a validator has been promoted for.
+type PromoteReason string
+
+const (
+	// PromoteReasonThresholdExceeded is the reason of a promotion triggered by the
+	// validator missing too many blocks or signatures.
+	PromoteReasonThresholdExceeded PromoteReason = "threshold_exceeded"
+
+	// PromoteReasonManual is the reason of a promotion requested by an operator.
+	PromoteReasonManual PromoteReason = "manual"
+
+	// PromoteReasonPeerCoordination is the reason of a promotion agreed on with the
+	// other nodes of the set.
+	PromoteReasonPeerCoordination PromoteReason = "peer_coordination"
 )
 
+// MissedContext describes the missed blocks that triggered a rank update. Its values
+// are captured before the validator is promoted.
+type MissedContext struct {
+	// Height is the block height the threshold has been exceeded at.
+	Height int64
+
+	// Missed is the number of missed blocks in a row, or the ones counted by the
+	// strategy, that exceeded the threshold.
+	Missed int
+
+	// Threshold is the effective threshold that has been exceeded.
+	Threshold int
+
+	// Rank is the validator's rank before the promotion.
+	Rank int
+}
+
 // SignCtrled defines the functionality of a SignCTRL PrivValidator that monitors the
 // blockchain for missed blocks in a row and keeps its rank up to date.
+// OnMissedTooMany is called whenever missed blocks exceed the threshold, with their
+// MissedContext captured before the resulting promotion or shutdown, and before
+// OnPromote.
+// OnPromote is called with the reason of the promotion once the validator has been
+// promoted. If it returns an error, the promotion fails with a MustShutdownError.
 type SignCtrled interface {
-	Missed() error
-	OnMissedTooMany()
+	Missed(height int64) error
+	OnMissedTooMany(mc MissedContext)
 
 	Reset()
 
 	Promote() error
-	OnPromote()
+	PromoteWithReason(reason PromoteReason) error
+	OnPromote(reason PromoteReason) error
+
+	Demote(rank int) error
+
+	OnStateChange()
 }
 
 // BaseSignCtrled is a base implementation of SignCtrled.
+// It is safe for concurrent use. The hooks of the implementation are always called
+// without holding the lock, so that they can use the getters.
 type BaseSignCtrled struct {
-	Logger        *SyncLogger
+	Logger *SyncLogger
+
+	// mtx is a pointer, so that copies of a BaseSignCtrled created by
+	// NewBaseSignCtrled share the lock.
+	mtx           *sync.RWMutex
 	counterLocked bool
 	currentHeight int64
 	missedInARow  int
-	threshold     int
 	rank          int
 
+	// setSize is the number of validators in the set, which is the last rank.
+	// peers are the other SignCTRL nodes of the set, if they are known.
+	setSize int
+	peers   []Peer
+
+	// lastSignedHeight is the current height the validator's signature has last been
+	// seen at. lockedAt and unlockedAt are the times the counter has last been locked
+	// and unlocked at, which are zero if it never has been.
+	lastSignedHeight int64
+	lockedAt         time.Time
+	unlockedAt       time.Time
+
+	// The metrics are updated on every state transition. The time the counter has
+	// been locked for is added once it is unlocked. As the counter starts out locked,
+	// it is counted from startedAt if the counter has never been locked explicitly.
+	metrics   Metrics
+	startedAt time.Time
+
+	// clock tells the time for all time-based behavior, so that it can be faked in
+	// tests.
+	clock Clock
+
+	// If no commitsig from the validator is seen for unlockAfter blocks in a row while
+	// the counter is locked, it is unlocked anyway, so that a validator that is
+	// already down when SignCTRL starts is replaced eventually. lockedBlocks is the
+	// number of blocks seen while locked, up to lastLocked. 0 disables it.
+	unlockAfter  int
+	lockedBlocks int
+	lastLocked   int64
+
+	// The counter is only unlocked once the validator's commitsig has been found in
+	// signsToUnlock commits in a row, which signStreak keeps track of, so that a
+	// single signature after a flaky restart doesn't reset the counter.
+	signsToUnlock int
+	signStreak    int
+
+	// lastCounted is the last height a missed block has been counted for, or that is
+	// skipped after a rank update, so that no block is counted twice.
+	lastCounted int64
+
+	// Missed blocks are only logged every missLogEvery blocks, except for the
+	// first and the last ones before the threshold, so that large thresholds don't
+	// flood the logs.
+	missLogEvery int
+
+	// After a promotion, missed blocks are skipped for grace blocks up to graceUntil,
+	// as the blocks right after it can't contain the validator's signature yet.
+	grace      int
+	graceUntil int64
+
+	// The threshold is the effective threshold on the current rank. It is either
+	// configured for the rank explicitly, or the base threshold increased by the
+	// stagger for every rank below rank 2, so that lower ranks wait progressively
+	// longer before they are promoted.
+	threshold     int
+	baseThreshold int
+	stagger       int
+	thresholds    map[int]int
+
+	// The window keeps track of which of the last blocks have been missed, so that
+	// strategies can promote validators missing most, but not all blocks in a row.
+	// It is a ring buffer, which is disabled if it has no capacity.
+	window       []bool
+	windowNext   int
+	windowLen    int
+	windowMissed int
+
+	// The strategy decides whether the validator is promoted after a missed block.
+	strategy RankStrategy
+
+	// After a promotion at promotedAt, further promotions are deferred for cooldown
+	// blocks, so that an unstable chain doesn't promote a validator several ranks
+	// at once. promotedAt is 0 if the validator has never been promoted.
+	cooldown         int
+	promotedAt       int64
+	promotionPending bool
+
+	// promoteReason is the reason the validator has last been promoted for.
+	promoteReason PromoteReason
+
+	// Automatic promotions are suppressed once maxAutoPromotions of them have taken
+	// place within autoPromotionWindow, which is disabled if it is 0. autoPromotions
+	// are the times of the ones in the window.
+	maxAutoPromotions   int
+	autoPromotionWindow time.Duration
+	autoPromotions      []time.Time
+	promotionSuppressed bool
+
+	// The block history keeps the outcome of the last historySize blocks for
+	// investigating incidents. It is a ring buffer, which is disabled if historySize
+	// is 0.
+	blockHistory []BlockRecord
+	historyNext  int
+	historySize  int
+
+	// While paused, no blocks are counted until the monitoring is resumed. A
+	// reminder is logged every pauseReminder until pauseStop is closed.
+	paused        bool
+	pauseReason   string
+	pausedAt      time.Time
+	pauseStop     chan struct{}
+	pauseReminder time.Duration
+
+	// Heights lower than the current one are rejected, and the counter is locked on
+	// them if lockOnRegression is set. Jumps skipping more than maxHeightGap heights
+	// lock the counter, which is disabled if it is 0. Neither is checked before a
+	// height has been seen. lastGap and lastGapAt are the last observed gap and the
+	// height it has been observed at.
+	heightSeen       bool
+	lockOnRegression bool
+	maxHeightGap     int64
+	lastGap          int64
+	lastGapAt        int64
+
+	// The lifetime stats keep the total number of counted missed blocks and the
+	// longest streak of them. avgBlockTime is the exponentially weighted average of
+	// the time between the heights set, the last one of which has been set at
+	// lastHeightAt. It is used to estimate the downtime from the missed blocks.
+	totalMissed   int64
+	longestStreak int
+	avgBlockTime  time.Duration
+	lastHeightAt  time.Time
+
+	// State transitions are emitted to the subscribers as events. Events that don't
+	// fit into a subscriber's buffer are dropped and counted.
+	subscribers   []chan Event
+	droppedEvents uint64
+
+	// The validator's signature not being seen for thresholdDuration triggers a rank
+	// update as well, even if no blocks arrive. It is disabled if it is 0.
+	thresholdDuration time.Duration
+	lastSignedAt      time.Time
+
 	impl SignCtrled
 }
 
-// NewBaseSignCtrled creates a new instance of BaseSignCtrled.
-func NewBaseSignCtrled(logger *SyncLogger, threshold int, rank int, impl SignCtrled) *BaseSignCtrled {
+// NewBaseSignCtrled creates a new instance of BaseSignCtrled in a set of the given
+// size, configured by the given options. An error is returned if the threshold is
+// lower than 2, the set size is lower than 1 or the rank isn't in the set.
+func NewBaseSignCtrled(logger *SyncLogger, threshold int, rank int, setSize int, impl SignCtrled, opts ...Option) (*BaseSignCtrled, error) {
+	if threshold < 2 {
+		return nil, fmt.Errorf("%w, got %v", ErrInvalidThreshold, threshold)
+	}
+	if setSize < 1 {
+		return nil, fmt.Errorf("%w, got %v", ErrInvalidSetSize, setSize)
+	}
+	if rank < 1 || rank > setSize {
+		return nil, fmt.Errorf("%w of %v, got %v", ErrInvalidRank, setSize, rank)
+	}
 	if logger == nil {
 		logger = NewSyncLogger(ioutil.Discard, "", 0)
 	}
 
-	return &BaseSignCtrled{
+	bsc := &BaseSignCtrled{
 		Logger:        logger,
+		mtx:           new(sync.RWMutex),
 		counterLocked: true,
 		currentHeight: 1,
 		threshold:     threshold,
+		baseThreshold: threshold,
 		rank:          rank,
+		setSize:       setSize,
+		grace:         1,
+		signsToUnlock: 1,
+		missLogEvery:  DefaultMissedBlockLogInterval,
+		historySize:   DefaultBlockHistorySize,
+		pauseReminder: PauseReminderInterval,
+		strategy:      InARowStrategy{},
+		metrics:       NopMetrics(),
+		clock:         RealClock{},
 		impl:          impl,
 	}
+	for _, opt := range opts {
+		opt(bsc)
+	}
+	bsc.startedAt = bsc.clock.Now()
+
+	return bsc, nil
+}
+
+// SetThresholdStagger sets the number of blocks missed in a row that is added to the
+// threshold for every rank below rank 2 and updates the effective threshold.
+func (bsc *BaseSignCtrled) SetThresholdStagger(stagger int) {
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+	bsc.stagger = stagger
+	bsc.updateThreshold()
+}
+
+// SetThresholds sets the thresholds of blocks missed in a row for specific ranks,
+// which override the base threshold and the stagger, and updates the effective
+// threshold.
+func (bsc *BaseSignCtrled) SetThresholds(thresholds map[int]int) {
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+	bsc.thresholds = make(map[int]int, len(thresholds))
+	for rank, threshold := range thresholds {
+		bsc.thresholds[rank] = threshold
+	}
+	bsc.updateThreshold()
+}
+
+// thresholdOf returns the threshold on the given rank. Rank 1 uses the threshold of
+// rank 2, as it must notice that it has been replaced as soon as rank 2 is promoted.
+// The caller must hold the lock.
+func (bsc *BaseSignCtrled) thresholdOf(rank int) int {
+	if rank < 2 {
+		rank = 2
+	}
+	if threshold, ok := bsc.thresholds[rank]; ok {
+		return threshold
+	}
+
+	return bsc.baseThreshold + (rank-2)*bsc.stagger
+}
+
+// updateThreshold updates the effective threshold to the one of the current rank.
+// The caller must hold the lock.
+func (bsc *BaseSignCtrled) updateThreshold() {
+	if threshold := bsc.thresholdOf(bsc.rank); threshold != bsc.threshold {
+		bsc.Logger.Info("Effective threshold on rank %v is %v blocks missed in a row", bsc.rank, threshold)
+		bsc.threshold = threshold
+	}
+}
+
+// SetWindow makes the validator keep track of which of the given number of last blocks
+// have been missed, which strategies like the WindowStrategy decide on. A size of 0
+// disables the window.
+func (bsc *BaseSignCtrled) SetWindow(size int) {
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+	bsc.window = make([]bool, size)
+	bsc.clearWindow()
+}
+
+// SetRankStrategy sets the strategy that decides whether the validator is promoted
+// after a missed block. The InARowStrategy is used by default.
+func (bsc *BaseSignCtrled) SetRankStrategy(strategy RankStrategy) {
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+	bsc.strategy = strategy
+}
+
+// SetPromotionCooldown sets the number of blocks after a promotion during which
+// further promotions are deferred. A cool-down of 0 disables it.
+func (bsc *BaseSignCtrled) SetPromotionCooldown(blocks int) {
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+	bsc.cooldown = blocks
+}
+
+// coolingDown returns the number of blocks left in the cool-down after the last
+// promotion at the given height, or 0 if promotions are allowed. Rank 1 is never
+// cooling down, as it must shut down as soon as the validator below it is promoted.
+// The caller must hold the lock.
+func (bsc *BaseSignCtrled) coolingDown(height int64) int64 {
+	if bsc.rank == 1 || bsc.promotedAt == 0 {
+		return 0
+	}
+	if left := bsc.promotedAt + int64(bsc.cooldown) - height; left > 0 {
+		return left
+	}
+
+	return 0
+}
+
+// SetPostPromotionGrace sets the number of blocks after a promotion for which missed
+// blocks are skipped. It is 1 by default, as the block right after a promotion
+// contains the commit of the block that triggered it.
+func (bsc *BaseSignCtrled) SetPostPromotionGrace(blocks int) {
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+	bsc.grace = blocks
+}
+
+// SetMissedBlockLogInterval sets the interval in which missed blocks are logged
+// between the first and the last ones before the threshold. An interval of 1 logs all
+// of them.
+func (bsc *BaseSignCtrled) SetMissedBlockLogInterval(interval int) {
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+	bsc.missLogEvery = interval
+}
+
+// logsMissed checks whether the missed block that brings the counter to the given
+// number of missed blocks towards the given threshold is logged. The caller must hold
+// the lock.
+func (bsc *BaseSignCtrled) logsMissed(missed int, threshold int) bool {
+	return bsc.missLogEvery <= 1 ||
+		missed <= missedBlockLogEdge ||
+		missed >= threshold-missedBlockLogEdge ||
+		missed%bsc.missLogEvery == 0
+}
+
+// SetCounterUnlockAfter sets the number of blocks in a row without a commitsig from
+// the validator after which a locked counter for missed blocks in a row is unlocked
+// anyway. A number of 0 disables it.
+func (bsc *BaseSignCtrled) SetCounterUnlockAfter(blocks int) {
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+	bsc.unlockAfter = blocks
+}
+
+// SetConsecutiveSignsToUnlock sets the number of commits in a row the validator's
+// commitsig must be found in before the counter for missed blocks in a row is
+// unlocked. It is 1 by default.
+func (bsc *BaseSignCtrled) SetConsecutiveSignsToUnlock(signs int) {
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+	bsc.signsToUnlock = signs
+}
+
+// autoUnlock counts the block at the given height as seen while the counter is
+// locked, and unlocks the counter if unlockAfter blocks have been seen in a row. It
+// returns whether the counter has been unlocked. The caller must hold the lock.
+func (bsc *BaseSignCtrled) autoUnlock(height int64) bool {
+	if bsc.unlockAfter == 0 {
+		return false
+	}
+	bsc.lockedBlocks++
+	if bsc.lockedBlocks < bsc.unlockAfter {
+		bsc.Logger.Debug("Counter for missed blocks in a row is still locked (%v/%v)", bsc.lockedBlocks, bsc.unlockAfter)
+		return false
+	}
+
+	bsc.Logger.Warn("Haven't seen a commitsig from validator for %v blocks, unlocking the counter for missed blocks in a row anyway! If rank 1 is running, check the start order of the set!", bsc.lockedBlocks)
+	bsc.counterLocked = false
+	bsc.observeUnlock()
+	bsc.unlockedAt = bsc.clock.Now()
+	bsc.lockedBlocks = 0
+	bsc.signStreak = 0
+	bsc.emit(EventCounterUnlocked, height)
+
+	return true
+}
+
+// SetThresholdDuration enables the time-based rank update policy in addition to the
+// ones based on missed blocks. Once the validator's signature hasn't been seen for the
+// given duration, a rank update is triggered. A duration of 0 disables the policy.
+func (bsc *BaseSignCtrled) SetThresholdDuration(d time.Duration) {
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+	bsc.thresholdDuration = d
+	bsc.lastSignedAt = bsc.clock.Now()
+}
+
+// GetLastSignedAt returns the time at which the validator's signature has last been
+// seen in a commit, or at which the time-based rank update policy was last reset.
+func (bsc *BaseSignCtrled) GetLastSignedAt() time.Time {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+	return bsc.lastSignedAt
+}
+
+// GetMissedInWindow returns the number of blocks missed in the window.
+func (bsc *BaseSignCtrled) GetMissedInWindow() int {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+	return bsc.windowMissed
+}
+
+// record adds the given block to the window, replacing the oldest one if the window
+// is full. The caller must hold the lock.
+func (bsc *BaseSignCtrled) record(missed bool) {
+	if len(bsc.window) == 0 {
+		return
+	}
+	if bsc.windowLen == len(bsc.window) && bsc.window[bsc.windowNext] {
+		bsc.windowMissed--
+	} else if bsc.windowLen < len(bsc.window) {
+		bsc.windowLen++
+	}
+	bsc.window[bsc.windowNext] = missed
+	if missed {
+		bsc.windowMissed++
+	}
+	bsc.windowNext = (bsc.windowNext + 1) % len(bsc.window)
+}
+
+// clearWindow forgets all blocks in the window. The caller must hold the lock.
+func (bsc *BaseSignCtrled) clearWindow() {
+	for i := range bsc.window {
+		bsc.window[i] = false
+	}
+	bsc.windowNext = 0
+	bsc.windowLen = 0
+	bsc.windowMissed = 0
+}
+
+// history returns the history of missed blocks up to the given height. The caller
+// must hold the lock.
+func (bsc *BaseSignCtrled) history(height int64) BlockHistory {
+	return BlockHistory{
+		Height:         height,
+		MissedInARow:   bsc.missedInARow,
+		Threshold:      bsc.threshold,
+		MissedInWindow: bsc.windowMissed,
+		WindowSize:     len(bsc.window),
+	}
 }
 
 // LockCounter locks the counter for missed blocks in a row.
@@ -64,120 +601,611 @@ func NewBaseSignCtrled(logger *SyncLogger, threshold int, rank int, impl SignCtr
 // validators in the set if they are started up in incorrect order, and if a reconnect
 // takes place.
 func (bsc *BaseSignCtrled) LockCounter() {
-	if !bsc.counterLocked {
+	bsc.mtx.Lock()
+	changed := !bsc.counterLocked
+	if changed {
 		bsc.Logger.Info("Looking for first commitsig from validator after reconnect, stop counting missed blocks in a row...")
-		bsc.counterLocked = true
+		bsc.lockCounter()
+	}
+	bsc.mtx.Unlock()
+
+	if changed {
+		bsc.notifyStateChange()
 	}
 }
 
-// UnlockCounter unlocks the counter for missed blocks in a row.
+// lockCounter locks the counter for missed blocks in a row. The caller must hold the
+// lock and check that the counter isn't locked yet.
+func (bsc *BaseSignCtrled) lockCounter() {
+	bsc.counterLocked = true
+	bsc.lockedAt = bsc.clock.Now()
+	bsc.lockedBlocks = 0
+	bsc.signStreak = 0
+	bsc.clearWindow()
+	bsc.emit(EventCounterLocked, bsc.currentHeight)
+}
+
+// UnlockCounter unlocks the counter for missed blocks in a row. It must be called for
+// every commit the validator's commitsig has been found in, as the counter is only
+// unlocked once it has been found in the configured number of commits in a row.
 // This lock is crucial for mitigating the risk of double-signing on startup of the
 // validators in the set if they are started up in incorrect order, and if a reconnect
 // takes place.
 func (bsc *BaseSignCtrled) UnlockCounter() {
+	bsc.mtx.Lock()
+	changed := false
 	if bsc.counterLocked {
-		bsc.Logger.Info("Found first commitsig from validator since fully synced, start counting missed blocks in a row...")
-		bsc.counterLocked = false
+		bsc.signStreak++
+		if bsc.signStreak < bsc.signsToUnlock {
+			bsc.Logger.Info("Found commitsig from validator (%v/%v), waiting for more in a row before counting missed blocks in a row...", bsc.signStreak, bsc.signsToUnlock)
+		} else {
+			bsc.Logger.Info("Found first commitsig from validator since fully synced, start counting missed blocks in a row...")
+			bsc.counterLocked = false
+			bsc.observeUnlock()
+			bsc.unlockedAt = bsc.clock.Now()
+			bsc.lockedBlocks = 0
+			bsc.signStreak = 0
+			bsc.emit(EventCounterUnlocked, bsc.currentHeight)
+			changed = true
+		}
+	}
+	bsc.mtx.Unlock()
+
+	if changed {
+		bsc.notifyStateChange()
+	}
+}
+
+// observeUnlock adds the time the counter for missed blocks in a row has been locked
+// for to the metrics. The caller must hold the lock.
+func (bsc *BaseSignCtrled) observeUnlock() {
+	since := bsc.lockedAt
+	if since.IsZero() {
+		since = bsc.startedAt
+	}
+	bsc.metrics.LockedSeconds.Add(bsc.clock.Now().Sub(since).Seconds())
+}
+
+// IsCounterLocked checks whether the counter for missed blocks in a row is locked.
+func (bsc *BaseSignCtrled) IsCounterLocked() bool {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+	return bsc.counterLocked
+}
+
+// Restore restores the counter for missed blocks in a row, the current height and the
+// counter lock persisted before a restart.
+func (bsc *BaseSignCtrled) Restore(missedInARow int, currentHeight int64, counterLocked bool) {
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+	bsc.missedInARow = missedInARow
+	bsc.metrics.MissedInARow.Set(float64(missedInARow))
+	bsc.currentHeight = currentHeight
+	bsc.heightSeen = true
+	bsc.lastCounted = currentHeight
+	bsc.counterLocked = counterLocked
+	bsc.lastSignedAt = bsc.clock.Now()
+}
+
+// GetStateSnapshot returns a snapshot of the validator's state, which is taken at once
+// under the lock, so that its values are consistent with each other.
+func (bsc *BaseSignCtrled) GetStateSnapshot() StateSnapshot {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+	return StateSnapshot{
+		Rank:             bsc.rank,
+		SetSize:          bsc.setSize,
+		Threshold:        bsc.threshold,
+		MissedInARow:     bsc.missedInARow,
+		CurrentHeight:    bsc.currentHeight,
+		CounterLocked:    bsc.counterLocked,
+		LastSignedHeight: bsc.lastSignedHeight,
+		LastLockedAt:     bsc.lockedAt,
+		LastUnlockedAt:   bsc.unlockedAt,
+		Paused:           bsc.paused,
+		PauseReason:      bsc.pauseReason,
+		PromoteReason:    bsc.promoteReason,
+		LastHeightGap:    bsc.lastGap,
+		LastHeightGapAt:  bsc.lastGapAt,
+
+		TotalMissed:         bsc.totalMissed,
+		LongestMissedStreak: bsc.longestStreak,
+		AvgBlockTime:        bsc.avgBlockTime,
+		EstimatedDowntime:   bsc.estimatedDowntime(),
+
+		PromotionSuppressed: bsc.promotionSuppressed,
+
+		Peers: append([]Peer(nil), bsc.peers...),
 	}
 }
 
 // GetCurrentHeight returns the validator's current height.
 func (bsc *BaseSignCtrled) GetCurrentHeight() int64 {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
 	return bsc.currentHeight
 }
 
-// SetCurrentHeight sets the current height to the given value.
-func (bsc *BaseSignCtrled) SetCurrentHeight(height int64) {
-	bsc.currentHeight = height
+// SetCurrentHeight sets the current height to the given value. A height lower than
+// the current one is rejected with ErrHeightRegression, and a height skipping more
+// than the maximum height gap locks the counter for missed blocks in a row.
+func (bsc *BaseSignCtrled) SetCurrentHeight(height int64) error {
+	bsc.mtx.Lock()
+	err := bsc.checkHeight(height)
+	if err == nil {
+		bsc.recordBlockTime(height)
+		bsc.currentHeight = height
+		bsc.heightSeen = true
+	}
+	bsc.mtx.Unlock()
+
+	bsc.notifyStateChange()
+
+	return err
 }
 
-// GetThreshold returns the threshold of blocks missed in a row that trigger a rank
-// update.
+// GetThreshold returns the effective threshold of blocks missed in a row that trigger
+// a rank update on the current rank.
 func (bsc *BaseSignCtrled) GetThreshold() int {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
 	return bsc.threshold
 }
 
+// SetThreshold sets the base threshold of blocks missed in a row at runtime and
+// updates the effective threshold, which thresholds configured for specific ranks
+// still take precedence over. If the validator already missed at least as many blocks
+// in a row as the new threshold, it is promoted on the next missed block instead of
+// right away. An error is returned if the threshold is lower than 2.
+func (bsc *BaseSignCtrled) SetThreshold(threshold int) error {
+	if threshold < 2 {
+		return fmt.Errorf("%w, got %v", ErrInvalidThreshold, threshold)
+	}
+
+	bsc.mtx.Lock()
+	defer bsc.mtx.Unlock()
+	bsc.Logger.Info("Set threshold (%v -> %v)", bsc.baseThreshold, threshold)
+	bsc.baseThreshold = threshold
+	bsc.updateThreshold()
+	if !bsc.counterLocked && bsc.missedInARow > 0 && bsc.missedInARow >= bsc.threshold {
+		bsc.Logger.Info("Already missed %v blocks in a row, promoting on the next missed block", bsc.missedInARow)
+		bsc.promotionPending = true
+	}
+
+	return nil
+}
+
+// GetMinThreshold returns the lowest threshold of blocks missed in a row on any rank,
+// which is the first one to be exceeded in the set.
+func (bsc *BaseSignCtrled) GetMinThreshold() int {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+	min := bsc.baseThreshold
+	for _, threshold := range bsc.thresholds {
+		if threshold < min {
+			min = threshold
+		}
+	}
+
+	return min
+}
+
 // GetMissedInARow returns the number of blocks missed in a row.
 func (bsc *BaseSignCtrled) GetMissedInARow() int {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
 	return bsc.missedInARow
 }
 
 // GetRank returns the validators current rank.
 func (bsc *BaseSignCtrled) GetRank() int {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
 	return bsc.rank
 }
 
+// GetSetSize returns the number of validators in the set, which is the last rank.
+func (bsc *BaseSignCtrled) GetSetSize() int {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+	return bsc.setSize
+}
+
 // SetRank sets the validator's rank to the given rank.
 func (bsc *BaseSignCtrled) SetRank(rank int) {
+	bsc.mtx.Lock()
 	bsc.rank = rank
+	bsc.updateThreshold()
+	bsc.mtx.Unlock()
+
+	bsc.notifyStateChange()
+}
+
+// notifyStateChange lets the implementation of SignCtrled know that the validator's
+// rank, counter for missed blocks in a row, current height or counter lock changed.
+// It must not be called while holding the lock.
+func (bsc *BaseSignCtrled) notifyStateChange() {
+	if bsc.impl != nil {
+		bsc.impl.OnStateChange()
+	}
 }
 
-// Missed updates the counter for missed blocks in a row. Errors are returned if...
+// notifyMissedTooMany lets the implementation of SignCtrled know that the given missed
+// blocks exceeded the threshold. It must not be called while holding the lock.
+func (bsc *BaseSignCtrled) notifyMissedTooMany(mc MissedContext) {
+	if bsc.impl != nil {
+		bsc.impl.OnMissedTooMany(mc)
+	}
+}
+
+// notifyPromote lets the implementation of SignCtrled know that the validator has been
+// promoted at the given height for the given reason. If the implementation can't serve
+// on the new rank, the promotion fails with a MustShutdownError wrapping its error. It
+// must not be called while holding the lock.
+func (bsc *BaseSignCtrled) notifyPromote(height int64, reason PromoteReason) error {
+	if bsc.impl == nil {
+		return nil
+	}
+	if err := bsc.impl.OnPromote(reason); err != nil {
+		return fmt.Errorf("%w: %v", &MustShutdownError{Height: height}, err)
+	}
+
+	return nil
+}
+
+// Missed updates the counter for missed blocks in a row and adds a missed block to the
+// window for the block at the given height. Whether the validator is promoted is
+// decided by its RankStrategy. During the cool-down after a previous promotion, the
+// promotion is deferred until the cool-down is over. Errors are returned if...
 //
-// 1) the threshold of too many blocks missed in a row is exceeded
-// 2) the validator's promotion fails
-// 3) the counter for missed blocks in a row is still locked
+// 1) the strategy promotes the validator (ThresholdExceededError)
+// 2) the validator's promotion fails (MustShutdownError)
+// 3) the counter for missed blocks in a row is still locked, including the block
+// that unlocks it after too many blocks without a commitsig from the validator
+// 4) the given height has already been counted or skipped, lies below the current
+// height or within the grace period after a promotion
+// 5) the monitoring of missed blocks is paused
+// 6) the promotion is suppressed due to too many automatic promotions
 //
 // Implements the SignCtrled interface.
-func (bsc *BaseSignCtrled) Missed() error {
+func (bsc *BaseSignCtrled) Missed(height int64) error {
+	bsc.mtx.Lock()
+	if bsc.paused {
+		bsc.Logger.Debug("Not counting missed block at height %v while paused", height)
+		bsc.mtx.Unlock()
+		return ErrPaused
+	}
+	if height <= bsc.lastCounted || height < bsc.currentHeight {
+		bsc.Logger.Debug("Not counting missed block at height %v again", height)
+		bsc.mtx.Unlock()
+		return ErrAlreadyCounted
+	}
+	if height <= bsc.graceUntil {
+		bsc.Logger.Info("Skipping missed block at height %v in the grace period after promotion (until block height %v)", height, bsc.graceUntil)
+		bsc.metrics.BlocksObserved.Add(1)
+		bsc.lastCounted = height
+		bsc.mtx.Unlock()
+		return ErrAlreadyCounted
+	}
 	if bsc.counterLocked {
+		// A missing commitsig breaks the streak of commitsigs needed to unlock the
+		// counter. The block that unlocks the counter after too many blocks without
+		// one isn't counted yet, so counting starts with the next one.
+		bsc.signStreak = 0
+		unlocked := false
+		if height > bsc.lastLocked {
+			bsc.metrics.BlocksObserved.Add(1)
+			bsc.lastLocked = height
+			bsc.recordBlock(height, BlockLocked)
+			unlocked = bsc.autoUnlock(height)
+		}
+		bsc.mtx.Unlock()
+		if unlocked {
+			bsc.notifyStateChange()
+		}
 		return ErrCounterLocked
 	}
+	defer bsc.notifyStateChange()
+
+	bsc.lastCounted = height
 
 	bsc.missedInARow++
-	if bsc.missedInARow < bsc.threshold {
-		bsc.Logger.Info("Missed a block (%v/%v)", bsc.missedInARow, bsc.threshold)
-	} else if bsc.missedInARow == bsc.threshold {
-		bsc.Logger.Info("Missed too many blocks in a row (%v/%v)", bsc.missedInARow, bsc.threshold)
-		bsc.OnMissedTooMany()
-		if err := bsc.Promote(); err != nil {
-			return err
+	bsc.recordDowntime()
+	bsc.metrics.BlocksObserved.Add(1)
+	bsc.metrics.BlocksMissed.Add(1)
+	bsc.metrics.MissedInARow.Set(float64(bsc.missedInARow))
+	bsc.record(true)
+	bsc.recordBlock(height, BlockMissed)
+	bsc.emit(EventMissed, height)
+	history := bsc.history(height)
+	missed, threshold := bsc.strategy.Progress(history, bsc.rank)
+	if !bsc.promotionPending && !bsc.strategy.ShouldPromote(history, bsc.rank) {
+		if missed < threshold && bsc.logsMissed(missed, threshold) {
+			bsc.Logger.Info("Missed a block at height %v (%v/%v)", height, missed, threshold)
 		}
+		bsc.mtx.Unlock()
+		return nil
+	}
+	if left := bsc.coolingDown(height); left > 0 {
+		// Defer the promotion instead of dropping it, as the strategy might not
+		// fire again for the blocks missed during the cool-down.
+		bsc.Logger.Info("Missed too many blocks at height %v (%v/%v), but promotion is suppressed for %v more blocks after the last promotion", height, missed, threshold, left)
+		bsc.promotionPending = true
+		bsc.mtx.Unlock()
+		return nil
+	}
+	if bsc.autoPromotionSuppressed(height) {
+		// Keep the promotion pending, so that it takes place on the next missed block
+		// once automatic promotions are enabled again.
+		bsc.promotionPending = true
+		bsc.mtx.Unlock()
+		return ErrPromotionSuppressed
+	}
+	bsc.Logger.Info("Missed too many blocks at height %v (%v/%v)", height, missed, threshold)
+	exceeded := &ThresholdExceededError{
+		Height:    height,
+		OldRank:   bsc.rank,
+		Missed:    missed,
+		Threshold: threshold,
+	}
+	mc := MissedContext{Height: height, Missed: missed, Threshold: threshold, Rank: bsc.rank}
 
+	err := bsc.promote(height, PromoteReasonThresholdExceeded)
+	exceeded.NewRank = bsc.rank
+	if err == nil {
 		// When a rank update due to ErrThresholdExceeded is triggered, it is expected
 		// that the next block will not contain the validator's signature. This is due
 		// to a block containing the commit of the previous height which we know wasn't
-		// signed. Therefore, skip ahead.
+		// signed. Therefore, skip the next height, or more if the new rank 1 needs
+		// longer to start signing.
 		// This is also the reason why the minimum threshold for blocks missed in a row
 		// is at 2.
-		bsc.currentHeight++
-		return ErrThresholdExceeded
+		bsc.graceUntil = height + int64(bsc.grace)
+		bsc.recordAutoPromotion()
 	}
+	bsc.mtx.Unlock()
 
-	return nil
+	bsc.notifyMissedTooMany(mc)
+	if err != nil {
+		return &MustShutdownError{Height: height}
+	}
+	if err := bsc.notifyPromote(height, PromoteReasonThresholdExceeded); err != nil {
+		return err
+	}
+
+	return exceeded
+}
+
+// Signed adds a signed block to the window, resets the counter for missed blocks in a
+// row to 0 and records the time and height the validator's signature has been seen
+// at. A promotion deferred during the cool-down is dropped, as the validator signs
+// again.
+// Signed blocks are only added while the counter is unlocked. While paused, only the
+// time is recorded.
+func (bsc *BaseSignCtrled) Signed() {
+	bsc.mtx.Lock()
+	bsc.lastSignedAt = bsc.clock.Now()
+	if bsc.paused {
+		bsc.mtx.Unlock()
+		return
+	}
+	bsc.lastSignedHeight = bsc.currentHeight
+	bsc.metrics.BlocksObserved.Add(1)
+	bsc.recordBlock(bsc.currentHeight, BlockSigned)
+	bsc.promotionPending = false
+	bsc.lockedBlocks = 0
+	if !bsc.counterLocked {
+		bsc.record(false)
+	}
+	changed := bsc.reset()
+	bsc.mtx.Unlock()
+
+	if changed {
+		bsc.notifyStateChange()
+	}
+}
+
+// CheckLastSigned triggers a rank update if the validator's signature hasn't been seen
+// for longer than the threshold duration, so that a validator that stopped signing is
+// replaced even if no more blocks arrive. Nothing is checked while paused. Errors are
+// returned if...
+//
+// 1) the threshold duration is exceeded (ThresholdExceededError)
+// 2) the validator's promotion fails (MustShutdownError)
+// 3) the counter for missed blocks in a row is still locked
+// 4) the promotion is suppressed due to too many automatic promotions
+func (bsc *BaseSignCtrled) CheckLastSigned() error {
+	bsc.mtx.Lock()
+	if bsc.thresholdDuration <= 0 || bsc.paused {
+		bsc.mtx.Unlock()
+		return nil
+	}
+	if bsc.counterLocked {
+		bsc.mtx.Unlock()
+		return ErrCounterLocked
+	}
+	since := bsc.clock.Now().Sub(bsc.lastSignedAt)
+	if since < bsc.thresholdDuration {
+		bsc.mtx.Unlock()
+		return nil
+	}
+	if left := bsc.coolingDown(bsc.currentHeight); left > 0 {
+		bsc.Logger.Debug("Missed signatures for too long, but promotion is suppressed for %v more blocks after the last promotion", left)
+		bsc.mtx.Unlock()
+		return nil
+	}
+	if bsc.autoPromotionSuppressed(bsc.currentHeight) {
+		bsc.mtx.Unlock()
+		return ErrPromotionSuppressed
+	}
+	defer bsc.notifyStateChange()
+
+	bsc.Logger.Info("Missed signatures for too long (%v/%v)", since.Round(time.Second), bsc.thresholdDuration)
+	exceeded := &ThresholdExceededError{
+		Height:    bsc.currentHeight,
+		OldRank:   bsc.rank,
+		Missed:    bsc.missedInARow,
+		Threshold: bsc.threshold,
+	}
+	mc := MissedContext{Height: bsc.currentHeight, Missed: bsc.missedInARow, Threshold: bsc.threshold, Rank: bsc.rank}
+	err := bsc.promote(bsc.currentHeight, PromoteReasonThresholdExceeded)
+	exceeded.NewRank = bsc.rank
+	if err == nil {
+		// Just like after too many blocks missed in a row, the next block will not
+		// contain the validator's signature, so skip the grace period.
+		bsc.lastCounted = bsc.currentHeight
+		bsc.graceUntil = bsc.currentHeight + int64(bsc.grace)
+		bsc.recordAutoPromotion()
+	}
+	bsc.mtx.Unlock()
+
+	bsc.notifyMissedTooMany(mc)
+	if err != nil {
+		return &MustShutdownError{Height: bsc.GetCurrentHeight()}
+	}
+	if err := bsc.notifyPromote(bsc.GetCurrentHeight(), PromoteReasonThresholdExceeded); err != nil {
+		return err
+	}
+
+	return exceeded
 }
 
 // OnMissedTooMany does nothing. This way, users don't need to call BaseSignCtrled.OnMissedTooMany().
 // Implements the SignCtrled interface.
-func (bsc *BaseSignCtrled) OnMissedTooMany() {}
+func (bsc *BaseSignCtrled) OnMissedTooMany(mc MissedContext) {}
 
 // Reset resets the counter for missed blocks in a row to 0.
 // Implements the SignCtrled interface.
 func (bsc *BaseSignCtrled) Reset() {
-	if bsc.missedInARow > 0 {
-		bsc.Logger.Debug("Reset counter for missed blocks in a row")
-		bsc.missedInARow = 0
+	bsc.mtx.Lock()
+	changed := bsc.reset()
+	bsc.mtx.Unlock()
+
+	if changed {
+		bsc.notifyStateChange()
 	}
 }
 
-// Promote moves the validator up one rank. An error is returned if the validator
-// cannot be promoted anymore and it has to be shut down consequently.
-// This method is only supposed to be called from within the Missed method and never
-// on its own.
+// reset resets the counter for missed blocks in a row to 0 and returns whether it
+// changed. The caller must hold the lock.
+func (bsc *BaseSignCtrled) reset() bool {
+	if bsc.missedInARow == 0 {
+		return false
+	}
+	bsc.Logger.Debug("Reset counter for missed blocks in a row")
+	bsc.missedInARow = 0
+	bsc.metrics.Resets.Add(1)
+	bsc.metrics.MissedInARow.Set(0)
+
+	return true
+}
+
+// Promote moves the validator up one rank, as the threshold has been exceeded. An
+// error is returned if the validator cannot be promoted anymore and it has to be shut
+// down consequently.
 // Implements the SignCtrled interface.
 func (bsc *BaseSignCtrled) Promote() error {
+	return bsc.PromoteWithReason(PromoteReasonThresholdExceeded)
+}
+
+// PromoteWithReason moves the validator up one rank for the given reason. An error is
+// returned if the validator cannot be promoted anymore and it has to be shut down
+// consequently.
+// Implements the SignCtrled interface.
+func (bsc *BaseSignCtrled) PromoteWithReason(reason PromoteReason) error {
+	bsc.mtx.Lock()
+	height := bsc.currentHeight
+	err := bsc.promote(height, reason)
+	bsc.mtx.Unlock()
+	if err != nil {
+		return err
+	}
+
+	err = bsc.notifyPromote(height, reason)
+	bsc.notifyStateChange()
+
+	return err
+}
+
+// GetPromoteReason returns the reason the validator has last been promoted for, or an
+// empty string if it hasn't been promoted yet.
+func (bsc *BaseSignCtrled) GetPromoteReason() PromoteReason {
+	bsc.mtx.RLock()
+	defer bsc.mtx.RUnlock()
+	return bsc.promoteReason
+}
+
+// promote moves the validator up one rank at the given height for the given reason.
+// The caller must hold the lock.
+func (bsc *BaseSignCtrled) promote(height int64, reason PromoteReason) error {
 	if bsc.rank == 1 {
+		bsc.emit(EventMustShutdown, height)
 		return ErrMustShutdown
 	}
 
-	bsc.Logger.Info("Promote validator (%v -> %v)", bsc.rank, bsc.rank-1)
+	bsc.Logger.Info("Promote validator %v -> %v (rank %v/%v, reason: %v)", bsc.rank, bsc.rank-1, bsc.rank-1, bsc.setSize, reason)
 	bsc.rank--
-	bsc.Reset()
-	bsc.OnPromote()
+	bsc.updateThreshold()
+	bsc.reset()
+	bsc.clearWindow()
+	bsc.lastSignedAt = bsc.clock.Now()
+	bsc.promotedAt = height
+	bsc.promotionPending = false
+	bsc.promoteReason = reason
+	bsc.metrics.Promotions.Add(1)
+	bsc.emit(EventPromoted, height)
 
 	return nil
 }
 
 // OnPromote does nothing. This way, users don't have to call BaseSignCtrled.OnPromote().
 // Implements the SignCtrled interface.
-func (bsc *BaseSignCtrled) OnPromote() {}
+func (bsc *BaseSignCtrled) OnPromote(reason PromoteReason) error {
+	return nil
+}
+
+// Demote moves the validator down to the given rank, typically the last rank of the
+// set, i.e. the set size. The counter for missed blocks in a row is reset and locked,
+// so that the validator only climbs back up the ranks once it has seen the new rank 1
+// sign. The block history is cleared, as it belongs to the old rank.
+// An error is returned if moving to the given rank would be a promotion or the rank
+// isn't in the set.
+// Implements the SignCtrled interface.
+func (bsc *BaseSignCtrled) Demote(rank int) error {
+	bsc.mtx.Lock()
+	if rank < 1 || rank < bsc.rank {
+		err := fmt.Errorf("can't demote validator from rank %v to rank %v", bsc.rank, rank)
+		bsc.mtx.Unlock()
+		return err
+	}
+	if rank > bsc.setSize {
+		err := fmt.Errorf("can't demote validator to rank %v, as the last rank of the set is %v", rank, bsc.setSize)
+		bsc.mtx.Unlock()
+		return err
+	}
+
+	bsc.Logger.Info("Demote validator %v -> %v (rank %v/%v)", bsc.rank, rank, rank, bsc.setSize)
+	bsc.rank = rank
+	bsc.updateThreshold()
+	bsc.reset()
+	if !bsc.counterLocked {
+		bsc.lockedAt = bsc.clock.Now()
+	}
+	bsc.counterLocked = true
+	bsc.lockedBlocks = 0
+	bsc.signStreak = 0
+	bsc.clearWindow()
+	bsc.clearHistory()
+	bsc.promotionPending = false
+	bsc.emit(EventDemoted, bsc.currentHeight)
+	bsc.mtx.Unlock()
+
+	bsc.notifyStateChange()
+
+	return nil
+}
+
+// OnStateChange does nothing. This way, users don't need to call
+// BaseSignCtrled.OnStateChange().
+// Implements the SignCtrled interface.
+func (bsc *BaseSignCtrled) OnStateChange() {}
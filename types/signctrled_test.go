@@ -1,46 +1,242 @@
 package types
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 type testSignCtrled struct {
 	BaseSignCtrled
+	height int64
+}
+
+// miss counts a missed block at the next height.
+func (sc *testSignCtrled) miss() error {
+	sc.height++
+	return sc.Missed(sc.height)
+}
+
+// testBaseSignCtrled creates a new BaseSignCtrled with the given threshold and rank in
+// a set of the given size.
+func testBaseSignCtrled(t *testing.T, threshold int, rank int, setSize int, impl SignCtrled) *BaseSignCtrled {
+	t.Helper()
+	bsc, err := NewBaseSignCtrled(nil, threshold, rank, setSize, impl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return bsc
+}
+
+func TestNewBaseSignCtrled(t *testing.T) {
+	_, err := NewBaseSignCtrled(nil, 1, 1, 2, nil)
+	assert.ErrorIs(t, err, ErrInvalidThreshold)
+	_, err = NewBaseSignCtrled(nil, 2, 0, 2, nil)
+	assert.ErrorIs(t, err, ErrInvalidRank)
+	_, err = NewBaseSignCtrled(nil, 2, 1, 0, nil)
+	assert.ErrorIs(t, err, ErrInvalidSetSize)
+	_, err = NewBaseSignCtrled(nil, 2, 7, 3, nil)
+	assert.ErrorIs(t, err, ErrInvalidRank)
+	assert.EqualError(t, err, "rank must be between 1 and the set size of 3, got 7")
+	bsc, err := NewBaseSignCtrled(nil, 2, 3, 3, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, bsc.GetThreshold())
+	assert.Equal(t, 3, bsc.GetRank())
+	assert.Equal(t, 3, bsc.GetSetSize())
 }
 
 func TestMissed(t *testing.T) {
 	sc := &testSignCtrled{}
-	sc.BaseSignCtrled = *NewBaseSignCtrled(nil, 2, 1, sc)
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 2, 1, 3, sc)
 
 	sc.UnlockCounter()
-	err := sc.Missed()
+	err := sc.miss()
 	assert.NoError(t, err)
 	assert.Equal(t, 1, sc.GetMissedInARow())
 	assert.Equal(t, 1, sc.GetRank())
 
 	sc.LockCounter()
-	err = sc.Missed()
-	assert.ErrorIs(t, ErrCounterLocked, err)
+	err = sc.miss()
+	assert.ErrorIs(t, err, ErrCounterLocked)
 	assert.Equal(t, 1, sc.GetMissedInARow())
 	assert.Equal(t, 1, sc.GetRank())
 }
 
 func TestThresholdExceeded(t *testing.T) {
 	sc := &testSignCtrled{}
-	sc.BaseSignCtrled = *NewBaseSignCtrled(nil, 1, 2, sc)
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 2, 2, 3, sc)
 
 	sc.UnlockCounter()
-	err := sc.Missed()
-	assert.ErrorIs(t, ErrThresholdExceeded, err)
+	err := sc.miss()
+	assert.NoError(t, err)
+	err = sc.miss()
+	assert.ErrorIs(t, err, ErrThresholdExceeded)
 	assert.Equal(t, 0, sc.GetMissedInARow())
 	assert.Equal(t, 1, sc.GetRank())
 }
 
+func TestMissedBlockLogInterval(t *testing.T) {
+	// missStreak counts the lines logged for missing 50 blocks in a row with the
+	// given log interval.
+	missStreak := func(interval int) (missed int, promoted int) {
+		var buf bytes.Buffer
+		sc := &testSignCtrled{}
+		sc.BaseSignCtrled = *testBaseSignCtrled(t, 50, 2, 3, sc)
+		sc.Logger = NewSyncLogger(&buf, "", 0)
+		sc.SetMissedBlockLogInterval(interval)
+		sc.UnlockCounter()
+		for i := 0; i < 49; i++ {
+			assert.NoError(t, sc.miss())
+		}
+		assert.ErrorIs(t, sc.miss(), ErrThresholdExceeded)
+		return strings.Count(buf.String(), "Missed a block"), strings.Count(buf.String(), "Missed too many blocks at height 50 (50/50)")
+	}
+
+	// The first and last 3 missed blocks are logged, and every 10th in between.
+	missed, promoted := missStreak(DefaultMissedBlockLogInterval)
+	assert.Equal(t, 10, missed)
+	assert.Equal(t, 1, promoted)
+
+	// An interval of 1 logs all of them.
+	missed, promoted = missStreak(1)
+	assert.Equal(t, 49, missed)
+	assert.Equal(t, 1, promoted)
+}
+
+func TestThresholdExceededError(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 2, 2, 3, sc)
+	sc.UnlockCounter()
+
+	// The rank update's context is returned and existing comparisons keep working.
+	_ = sc.miss()
+	err := sc.miss()
+	assert.ErrorIs(t, err, ErrThresholdExceeded)
+	var exceeded *ThresholdExceededError
+	assert.True(t, errors.As(err, &exceeded))
+	assert.Equal(t, ThresholdExceededError{Height: 2, OldRank: 2, NewRank: 1, Missed: 2, Threshold: 2}, *exceeded)
+	assert.Contains(t, err.Error(), ErrThresholdExceeded.Error())
+
+	// The final height is returned if the validator must shut down.
+	_ = sc.Missed(4)
+	err = sc.Missed(5)
+	assert.ErrorIs(t, err, ErrMustShutdown)
+	var shutdown *MustShutdownError
+	assert.True(t, errors.As(err, &shutdown))
+	assert.Equal(t, int64(5), shutdown.Height)
+	assert.True(t, errors.Is(fmt.Errorf("wrapped: %w", err), ErrMustShutdown))
+}
+
+// sequenceStep is a block fed to a BaseSignCtrled in TestMissed_PromotionSequence and
+// the state expected afterwards.
+type sequenceStep struct {
+	height  int64
+	signed  bool
+	err     error
+	rank    int
+	missed  int
+	logLine string
+}
+
+// runSequence feeds the given blocks to the given BaseSignCtrled like privval does and
+// checks the state and the log after each of them.
+func runSequence(t *testing.T, sc *testSignCtrled, buf *bytes.Buffer, steps []sequenceStep) {
+	t.Helper()
+	for _, step := range steps {
+		buf.Reset()
+		sc.SetCurrentHeight(step.height)
+		var err error
+		if step.signed {
+			sc.Signed()
+			sc.UnlockCounter()
+		} else {
+			err = sc.Missed(step.height)
+		}
+		if step.err == nil {
+			assert.NoError(t, err, "height %v", step.height)
+		} else {
+			assert.ErrorIs(t, err, step.err, "height %v", step.height)
+		}
+		assert.Equal(t, step.rank, sc.GetRank(), "height %v", step.height)
+		assert.Equal(t, step.missed, sc.GetMissedInARow(), "height %v", step.height)
+		assert.Contains(t, buf.String(), step.logLine, "height %v", step.height)
+	}
+}
+
+func TestMissed_PromotionSequence(t *testing.T) {
+	newSequence := func(grace int) (*testSignCtrled, *bytes.Buffer, <-chan Event) {
+		var buf bytes.Buffer
+		sc := &testSignCtrled{}
+		sc.BaseSignCtrled = *testBaseSignCtrled(t, 3, 3, 3, sc)
+		sc.Logger = NewSyncLogger(&buf, "", 0)
+		sc.SetPostPromotionGrace(grace)
+		sc.UnlockCounter()
+		return sc, &buf, sc.Subscribe(64)
+	}
+
+	// Every newly promoted signer fails right away, so rank 3 climbs up to rank 1 and
+	// must shut down eventually. The block right after each promotion is skipped.
+	sc, buf, events := newSequence(1)
+	runSequence(t, sc, buf, []sequenceStep{
+		{height: 1, rank: 3, missed: 1, logLine: "Missed a block at height 1 (1/3)"},
+		{height: 2, rank: 3, missed: 2, logLine: "Missed a block at height 2 (2/3)"},
+		{height: 3, err: ErrThresholdExceeded, rank: 2, logLine: "Missed too many blocks at height 3 (3/3)"},
+		{height: 4, err: ErrAlreadyCounted, rank: 2, logLine: "Skipping missed block at height 4"},
+		{height: 5, rank: 2, missed: 1, logLine: "Missed a block at height 5 (1/3)"},
+		{height: 6, rank: 2, missed: 2, logLine: "Missed a block at height 6 (2/3)"},
+		{height: 7, err: ErrThresholdExceeded, rank: 1, logLine: "Missed too many blocks at height 7 (3/3)"},
+		{height: 8, err: ErrAlreadyCounted, rank: 1, logLine: "Skipping missed block at height 8"},
+		{height: 9, rank: 1, missed: 1, logLine: "Missed a block at height 9 (1/3)"},
+		{height: 10, rank: 1, missed: 2, logLine: "Missed a block at height 10 (2/3)"},
+		{height: 11, err: ErrMustShutdown, rank: 1, missed: 3, logLine: "Missed too many blocks at height 11 (3/3)"},
+	})
+	var heights []int64
+	for _, e := range receive(t, events) {
+		if e.Kind == EventPromoted || e.Kind == EventMustShutdown {
+			heights = append(heights, e.Height)
+		}
+	}
+	assert.Equal(t, []int64{3, 7, 11}, heights)
+
+	// Requests for the same height don't count it twice, a longer grace period skips
+	// more blocks, and a signature in between starts the count over.
+	sc, buf, events = newSequence(2)
+	runSequence(t, sc, buf, []sequenceStep{
+		{height: 1, rank: 3, missed: 1, logLine: "Missed a block at height 1 (1/3)"},
+		{height: 1, err: ErrAlreadyCounted, rank: 3, missed: 1},
+		{height: 2, rank: 3, missed: 2, logLine: "Missed a block at height 2 (2/3)"},
+		{height: 3, err: ErrThresholdExceeded, rank: 2, logLine: "Missed too many blocks at height 3 (3/3)"},
+		{height: 4, err: ErrAlreadyCounted, rank: 2, logLine: "Skipping missed block at height 4"},
+		{height: 5, err: ErrAlreadyCounted, rank: 2, logLine: "Skipping missed block at height 5"},
+		{height: 6, rank: 2, missed: 1, logLine: "Missed a block at height 6 (1/3)"},
+		{height: 7, signed: true, rank: 2},
+		{height: 8, rank: 2, missed: 1, logLine: "Missed a block at height 8 (1/3)"},
+		{height: 9, rank: 2, missed: 2, logLine: "Missed a block at height 9 (2/3)"},
+		{height: 10, err: ErrThresholdExceeded, rank: 1, logLine: "Missed too many blocks at height 10 (3/3)"},
+		{height: 11, err: ErrAlreadyCounted, rank: 1, logLine: "Skipping missed block at height 11"},
+		{height: 12, err: ErrAlreadyCounted, rank: 1, logLine: "Skipping missed block at height 12"},
+		{height: 13, rank: 1, missed: 1, logLine: "Missed a block at height 13 (1/3)"},
+	})
+	heights = nil
+	for _, e := range receive(t, events) {
+		if e.Kind == EventMissed {
+			heights = append(heights, e.Height)
+		}
+	}
+	assert.Equal(t, []int64{1, 2, 3, 6, 8, 9, 10, 13}, heights)
+}
+
 func TestReset(t *testing.T) {
 	sc := &testSignCtrled{}
-	sc.BaseSignCtrled = *NewBaseSignCtrled(nil, 2, 1, sc)
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 2, 1, 3, sc)
 	sc.missedInARow = 1
 
 	sc.UnlockCounter()
@@ -50,9 +246,749 @@ func TestReset(t *testing.T) {
 
 func TestPromote(t *testing.T) {
 	sc := &testSignCtrled{}
-	sc.BaseSignCtrled = *NewBaseSignCtrled(nil, 1, 1, sc)
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 2, 1, 3, sc)
+
+	sc.UnlockCounter()
+	err := sc.miss()
+	assert.NoError(t, err)
+	err = sc.miss()
+	assert.ErrorIs(t, err, ErrMustShutdown)
+}
+
+func TestDemote(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 2, 1, 3, sc)
+	sc.UnlockCounter()
+	sc.missedInARow = 1
+
+	// Demote to the last rank.
+	err := sc.Demote(3)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, sc.GetRank())
+	assert.Equal(t, 0, sc.GetMissedInARow())
+
+	// The counter is locked until the new rank 1 signs.
+	err = sc.miss()
+	assert.ErrorIs(t, err, ErrCounterLocked)
+
+	// Demoting can't promote.
+	err = sc.Demote(2)
+	assert.Error(t, err)
+	err = sc.Demote(0)
+	assert.Error(t, err)
+	assert.Equal(t, 3, sc.GetRank())
+
+	// Rank updates are logged with the set size.
+	var buf bytes.Buffer
+	sc.Logger = NewSyncLogger(&buf, "", 0)
+	assert.NoError(t, sc.Promote())
+	assert.NoError(t, sc.Demote(3))
+	assert.Contains(t, buf.String(), "Promote validator 3 -> 2 (rank 2/3, reason: threshold_exceeded)")
+	assert.Contains(t, buf.String(), "Demote validator 2 -> 3 (rank 3/3)")
+
+	// Demoting can't leave the set.
+	err = sc.Demote(4)
+	assert.EqualError(t, err, "can't demote validator to rank 4, as the last rank of the set is 3")
+	assert.Equal(t, 3, sc.GetRank())
+}
+
+type hookCounter struct {
+	BaseSignCtrled
+	missed     int
+	promotions int
+	reasons    []PromoteReason
+	contexts   []MissedContext
+	promoteErr error
+}
+
+func (hc *hookCounter) OnMissedTooMany(mc MissedContext) {
+	hc.missed++
+	hc.contexts = append(hc.contexts, mc)
+}
+
+func (hc *hookCounter) OnPromote(reason PromoteReason) error {
+	hc.promotions++
+	hc.reasons = append(hc.reasons, reason)
+	return hc.promoteErr
+}
+
+func TestOnPromote(t *testing.T) {
+	hc := &hookCounter{}
+	hc.BaseSignCtrled = *testBaseSignCtrled(t, 2, 3, 3, hc)
+
+	// The hooks of the implementation are called, not the ones of BaseSignCtrled.
+	hc.UnlockCounter()
+	assert.NoError(t, hc.Missed(1))
+	assert.ErrorIs(t, hc.Missed(2), ErrThresholdExceeded)
+	assert.Equal(t, 1, hc.missed)
+	assert.Equal(t, 1, hc.promotions)
+	assert.NoError(t, hc.Promote())
+	assert.Equal(t, 2, hc.promotions)
+
+	// Failed promotions aren't reported.
+	assert.ErrorIs(t, hc.Promote(), ErrMustShutdown)
+	assert.Equal(t, 2, hc.promotions)
+}
+
+func TestOnPromote_Error(t *testing.T) {
+	hc := &hookCounter{promoteErr: errors.New("key unavailable")}
+	hc.BaseSignCtrled = *testBaseSignCtrled(t, 2, 3, 3, hc)
+
+	// If the implementation can't serve on the new rank, the promotion fails.
+	hc.UnlockCounter()
+	assert.NoError(t, hc.Missed(1))
+	err := hc.Missed(2)
+	assert.ErrorIs(t, err, ErrMustShutdown)
+	assert.EqualError(t, err, "node cannot be promoted anymore, so it must be shut down (final block height 2): key unavailable")
+	var mustShutdown *MustShutdownError
+	assert.True(t, errors.As(err, &mustShutdown))
+	assert.Equal(t, 2, hc.GetRank())
+
+	err = hc.Promote()
+	assert.ErrorIs(t, err, ErrMustShutdown)
+	assert.Equal(t, 1, hc.GetRank())
+}
+
+func TestOnMissedTooMany_Context(t *testing.T) {
+	hc := &hookCounter{}
+	hc.BaseSignCtrled = *testBaseSignCtrled(t, 2, 2, 3, hc)
+	hc.UnlockCounter()
+
+	// The context is captured before the promotion, including on rank 1, which can't
+	// be promoted anymore.
+	assert.NoError(t, hc.Missed(5))
+	assert.ErrorIs(t, hc.Missed(6), ErrThresholdExceeded)
+	assert.NoError(t, hc.Missed(8))
+	assert.ErrorIs(t, hc.Missed(9), ErrMustShutdown)
+	assert.Equal(t, []MissedContext{
+		{Height: 6, Missed: 2, Threshold: 2, Rank: 2},
+		{Height: 9, Missed: 2, Threshold: 2, Rank: 1},
+	}, hc.contexts)
+}
+
+func TestPromoteWithReason(t *testing.T) {
+	var buf bytes.Buffer
+	hc := &hookCounter{}
+	hc.BaseSignCtrled = *testBaseSignCtrled(t, 2, 3, 3, hc)
+	hc.Logger = NewSyncLogger(&buf, "", 0)
+	assert.Empty(t, hc.GetPromoteReason())
+
+	// Promote defaults to the threshold being exceeded.
+	assert.NoError(t, hc.Promote())
+	assert.Equal(t, PromoteReasonThresholdExceeded, hc.GetPromoteReason())
+	assert.NoError(t, hc.PromoteWithReason(PromoteReasonManual))
+	assert.Equal(t, PromoteReasonManual, hc.GetPromoteReason())
+	assert.Equal(t, PromoteReasonManual, hc.GetStateSnapshot().PromoteReason)
+	assert.Equal(t, []PromoteReason{PromoteReasonThresholdExceeded, PromoteReasonManual}, hc.reasons)
+	assert.Contains(t, buf.String(), "Promote validator 2 -> 1 (rank 1/3, reason: manual)")
+
+	// A failed promotion doesn't change the reason of the last one.
+	assert.ErrorIs(t, hc.PromoteWithReason(PromoteReasonPeerCoordination), ErrMustShutdown)
+	assert.Equal(t, PromoteReasonManual, hc.GetPromoteReason())
+}
+
+type stateChangeCounter struct {
+	BaseSignCtrled
+	changes int
+}
+
+func (rc *stateChangeCounter) OnStateChange() {
+	rc.changes++
+}
+
+func TestOnStateChange(t *testing.T) {
+	rc := &stateChangeCounter{}
+	rc.BaseSignCtrled = *testBaseSignCtrled(t, 2, 3, 3, rc)
+
+	rc.SetRank(2)
+	assert.Equal(t, 1, rc.changes)
+	err := rc.Promote()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, rc.changes)
+	err = rc.Demote(3)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, rc.changes)
+
+	// Failed state changes aren't reported.
+	err = rc.Demote(1)
+	assert.Error(t, err)
+	assert.Equal(t, 3, rc.changes)
+	err = rc.Missed(2)
+	assert.ErrorIs(t, err, ErrCounterLocked)
+	assert.Equal(t, 3, rc.changes)
+
+	// Changes of the counter and the current height are reported, too.
+	rc.UnlockCounter()
+	assert.Equal(t, 4, rc.changes)
+	err = rc.Missed(2)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, rc.changes)
+	rc.Reset()
+	assert.Equal(t, 6, rc.changes)
+	rc.SetCurrentHeight(10)
+	assert.Equal(t, 7, rc.changes)
+	rc.LockCounter()
+	assert.Equal(t, 8, rc.changes)
+}
+
+func TestMissed_AlreadyCounted(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 3, 2, 3, sc)
+	sc.UnlockCounter()
+
+	// Counting the same height twice doesn't inflate the counter.
+	assert.NoError(t, sc.Missed(5))
+	assert.ErrorIs(t, sc.Missed(5), ErrAlreadyCounted)
+	assert.ErrorIs(t, sc.Missed(4), ErrAlreadyCounted)
+	assert.Equal(t, 1, sc.GetMissedInARow())
+	assert.Equal(t, 2, sc.GetRank())
+
+	// Neither do heights below the current height.
+	sc.SetCurrentHeight(10)
+	assert.ErrorIs(t, sc.Missed(9), ErrAlreadyCounted)
+	assert.NoError(t, sc.Missed(10))
+	assert.Equal(t, 2, sc.GetMissedInARow())
+
+	// The height after a promotion is skipped explicitly.
+	assert.ErrorIs(t, sc.Missed(11), ErrThresholdExceeded)
+	assert.ErrorIs(t, sc.Missed(12), ErrAlreadyCounted)
+	assert.NoError(t, sc.Missed(13))
+	assert.Equal(t, 1, sc.GetMissedInARow())
+}
+
+func TestRestore(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 5, 2, 3, sc)
+
+	sc.Restore(3, 100, false)
+	assert.Equal(t, 3, sc.GetMissedInARow())
+	assert.Equal(t, int64(100), sc.GetCurrentHeight())
+	assert.False(t, sc.IsCounterLocked())
+
+	// The streak continues where it left off.
+	sc.height = 100
+	err := sc.miss()
+	assert.NoError(t, err)
+	assert.Equal(t, 4, sc.GetMissedInARow())
+}
+
+// readingSignCtrled reads its state whenever it changes, like implementations that
+// persist it do.
+type readingSignCtrled struct {
+	BaseSignCtrled
+}
+
+func (rs *readingSignCtrled) OnStateChange() {
+	_ = rs.GetRank()
+	_ = rs.GetMissedInARow()
+	_ = rs.GetCurrentHeight()
+	_ = rs.IsCounterLocked()
+}
+
+func TestConcurrentUse(t *testing.T) {
+	rs := &readingSignCtrled{}
+	rs.BaseSignCtrled = *testBaseSignCtrled(t, 2, 1000, 1000, rs)
+	rs.UnlockCounter()
+
+	// Run with -race to detect unsynchronized access.
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = rs.Missed(int64(j))
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				rs.Reset()
+				rs.SetCurrentHeight(int64(j))
+				rs.LockCounter()
+				rs.UnlockCounter()
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_ = rs.GetRank()
+				_ = rs.GetMissedInARow()
+				_ = rs.GetCurrentHeight()
+				_ = rs.GetThreshold()
+				_ = rs.IsCounterLocked()
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Promotions from within Missed don't deadlock and stay within the ranks.
+	assert.LessOrEqual(t, rs.GetRank(), 1000)
+	assert.GreaterOrEqual(t, rs.GetRank(), 1)
+}
+
+func TestWindow(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 10, 3, 3, sc)
+	sc.SetWindow(10)
+	sc.SetRankStrategy(AnyStrategy{InARowStrategy{}, WindowStrategy{Threshold: 7}})
+	sc.UnlockCounter()
+
+	// A validator missing 4 out of every 5 blocks never misses 10 blocks in a row,
+	// but 7 of the last 8 blocks.
+	var err error
+	for i := 0; i < 8 && err == nil; i++ {
+		if i%5 == 4 {
+			sc.Signed()
+			continue
+		}
+		err = sc.miss()
+	}
+	assert.ErrorIs(t, err, ErrThresholdExceeded)
+	assert.Equal(t, 2, sc.GetRank())
+
+	// The window is cleared on promotion.
+	assert.Equal(t, 0, sc.GetMissedInWindow())
+	assert.Equal(t, 0, sc.GetMissedInARow())
+
+	// The block after the promotion is skipped, as it can't contain the validator's
+	// signature yet.
+	err = sc.miss()
+	assert.ErrorIs(t, err, ErrAlreadyCounted)
+
+	// And on counter lock.
+	err = sc.miss()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sc.GetMissedInWindow())
+	sc.LockCounter()
+	assert.Equal(t, 0, sc.GetMissedInWindow())
+}
+
+func TestWindow_RingBuffer(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 10, 3, 3, sc)
+	sc.SetWindow(3)
+	sc.SetRankStrategy(AnyStrategy{InARowStrategy{}, WindowStrategy{Threshold: 3}})
+	sc.UnlockCounter()
+
+	// Blocks falling out of the window aren't counted anymore.
+	_ = sc.miss()
+	_ = sc.miss()
+	sc.Signed()
+	assert.Equal(t, 2, sc.GetMissedInWindow())
+	_ = sc.miss()
+	assert.Equal(t, 2, sc.GetMissedInWindow())
+	_ = sc.miss()
+	assert.Equal(t, 2, sc.GetMissedInWindow())
+	err := sc.miss()
+	assert.ErrorIs(t, err, ErrThresholdExceeded)
+
+	// The in-a-row policy still applies with the window enabled.
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 2, 3, 3, sc)
+	sc.SetWindow(10)
+	sc.SetRankStrategy(AnyStrategy{InARowStrategy{}, WindowStrategy{Threshold: 5}})
+	sc.UnlockCounter()
+	_ = sc.miss()
+	err = sc.miss()
+	assert.ErrorIs(t, err, ErrThresholdExceeded)
+
+	// Without a window, only the in-a-row policy applies.
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 3, 3, 3, sc)
+	sc.UnlockCounter()
+	for i := 0; i < 10; i++ {
+		if i%3 == 2 {
+			sc.Signed()
+			continue
+		}
+		assert.NoError(t, sc.miss())
+	}
+	assert.Equal(t, 0, sc.GetMissedInWindow())
+}
+
+func TestCounterUnlockAfter(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 2, 2, 3, sc)
+	events := sc.Subscribe(10)
+	sc.SetCounterUnlockAfter(5)
+
+	// Rank 1 is dead from the start, so its commitsig is never seen.
+	for i := 0; i < 5; i++ {
+		assert.ErrorIs(t, sc.miss(), ErrCounterLocked)
+	}
+	assert.False(t, sc.IsCounterLocked())
+	assert.Equal(t, []Event{{Kind: EventCounterUnlocked, Height: 5, Rank: 2}}, receive(t, events))
+
+	// Blocks seen again while locked aren't counted twice.
+	sc.LockCounter()
+	for i := 0; i < 4; i++ {
+		assert.ErrorIs(t, sc.Missed(sc.height), ErrCounterLocked)
+	}
+	assert.True(t, sc.IsCounterLocked())
+	sc.UnlockCounter()
+
+	// Counting starts with the block after the one that unlocked the counter.
+	assert.NoError(t, sc.miss())
+	assert.ErrorIs(t, sc.miss(), ErrThresholdExceeded)
+	assert.Equal(t, 1, sc.GetRank())
+}
+
+func TestCounterUnlockAfter_NormalUnlock(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 2, 2, 3, sc)
+	sc.SetCounterUnlockAfter(5)
+
+	// The commitsig of the validator unlocks the counter before the blocks run out.
+	for i := 0; i < 4; i++ {
+		assert.ErrorIs(t, sc.miss(), ErrCounterLocked)
+	}
+	sc.Signed()
+	sc.UnlockCounter()
+	assert.False(t, sc.IsCounterLocked())
+
+	// The blocks seen while locked before don't count towards the next lock.
+	sc.LockCounter()
+	for i := 0; i < 4; i++ {
+		assert.ErrorIs(t, sc.miss(), ErrCounterLocked)
+	}
+	assert.True(t, sc.IsCounterLocked())
+
+	// Without the auto-unlock, the counter stays locked.
+	sc.SetCounterUnlockAfter(0)
+	for i := 0; i < 10; i++ {
+		assert.ErrorIs(t, sc.miss(), ErrCounterLocked)
+	}
+	assert.True(t, sc.IsCounterLocked())
+}
+
+func TestConsecutiveSignsToUnlock(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 2, 2, 3, sc)
+	sc.SetConsecutiveSignsToUnlock(3)
+
+	// A single lucky commitsig after a restart doesn't unlock the counter.
+	sc.UnlockCounter()
+	sc.UnlockCounter()
+	assert.True(t, sc.IsCounterLocked())
+
+	// A missing one starts the streak over.
+	assert.ErrorIs(t, sc.miss(), ErrCounterLocked)
+	sc.UnlockCounter()
+	sc.UnlockCounter()
+	assert.True(t, sc.IsCounterLocked())
+	sc.UnlockCounter()
+	assert.False(t, sc.IsCounterLocked())
+
+	// The streak starts over after the counter is locked again.
+	sc.UnlockCounter()
+	sc.LockCounter()
+	sc.UnlockCounter()
+	sc.UnlockCounter()
+	assert.True(t, sc.IsCounterLocked())
+}
+
+func TestSetThreshold(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 10, 2, 3, sc)
+	sc.UnlockCounter()
+
+	err := sc.SetThreshold(1)
+	assert.ErrorIs(t, err, ErrInvalidThreshold)
+	assert.Equal(t, 10, sc.GetThreshold())
+
+	assert.NoError(t, sc.SetThreshold(20))
+	assert.Equal(t, 20, sc.GetThreshold())
+	assert.Equal(t, 20, sc.GetStateSnapshot().Threshold)
+	assert.Equal(t, 20, sc.GetMinThreshold())
+
+	// Lowering the threshold below the counter promotes on the next missed block
+	// rather than right away.
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, sc.miss())
+	}
+	assert.NoError(t, sc.SetThreshold(3))
+	assert.Equal(t, 2, sc.GetRank())
+	assert.ErrorIs(t, sc.miss(), ErrThresholdExceeded)
+	assert.Equal(t, 1, sc.GetRank())
+
+	// Thresholds configured for specific ranks take precedence.
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 10, 3, 3, sc)
+	sc.SetThresholds(map[int]int{3: 15})
+	assert.NoError(t, sc.SetThreshold(5))
+	assert.Equal(t, 15, sc.GetThreshold())
+	assert.Equal(t, 5, sc.GetMinThreshold())
+}
+
+func TestGetStateSnapshot(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 5, 2, 3, sc)
+	assert.Equal(t, StateSnapshot{Rank: 2, SetSize: 3, Threshold: 5, CurrentHeight: 1, CounterLocked: true}, sc.GetStateSnapshot())
+
+	before := time.Now()
+	sc.SetCurrentHeight(10)
+	sc.Signed()
+	sc.UnlockCounter()
+	sc.height = 10
+	assert.NoError(t, sc.miss())
+	sc.LockCounter()
+
+	snapshot := sc.GetStateSnapshot()
+	assert.Equal(t, 2, snapshot.Rank)
+	assert.Equal(t, 5, snapshot.Threshold)
+	assert.Equal(t, 1, snapshot.MissedInARow)
+	assert.Equal(t, int64(10), snapshot.CurrentHeight)
+	assert.True(t, snapshot.CounterLocked)
+	assert.Equal(t, int64(10), snapshot.LastSignedHeight)
+	assert.False(t, snapshot.LastUnlockedAt.Before(before))
+	assert.False(t, snapshot.LastLockedAt.Before(snapshot.LastUnlockedAt))
+
+	// The snapshot survives a round trip through JSON.
+	bz, err := json.Marshal(snapshot)
+	assert.NoError(t, err)
+	assert.Contains(t, string(bz), `"counter_locked":true`)
+	var decoded StateSnapshot
+	assert.NoError(t, json.Unmarshal(bz, &decoded))
+	assert.True(t, snapshot.LastLockedAt.Equal(decoded.LastLockedAt))
+	decoded.LastLockedAt, decoded.LastUnlockedAt = snapshot.LastLockedAt, snapshot.LastUnlockedAt
+	assert.Equal(t, snapshot, decoded)
+}
+
+func TestPostPromotionGrace(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 2, 3, 3, sc)
+	var buf bytes.Buffer
+	sc.Logger = NewSyncLogger(&buf, "", 0)
+	sc.SetPostPromotionGrace(3)
+	sc.UnlockCounter()
+	_ = sc.miss()
+	assert.ErrorIs(t, sc.miss(), ErrThresholdExceeded)
 
+	// Every height within the grace period is skipped and logged.
+	for h := 3; h <= 5; h++ {
+		assert.ErrorIs(t, sc.miss(), ErrAlreadyCounted)
+		assert.Contains(t, buf.String(), fmt.Sprintf("Skipping missed block at height %v in the grace period after promotion (until block height 5)", h))
+	}
+	assert.Equal(t, 0, sc.GetMissedInARow())
+
+	// Skipped heights aren't skipped twice.
+	buf.Reset()
+	assert.ErrorIs(t, sc.Missed(5), ErrAlreadyCounted)
+	assert.NotContains(t, buf.String(), "grace period")
+
+	// After the grace period, missed blocks are counted again.
+	assert.NoError(t, sc.miss())
+	assert.Equal(t, 1, sc.GetMissedInARow())
+}
+
+func TestPromotionCooldown(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 2, 3, 3, sc)
+	sc.SetPromotionCooldown(5)
 	sc.UnlockCounter()
-	err := sc.Missed()
-	assert.ErrorIs(t, ErrMustShutdown, err)
+
+	// The first promotion isn't subject to the cool-down.
+	assert.NoError(t, sc.miss())
+	assert.ErrorIs(t, sc.miss(), ErrThresholdExceeded)
+	assert.Equal(t, 2, sc.GetRank())
+	assert.ErrorIs(t, sc.miss(), ErrAlreadyCounted)
+
+	// Exceeding the threshold again during the cool-down defers the promotion, even
+	// though the counter moves past the threshold.
+	assert.NoError(t, sc.miss())
+	assert.NoError(t, sc.miss())
+	assert.NoError(t, sc.miss())
+	assert.Equal(t, 2, sc.GetRank())
+	assert.Equal(t, 3, sc.GetMissedInARow())
+
+	// Once the cool-down is over, the deferred promotion takes place.
+	err := sc.miss()
+	var exceeded *ThresholdExceededError
+	assert.True(t, errors.As(err, &exceeded))
+	assert.Equal(t, ThresholdExceededError{Height: 7, OldRank: 2, NewRank: 1, Missed: 4, Threshold: 2}, *exceeded)
+
+	// Rank 1 isn't subject to the cool-down, as it must shut down on time.
+	assert.ErrorIs(t, sc.miss(), ErrAlreadyCounted)
+	assert.NoError(t, sc.miss())
+	assert.ErrorIs(t, sc.miss(), ErrMustShutdown)
+}
+
+func TestPromotionCooldown_Signed(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 2, 3, 3, sc)
+	sc.SetPromotionCooldown(10)
+	sc.UnlockCounter()
+	_ = sc.miss()
+	assert.ErrorIs(t, sc.miss(), ErrThresholdExceeded)
+	sc.height++
+
+	// A deferred promotion is dropped once the validator signs again.
+	_ = sc.miss()
+	assert.NoError(t, sc.miss())
+	sc.Signed()
+	sc.height = 20
+	assert.NoError(t, sc.miss())
+	assert.Equal(t, 2, sc.GetRank())
+}
+
+// recordingStrategy promotes on the given number of missed blocks in the window and
+// records the histories it decided on.
+type recordingStrategy struct {
+	threshold int
+	histories []BlockHistory
+}
+
+func (rs *recordingStrategy) ShouldPromote(history BlockHistory, rank int) bool {
+	rs.histories = append(rs.histories, history)
+	return history.MissedInWindow == rs.threshold
+}
+
+func (rs *recordingStrategy) Progress(history BlockHistory, rank int) (int, int) {
+	return history.MissedInWindow, rs.threshold
+}
+
+func TestSetRankStrategy(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 2, 3, 3, sc)
+	rs := &recordingStrategy{threshold: 3}
+	sc.SetWindow(5)
+	sc.SetRankStrategy(rs)
+	sc.UnlockCounter()
+
+	// The strategy replaces the in-a-row policy entirely.
+	assert.NoError(t, sc.miss())
+	assert.NoError(t, sc.miss())
+	sc.Signed()
+	err := sc.miss()
+	var exceeded *ThresholdExceededError
+	assert.True(t, errors.As(err, &exceeded))
+	assert.Equal(t, ThresholdExceededError{Height: 3, OldRank: 3, NewRank: 2, Missed: 3, Threshold: 3}, *exceeded)
+	assert.Equal(t, []BlockHistory{
+		{Height: 1, MissedInARow: 1, Threshold: 2, MissedInWindow: 1, WindowSize: 5},
+		{Height: 2, MissedInARow: 2, Threshold: 2, MissedInWindow: 2, WindowSize: 5},
+		{Height: 3, MissedInARow: 1, Threshold: 2, MissedInWindow: 3, WindowSize: 5},
+	}, rs.histories)
+
+	// Skipping the block after a rank update doesn't depend on the strategy.
+	assert.ErrorIs(t, sc.miss(), ErrAlreadyCounted)
+	assert.Len(t, rs.histories, 3)
+}
+
+func TestCheckLastSigned(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 10, 2, 3, sc)
+
+	// Disabled without a threshold duration.
+	sc.lastSignedAt = time.Now().Add(-time.Hour)
+	assert.NoError(t, sc.CheckLastSigned())
+
+	// Not counting while the counter is locked.
+	sc.SetThresholdDuration(time.Minute)
+	sc.lastSignedAt = time.Now().Add(-time.Hour)
+	assert.ErrorIs(t, sc.CheckLastSigned(), ErrCounterLocked)
+	assert.Equal(t, 2, sc.GetRank())
+
+	// A signature resets the time.
+	sc.UnlockCounter()
+	sc.Signed()
+	assert.NoError(t, sc.CheckLastSigned())
+	assert.WithinDuration(t, time.Now(), sc.GetLastSignedAt(), time.Second)
+
+	// No signature for too long triggers a rank update and skips the next block.
+	sc.lastSignedAt = time.Now().Add(-time.Minute)
+	err := sc.CheckLastSigned()
+	assert.ErrorIs(t, err, ErrThresholdExceeded)
+	assert.Equal(t, 1, sc.GetRank())
+	assert.ErrorIs(t, sc.Missed(2), ErrAlreadyCounted)
+	assert.NoError(t, sc.CheckLastSigned())
+
+	// Rank 1 can't be promoted anymore.
+	sc.lastSignedAt = time.Now().Add(-time.Minute)
+	err = sc.CheckLastSigned()
+	assert.ErrorIs(t, err, ErrMustShutdown)
+}
+
+func TestThresholdStagger(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 3, 4, 4, sc)
+	assert.Equal(t, 3, sc.GetThreshold())
+
+	// Lower ranks wait progressively longer, ranks 1 and 2 use the base threshold.
+	sc.SetThresholdStagger(2)
+	assert.Equal(t, 7, sc.GetThreshold())
+	assert.Equal(t, 3, sc.GetMinThreshold())
+	assert.NoError(t, sc.Promote())
+	assert.Equal(t, 5, sc.GetThreshold())
+	assert.NoError(t, sc.Promote())
+	assert.Equal(t, 3, sc.GetThreshold())
+	assert.NoError(t, sc.Promote())
+	assert.Equal(t, 3, sc.GetThreshold())
+
+	// The threshold is updated on demotion, too.
+	assert.NoError(t, sc.Demote(3))
+	assert.Equal(t, 5, sc.GetThreshold())
+}
+
+func TestThresholdStagger_DeadRanks(t *testing.T) {
+	// newRank returns a node of a 3-rank set on the given rank with a threshold of 3
+	// and a stagger of 2.
+	newRank := func(rank int) *testSignCtrled {
+		sc := &testSignCtrled{}
+		sc.BaseSignCtrled = *testBaseSignCtrled(t, 3, rank, 3, sc)
+		sc.SetThresholdStagger(2)
+		sc.UnlockCounter()
+		return sc
+	}
+
+	// With only rank 1 dead, rank 2 is promoted first, while rank 3 stays put.
+	rank2, rank3 := newRank(2), newRank(3)
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, rank2.miss())
+		assert.NoError(t, rank3.miss())
+	}
+	assert.ErrorIs(t, rank2.miss(), ErrThresholdExceeded)
+	assert.NoError(t, rank3.miss())
+	assert.Equal(t, 1, rank2.GetRank())
+	assert.Equal(t, 3, rank3.GetRank())
+
+	// With ranks 1 and 2 dead, rank 3 is promoted to rank 2 after 5 blocks missed in
+	// a row, and to rank 1 after 3 more, skipping the block after the promotion.
+	rank3 = newRank(3)
+	for rank3.GetRank() > 1 {
+		err := rank3.miss()
+		switch rank3.height {
+		case 5, 9:
+			assert.ErrorIs(t, err, ErrThresholdExceeded)
+		case 6:
+			assert.ErrorIs(t, err, ErrAlreadyCounted)
+		default:
+			assert.NoError(t, err)
+		}
+		if rank3.height == 5 {
+			assert.Equal(t, 2, rank3.GetRank())
+			assert.Equal(t, 3, rank3.GetThreshold())
+		}
+	}
+	assert.Equal(t, int64(9), rank3.height)
+}
+
+func TestThresholds(t *testing.T) {
+	sc := &testSignCtrled{}
+	sc.BaseSignCtrled = *testBaseSignCtrled(t, 5, 4, 4, sc)
+	sc.SetThresholdStagger(1)
+	assert.Equal(t, 7, sc.GetThreshold())
+
+	// Configured ranks override the base threshold and the stagger.
+	sc.SetThresholds(map[int]int{2: 3, 3: 10})
+	assert.Equal(t, 7, sc.GetThreshold())
+	assert.Equal(t, 3, sc.GetMinThreshold())
+	assert.NoError(t, sc.Promote())
+	assert.Equal(t, 10, sc.GetThreshold())
+	assert.NoError(t, sc.Promote())
+	assert.Equal(t, 3, sc.GetThreshold())
+
+	// Rank 1 uses the threshold of rank 2.
+	assert.NoError(t, sc.Promote())
+	assert.Equal(t, 3, sc.GetThreshold())
+
+	sc.SetRank(3)
+	assert.Equal(t, 10, sc.GetThreshold())
 }
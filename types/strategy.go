@@ -0,0 +1,112 @@
+package types
+
+const (
+	// RankStrategyInARow is the name of the InARowStrategy in the configuration.
+	RankStrategyInARow = "in_a_row"
+
+	// RankStrategyWindow is the name of the WindowStrategy in the configuration.
+	RankStrategyWindow = "window"
+)
+
+var (
+	// RankStrategies defines the rank strategies that can be configured.
+	RankStrategies = []string{RankStrategyInARow, RankStrategyWindow}
+)
+
+// BlockHistory is the history of missed blocks a RankStrategy decides on.
+type BlockHistory struct {
+	// Height is the height of the block that has just been missed.
+	Height int64
+
+	// MissedInARow is the number of blocks missed in a row, including the one at
+	// Height.
+	MissedInARow int
+
+	// Threshold is the effective threshold of blocks missed in a row on the
+	// validator's rank.
+	Threshold int
+
+	// MissedInWindow is the number of blocks missed within the last WindowSize
+	// blocks, including the one at Height.
+	MissedInWindow int
+
+	// WindowSize is the number of last blocks that are kept track of. It is 0 if the
+	// window is disabled.
+	WindowSize int
+}
+
+// RankStrategy decides whether a validator must be promoted after it missed a block.
+// BaseSignCtrled keeps track of the history of missed blocks, locks the counter and
+// skips the block after a rank update, so that a RankStrategy only has to make the
+// decision.
+type RankStrategy interface {
+	// ShouldPromote returns whether the validator on the given rank must be promoted
+	// with the given history of missed blocks.
+	ShouldPromote(history BlockHistory, rank int) bool
+
+	// Progress returns the number of missed blocks that count towards a promotion on
+	// the given rank, and the number that triggers it.
+	Progress(history BlockHistory, rank int) (missed int, threshold int)
+}
+
+// InARowStrategy promotes a validator once it missed exactly the threshold of blocks
+// in a row on its rank. It is the default strategy.
+type InARowStrategy struct{}
+
+// ShouldPromote implements the RankStrategy interface.
+func (InARowStrategy) ShouldPromote(history BlockHistory, rank int) bool {
+	return history.MissedInARow == history.Threshold
+}
+
+// Progress implements the RankStrategy interface.
+func (InARowStrategy) Progress(history BlockHistory, rank int) (int, int) {
+	return history.MissedInARow, history.Threshold
+}
+
+// WindowStrategy promotes a validator once it missed Threshold of the last blocks in
+// the window, so that validators missing most, but not all blocks are replaced as
+// well. It never promotes if the window is disabled.
+type WindowStrategy struct {
+	Threshold int
+}
+
+// ShouldPromote implements the RankStrategy interface.
+func (ws WindowStrategy) ShouldPromote(history BlockHistory, rank int) bool {
+	return history.WindowSize > 0 && history.MissedInWindow >= ws.Threshold
+}
+
+// Progress implements the RankStrategy interface.
+func (ws WindowStrategy) Progress(history BlockHistory, rank int) (int, int) {
+	return history.MissedInWindow, ws.Threshold
+}
+
+// AnyStrategy promotes a validator as soon as any of its strategies does, whichever
+// fires first.
+type AnyStrategy []RankStrategy
+
+// ShouldPromote implements the RankStrategy interface.
+func (as AnyStrategy) ShouldPromote(history BlockHistory, rank int) bool {
+	for _, s := range as {
+		if s.ShouldPromote(history, rank) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Progress implements the RankStrategy interface. It returns the progress of the
+// first strategy that promotes the validator, or the one of the first strategy if
+// none does.
+func (as AnyStrategy) Progress(history BlockHistory, rank int) (int, int) {
+	if len(as) == 0 {
+		return 0, 0
+	}
+	for _, s := range as {
+		if s.ShouldPromote(history, rank) {
+			return s.Progress(history, rank)
+		}
+	}
+
+	return as[0].Progress(history, rank)
+}
@@ -0,0 +1,85 @@
+package types
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInARowStrategy(t *testing.T) {
+	tests := []struct {
+		name      string
+		history   BlockHistory
+		rank      int
+		promote   bool
+		missed    int
+		threshold int
+	}{
+		{"below threshold", BlockHistory{MissedInARow: 2, Threshold: 3}, 2, false, 2, 3},
+		{"at threshold", BlockHistory{MissedInARow: 3, Threshold: 3}, 2, true, 3, 3},
+		{"above threshold", BlockHistory{MissedInARow: 4, Threshold: 3}, 2, false, 4, 3},
+		{"rank 1", BlockHistory{MissedInARow: 3, Threshold: 3}, 1, true, 3, 3},
+		{"window ignored", BlockHistory{MissedInARow: 1, Threshold: 3, MissedInWindow: 9, WindowSize: 10}, 2, false, 1, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := InARowStrategy{}
+			assert.Equal(t, tt.promote, s.ShouldPromote(tt.history, tt.rank))
+			missed, threshold := s.Progress(tt.history, tt.rank)
+			assert.Equal(t, tt.missed, missed)
+			assert.Equal(t, tt.threshold, threshold)
+		})
+	}
+}
+
+func TestWindowStrategy(t *testing.T) {
+	tests := []struct {
+		name      string
+		history   BlockHistory
+		rank      int
+		promote   bool
+		missed    int
+		threshold int
+	}{
+		{"below threshold", BlockHistory{MissedInWindow: 6, WindowSize: 10}, 2, false, 6, 7},
+		{"at threshold", BlockHistory{MissedInWindow: 7, WindowSize: 10}, 2, true, 7, 7},
+		{"above threshold", BlockHistory{MissedInWindow: 8, WindowSize: 10}, 2, true, 8, 7},
+		{"rank 1", BlockHistory{MissedInWindow: 7, WindowSize: 10}, 1, true, 7, 7},
+		{"in a row ignored", BlockHistory{MissedInARow: 5, Threshold: 5, MissedInWindow: 5, WindowSize: 10}, 2, false, 5, 7},
+		{"window disabled", BlockHistory{MissedInWindow: 7}, 2, false, 7, 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := WindowStrategy{Threshold: 7}
+			assert.Equal(t, tt.promote, s.ShouldPromote(tt.history, tt.rank))
+			missed, threshold := s.Progress(tt.history, tt.rank)
+			assert.Equal(t, tt.missed, missed)
+			assert.Equal(t, tt.threshold, threshold)
+		})
+	}
+}
+
+func TestAnyStrategy(t *testing.T) {
+	tests := []struct {
+		name      string
+		strategy  AnyStrategy
+		history   BlockHistory
+		promote   bool
+		missed    int
+		threshold int
+	}{
+		{"none", AnyStrategy{}, BlockHistory{MissedInARow: 3, Threshold: 3}, false, 0, 0},
+		{"neither", AnyStrategy{InARowStrategy{}, WindowStrategy{Threshold: 7}}, BlockHistory{MissedInARow: 2, Threshold: 3, MissedInWindow: 6, WindowSize: 10}, false, 2, 3},
+		{"in a row", AnyStrategy{InARowStrategy{}, WindowStrategy{Threshold: 7}}, BlockHistory{MissedInARow: 3, Threshold: 3, MissedInWindow: 6, WindowSize: 10}, true, 3, 3},
+		{"window", AnyStrategy{InARowStrategy{}, WindowStrategy{Threshold: 7}}, BlockHistory{MissedInARow: 2, Threshold: 3, MissedInWindow: 7, WindowSize: 10}, true, 7, 7},
+		{"both", AnyStrategy{InARowStrategy{}, WindowStrategy{Threshold: 7}}, BlockHistory{MissedInARow: 3, Threshold: 3, MissedInWindow: 7, WindowSize: 10}, true, 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.promote, tt.strategy.ShouldPromote(tt.history, 2))
+			missed, threshold := tt.strategy.Progress(tt.history, 2)
+			assert.Equal(t, tt.missed, missed)
+			assert.Equal(t, tt.threshold, threshold)
+		})
+	}
+}